@@ -3,12 +3,18 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -16,7 +22,11 @@ import (
 	"github.com/anthropics/claude-code-go/internal/agentregistry"
 	"github.com/anthropics/claude-code-go/internal/api"
 	"github.com/anthropics/claude-code-go/internal/config"
+	"github.com/anthropics/claude-code-go/internal/hooks"
 	"github.com/anthropics/claude-code-go/internal/logger"
+	"github.com/anthropics/claude-code-go/internal/permission"
+	"github.com/anthropics/claude-code-go/internal/retry"
+	"github.com/anthropics/claude-code-go/internal/session"
 	"github.com/anthropics/claude-code-go/internal/tools"
 	"github.com/anthropics/claude-code-go/internal/ui"
 )
@@ -25,6 +35,9 @@ var (
 	version = "0.4.0"
 )
 
+// sessionPageSize is the number of sessions shown per page in session pickers
+const sessionPageSize = 10
+
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "claude [prompt]",
@@ -39,7 +52,21 @@ It can read, write, and edit files, execute commands, search code, and more.`,
 	rootCmd.Flags().Bool("version", false, "Show version information")
 	rootCmd.Flags().Bool("enable-logging", false, "Enable detailed logging to /tmp")
 	rootCmd.Flags().Bool("pretty-log", false, "Enable pretty-printed JSON logs")
+	rootCmd.Flags().Bool("log-stream", false, "Also log every streamed response chunk (with --enable-logging; noisy)")
 	rootCmd.Flags().Bool("simple", false, "Use simple terminal mode (no TUI)")
+	rootCmd.Flags().Bool("no-compact", false, "Disable automatic context compaction (warn near the limit instead)")
+	rootCmd.Flags().Bool("pick", false, "Choose the model and starting agent interactively")
+	rootCmd.Flags().Bool("resume", false, "Resume the latest saved session for this directory")
+	rootCmd.Flags().String("session", "", "Resume the saved session with this ID")
+	rootCmd.Flags().StringArray("image", nil, "Attach an image file to the first message in simple mode (repeatable)")
+	rootCmd.Flags().String("export", "", "Export a saved session (--session, or the latest for this directory) to a .md or .html file and exit")
+	rootCmd.Flags().StringArray("allow-path", nil, "Let file tools access paths matching this glob despite the path policy (repeatable)")
+	rootCmd.Flags().Bool("dangerously-skip-permissions", false, "Run every tool call that would need approval without asking and skip all confirmations (for CI; deny rules still apply)")
+	rootCmd.Flags().Bool("dry-run", false, "Simulate tool calls that could change anything: the model is told what each would do, and read-only tools still run")
+	rootCmd.Flags().Bool("dry-run-all", false, "Like --dry-run, but simulate read-only tools too")
+	rootCmd.Flags().String("output", "text", "Output format for prompts given as arguments: text or json (newline-delimited events)")
+	rootCmd.Flags().BoolP("print", "p", false, "Print only the final response and exit; the prompt comes from the arguments and/or stdin, tool activity goes to stderr")
+	rootCmd.Flags().BoolP("quiet", "q", false, "With --print, do not report tool activity on stderr")
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -64,6 +91,21 @@ func runMain(cmd *cobra.Command, args []string) error {
 		cfg.Model = model
 	}
 
+	if noCompact, _ := cmd.Flags().GetBool("no-compact"); noCompact {
+		cfg.DisableAutoCompact = true
+	}
+
+	if skip, _ := cmd.Flags().GetBool("dangerously-skip-permissions"); skip {
+		cfg.SkipPermissions = true
+		ui.WarningColor.Fprintln(os.Stderr, skipPermissionsWarning)
+	}
+
+	cfg.DryRunAll, _ = cmd.Flags().GetBool("dry-run-all")
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun || cfg.DryRunAll {
+		cfg.DryRun = true
+		ui.WarningColor.Fprintln(os.Stderr, dryRunNotice)
+	}
+
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		return err
@@ -72,10 +114,16 @@ func runMain(cmd *cobra.Command, args []string) error {
 	// Initialize logging if enabled
 	enableLogging, _ := cmd.Flags().GetBool("enable-logging")
 	prettyLog, _ := cmd.Flags().GetBool("pretty-log")
+	if logStream, _ := cmd.Flags().GetBool("log-stream"); logStream && !enableLogging {
+		return fmt.Errorf("--log-stream requires --enable-logging")
+	}
 	if enableLogging {
 		if err := logger.InitLogger("/tmp", prettyLog); err != nil {
 			return fmt.Errorf("failed to initialize logger: %w", err)
 		}
+		if logStream, _ := cmd.Flags().GetBool("log-stream"); logStream {
+			logger.GetLogger().EnableStreamLogging()
+		}
 		defer func() {
 			if log := logger.GetLogger(); log != nil {
 				log.Close()
@@ -89,6 +137,27 @@ func runMain(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
 
+	if exportPath, _ := cmd.Flags().GetString("export"); exportPath != "" {
+		id, _ := cmd.Flags().GetString("session")
+		return exportSavedSession(exportPath, workDir, id)
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	if printMode, _ := cmd.Flags().GetBool("print"); printMode {
+		if output == "json" {
+			return fmt.Errorf("--print and --output json cannot be combined")
+		}
+		prompt, err := printPrompt(args, os.Stdin)
+		if err != nil {
+			return err
+		}
+		args = []string{prompt}
+		output = "print"
+	} else if quiet {
+		return fmt.Errorf("--quiet requires --print")
+	}
+
 	// Check for simple mode
 	simpleMode, _ := cmd.Flags().GetBool("simple")
 
@@ -97,6 +166,45 @@ func runMain(cmd *cobra.Command, args []string) error {
 		simpleMode = true
 	}
 
+	images, _ := cmd.Flags().GetStringArray("image")
+	if len(images) > 0 && !simpleMode {
+		return fmt.Errorf("--image requires simple mode (--simple or a prompt argument); use /image in the TUI")
+	}
+
+	switch output {
+	case "text", "print":
+	case "json":
+		if len(args) == 0 {
+			return fmt.Errorf("--output json requires a prompt argument")
+		}
+	default:
+		return fmt.Errorf("unknown output format %q: use text or json", output)
+	}
+
+	// Create agent registry and register built-in agents
+	agentRegistry := agentregistry.NewRegistry()
+	if err := agentregistry.RegisterBuiltinAgents(agentRegistry); err != nil {
+		return fmt.Errorf("failed to register built-in agents: %w", err)
+	}
+
+	// Custom agents from .gmain-agent/agents/*.json
+	if _, err := agentregistry.LoadProjectAgents(agentRegistry, workDir); err != nil {
+		return err
+	}
+
+	// Project conventions from AGENTS.md (or the configured file) go into every agent's prompt
+	contextNote, err := loadProjectContext(agentRegistry, workDir, cfg)
+	if err != nil {
+		return err
+	}
+
+	// Interactive model/agent selection (not for one-shot prompts)
+	if pick, _ := cmd.Flags().GetBool("pick"); pick && len(args) == 0 {
+		if err := pickStartup(cfg, agentRegistry, simpleMode, !cmd.Flags().Changed("model")); err != nil {
+			return err
+		}
+	}
+
 	// Create API client
 	credential, authType := cfg.GetAuthCredential()
 	clientOpts := []api.ClientOption{
@@ -109,60 +217,393 @@ func runMain(cmd *cobra.Command, args []string) error {
 	if authType == config.AuthTypeBearer {
 		clientOpts = append(clientOpts, api.WithAuthType(api.AuthTypeBearer))
 	}
-	client := api.NewClient(credential, clientOpts...)
-
-	// Create agent registry and register built-in agents
-	agentRegistry := agentregistry.NewRegistry()
-	if err := agentregistry.RegisterBuiltinAgents(agentRegistry); err != nil {
-		return fmt.Errorf("failed to register built-in agents: %w", err)
+	// Expiring tokens are renewed before each request
+	if refresher, ok := cfg.CredentialProvider().(api.RefreshableCredential); ok {
+		clientOpts = append(clientOpts, api.WithRefreshableCredential(refresher))
+	}
+	if cfg.PromptCaching {
+		clientOpts = append(clientOpts, api.WithPromptCaching(true))
+	}
+	if cfg.ThinkingBudget > 0 {
+		clientOpts = append(clientOpts, api.WithThinking(cfg.ThinkingBudget))
+	}
+	if cfg.Temperature != nil {
+		clientOpts = append(clientOpts, api.WithTemperature(*cfg.Temperature))
+	}
+	if len(cfg.StopSequences) > 0 {
+		clientOpts = append(clientOpts, api.WithStopSequences(cfg.StopSequences...))
+	}
+	clientOpts = append(clientOpts, api.WithRetryConfig(api.RetryConfig{
+		MaxRetries:   cfg.Retry.MaxRetries,
+		JitterFactor: cfg.Retry.Jitter,
+		BaseDelay:    time.Duration(cfg.Retry.BaseDelayMs) * time.Millisecond,
+		MaxDelay:     time.Duration(cfg.Retry.MaxDelayMs) * time.Millisecond,
+	}))
+	if cfg.RetryBudgetSeconds > 0 {
+		clientOpts = append(clientOpts, api.WithRetryBudget(time.Duration(cfg.RetryBudgetSeconds)*time.Second))
+	}
+	if cfg.StreamIdleTimeoutSeconds != 0 {
+		clientOpts = append(clientOpts, api.WithStreamIdleTimeout(time.Duration(cfg.StreamIdleTimeoutSeconds)*time.Second))
+	}
+	var client api.MessageClient
+	if cfg.GetAPIFormat() == config.APIFormatOpenAI {
+		client = api.NewOpenAIClient(credential, clientOpts...)
+	} else {
+		client = api.NewClient(credential, clientOpts...)
 	}
 
 	// Create tool registry
 	registry := tools.NewRegistry()
 	todoList := tools.NewTodoList()
 
+	// Bash refuses obviously destructive commands; the config can relax or extend the checks
+	dangerous, err := tools.DangerousCommands(cfg.BashGuard.Disable, cfg.BashGuard.Patterns)
+	if err != nil {
+		return err
+	}
+	bashTool := tools.NewBashTool(workDir)
+	bashTool.SetDangerousCommands(dangerous)
+	defer bashTool.Cleanup() // Stop background processes and delete their logs
+
+	// WebFetch blocks internal addresses unless the config allows them
+	webFetchTool := tools.NewWebFetchTool()
+	webFetchTool.SetAllowedHosts(cfg.WebFetchAllowedHosts)
+
 	// Register tools
-	registry.Register(tools.NewBashTool(workDir))
-	registry.Register(tools.NewReadTool(workDir))
-	registry.Register(tools.NewWriteTool(workDir))
-	registry.Register(tools.NewEditTool(workDir))
-	registry.Register(tools.NewGlobTool(workDir))
-	registry.Register(tools.NewGrepTool(workDir))
-	registry.Register(tools.NewWebFetchTool())
-	registry.Register(tools.NewTodoWriteTool(todoList))
+	builtinTools := []tools.Tool{
+		bashTool,
+		tools.NewBashLogsTool(bashTool),
+		tools.NewBashKillTool(bashTool),
+		tools.NewBashListTool(bashTool),
+		tools.NewReadTool(workDir),
+		tools.NewReadToolOutputTool(agent.SavedOutputPath),
+		tools.NewWriteTool(workDir),
+		tools.NewEditTool(workDir),
+		tools.NewMultiEditTool(workDir),
+		tools.NewApplyPatchTool(workDir),
+		tools.NewGlobTool(workDir),
+		tools.NewListTool(workDir),
+		tools.NewTreeTool(workDir),
+		tools.NewGrepTool(workDir),
+		tools.NewGitBranchTool(workDir),
+		webFetchTool,
+		tools.NewWebSearchToolFromEnv(),
+		tools.NewTodoWriteTool(todoList),
+	}
+	for _, tool := range builtinTools {
+		if err := registry.Register(tool); err != nil {
+			return fmt.Errorf("failed to register tools: %w", err)
+		}
+	}
+
+	// File tools refuse secrets and the like before any permission check
+	allowPaths, _ := cmd.Flags().GetStringArray("allow-path")
+	policy, err := pathPolicy(workDir, cfg, allowPaths)
+	if err != nil {
+		return err
+	}
+	registry.SetPathPolicy(policy)
+	registry.RestrictToWorkDir(cfg.RestrictToWorkDir)
+	registry.SetFileHistory(tools.NewFileHistory(tools.MaxFileHistory))
+
+	// Per-tool time limits (already validated with the config)
+	timeouts, _ := cfg.GetToolTimeouts()
+	for name, timeout := range timeouts {
+		registry.SetTimeout(name, timeout)
+	}
+
+	// Saved session to continue, if any
+	var resume resumeRequest
+	resume.latest, _ = cmd.Flags().GetBool("resume")
+	resume.id, _ = cmd.Flags().GetString("session")
 
 	if simpleMode {
-		return runSimpleMode(client, registry, agentRegistry, workDir, args)
+		return runSimpleMode(client, registry, agentRegistry, workDir, cfg, resume, contextNote, images, output, quiet, args)
 	}
 
-	return runTUIMode(client, registry, agentRegistry, workDir, cfg.Model)
+	return runTUIMode(client, registry, agentRegistry, workDir, cfg, resume, contextNote)
 }
 
-// runTUIMode runs the application in TUI mode
-func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *agentregistry.Registry, workDir, modelName string) error {
-	// Create TUI
-	tui := ui.NewSimpleTUI(version, "build", modelName, workDir)
+// pickStartup lets the user choose the model and starting agent before the UI launches
+func pickStartup(cfg *config.Config, agentRegistry *agentregistry.Registry, simpleMode, pickModel bool) error {
+	choose := ui.RunPicker
+	if simpleMode {
+		choose = ui.NewTerminal().PickFromList
+	}
+
+	if pickModel {
+		items := make([]ui.PickerItem, 0, len(api.KnownModels))
+		for _, m := range api.KnownModels {
+			items = append(items, ui.PickerItem{Value: m.ID, Label: m.Name, Description: m.Description})
+		}
+		model, err := choose("Select a model", items, cfg.Model)
+		if err != nil {
+			return fmt.Errorf("model selection failed: %w", err)
+		}
+		if model != "" {
+			cfg.Model = model
+		}
+	}
+
+	agents := agentRegistry.ListByMode(agentregistry.ModePrimary, false)
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	items := make([]ui.PickerItem, 0, len(agents))
+	for _, info := range agents {
+		items = append(items, ui.PickerItem{Value: info.Name, Label: info.Name, Description: info.Description})
+	}
+
+	current := ""
+	if def, err := agentRegistry.GetDefault(); err == nil {
+		current = def.Name
+	}
+	name, err := choose("Select the starting agent", items, current)
+	if err != nil {
+		return fmt.Errorf("agent selection failed: %w", err)
+	}
+	if name != "" {
+		return agentRegistry.SetDefault(name)
+	}
+	return nil
+}
+
+// pathPolicy combines the built-in, configured and .gmain-agentignore path
+// patterns with the paths allowed on the command line
+func pathPolicy(workDir string, cfg *config.Config, allowPaths []string) (*tools.PathPolicy, error) {
+	var deny, allow []string
+	if !cfg.PathPolicy.NoDefaults {
+		deny = append(deny, tools.DefaultDeniedPaths...)
+	}
+	deny = append(deny, cfg.PathPolicy.Deny...)
+	allow = append(allow, cfg.PathPolicy.Allow...)
+
+	fileDeny, fileAllow, err := tools.LoadPathIgnoreFile(workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", tools.PathIgnoreFile, err)
+	}
+	deny = append(deny, fileDeny...)
+	allow = append(allow, fileAllow...)
+	allow = append(allow, allowPaths...)
+
+	return tools.NewPathPolicy(workDir, deny, allow), nil
+}
+
+// loadTheme returns the TUI color scheme chosen in the config
+func loadTheme(cfg *config.Config) (*ui.Theme, error) {
+	if cfg.ThemeFile != "" {
+		return ui.LoadThemeFile(cfg.ThemeFile)
+	}
+	return ui.ThemeByName(cfg.Theme)
+}
+
+// loadProjectContext loads the project context file into the agent registry
+// and returns a note for the user ("" if there is none)
+func loadProjectContext(agentRegistry *agentregistry.Registry, workDir string, cfg *config.Config) (string, error) {
+	files := agentregistry.DefaultContextFiles
+	switch cfg.ContextFile {
+	case "":
+	case "none":
+		return "", nil
+	default:
+		files = []string{cfg.ContextFile}
+	}
+
+	pc, err := agentregistry.LoadProjectContext(workDir, files, cfg.ContextMaxBytes)
+	if err != nil || pc == nil {
+		return "", err
+	}
+	agentRegistry.SetProjectContext(pc)
+
+	note := fmt.Sprintf("Loaded project context from %s", pc.Path)
+	if pc.Truncated {
+		note += fmt.Sprintf(" (truncated to %d of %d bytes)", len(pc.Content), pc.Size)
+	}
+	return note, nil
+}
+
+// toolDisplayOptions maps the tool_display config onto terminal settings
+func toolDisplayOptions(cfg *config.Config) ui.ToolDisplayOptions {
+	opts := ui.DefaultToolDisplayOptions()
+	td := cfg.ToolDisplay
+	if td.MaxChars != 0 {
+		opts.MaxChars = td.MaxChars
+	}
+	opts.MaxLines = td.MaxLines
+	opts.ShowInput = td.ShowInput
+	opts.Color = !td.NoColor
+	opts.StatusLine = td.StatusLine
+	return opts
+}
+
+// newSessionManager opens session storage (nil if unavailable) and applies
+// the session_titles setting
+func newSessionManager(a *agent.Agent, cfg *config.Config) *session.SessionManager {
+	sessMgr, err := session.NewSessionManager()
+	if err != nil {
+		return nil
+	}
+
+	switch cfg.SessionTitles {
+	case "off":
+		sessMgr.SetAutoTitle(false, nil)
+	case "model":
+		sessMgr.SetAutoTitle(true, func(firstMessage string) (string, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			return a.GenerateTitle(ctx, firstMessage)
+		})
+	default:
+		sessMgr.SetAutoTitle(true, nil)
+	}
+	return sessMgr
+}
+
+// dryRunNotice is shown whenever --dry-run or --dry-run-all is on
+const dryRunNotice = "DRY RUN: tool calls are described to the model instead of being run. No files, commands or other state will change."
+
+// skipPermissionsWarning is shown whenever --dangerously-skip-permissions is on
+const skipPermissionsWarning = "WARNING: --dangerously-skip-permissions is on. Tool calls that need approval (shell commands, file writes, ...) run without asking. Use it only in a sandbox or CI."
+
+// outputReserve is the max_tokens of streamed requests, which the client
+// raises above the thinking budget when extended thinking is on
+func outputReserve(cfg *config.Config) int {
+	if cfg.ThinkingBudget > 0 && cfg.MaxTokens <= cfg.ThinkingBudget {
+		return cfg.ThinkingBudget + cfg.MaxTokens
+	}
+	return cfg.MaxTokens
+}
+
+// configureAgent applies config-driven agent settings shared by all modes
+func configureAgent(a *agent.Agent, cfg *config.Config) {
+	if cfg.AskFallback == "deny" {
+		a.SetAskFallback(permission.ActionDeny)
+	}
+	a.SetSkipPermissions(cfg.SkipPermissions)
+	a.SetDryRun(cfg.DryRun, !cfg.DryRunAll)
+	a.SetAutoCompact(!cfg.DisableAutoCompact)
+	a.SetCompactionThresholds(cfg.CompactionThreshold, cfg.CompactionWarnThreshold)
+	a.SetOutputReserve(outputReserve(cfg))
+	a.SetMaxMessages(cfg.MaxMessages)
+	for name, policy := range cfg.OutputTruncation {
+		a.SetTruncatePolicy(name, policy)
+	}
+	a.SetInjectionScan(cfg.DetectPromptInjection)
+	a.SetParallelTools(!cfg.DisableParallelTools)
+	if cfg.ResponseHook != "" {
+		a.SetResponseProcessor(hooks.CommandProcessor(cfg.ResponseHook, hooks.DefaultHookTimeout))
+	}
+}
 
+// runTUIMode runs the application in TUI mode
+func runTUIMode(client api.MessageClient, registry *tools.Registry, agentRegistry *agentregistry.Registry, workDir string, cfg *config.Config, resume resumeRequest, contextNote string) error {
 	// Create agent
 	a := agent.NewAgent(client, registry, agentRegistry, workDir)
+	defer a.CleanupOutputs()
+	configureAgent(a, cfg)
+
+	// Create TUI
+	tui := ui.NewSimpleTUI(version, a.GetCurrentAgent(), cfg.Model, workDir)
 
 	// Get TUI adapter
 	adapter := tui.GetAdapter()
 
+	// Warn in the status bar when close to the API rate limit
+	client.SetRateLimitCallback(func(status api.RateLimitStatus) {
+		if status.NearLimit() {
+			adapter.OnRateLimit("Rate limit: " + status.Summary())
+		} else {
+			adapter.OnRateLimit("")
+		}
+	})
+
+	// Show pending retries of failed API requests in the status bar
+	client.SetRetryCallback(func(stats retry.RetryStats) {
+		adapter.OnAPIRetry(retryNotice(stats))
+	})
+
 	// Register ask user question tool
 	askTool := tools.NewAskUserQuestionTool(func(questions []tools.Question) (map[string]string, error) {
-		// In TUI mode, we'll use a simple approach for now
-		// TODO: Implement proper TUI-based question dialog
-		answers := make(map[string]string)
+		items := make([]ui.QuestionItem, 0, len(questions))
 		for _, q := range questions {
-			// Default to first option for now
-			if len(q.Options) > 0 {
-				answers[q.Header] = q.Options[0].Label
+			item := ui.QuestionItem{
+				Header:      q.Header,
+				Question:    q.Question,
+				MultiSelect: q.MultiSelect,
 			}
+			for _, opt := range q.Options {
+				item.Options = append(item.Options, ui.QuestionOption{Label: opt.Label, Description: opt.Description})
+			}
+			items = append(items, item)
+		}
+
+		// Block the tool until the dialog is answered or cancelled
+		done := make(chan [][]string, 1)
+		adapter.OnQuestion(items, func(answers [][]string) {
+			done <- answers
+		})
+		chosen := <-done
+		if chosen == nil {
+			return nil, fmt.Errorf("the user cancelled the question")
+		}
+
+		answers := make(map[string]string)
+		for i, labels := range chosen {
+			answers[questions[i].Header] = strings.Join(labels, ", ")
 		}
 		return answers, nil
 	})
-	registry.Register(askTool)
+	if err := registry.Register(askTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
+
+	// Ask the user about tool calls whose permission is Ask; the input can be edited first
+	a.SetAskFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+		type answer struct{ result, input string }
+		done := make(chan answer, 1)
+		adapter.OnApprovalRequest("Permission Required", req.Message, req.Input, func(result, input string) {
+			done <- answer{result, input}
+		})
+		ans := <-done
+		switch ans.result {
+		case "Allow":
+			return permission.AskResponse{Approved: true, EditedInput: ans.input}, nil
+		case "Allow Always":
+			return permission.AskResponse{Approved: true, Always: true, EditedInput: ans.input}, nil
+		default:
+			return permission.AskResponse{Rejected: true}, nil
+		}
+	})
+
+	// Confirm tool calls that keep repeating with identical input
+	a.SetLoopConfirmFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+		done := make(chan string, 1)
+		adapter.OnConfirmRequest("Repeated Tool Call", req.Message, req.Pattern, func(result string) {
+			done <- result
+		})
+		switch <-done {
+		case "Allow", "Allow Always":
+			return permission.AskResponse{Approved: true}, nil
+		default:
+			return permission.AskResponse{Rejected: true}, nil
+		}
+	})
+
+	// Review every file change as a diff before it is written
+	if cfg.ConfirmWrites {
+		a.SetWriteConfirmFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+			done := make(chan string, 1)
+			adapter.OnDiffConfirmRequest("Review Change", req.Message, req.Diff, func(result string) {
+				done <- result
+			})
+			switch <-done {
+			case "Allow":
+				return permission.AskResponse{Approved: true}, nil
+			case "Allow Always":
+				return permission.AskResponse{Approved: true, Always: true}, nil
+			default:
+				return permission.AskResponse{Rejected: true}, nil
+			}
+		})
+	}
 
 	// Register plan mode tools
 	planEnterTool := tools.NewPlanEnterTool(workDir, func(toAgent string) error {
@@ -172,7 +613,9 @@ func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *age
 		}
 		return err
 	})
-	registry.Register(planEnterTool)
+	if err := registry.Register(planEnterTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
 
 	planExitTool := tools.NewPlanExitTool(workDir, func(toAgent string) error {
 		err := a.SwitchAgent(toAgent)
@@ -181,7 +624,13 @@ func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *age
 		}
 		return err
 	})
-	registry.Register(planExitTool)
+	if err := registry.Register(planExitTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
+
+	if err := registry.Register(tools.NewPlanListTool(workDir)); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
 
 	// Create task executor
 	taskExecutor := &simpleTaskExecutor{
@@ -189,9 +638,15 @@ func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *age
 		agentRegistry: agentRegistry,
 		toolRegistry:  registry,
 		workDir:       workDir,
+		askFallback:   cfg.AskFallback,
 	}
 	taskTool := tools.NewTaskTool(agentRegistry, taskExecutor)
-	registry.Register(taskTool)
+	if err := registry.Register(taskTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
+
+	// Session storage (optional)
+	sess := newChatSession(newSessionManager(a, cfg), a, workDir)
 
 	// Set up agent event handler
 	a.SetEventHandler(func(event agent.Event) {
@@ -199,6 +654,9 @@ func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *age
 		case agent.EventTypeText:
 			adapter.OnText(event.Text)
 
+		case agent.EventTypeThinking:
+			adapter.OnThinking(event.Text)
+
 		case agent.EventTypeToolUseStart:
 			var inputStr string
 			if event.ToolInput != "" {
@@ -206,8 +664,11 @@ func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *age
 			}
 			adapter.OnToolStart(event.ToolName, event.ToolID, inputStr)
 
+		case agent.EventTypeToolProgress:
+			adapter.OnToolProgress(event.ToolID, event.Progress.Done, event.Progress.Total, event.Progress.Unit)
+
 		case agent.EventTypeToolUseEnd:
-			adapter.OnToolEnd(event.ToolName, event.ToolID, event.ToolResult, event.IsError)
+			adapter.OnToolEnd(event.ToolName, event.ToolID, event.ToolInput, event.ToolResult, event.IsError)
 
 		case agent.EventTypeError:
 			adapter.OnError(event.Error)
@@ -217,36 +678,195 @@ func runTUIMode(client *api.Client, registry *tools.Registry, agentRegistry *age
 
 		case agent.EventTypeAgentSwitch:
 			adapter.OnAgentSwitch(event.AgentName)
+			// The agent may bring its own model
+			adapter.OnModelChange(a.GetModel())
+
+		case agent.EventTypeRetry:
+			adapter.OnRetry()
+
+		case agent.EventTypeStreamRetry:
+			adapter.OnStreamRetry(event.Text)
 
 		case agent.EventTypeTokenUsage:
 			if event.TokenUsage != nil {
 				input, output, cacheRead, cacheWrite := a.GetTokenUsage()
 				adapter.OnTokenUpdate(input, output, cacheRead, cacheWrite)
+				if cfg.ShowCost {
+					if pricing, ok := api.LookupPricing(a.GetModel(), cfg.Pricing); ok {
+						adapter.OnCostUpdate(api.EstimateCost(pricing, sess.Usage()).Total())
+					}
+				}
 			}
 
 		case agent.EventTypeCompaction:
 			adapter.OnCompaction(event.CompactionInfo)
+
+		case agent.EventTypeContextWarning:
+			adapter.OnContextWarning(event.Text)
 		}
 	})
 
+	if cfg.AutoSaveSession {
+		a.SetCheckpointSteps(cfg.CheckpointSteps)
+		a.SetTurnHook(func(messages []api.Message) {
+			if err := sess.Save(messages); err != nil {
+				adapter.OnCompaction(fmt.Sprintf("Failed to save session: %v", err))
+			}
+		})
+	}
+	if contextNote != "" {
+		adapter.OnCompaction(contextNote)
+	}
+	if cfg.SkipPermissions {
+		adapter.OnCompaction(skipPermissionsWarning)
+	}
+	if cfg.DryRun {
+		adapter.OnCompaction(dryRunNotice)
+	}
+	if resume.requested() {
+		loaded, err := sess.Resume(a, resume.id)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(fmt.Sprintf("Resumed session %q (%d messages)", loaded.Title(), len(loaded.Messages)))
+	}
+
 	// Set up message handler
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	tui.SetScrollPause(!cfg.DisableScrollPause)
+	theme, err := loadTheme(cfg)
+	if err != nil {
+		return err
+	}
+	tui.SetTheme(theme)
+
+	formatters := make(map[string]ui.ToolFormatter)
+	for name, f := range registry.Formatters() {
+		formatters[name] = f
+	}
+	tui.SetToolFormatters(formatters)
+	tui.SetCommands(tuiCommands(a))
+
+	if cfg.InitialPrompt != "" {
+		tui.SetInitialPrompt(cfg.InitialPrompt)
+	}
+
+	// Each turn gets its own context so Ctrl+C cancels just that turn
+	var turnMu sync.Mutex
+	var cancelTurn context.CancelFunc
+	tui.SetCancelHandler(func() {
+		turnMu.Lock()
+		defer turnMu.Unlock()
+		if cancelTurn != nil {
+			cancelTurn()
+		}
+	})
+
 	tui.SetMessageHandler(func(msg string) error {
+		retry := strings.TrimSpace(msg) == ui.RetryCommand
+
 		// Handle commands
-		if strings.HasPrefix(msg, "/") {
-			return handleTUICommand(msg, a, adapter)
+		if strings.HasPrefix(msg, "/") && !retry {
+			defer adapter.OnDone()
+			return handleTUICommand(msg, a, registry, adapter, sess, cfg)
+		}
+
+		turnCtx, cancel := context.WithCancel(ctx)
+		turnMu.Lock()
+		cancelTurn = cancel
+		turnMu.Unlock()
+		defer func() {
+			turnMu.Lock()
+			cancelTurn = nil
+			turnMu.Unlock()
+			cancel()
+		}()
+
+		var err error
+		if retry {
+			err = a.Retry(turnCtx)
+			if errors.Is(err, agent.ErrNothingToRetry) {
+				adapter.OnCompaction("Nothing to retry yet")
+				adapter.OnDone()
+				return nil
+			}
+		} else {
+			err = a.Chat(turnCtx, msg)
 		}
-		return a.Chat(ctx, msg)
+		if errors.Is(err, context.Canceled) {
+			return nil // The TUI already reported the cancellation
+		}
+		return err
 	})
 
 	// Run TUI
 	return tui.Run()
 }
 
+// tuiCommands lists the TUI's slash commands for the command palette and
+// /help, with completions for their arguments
+func tuiCommands(a *agent.Agent) []ui.Command {
+	return []ui.Command{
+		{Name: "/help", Description: "List the commands"},
+		{Name: "/clear", Description: "Clear the conversation history"},
+		{Name: "/exit", Description: "Exit the program"},
+		{Name: "/quit", Description: "Same as /exit"},
+		{Name: "/model", Description: "Show or switch the model", Args: func() []string {
+			ids := make([]string, 0, len(api.KnownModels))
+			for _, m := range api.KnownModels {
+				ids = append(ids, m.ID)
+			}
+			return ids
+		}},
+		{Name: "/agent", Description: "Show or switch the primary agent", Args: func() []string {
+			var names []string
+			for _, info := range a.GetAgentRegistry().List(false) {
+				names = append(names, info.Name)
+			}
+			sort.Strings(names)
+			return names
+		}},
+		{Name: "/agents", Description: "List the available agents"},
+		{Name: "/pwd", Description: "Show the Bash working directory", Args: func() []string {
+			return []string{"reset"}
+		}},
+		{Name: ui.RetryCommand, Description: "Regenerate the last response"},
+		{Name: "/tokens", Description: "Show the token usage"},
+		{Name: "/cost", Description: "Show the estimated session cost"},
+		{Name: "/stats", Description: "Show turns, tool calls, tool time and the files changed"},
+		{Name: "/output", Description: "List truncated tool outputs, or show one", Args: func() []string {
+			var ids []string
+			for _, saved := range a.SavedOutputs() {
+				ids = append(ids, saved.CallID)
+			}
+			return ids
+		}},
+		{Name: "/export", Description: "Save the conversation as Markdown or HTML"},
+		{Name: "/image", Description: "Attach image files to your next message"},
+		{Name: "/perms", Description: "Show the current agent's permission rules"},
+		{Name: "/sessions", Description: "Browse and load saved sessions"},
+		{Name: "/resume", Description: "Resume a saved session, or the latest for this directory"},
+		{Name: "/pin", Description: "Pin a note that survives compaction"},
+		{Name: "/unpin", Description: "Remove a pinned note by number, or all"},
+		{Name: "/summary", Description: "Summarize the conversation so far"},
+		{Name: "/compact", Description: "Compact the conversation now (keep=N keeps the last N exchanges)"},
+		{Name: "/undo", Description: "Revert the last file change made by a tool (/undo N reverts N)"},
+		{Name: "/checkpoint", Description: "Save the conversation as a named checkpoint"},
+		{Name: "/goto", Description: "Go back to a checkpoint, dropping later messages", Args: func() []string {
+			var names []string
+			for _, cp := range a.Checkpoints() {
+				names = append(names, cp.Name)
+			}
+			return names
+		}},
+		{Name: "/checkpoints", Description: "List the saved checkpoints"},
+	}
+}
+
 // handleTUICommand handles commands in TUI mode
-func handleTUICommand(input string, a *agent.Agent, adapter *ui.AgentEventAdapter) error {
+func handleTUICommand(input string, a *agent.Agent, registry *tools.Registry, adapter *ui.AgentEventAdapter, sess *chatSession, cfg *config.Config) error {
 	parts := strings.Fields(input)
 	if len(parts) == 0 {
 		return nil
@@ -256,24 +876,54 @@ func handleTUICommand(input string, a *agent.Agent, adapter *ui.AgentEventAdapte
 
 	switch cmd {
 	case "/help":
-		adapter.OnCompaction("Commands: /help, /clear, /exit, /model, /agent, /tokens")
+		var names []string
+		for _, c := range tuiCommands(a) {
+			names = append(names, c.Name)
+		}
+		adapter.OnCompaction("Commands: " + strings.Join(names, ", ") + " (type / to search them)")
 		return nil
 
 	case "/clear":
 		a.GetConversation().Clear()
+		a.ClearImages()
+		a.ResetStats()
+		registry.ClearCaches()
+		sess.Reset()
 		adapter.OnCompaction("Conversation cleared")
 		return nil
 
 	case "/exit", "/quit":
+		a.CleanupOutputs()
 		os.Exit(0)
 		return nil
 
 	case "/model":
-		adapter.OnCompaction("Current model: " + "claude-sonnet-4-20250514")
+		if len(parts) < 2 {
+			adapter.OnCompaction(formatModels(a.GetModel()))
+			return nil
+		}
+		a.SetModel(parts[1])
+		adapter.OnModelChange(parts[1])
 		return nil
 
 	case "/agent":
-		adapter.OnCompaction("Current agent: " + a.GetCurrentAgent())
+		if len(parts) < 2 {
+			adapter.OnCompaction("Current agent: " + a.GetCurrentAgent())
+			return nil
+		}
+		// The agent switch event updates the badge
+		return switchPrimaryAgent(a, parts[1])
+
+	case "/agents":
+		adapter.OnCompaction(formatAgents(a))
+		return nil
+
+	case "/pwd":
+		out, err := handlePwdCommand(registry, parts)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(out)
 		return nil
 
 	case "/tokens":
@@ -282,51 +932,328 @@ func handleTUICommand(input string, a *agent.Agent, adapter *ui.AgentEventAdapte
 			input, output, cacheRead, input+output+cacheRead+cacheWrite))
 		return nil
 
-	default:
-		adapter.OnCompaction(fmt.Sprintf("Unknown command: %s. Type /help for available commands", cmd))
+	case "/cost":
+		adapter.OnCompaction(formatCost(sess.Usage(), a.GetModel(), cfg.Pricing))
 		return nil
-	}
-}
 
-// runSimpleMode runs the application in simple terminal mode
-func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *agentregistry.Registry, workDir string, args []string) error {
-	// Create terminal UI
-	terminal := ui.NewTerminal()
-
-	// Create ask user question tool with handler
-	askTool := tools.NewAskUserQuestionTool(func(questions []tools.Question) (map[string]string, error) {
-		answers := make(map[string]string)
-		for _, q := range questions {
-			fmt.Println()
-			fmt.Println(q.Question)
-			for i, opt := range q.Options {
-				fmt.Printf("  %d. %s - %s\n", i+1, opt.Label, opt.Description)
-			}
-			fmt.Print("Enter your choice (number or text): ")
+	case "/stats":
+		adapter.OnCompaction(formatStats(a.GetStats(), sess.workDir))
+		return nil
 
-			line, err := terminal.ReadLine()
-			if err != nil {
-				return nil, err
-			}
-			answers[q.Header] = line
+	case "/output":
+		out, err := handleOutputCommand(a, parts)
+		if err != nil {
+			return err
 		}
-		return answers, nil
-	})
-	registry.Register(askTool)
+		adapter.OnCompaction(out)
+		return nil
 
-	// Create agent with agent registry
-	a := agent.NewAgent(client, registry, agentRegistry, workDir)
+	case "/image":
+		out, err := handleImageCommand(a, sess.workDir, parts)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(out)
+		return nil
 
-	// Register plan mode tools with agent switch callback
-	planEnterTool := tools.NewPlanEnterTool(workDir, func(toAgent string) error {
-		return a.SwitchAgent(toAgent)
-	})
-	registry.Register(planEnterTool)
+	case "/export":
+		out, err := handleExportCommand(a, sess, parts)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(out)
+		return nil
 
-	planExitTool := tools.NewPlanExitTool(workDir, func(toAgent string) error {
+	case "/perms":
+		perms, err := formatPermissions(a)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(perms)
+		return nil
+
+	case "/pin", "/unpin":
+		out, err := handlePinCommand(a, input)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(out)
+		return nil
+
+	case "/summary":
+		adapter.OnCompaction("Summarizing conversation...")
+		summary, err := a.Summarize(context.Background())
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction("Conversation summary:\n" + summary)
+		return nil
+
+	case "/compact":
+		// Progress is reported through the compaction events
+		out, err := handleCompactCommand(a, parts)
+		if err != nil {
+			return err
+		}
+		if out != "" {
+			adapter.OnCompaction(out)
+		}
+		return nil
+
+	case "/undo":
+		out, err := handleUndoCommand(registry, sess.workDir, parts)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(out)
+		return nil
+
+	case "/checkpoint", "/goto", "/checkpoints":
+		out, err := handleCheckpointCommand(a, parts)
+		if err != nil {
+			return err
+		}
+		if cmd == "/goto" {
+			adapter.OnHistoryRestored(a.GetConversation().GetMessages(), out)
+			return nil
+		}
+		adapter.OnCompaction(out)
+		return nil
+
+	case "/sessions":
+		if sess.mgr == nil {
+			return fmt.Errorf("session storage is unavailable")
+		}
+		sessions, _, err := sess.mgr.ListSessionsPage("", 0, 0)
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			adapter.OnCompaction("No saved sessions")
+			return nil
+		}
+		items := make([]ui.SessionItem, 0, len(sessions))
+		for _, s := range sessions {
+			items = append(items, ui.SessionItem{
+				ID:           s.ID,
+				Title:        s.Title(),
+				UpdatedAt:    s.UpdatedAt,
+				MessageCount: len(s.Messages),
+			})
+		}
+		adapter.OnSessionPicker(items, func(id string) {
+			if id == "" {
+				return
+			}
+			// Runs on the UI goroutine; load and report asynchronously
+			go func() {
+				loaded, err := sess.Resume(a, id)
+				if err != nil {
+					adapter.OnError(err)
+					return
+				}
+				adapter.OnCompaction(fmt.Sprintf("Loaded session %q (%d messages)", loaded.Title(), len(loaded.Messages)))
+			}()
+		})
+		return nil
+
+	case "/resume":
+		id := ""
+		if len(parts) > 1 {
+			id = parts[1]
+		}
+		loaded, err := sess.Resume(a, id)
+		if err != nil {
+			return err
+		}
+		adapter.OnCompaction(fmt.Sprintf("Resumed session %q (%d messages)", loaded.Title(), len(loaded.Messages)))
+		return nil
+
+	default:
+		adapter.OnCompaction(fmt.Sprintf("Unknown command: %s. Type /help for available commands", cmd))
+		return nil
+	}
+}
+
+// retryNotice describes an upcoming retry of a failed API request, e.g.
+// "Server overloaded, retrying (attempt 2/5) in 1.5s"
+func retryNotice(stats retry.RetryStats) string {
+	reason := fmt.Sprintf("API request failed (%v);", stats.LastErr)
+	if apiErr, ok := api.AsAPIError(stats.LastErr); ok {
+		reason = apiErr.Summary() + ","
+	}
+	return fmt.Sprintf("%s retrying (attempt %d/%d) in %s",
+		reason, stats.NextAttempt(), stats.MaxAttempts, stats.NextDelay.Round(100*time.Millisecond))
+}
+
+// runSimpleMode runs the application in simple terminal mode
+func runSimpleMode(client api.MessageClient, registry *tools.Registry, agentRegistry *agentregistry.Registry, workDir string, cfg *config.Config, resume resumeRequest, contextNote string, images []string, output string, quiet bool, args []string) error {
+	// Create terminal UI
+	terminal := ui.NewTerminal()
+	terminal.SetToolDisplay(toolDisplayOptions(cfg))
+
+	// JSON output and print mode replace everything the terminal would
+	// print; without a terminal nobody can answer questions
+	var jsonOut *ui.JSONWriter
+	var printOut *ui.PrintWriter
+	batchMode := "" // Name of the non-interactive mode, if any
+	switch output {
+	case "json":
+		jsonOut = ui.NewJSONWriter(os.Stdout)
+		batchMode = "JSON output mode"
+	case "print":
+		var activity io.Writer = os.Stderr
+		if quiet {
+			activity = nil
+		}
+		printOut = ui.NewPrintWriter(os.Stdout, activity)
+		batchMode = "print mode"
+		client.SetRetryCallback(func(stats retry.RetryStats) {
+			printOut.Info(retryNotice(stats))
+		})
+	default:
+		client.SetRetryCallback(func(stats retry.RetryStats) {
+			terminal.PrintWarning(retryNotice(stats))
+		})
+	}
+
+	// Create ask user question tool with handler
+	askTool := tools.NewAskUserQuestionTool(func(questions []tools.Question) (map[string]string, error) {
+		if batchMode != "" {
+			return nil, fmt.Errorf("questions cannot be answered in %s; proceed with your best judgement", batchMode)
+		}
+		answers := make(map[string]string)
+		for _, q := range questions {
+			fmt.Println()
+			fmt.Println(q.Question)
+			for i, opt := range q.Options {
+				fmt.Printf("  %d. %s - %s\n", i+1, opt.Label, opt.Description)
+			}
+			fmt.Print("Enter your choice (number or text): ")
+
+			line, err := terminal.ReadLine()
+			if err != nil {
+				return nil, err
+			}
+			answers[q.Header] = line
+		}
+		return answers, nil
+	})
+	if err := registry.Register(askTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
+
+	// Create agent with agent registry
+	a := agent.NewAgent(client, registry, agentRegistry, workDir)
+	defer a.CleanupOutputs()
+	configureAgent(a, cfg)
+
+	// Ask the user about tool calls whose permission is Ask. Without a
+	// terminal to ask (JSON output, print mode) ask_fallback decides.
+	if batchMode == "" {
+		a.SetAskFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+			terminal.EndAssistantResponse()
+			terminal.PrintWarning(req.Message)
+			if req.Pattern != "" && req.Pattern != "*" {
+				terminal.PrintDim("  " + req.Pattern)
+			}
+			if req.Input != "" {
+				fmt.Print("Allow? [y/N/a(lways)/e(dit)] ")
+			} else {
+				fmt.Print("Allow? [y/N/a(lways)] ")
+			}
+
+			line, err := terminal.ReadLine()
+			if err != nil {
+				return permission.AskResponse{}, err
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				return permission.AskResponse{Approved: true}, nil
+			case "a", "always":
+				return permission.AskResponse{Approved: true, Always: true}, nil
+			case "e", "edit":
+				if req.Input == "" {
+					return permission.AskResponse{Rejected: true}, nil
+				}
+				// The edited input replaces the call's input when approved
+				terminal.PrintDim("  Input: " + req.Input)
+				fmt.Print("New input (JSON, empty to keep): ")
+				edited, err := terminal.ReadLine()
+				if err != nil {
+					return permission.AskResponse{}, err
+				}
+				return permission.AskResponse{Approved: true, EditedInput: strings.TrimSpace(edited)}, nil
+			default:
+				return permission.AskResponse{Rejected: true}, nil
+			}
+		})
+	}
+
+	// Confirm tool calls that keep repeating with identical input
+	if batchMode == "" {
+		a.SetLoopConfirmFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+			terminal.EndAssistantResponse()
+			terminal.PrintWarning(req.Message)
+			fmt.Print("Continue? [y/N] ")
+
+			line, err := terminal.ReadLine()
+			if err != nil {
+				return permission.AskResponse{}, err
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				return permission.AskResponse{Approved: true}, nil
+			default:
+				return permission.AskResponse{Rejected: true}, nil
+			}
+		})
+	}
+
+	// Review every file change as a diff before it is written
+	if cfg.ConfirmWrites {
+		a.SetWriteConfirmFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+			if batchMode != "" {
+				return permission.AskResponse{}, fmt.Errorf("confirm_writes is on, but changes cannot be reviewed in %s; %s was not changed", batchMode, req.Pattern)
+			}
+			terminal.EndAssistantResponse()
+			terminal.PrintInfo(req.Message)
+			terminal.PrintDiff(req.Diff)
+			fmt.Print("Apply this change? [y/N/a(lways for this file)] ")
+
+			line, err := terminal.ReadLine()
+			if err != nil {
+				return permission.AskResponse{}, err
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "y", "yes":
+				return permission.AskResponse{Approved: true}, nil
+			case "a", "always":
+				return permission.AskResponse{Approved: true, Always: true}, nil
+			default:
+				return permission.AskResponse{Rejected: true}, nil
+			}
+		})
+	}
+
+	// Register plan mode tools with agent switch callback
+	planEnterTool := tools.NewPlanEnterTool(workDir, func(toAgent string) error {
 		return a.SwitchAgent(toAgent)
 	})
-	registry.Register(planExitTool)
+	if err := registry.Register(planEnterTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
+
+	planExitTool := tools.NewPlanExitTool(workDir, func(toAgent string) error {
+		return a.SwitchAgent(toAgent)
+	})
+	if err := registry.Register(planExitTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
+
+	if err := registry.Register(tools.NewPlanListTool(workDir)); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
 
 	// Create task executor for subagent execution
 	taskExecutor := &simpleTaskExecutor{
@@ -334,34 +1261,82 @@ func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *
 		agentRegistry: agentRegistry,
 		toolRegistry:  registry,
 		workDir:       workDir,
+		askFallback:   cfg.AskFallback,
 	}
 
 	// Register task tool (for subagent invocation)
 	taskTool := tools.NewTaskTool(agentRegistry, taskExecutor)
-	registry.Register(taskTool)
+	if err := registry.Register(taskTool); err != nil {
+		return fmt.Errorf("failed to register tools: %w", err)
+	}
 
 	// Set up event handler
 	a.SetEventHandler(func(event agent.Event) {
+		if jsonOut != nil {
+			writeJSONEvent(jsonOut, event)
+			return
+		}
+		if printOut != nil {
+			switch event.Type {
+			case agent.EventTypeText:
+				printOut.Text(event.Text)
+			case agent.EventTypeToolUseStart:
+				printOut.ToolStart()
+			case agent.EventTypeToolUseEnd:
+				printOut.ToolEnd(event.ToolName, event.ToolInput, event.ToolResult, event.IsError)
+			case agent.EventTypeStreamRetry:
+				printOut.Info(event.Text)
+			case agent.EventTypeCompaction:
+				printOut.Info("Context: " + event.CompactionInfo)
+			case agent.EventTypeContextWarning:
+				if event.Text != "" {
+					printOut.Info(event.Text)
+				}
+			}
+			// Errors are returned from Chat and reported once on exit
+			return
+		}
+
 		switch event.Type {
 		case agent.EventTypeText:
 			terminal.PrintAssistantText(event.Text)
 
+		case agent.EventTypeThinking:
+			terminal.PrintThinking(event.Text)
+
 		case agent.EventTypeToolUseStart:
 			terminal.EndAssistantResponse()
 			terminal.PrintToolStart(event.ToolName, event.ToolID)
 
+		case agent.EventTypeToolRunning:
+			terminal.StartToolStatus(event.ToolName)
+
+		case agent.EventTypeToolProgress:
+			terminal.UpdateToolStatus(event.Progress.Done, event.Progress.Total, event.Progress.Unit)
+
 		case agent.EventTypeToolUseEnd:
-			terminal.PrintToolEnd(event.ToolName, event.ToolResult, event.IsError)
+			terminal.PrintToolEnd(event.ToolName, event.ToolInput, event.ToolResult, event.IsError)
 
 		case agent.EventTypeError:
 			terminal.PrintError(event.Error)
 
 		case agent.EventTypeConversationEnd:
 			terminal.EndAssistantResponse()
+			if event.StopReason == "stop_sequence" {
+				terminal.PrintDim(fmt.Sprintf("(stopped at stop sequence %q)", event.StopSequence))
+			}
 
 		case agent.EventTypeAgentSwitch:
 			terminal.EndAssistantResponse()
-			terminal.PrintInfo(fmt.Sprintf("Switched to %s agent", event.AgentName))
+			terminal.PrintInfo(fmt.Sprintf("Switched to %s agent (model %s)", event.AgentName, a.GetModel()))
+
+		case agent.EventTypeRetry:
+			terminal.EndAssistantResponse()
+			terminal.PrintInfo(event.Text)
+
+		case agent.EventTypeStreamRetry:
+			terminal.EndAssistantResponse()
+			terminal.PrintWarning(event.Text)
 
 		case agent.EventTypeTokenUsage:
 			if event.TokenUsage != nil {
@@ -373,6 +1348,12 @@ func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *
 		case agent.EventTypeCompaction:
 			terminal.EndAssistantResponse()
 			terminal.PrintInfo(fmt.Sprintf("Context: %s", event.CompactionInfo))
+
+		case agent.EventTypeContextWarning:
+			if event.Text != "" {
+				terminal.EndAssistantResponse()
+				terminal.PrintWarning(event.Text)
+			}
 		}
 	})
 
@@ -384,14 +1365,69 @@ func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		fmt.Println("\nInterrupted. Exiting...")
+		if batchMode == "" {
+			fmt.Println("\nInterrupted. Exiting...")
+		}
 		cancel()
 	}()
 
+	// Session storage (optional)
+	sess := newChatSession(newSessionManager(a, cfg), a, workDir)
+	if cfg.AutoSaveSession {
+		a.SetCheckpointSteps(cfg.CheckpointSteps)
+		a.SetTurnHook(func(messages []api.Message) {
+			if err := sess.Save(messages); err != nil {
+				warning := fmt.Sprintf("failed to save session: %v", err)
+				if jsonOut != nil {
+					jsonOut.Write(ui.JSONEvent{Type: "warning", Info: warning})
+					return
+				}
+				if printOut != nil {
+					printOut.Info("Warning: " + warning)
+					return
+				}
+				terminal.PrintWarning(warning)
+			}
+		})
+	}
+	if resume.requested() {
+		loaded, err := sess.Resume(a, resume.id)
+		if err != nil {
+			return err
+		}
+		if batchMode == "" {
+			terminal.PrintInfo(fmt.Sprintf("Resumed session %q (%d messages)", loaded.Title(), len(loaded.Messages)))
+		}
+	}
+
+	// Images from --image go with the first message
+	for _, path := range images {
+		info, err := attachImage(a, workDir, path)
+		if err != nil {
+			return err
+		}
+		if batchMode == "" {
+			terminal.PrintInfo(info)
+		}
+	}
+
 	// If prompt provided as argument, run non-interactively
 	if len(args) > 0 {
 		prompt := strings.Join(args, " ")
-		return a.Chat(ctx, prompt)
+		err := a.Chat(ctx, prompt)
+		if printOut != nil && err == nil {
+			printOut.Finish()
+		}
+		if jsonOut != nil {
+			input, output, cacheRead, cacheWrite := a.GetTokenUsage()
+			jsonOut.WriteResult(ui.JSONUsage{
+				InputTokens:      input,
+				OutputTokens:     output,
+				CacheReadTokens:  cacheRead,
+				CacheWriteTokens: cacheWrite,
+			}, err)
+		}
+		return err
 	}
 
 	// Interactive mode
@@ -399,8 +1435,23 @@ func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *
 	terminal.PrintInfo(fmt.Sprintf("Model: %s", client.GetModel()))
 	terminal.PrintInfo(fmt.Sprintf("API: %s", client.GetBaseURL()))
 	terminal.PrintInfo(fmt.Sprintf("Working directory: %s", workDir))
+	if contextNote != "" {
+		terminal.PrintInfo(contextNote)
+	}
 	fmt.Println()
 
+	// Seed the session with the configured initial prompt
+	if cfg.InitialPrompt != "" {
+		terminal.PrintPrompt()
+		fmt.Println(cfg.InitialPrompt)
+		if err := a.Chat(ctx, cfg.InitialPrompt); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			terminal.PrintError(err)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -423,9 +1474,20 @@ func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *
 			continue
 		}
 
+		// Regenerate the last response
+		if input == "/retry" {
+			if err := a.Retry(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				terminal.PrintError(err)
+			}
+			continue
+		}
+
 		// Handle commands
 		if strings.HasPrefix(input, "/") {
-			handled, err := handleSimpleCommand(input, terminal, a)
+			handled, err := handleSimpleCommand(input, terminal, a, registry, sess, cfg)
 			if err != nil {
 				terminal.PrintError(err)
 			}
@@ -444,7 +1506,67 @@ func runSimpleMode(client *api.Client, registry *tools.Registry, agentRegistry *
 	}
 }
 
-func handleSimpleCommand(input string, terminal *ui.Terminal, a *agent.Agent) (bool, error) {
+// writeJSONEvent converts an agent event to a line of JSON output
+func writeJSONEvent(out *ui.JSONWriter, event agent.Event) {
+	jsonEvent := ui.JSONEvent{
+		Type:      string(event.Type),
+		Text:      event.Text,
+		ToolName:  event.ToolName,
+		ToolID:    event.ToolID,
+		ToolInput: ui.JSONToolInput(event.ToolInput),
+		IsError:   event.IsError,
+		AgentName: event.AgentName,
+		Info:      event.CompactionInfo,
+	}
+
+	switch event.Type {
+	case agent.EventTypeToolUseEnd:
+		jsonEvent.ToolOutput = event.ToolResult
+	case agent.EventTypeError:
+		if event.Error != nil {
+			jsonEvent.Error = event.Error.Error()
+		}
+	case agent.EventTypeConversationEnd:
+		jsonEvent.StopReason = event.StopReason
+		jsonEvent.StopSequence = event.StopSequence
+	case agent.EventTypeTokenUsage:
+		if event.TokenUsage == nil {
+			return
+		}
+		jsonEvent.Usage = &ui.JSONUsage{
+			InputTokens:      event.TokenUsage.InputTokens,
+			OutputTokens:     event.TokenUsage.OutputTokens,
+			CacheReadTokens:  event.TokenUsage.CacheReadInputTokens,
+			CacheWriteTokens: event.TokenUsage.CacheCreationInputTokens,
+		}
+	}
+
+	out.Write(jsonEvent)
+}
+
+// printPrompt builds the prompt for print mode from the arguments and
+// whatever is piped to stdin, so "git diff | claude -p 'review this'" works
+func printPrompt(args []string, stdin *os.File) (string, error) {
+	prompt := strings.Join(args, " ")
+	if info, err := stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		if piped := strings.TrimSpace(string(data)); piped != "" {
+			if prompt != "" {
+				prompt += "\n\n"
+			}
+			prompt += piped
+		}
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return "", fmt.Errorf("--print requires a prompt argument or input on stdin")
+	}
+	return prompt, nil
+}
+
+func handleSimpleCommand(input string, terminal *ui.Terminal, a *agent.Agent, registry *tools.Registry, sess *chatSession, cfg *config.Config) (bool, error) {
 	parts := strings.Fields(input)
 	if len(parts) == 0 {
 		return false, nil
@@ -459,24 +1581,45 @@ func handleSimpleCommand(input string, terminal *ui.Terminal, a *agent.Agent) (b
 
 	case "/clear":
 		a.GetConversation().Clear()
+		a.ClearImages()
+		a.ResetStats()
+		registry.ClearCaches()
+		sess.Reset()
 		terminal.PrintSuccess("Conversation cleared")
 		return true, nil
 
 	case "/exit", "/quit":
 		fmt.Println("Goodbye!")
+		a.CleanupOutputs()
 		os.Exit(0)
 		return true, nil
 
 	case "/model":
 		if len(parts) < 2 {
-			terminal.PrintInfo("Current model: " + "claude-sonnet-4-20250514")
+			terminal.PrintInfo(formatModels(a.GetModel()))
 			return true, nil
 		}
-		terminal.PrintInfo("Model switching requires restart")
+		a.SetModel(parts[1])
+		terminal.PrintSuccess("Switched to model " + parts[1])
 		return true, nil
 
 	case "/agent":
-		terminal.PrintInfo("Current agent: " + a.GetCurrentAgent())
+		if len(parts) < 2 {
+			terminal.PrintInfo("Current agent: " + a.GetCurrentAgent())
+			return true, nil
+		}
+		return true, switchPrimaryAgent(a, parts[1])
+
+	case "/agents":
+		terminal.PrintInfo(formatAgents(a))
+		return true, nil
+
+	case "/pwd":
+		out, err := handlePwdCommand(registry, parts)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintInfo(out)
 		return true, nil
 
 	case "/tokens":
@@ -485,24 +1628,737 @@ func handleSimpleCommand(input string, terminal *ui.Terminal, a *agent.Agent) (b
 			input, output, cacheRead, input+output+cacheRead+cacheWrite))
 		return true, nil
 
+	case "/cost":
+		terminal.PrintInfo(formatCost(sess.Usage(), a.GetModel(), cfg.Pricing))
+		return true, nil
+
+	case "/stats":
+		terminal.PrintInfo(formatStats(a.GetStats(), sess.workDir))
+		return true, nil
+
+	case "/output":
+		out, err := handleOutputCommand(a, parts)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintInfo(out)
+		return true, nil
+
+	case "/image":
+		out, err := handleImageCommand(a, sess.workDir, parts)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintInfo(out)
+		return true, nil
+
+	case "/export":
+		out, err := handleExportCommand(a, sess, parts)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintSuccess(out)
+		return true, nil
+
+	case "/perms":
+		perms, err := formatPermissions(a)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintInfo(perms)
+		return true, nil
+
+	case "/pin", "/unpin":
+		out, err := handlePinCommand(a, input)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintInfo(out)
+		return true, nil
+
+	case "/summary":
+		terminal.StartSpinner("Summarizing conversation...")
+		summary, err := a.Summarize(context.Background())
+		terminal.StopSpinner()
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintInfo("Conversation summary:")
+		terminal.PrintMarkdown(summary)
+		return true, nil
+
+	case "/compact":
+		out, err := handleCompactCommand(a, parts)
+		if err != nil {
+			return true, err
+		}
+		if out != "" {
+			terminal.PrintInfo(out)
+		}
+		return true, nil
+
+	case "/undo":
+		out, err := handleUndoCommand(registry, sess.workDir, parts)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintSuccess(out)
+		return true, nil
+
+	case "/checkpoint", "/goto", "/checkpoints":
+		out, err := handleCheckpointCommand(a, parts)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintSuccess(out)
+		return true, nil
+
+	case "/sessions":
+		if sess.mgr == nil {
+			return true, fmt.Errorf("session storage is unavailable")
+		}
+		id, err := pickSession(terminal, sess.mgr)
+		if err != nil || id == "" {
+			return true, err
+		}
+		loaded, err := sess.Resume(a, id)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintSuccess(fmt.Sprintf("Loaded session %q (%d messages)", loaded.Title(), len(loaded.Messages)))
+		return true, nil
+
+	case "/resume":
+		id := ""
+		if len(parts) > 1 {
+			id = parts[1]
+		}
+		loaded, err := sess.Resume(a, id)
+		if err != nil {
+			return true, err
+		}
+		terminal.PrintSuccess(fmt.Sprintf("Resumed session %q (%d messages)", loaded.Title(), len(loaded.Messages)))
+		return true, nil
+
 	default:
 		return false, fmt.Errorf("unknown command: %s. Type /help for available commands", cmd)
 	}
 }
 
+// pickSession shows a numbered, paginated session list and returns the chosen ID ("" if cancelled)
+func pickSession(terminal *ui.Terminal, sessMgr *session.SessionManager) (string, error) {
+	page := 1
+	query := ""
+
+	for {
+		sessions, total, err := sessMgr.ListSessionsPage(query, page, sessionPageSize)
+		if err != nil {
+			return "", err
+		}
+		if total == 0 {
+			if query != "" {
+				terminal.PrintInfo(fmt.Sprintf("No sessions matching %q", query))
+				query = ""
+				continue
+			}
+			terminal.PrintInfo("No saved sessions")
+			return "", nil
+		}
+
+		pages := (total + sessionPageSize - 1) / sessionPageSize
+		start := (page - 1) * sessionPageSize
+
+		fmt.Println()
+		for i, s := range sessions {
+			fmt.Printf("  %3d. %-50s %s  %3d msgs\n", start+i+1, s.Title(), s.UpdatedAt.Format("2006-01-02 15:04"), len(s.Messages))
+		}
+		status := fmt.Sprintf("Page %d/%d · %d sessions", page, pages, total)
+		if query != "" {
+			status += fmt.Sprintf(" matching %q", query)
+		}
+		terminal.PrintDim(status)
+		fmt.Print("Number to load, n/p for next/prev page, /text to search, Enter to cancel: ")
+
+		line, err := terminal.ReadLine()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case line == "":
+			return "", nil
+		case line == "n":
+			if page < pages {
+				page++
+			}
+		case line == "p":
+			if page > 1 {
+				page--
+			}
+		case strings.HasPrefix(line, "/"):
+			query = strings.TrimSpace(strings.TrimPrefix(line, "/"))
+			page = 1
+		default:
+			n, err := strconv.Atoi(line)
+			if err != nil || n < start+1 || n > start+len(sessions) {
+				terminal.PrintWarning(fmt.Sprintf("Invalid choice: %s", line))
+				continue
+			}
+			return sessions[n-start-1].ID, nil
+		}
+	}
+}
+
+// resumeRequest selects a saved session to continue at startup
+type resumeRequest struct {
+	latest bool   // --resume: latest session for the working directory
+	id     string // --session: a specific session (takes precedence)
+}
+
+func (r resumeRequest) requested() bool {
+	return r.latest || r.id != ""
+}
+
+// chatSession ties the running conversation to a saved session so that
+// saves after a resume keep writing to the same session file
+type chatSession struct {
+	mgr     *session.SessionManager // nil if session storage is unavailable
+	agent   *agent.Agent
+	workDir string
+
+	mu      sync.Mutex
+	current *session.Session // nil until the first save or resume
+
+	// base is added to the agent's token totals to get the session's usage:
+	// the usage saved before a resume, minus what the agent had counted when
+	// the session was attached
+	base api.Usage
+}
+
+func newChatSession(mgr *session.SessionManager, a *agent.Agent, workDir string) *chatSession {
+	return &chatSession{mgr: mgr, agent: a, workDir: workDir}
+}
+
+// Usage returns the token usage of the current session, including runs
+// before it was resumed
+func (s *chatSession) Usage() api.Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return addUsage(s.base, agentUsage(s.agent), 1)
+}
+
+// Save writes messages to the current session, creating it on first use
+func (s *chatSession) Save(messages []api.Message) error {
+	if s.mgr == nil || len(messages) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = s.mgr.CreateSession(s.workDir)
+	}
+	s.current.Messages = messages
+	s.current.Usage = addUsage(s.base, agentUsage(s.agent), 1)
+	return s.mgr.SaveSession(s.current)
+}
+
+// Resume loads a saved session into the agent ("" = latest for the working directory)
+func (s *chatSession) Resume(a *agent.Agent, id string) (*session.Session, error) {
+	if s.mgr == nil {
+		return nil, fmt.Errorf("session storage is unavailable")
+	}
+
+	var loaded *session.Session
+	var err error
+	if id == "" {
+		loaded, err = s.mgr.GetLatestSession(s.workDir)
+	} else {
+		loaded, err = s.mgr.LoadSession(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.LoadMessages(loaded.Messages)
+
+	s.mu.Lock()
+	s.current = loaded
+	s.base = addUsage(loaded.Usage, agentUsage(a), -1)
+	s.mu.Unlock()
+	return loaded, nil
+}
+
+// Reset detaches from the current session so the next save starts a new one
+func (s *chatSession) Reset() {
+	s.mu.Lock()
+	s.current = nil
+	s.base = addUsage(api.Usage{}, agentUsage(s.agent), -1)
+	s.mu.Unlock()
+}
+
+// agentUsage returns the agent's token totals
+func agentUsage(a *agent.Agent) api.Usage {
+	input, output, cacheRead, cacheWrite := a.GetTokenUsage()
+	return api.Usage{
+		InputTokens:              input,
+		OutputTokens:             output,
+		CacheReadInputTokens:     cacheRead,
+		CacheCreationInputTokens: cacheWrite,
+	}
+}
+
+// addUsage returns a + sign*b
+func addUsage(a, b api.Usage, sign int) api.Usage {
+	return api.Usage{
+		InputTokens:              a.InputTokens + sign*b.InputTokens,
+		OutputTokens:             a.OutputTokens + sign*b.OutputTokens,
+		CacheReadInputTokens:     a.CacheReadInputTokens + sign*b.CacheReadInputTokens,
+		CacheCreationInputTokens: a.CacheCreationInputTokens + sign*b.CacheCreationInputTokens,
+	}
+}
+
+// formatStats shows where the conversation spent its effort, with the
+// changed files relative to workDir
+func formatStats(stats agent.Stats, workDir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Conversation stats (%s elapsed):\n", time.Since(stats.Started).Round(time.Second))
+	fmt.Fprintf(&b, "  Turns        %d\n", stats.Turns)
+	fmt.Fprintf(&b, "  Requests     %d\n", stats.Requests)
+	fmt.Fprintf(&b, "  Tool calls   %d (%d failed, %s running)", stats.TotalToolCalls(), stats.ToolErrors, stats.ToolTime.Round(100*time.Millisecond))
+	for _, name := range stats.ToolNames() {
+		fmt.Fprintf(&b, "\n    %-16s %d", name, stats.ToolCalls[name])
+	}
+	if len(stats.FilesChanged) == 0 {
+		b.WriteString("\nNo files changed")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\nFiles changed (%d):", len(stats.FilesChanged))
+	for _, path := range stats.FilesChanged {
+		if rel, err := filepath.Rel(workDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		b.WriteString("\n  " + path)
+	}
+	return b.String()
+}
+
+// formatCost estimates the session cost at the current model's rates
+func formatCost(usage api.Usage, model string, overrides map[string]api.ModelPricing) string {
+	pricing, ok := api.LookupPricing(model, overrides)
+	if !ok {
+		return fmt.Sprintf("No pricing known for model %s. Add its rates under \"pricing\" in the config to estimate costs.", model)
+	}
+
+	cost := api.EstimateCost(pricing, usage)
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Estimated session cost at %s rates:\n", model))
+	b.WriteString(fmt.Sprintf("  Input        %10d tokens  $%.4f\n", usage.InputTokens, cost.Input))
+	b.WriteString(fmt.Sprintf("  Output       %10d tokens  $%.4f\n", usage.OutputTokens, cost.Output))
+	b.WriteString(fmt.Sprintf("  Cache read   %10d tokens  $%.4f\n", usage.CacheReadInputTokens, cost.CacheRead))
+	b.WriteString(fmt.Sprintf("  Cache write  %10d tokens  $%.4f\n", usage.CacheCreationInputTokens, cost.CacheWrite))
+	b.WriteString(fmt.Sprintf("  Total                           $%.4f", cost.Total()))
+	if usage.CacheReadInputTokens > 0 {
+		b.WriteString(fmt.Sprintf("\nCache savings: $%.4f (cache reads would have cost $%.4f at the full input rate)",
+			cost.CacheSavings, cost.CacheSavings+cost.CacheRead))
+	}
+	return b.String()
+}
+
+// handleImageCommand attaches the image files in parts to the next message;
+// "/image clear" drops the attached images
+func handleImageCommand(a *agent.Agent, workDir string, parts []string) (string, error) {
+	if len(parts) < 2 {
+		if n := a.PendingImages(); n > 0 {
+			return fmt.Sprintf("%d image(s) will be sent with your next message (/image clear to drop them)", n), nil
+		}
+		return "", fmt.Errorf("usage: /image <path> [path...]")
+	}
+	if len(parts) == 2 && parts[1] == "clear" {
+		a.ClearImages()
+		return "Attached images dropped", nil
+	}
+
+	var lines []string
+	for _, path := range parts[1:] {
+		info, err := attachImage(a, workDir, path)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, info)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// attachImage loads an image file and queues it for the next user message
+func attachImage(a *agent.Agent, workDir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workDir, path)
+	}
+	img, err := tools.LoadImageFile(path)
+	if err != nil {
+		return "", err
+	}
+	a.AttachImage(img.MediaType, img.Data)
+	return fmt.Sprintf("Attached %s (%s); it will be sent with your next message", filepath.Base(path), img.MediaType), nil
+}
+
+// handleCompactCommand compacts the conversation now; "keep=N" keeps the
+// last N exchanges instead of the default. It returns a message only when
+// there was nothing to do.
+func handleCompactCommand(a *agent.Agent, parts []string) (string, error) {
+	keep := 0
+	for _, arg := range parts[1:] {
+		value, ok := strings.CutPrefix(arg, "keep=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 1 {
+			return "", fmt.Errorf("usage: /compact [keep=N] (N = recent exchanges to keep, default %d)", agent.DefaultKeepRecent)
+		}
+		keep = n
+	}
+
+	err := a.Compact(context.Background(), keep)
+	if errors.Is(err, agent.ErrNothingToCompact) {
+		return "Nothing to compact", nil
+	}
+	return "", err
+}
+
+// handleUndoCommand reverts the last file change made by a tool, or the last
+// N with "/undo N", and reports what was reverted
+func handleUndoCommand(registry *tools.Registry, workDir string, parts []string) (string, error) {
+	history := registry.FileHistory()
+	if history == nil {
+		return "", fmt.Errorf("file change history is unavailable")
+	}
+	n := 1
+	if len(parts) > 1 {
+		v, err := strconv.Atoi(parts[1])
+		if err != nil || v < 1 || len(parts) > 2 {
+			return "", fmt.Errorf("usage: /undo [N] (N = file changes to revert, default 1)")
+		}
+		n = v
+	}
+
+	undone, err := history.Undo(n)
+	if errors.Is(err, tools.ErrNothingToUndo) {
+		return "No file changes to undo", nil
+	}
+	var b strings.Builder
+	for _, snap := range undone {
+		name := snap.Path
+		if rel, relErr := filepath.Rel(workDir, snap.Path); relErr == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		if snap.Existed {
+			fmt.Fprintf(&b, "Reverted %s change to %s\n", snap.Tool, name)
+		} else {
+			fmt.Fprintf(&b, "Deleted %s (created by %s)\n", name, snap.Tool)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s%w", b.String(), err)
+	}
+	if left := history.Len(); left > 0 {
+		fmt.Fprintf(&b, "%d more change(s) can be undone", left)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// handleCheckpointCommand handles /checkpoint, /goto and /checkpoints, which
+// save, restore and list named snapshots of the conversation
+func handleCheckpointCommand(a *agent.Agent, parts []string) (string, error) {
+	switch strings.ToLower(parts[0]) {
+	case "/checkpoint":
+		if len(parts) > 2 {
+			return "", fmt.Errorf("usage: /checkpoint [name]")
+		}
+		name := ""
+		if len(parts) > 1 {
+			name = parts[1]
+		}
+		cp := a.SaveCheckpoint(name)
+		return fmt.Sprintf("Saved checkpoint %q (%d messages); /goto %s returns to it", cp.Name, len(cp.Messages), cp.Name), nil
+
+	case "/goto":
+		if len(parts) != 2 {
+			return "", fmt.Errorf("usage: /goto <name> (/checkpoints lists them)")
+		}
+		before := a.GetConversation().MessageCount()
+		cp, err := a.RestoreCheckpoint(parts[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Went back to checkpoint %q: %d messages (was %d)", cp.Name, len(cp.Messages), before), nil
+
+	default:
+		checkpoints := a.Checkpoints()
+		if len(checkpoints) == 0 {
+			return "No checkpoints yet; /checkpoint <name> saves one", nil
+		}
+		var b strings.Builder
+		b.WriteString("Checkpoints:")
+		for _, cp := range checkpoints {
+			fmt.Fprintf(&b, "\n  %s - %d messages (%s)", cp.Name, len(cp.Messages), cp.Created.Format("15:04:05"))
+		}
+		return b.String(), nil
+	}
+}
+
+// handleExportCommand writes the conversation to the file given in parts,
+// or to a timestamped Markdown file in the working directory
+func handleExportCommand(a *agent.Agent, sess *chatSession, parts []string) (string, error) {
+	messages := a.GetConversation().GetMessages()
+	if len(messages) == 0 {
+		return "", fmt.Errorf("nothing to export yet")
+	}
+
+	path := fmt.Sprintf("conversation-%s.md", time.Now().Format("20060102-150405"))
+	if len(parts) > 1 {
+		path = parts[1]
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(sess.workDir, path)
+	}
+
+	e := session.NewExporter(a.GetConversation().BuildSystemPrompt())
+	e.Model = a.GetModel()
+	e.Usage = sess.Usage()
+	sess.mu.Lock()
+	if sess.current != nil {
+		e.Title = sess.current.Title()
+	}
+	sess.mu.Unlock()
+
+	if err := e.ExportFile(path, messages); err != nil {
+		return "", err
+	}
+	return "Exported conversation to " + path, nil
+}
+
+// exportSavedSession writes a saved session ("" = latest for workDir) to path
+func exportSavedSession(path, workDir, id string) error {
+	sessMgr, err := session.NewSessionManager()
+	if err != nil {
+		return err
+	}
+
+	var saved *session.Session
+	if id == "" {
+		saved, err = sessMgr.GetLatestSession(workDir)
+	} else {
+		saved, err = sessMgr.LoadSession(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := saved.Exporter().ExportFile(path, saved.Messages); err != nil {
+		return err
+	}
+	fmt.Printf("Exported session %q to %s\n", saved.Title(), path)
+	return nil
+}
+
+// formatModels describes the current model and lists the known ones
+func formatModels(current string) string {
+	var b strings.Builder
+	b.WriteString("Current model: " + current)
+	b.WriteString("\nAvailable models (switch with /model <id>):")
+	for _, m := range api.KnownModels {
+		marker := " "
+		if m.ID == current {
+			marker = "*"
+		}
+		b.WriteString(fmt.Sprintf("\n %s %-28s %s", marker, m.ID, m.Name))
+	}
+	return b.String()
+}
+
+// handlePinCommand implements /pin [text] and /unpin <n|all>
+func handlePinCommand(a *agent.Agent, input string) (string, error) {
+	conv := a.GetConversation()
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	arg = strings.TrimSpace(arg)
+
+	if strings.ToLower(cmd) == "/unpin" {
+		if arg == "" {
+			return "", fmt.Errorf("usage: /unpin <number|all>")
+		}
+		if arg == "all" {
+			conv.ClearPins()
+			return "Removed all pinned notes", nil
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", fmt.Errorf("usage: /unpin <number|all>")
+		}
+		note, err := conv.Unpin(n - 1)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Unpinned: %s", note), nil
+	}
+
+	if arg != "" {
+		conv.Pin(arg)
+		return fmt.Sprintf("Pinned note #%d (kept across compaction and /clear)", len(conv.Pinned())), nil
+	}
+
+	pinned := conv.Pinned()
+	if len(pinned) == 0 {
+		return "No pinned notes. Use /pin <text> to add one.", nil
+	}
+	var b strings.Builder
+	b.WriteString("Pinned notes:")
+	for i, note := range pinned {
+		b.WriteString(fmt.Sprintf("\n  %d. %s", i+1, note))
+	}
+	return b.String(), nil
+}
+
+// handlePwdCommand shows the Bash tool's working directory, or resets it
+// to the project directory with "/pwd reset"
+func handlePwdCommand(registry *tools.Registry, parts []string) (string, error) {
+	tool, ok := registry.Get("Bash")
+	if !ok {
+		return "", fmt.Errorf("the Bash tool is not available")
+	}
+	bash, ok := tool.(*tools.BashTool)
+	if !ok {
+		return "", fmt.Errorf("the Bash tool is not available")
+	}
+
+	if len(parts) > 1 {
+		if parts[1] != "reset" {
+			return "", fmt.Errorf("usage: /pwd [reset]")
+		}
+		bash.ResetCwd()
+	}
+	return "Bash working directory: " + bash.Cwd(), nil
+}
+
+// outputPreviewLines caps how much of a saved output /output prints
+const outputPreviewLines = 200
+
+// handleOutputCommand lists the saved full outputs of truncated tool results,
+// or prints one by call ID
+func handleOutputCommand(a *agent.Agent, parts []string) (string, error) {
+	if len(parts) < 2 {
+		outputs := a.SavedOutputs()
+		if len(outputs) == 0 {
+			return "No truncated tool outputs saved in this session", nil
+		}
+		var b strings.Builder
+		b.WriteString("Saved tool outputs (show one with /output <call id>):")
+		for _, saved := range outputs {
+			b.WriteString(fmt.Sprintf("\n  %s  %-12s %d bytes", saved.CallID, saved.ToolName, saved.Size))
+		}
+		return b.String(), nil
+	}
+
+	path, ok := agent.SavedOutputPath(parts[1])
+	if !ok {
+		return "", fmt.Errorf("no saved output for call %s (see /output)", parts[1])
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) > outputPreviewLines {
+		return fmt.Sprintf("%s\n... (%d more lines; full output in %s)",
+			strings.Join(lines[:outputPreviewLines], "\n"), len(lines)-outputPreviewLines, path), nil
+	}
+	return strings.Join(lines, "\n") + "\n(" + path + ")", nil
+}
+
+// switchPrimaryAgent switches to the named agent if it can be used as a primary agent
+func switchPrimaryAgent(a *agent.Agent, name string) error {
+	info, err := a.GetAgentRegistry().Get(name)
+	if err != nil {
+		return fmt.Errorf("unknown agent %q (see /agents)", name)
+	}
+	if !info.IsPrimary() {
+		return fmt.Errorf("%s is a subagent and cannot be switched to; it only runs through the task tool", name)
+	}
+	return a.SwitchAgent(name)
+}
+
+// formatAgents lists the registered agents with their mode and description
+func formatAgents(a *agent.Agent) string {
+	agents := a.GetAgentRegistry().List(false)
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Name < agents[j].Name
+	})
+
+	var b strings.Builder
+	b.WriteString("Agents:")
+	for _, info := range agents {
+		marker := " "
+		if info.Name == a.GetCurrentAgent() {
+			marker = "*"
+		}
+		name := info.Name
+		if !info.Native {
+			name += " (custom)"
+		}
+		b.WriteString(fmt.Sprintf("\n %s %-20s %-9s %s", marker, name, info.Mode, info.Description))
+	}
+	return b.String()
+}
+
+// formatPermissions renders the current agent's ruleset and session approvals
+func formatPermissions(a *agent.Agent) (string, error) {
+	ruleset, err := a.GetPermissionRuleset()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Permissions for agent '%s':\n", a.GetCurrentAgent()))
+	b.WriteString(ruleset.FormatTable())
+
+	approvals := a.GetSessionApprovals()
+	keys := make([]string, 0, len(approvals))
+	for key, approved := range approvals {
+		if approved {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	b.WriteString("\n\nSession approvals:")
+	if len(keys) == 0 {
+		b.WriteString(" (none)")
+	}
+	for _, key := range keys {
+		b.WriteString("\n  " + key)
+	}
+
+	return b.String(), nil
+}
+
 // simpleTaskExecutor implements tools.TaskExecutor for subagent execution
 type simpleTaskExecutor struct {
-	client        *api.Client
+	client        api.MessageClient
 	agentRegistry *agentregistry.Registry
 	toolRegistry  *tools.Registry
 	workDir       string
+	askFallback   string // ask_fallback from the config
 }
 
 func (e *simpleTaskExecutor) ExecuteAgent(ctx context.Context, agentName string, prompt string) (string, error) {
 	// Create a new agent instance for the subagent
 	subAgent := agent.NewAgent(e.client, e.toolRegistry, e.agentRegistry, e.workDir)
+	defer subAgent.CleanupOutputs()
+	if e.askFallback == "deny" {
+		subAgent.SetAskFallback(permission.ActionDeny)
+	}
 
-	// Switch to the requested agent
+	// Switch to the requested agent, which also selects its model if it
+	// configures one; the shared client's model is left alone
 	if err := subAgent.SwitchAgent(agentName); err != nil {
 		return "", fmt.Errorf("failed to switch to agent %s: %w", agentName, err)
 	}
@@ -3,16 +3,21 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/anthropics/claude-code-go/internal/agentregistry"
 	"github.com/anthropics/claude-code-go/internal/api"
 	"github.com/anthropics/claude-code-go/internal/compaction"
+	"github.com/anthropics/claude-code-go/internal/hooks"
+	"github.com/anthropics/claude-code-go/internal/injection"
 	"github.com/anthropics/claude-code-go/internal/logger"
 	"github.com/anthropics/claude-code-go/internal/permission"
+	"github.com/anthropics/claude-code-go/internal/retry"
 	"github.com/anthropics/claude-code-go/internal/tools"
 )
 
@@ -20,15 +25,20 @@ import (
 type EventType string
 
 const (
-	EventTypeText           EventType = "text"
-	EventTypeToolUseStart   EventType = "tool_use_start"
-	EventTypeToolUseEnd     EventType = "tool_use_end"
-	EventTypeThinking       EventType = "thinking"
-	EventTypeError          EventType = "error"
+	EventTypeText            EventType = "text"
+	EventTypeToolUseStart    EventType = "tool_use_start"
+	EventTypeToolUseEnd      EventType = "tool_use_end"
+	EventTypeToolRunning     EventType = "tool_running"  // Execution begins (after permission checks)
+	EventTypeToolProgress    EventType = "tool_progress" // A running tool reports how far it has got
+	EventTypeThinking        EventType = "thinking"
+	EventTypeError           EventType = "error"
 	EventTypeConversationEnd EventType = "conversation_end"
-	EventTypeAgentSwitch    EventType = "agent_switch"
-	EventTypeCompaction     EventType = "compaction"
-	EventTypeTokenUsage     EventType = "token_usage"
+	EventTypeAgentSwitch     EventType = "agent_switch"
+	EventTypeCompaction      EventType = "compaction"
+	EventTypeTokenUsage      EventType = "token_usage"
+	EventTypeRetry           EventType = "retry"           // The last response was discarded to be regenerated
+	EventTypeStreamRetry     EventType = "stream_retry"    // A response broke off mid-stream; its partial output is discarded and the request resent
+	EventTypeContextWarning  EventType = "context_warning" // The context passed the warning threshold (Text set) or dropped back below it (Text empty)
 )
 
 // Event represents an event emitted during agent execution
@@ -43,6 +53,14 @@ type Event struct {
 	Error      error
 	AgentName  string // For agent switch events
 
+	// Progress of a running tool, for tool progress events
+	Progress *tools.Progress
+
+	// Why the response ended, for conversation end events: "end_turn",
+	// "max_tokens" or "stop_sequence" (with the sequence matched)
+	StopReason   string
+	StopSequence string
+
 	// Token usage
 	TokenUsage *api.Usage
 
@@ -50,21 +68,91 @@ type Event struct {
 	CompactionInfo string
 }
 
+// DefaultMaxSteps bounds the model requests in one turn when the agent sets no limit
+const DefaultMaxSteps = 50
+
 // EventHandler is a function that handles events
 type EventHandler func(event Event)
 
 // Agent represents the main Claude agent
 type Agent struct {
-	client        *api.Client
+	client        api.MessageClient
 	registry      *tools.Registry
 	agentRegistry *agentregistry.Registry
-	permEvaluator *permission.Evaluator
+	permManager   *permission.Manager
 	compactor     *compaction.Compactor
 	conversation  *Conversation
 	eventHandler  EventHandler
+	emitMu        sync.Mutex // Serializes events from concurrently running tools
 	workDir       string
-	currentAgent  string // Current agent name (build, plan, explore)
-	sessionID     string // Session ID for output truncation
+	currentAgent  string   // Current agent name (build, plan, explore)
+	model         string   // Current agent's model ("" = client default)
+	temperature   *float64 // Current agent's temperature (nil = client default)
+	stopSequences []string // Current agent's stop sequences (nil = client default)
+	sessionID     string   // Session ID for output truncation
+
+	// Compaction settings
+	autoCompact      bool    // Automatically prune/summarize when nearing the context limit
+	compactWarned    bool    // Whether the near-limit warning was already shown (auto-compaction disabled)
+	nearLimit        bool    // Whether the context is past warnThreshold, as last reported
+	compactThreshold float64 // Fraction of the context window that triggers compaction
+	warnThreshold    float64 // Fraction of the context window that triggers the headroom warning
+	outputReserve    int     // Tokens kept free for the response (0 = the model's maximum output)
+	maxMessages      int     // Compact above this many messages, whatever their size (0 = no limit)
+
+	// How much of long tool results to keep, by tool name (others use the defaults)
+	truncatePolicies map[string]compaction.TruncatePolicy
+	lastCount        *tokenCount // Size of the last request counted by the API
+
+	// Display-only transform for finalized assistant text (nil = stream text as-is)
+	responseProcessor hooks.TextProcessor
+
+	// Flag and fence likely prompt injection in tool output
+	injectionScan bool
+
+	// Run consecutive read-only tool calls concurrently
+	parallelTools bool
+
+	// Asks the user to approve tool calls whose permission is Ask (nil = not asked)
+	askFunc func(permission.AskRequest) (permission.AskResponse, error)
+
+	// What Ask means when askFunc is nil: ActionAllow or ActionDeny
+	askFallback permission.Action
+
+	// Approve Ask calls and skip all confirmations (deny rules still apply)
+	skipPermissions bool
+
+	// Describe tool calls to the model instead of running them
+	dryRun         bool
+	dryRunReadOnly bool // Still run read-only tools in dry-run mode
+
+	// Asks the user whether to continue when a call repeats with identical input (nil = not asked)
+	loopConfirmFunc func(permission.AskRequest) (permission.AskResponse, error)
+
+	// Asks the user to review the diff of every file write (nil = not asked)
+	writeConfirmFunc func(permission.AskRequest) (permission.AskResponse, error)
+	approvedWrites   map[string]bool // Files the user allowed to be written without review
+
+	// Called with the history after every turn (nil = none)
+	turnHook        func(messages []api.Message)
+	checkpointSteps int // Also call turnHook every this many steps within a turn (0 = never)
+
+	// Named snapshots of the history the user can go back to, oldest first
+	checkpoints []Checkpoint
+
+	// Images to send with the next user message
+	imagesMu      sync.Mutex
+	pendingImages []api.Content
+
+	// Model requests made in the current turn
+	stepCount int
+
+	// Work done in the conversation, for /stats
+	stats *statsRecorder
+
+	// Context used by the last response, or estimated after the history
+	// changed; compaction and the headroom warning measure against this
+	contextUsage compaction.TokenUsage
 
 	// Token tracking
 	totalInputTokens      int
@@ -74,24 +162,36 @@ type Agent struct {
 }
 
 // NewAgent creates a new agent
-func NewAgent(client *api.Client, registry *tools.Registry, agentRegistry *agentregistry.Registry, workDir string) *Agent {
-	// Get build agent info for initial system prompt
-	buildAgent, _ := agentRegistry.Get("build")
-	systemPrompt := buildAgent.GetSystemPrompt(workDir)
+func NewAgent(client api.MessageClient, registry *tools.Registry, agentRegistry *agentregistry.Registry, workDir string) *Agent {
+	// Start with the registry's default agent (build unless changed)
+	startAgent, err := agentRegistry.GetDefault()
+	if err != nil {
+		startAgent, _ = agentRegistry.Get("build")
+	}
+	systemPrompt := startAgent.GetSystemPromptWithContext(workDir, agentRegistry.ProjectContext())
 
 	// Generate session ID
-	sessionID := fmt.Sprintf("session-%d", time.Now().Unix())
+	sessionID := fmt.Sprintf("session-%d", time.Now().UnixNano())
 
 	return &Agent{
-		client:        client,
-		registry:      registry,
-		agentRegistry: agentRegistry,
-		permEvaluator: permission.NewEvaluator(),
-		compactor:     compaction.NewCompactor(client),
-		conversation:  NewConversation(systemPrompt),
-		workDir:       workDir,
-		currentAgent:  "build", // Start with build agent
-		sessionID:     sessionID,
+		client:           client,
+		registry:         registry,
+		agentRegistry:    agentRegistry,
+		permManager:      permission.NewManager(),
+		compactor:        compaction.NewCompactor(client),
+		conversation:     NewConversation(systemPrompt),
+		workDir:          workDir,
+		currentAgent:     startAgent.Name,
+		model:            startAgent.Model,
+		temperature:      startAgent.Temperature,
+		stopSequences:    startAgent.StopSequences,
+		sessionID:        sessionID,
+		autoCompact:      true,
+		compactThreshold: compaction.CompactionThreshold,
+		warnThreshold:    compaction.CompactionThreshold - compaction.WarnMargin,
+		parallelTools:    true,
+		askFallback:      permission.ActionAllow,
+		stats:            newStatsRecorder(),
 	}
 }
 
@@ -105,6 +205,112 @@ func (a *Agent) SetSystemPrompt(prompt string) {
 	a.conversation.SetSystemMessage(prompt)
 }
 
+// SetAutoCompact enables or disables automatic compaction
+func (a *Agent) SetAutoCompact(enabled bool) {
+	a.autoCompact = enabled
+}
+
+// SetCompactionThresholds sets the fractions of the context window at which
+// the conversation is compacted and at which the user is warned first. Zero
+// keeps the default compaction threshold and warns WarnMargin before it.
+func (a *Agent) SetCompactionThresholds(compact, warn float64) {
+	if compact <= 0 {
+		compact = compaction.CompactionThreshold
+	}
+	if warn <= 0 {
+		warn = compact - compaction.WarnMargin
+	}
+	a.compactThreshold = compact
+	a.warnThreshold = warn
+}
+
+// SetOutputReserve sets how many tokens of the context window compaction
+// keeps free for the response, normally the requests' max_tokens
+func (a *Agent) SetOutputReserve(tokens int) {
+	a.outputReserve = tokens
+}
+
+// SetMaxMessages compacts the conversation once it holds more than max
+// messages, independent of the token count (0 = no limit)
+func (a *Agent) SetMaxMessages(max int) {
+	a.maxMessages = max
+}
+
+// SetTruncatePolicy sets how much of the named tool's long results the model
+// sees from the start and the end
+func (a *Agent) SetTruncatePolicy(toolName string, policy compaction.TruncatePolicy) {
+	if a.truncatePolicies == nil {
+		a.truncatePolicies = make(map[string]compaction.TruncatePolicy)
+	}
+	a.truncatePolicies[toolName] = policy
+}
+
+// SetParallelTools enables or disables running read-only tool calls concurrently
+func (a *Agent) SetParallelTools(enabled bool) {
+	a.parallelTools = enabled
+}
+
+// SetInjectionScan enables or disables prompt-injection scanning of tool output
+func (a *Agent) SetInjectionScan(enabled bool) {
+	a.injectionScan = enabled
+}
+
+// SetAskFunc sets the callback used to confirm tool calls that need approval.
+// The callback may return edited tool input, which is executed instead.
+func (a *Agent) SetAskFunc(fn func(permission.AskRequest) (permission.AskResponse, error)) {
+	a.askFunc = fn
+}
+
+// SetAskFallback sets what tool calls whose permission is Ask do when no
+// ask callback is set: permission.ActionAllow (the default) runs them,
+// permission.ActionDeny refuses them
+func (a *Agent) SetAskFallback(action permission.Action) {
+	a.askFallback = action
+}
+
+// SetSkipPermissions runs every tool call whose permission is Ask without
+// asking and turns off the repeated-call and write confirmations. Deny rules
+// and the path policy still apply.
+func (a *Agent) SetSkipPermissions(skip bool) {
+	a.skipPermissions = skip
+}
+
+// SetLoopConfirmFunc sets the callback used when the same tool is called
+// repeatedly with identical input. Rejecting it stops the call.
+func (a *Agent) SetLoopConfirmFunc(fn func(permission.AskRequest) (permission.AskResponse, error)) {
+	a.loopConfirmFunc = fn
+}
+
+// SetWriteConfirmFunc sets the callback shown the diff of every Write, Edit,
+// MultiEdit and ApplyPatch call before the file is changed, independent of the
+// permission rules. Approving with Always skips the review for that file.
+func (a *Agent) SetWriteConfirmFunc(fn func(permission.AskRequest) (permission.AskResponse, error)) {
+	a.writeConfirmFunc = fn
+}
+
+// SetResponseProcessor sets a transform applied to finalized assistant text before display.
+// When set, text is emitted once per finalized block instead of streamed; the conversation
+// sent back to the API always keeps the original text.
+func (a *Agent) SetResponseProcessor(processor hooks.TextProcessor) {
+	a.responseProcessor = processor
+}
+
+// GetModel returns the model used for requests: the current agent's model
+// if it sets one, otherwise the client's
+func (a *Agent) GetModel() string {
+	if a.model != "" {
+		return a.model
+	}
+	return a.client.GetModel()
+}
+
+// SetModel switches the model used for subsequent requests. It overrides the
+// current agent's model until the next agent switch.
+func (a *Agent) SetModel(model string) {
+	a.client.SetModel(model)
+	a.model = ""
+}
+
 // GetConversation returns the conversation
 func (a *Agent) GetConversation() *Conversation {
 	return a.conversation
@@ -115,6 +321,40 @@ func (a *Agent) GetCurrentAgent() string {
 	return a.currentAgent
 }
 
+// GetAgentRegistry returns the registry of available agents
+func (a *Agent) GetAgentRegistry() *agentregistry.Registry {
+	return a.agentRegistry
+}
+
+// GetPermissionRuleset returns the permission ruleset of the current agent
+func (a *Agent) GetPermissionRuleset() (permission.Ruleset, error) {
+	agentInfo, err := a.agentRegistry.Get(a.currentAgent)
+	if err != nil {
+		return permission.Ruleset{}, fmt.Errorf("failed to get current agent: %w", err)
+	}
+	return agentInfo.Permission, nil
+}
+
+// GetSessionApprovals returns the permissions approved for this session
+func (a *Agent) GetSessionApprovals() map[string]bool {
+	return a.permManager.GetSessionApprovals(a.sessionID)
+}
+
+// GetStepCount returns the number of model requests made in the current turn
+func (a *Agent) GetStepCount() int {
+	return a.stepCount
+}
+
+// maxSteps returns the step limit of the current agent
+func (a *Agent) maxSteps() int {
+	if info, err := a.agentRegistry.Get(a.currentAgent); err == nil {
+		if limit := info.StepLimit(); limit > 0 {
+			return limit
+		}
+	}
+	return DefaultMaxSteps
+}
+
 // GetTokenUsage returns the total token usage
 func (a *Agent) GetTokenUsage() (input, output, cacheRead, cacheWrite int) {
 	return a.totalInputTokens, a.totalOutputTokens, a.totalCacheReadTokens, a.totalCacheWriteTokens
@@ -126,6 +366,12 @@ func (a *Agent) trackTokens(usage api.Usage) {
 	a.totalOutputTokens += usage.OutputTokens
 	a.totalCacheReadTokens += usage.CacheReadInputTokens
 	a.totalCacheWriteTokens += usage.CacheCreationInputTokens
+	a.contextUsage = compaction.TokenUsage{
+		Input:      usage.InputTokens,
+		Output:     usage.OutputTokens,
+		CacheRead:  usage.CacheReadInputTokens,
+		CacheWrite: usage.CacheCreationInputTokens,
+	}
 
 	// Emit token usage event
 	a.emit(Event{
@@ -144,9 +390,12 @@ func (a *Agent) SwitchAgent(agentName string) error {
 
 	// Update current agent
 	a.currentAgent = agentName
+	a.temperature = newAgent.Temperature
+	a.stopSequences = newAgent.StopSequences
+	a.model = newAgent.Model
 
-	// Update system prompt
-	systemPrompt := newAgent.GetSystemPrompt(a.workDir)
+	// Update system prompt, keeping the project context
+	systemPrompt := newAgent.GetSystemPromptWithContext(a.workDir, a.agentRegistry.ProjectContext())
 	a.conversation.SetSystemMessage(systemPrompt)
 
 	// Emit agent switch event
@@ -160,22 +409,113 @@ func (a *Agent) SwitchAgent(agentName string) error {
 
 // emit emits an event to the handler
 func (a *Agent) emit(event Event) {
+	a.emitMu.Lock()
+	defer a.emitMu.Unlock()
 	if a.eventHandler != nil {
 		a.eventHandler(event)
 	}
 }
 
-// Chat sends a user message and processes the response
+// Chat sends a user message, with any attached images, and processes the response
 func (a *Agent) Chat(ctx context.Context, userMessage string) error {
 	// Add user message to conversation
-	a.conversation.AddUserMessage(userMessage)
+	a.imagesMu.Lock()
+	images := a.pendingImages
+	a.pendingImages = nil
+	a.imagesMu.Unlock()
+
+	if len(images) > 0 {
+		a.conversation.AddUserMessageWithImages(userMessage, images)
+	} else {
+		a.conversation.AddUserMessage(userMessage)
+	}
+	return a.runTurn(ctx)
+}
+
+// AttachImage queues a base64-encoded image to send with the next user message
+func (a *Agent) AttachImage(mediaType, data string) {
+	a.imagesMu.Lock()
+	defer a.imagesMu.Unlock()
+	a.pendingImages = append(a.pendingImages, api.NewImageContent(mediaType, data))
+}
+
+// PendingImages returns the number of images queued for the next user message
+func (a *Agent) PendingImages() int {
+	a.imagesMu.Lock()
+	defer a.imagesMu.Unlock()
+	return len(a.pendingImages)
+}
+
+// ClearImages drops the images queued for the next user message
+func (a *Agent) ClearImages() {
+	a.imagesMu.Lock()
+	defer a.imagesMu.Unlock()
+	a.pendingImages = nil
+}
+
+// ErrNothingToRetry is returned by Retry when there is no user message to respond to
+var ErrNothingToRetry = errors.New("nothing to retry: the conversation has no user message yet")
+
+// Retry discards the response to the last user message, including its tool
+// calls and results, and runs that turn again
+func (a *Agent) Retry(ctx context.Context) error {
+	removed, ok := a.conversation.PopLastExchange()
+	if !ok {
+		return ErrNothingToRetry
+	}
+	a.emit(Event{
+		Type: EventTypeRetry,
+		Text: fmt.Sprintf("Regenerating the last response (%d messages discarded)", len(removed)),
+	})
+	return a.runTurn(ctx)
+}
+
+// runTurn runs the agent loop for the user message at the end of the conversation
+func (a *Agent) runTurn(ctx context.Context) error {
+	// Each turn gets a fresh retry budget and step count
+	a.client.ResetRetryBudget()
+	a.stepCount = 0
+	a.stats.turn()
 
 	// Run the agent loop
-	return a.runLoop(ctx)
+	err := a.runLoop(ctx)
+
+	if a.turnHook != nil {
+		a.turnHook(a.conversation.GetMessages())
+	}
+	return err
+}
+
+// SetTurnHook sets a function called with the full history after every turn,
+// whether or not it succeeded (e.g. to save the session)
+func (a *Agent) SetTurnHook(hook func(messages []api.Message)) {
+	a.turnHook = hook
+}
+
+// SetCheckpointSteps also calls the turn hook every steps agent steps within
+// a turn, after the tool results are added (0 = only at the end of the turn)
+func (a *Agent) SetCheckpointSteps(steps int) {
+	a.checkpointSteps = steps
+}
+
+// LoadMessages replaces the conversation history, e.g. with a saved session.
+// Pinned notes and the system prompt are kept.
+func (a *Agent) LoadMessages(messages []api.Message) {
+	a.conversation.Clear()
+	for _, msg := range messages {
+		a.conversation.AddMessage(msg)
+	}
+	a.compactWarned = false
+	a.remeasureContext()
 }
 
 // runLoop runs the main agent loop until no more tool calls
 func (a *Agent) runLoop(ctx context.Context) error {
+	contextRetried := false
+	preflightCompacted := false // At most one proactive compaction per turn
+	capCompacted := false       // At most one max_messages compaction per turn
+	streamFailures := 0         // Consecutive responses that broke off mid-stream
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -183,19 +523,78 @@ func (a *Agent) runLoop(ctx context.Context) error {
 		default:
 		}
 
+		// Stop runaway tool loops
+		if limit := a.maxSteps(); a.stepCount >= limit {
+			err := fmt.Errorf("stopped after %d steps: the %s agent's step limit was reached; send a message to continue", a.stepCount, a.currentAgent)
+			a.emit(Event{Type: EventTypeError, Error: err})
+			return err
+		}
+		a.stepCount++
+		a.stats.request()
+
+		// Too many messages: summarize them whatever their size
+		if a.autoCompact && !capCompacted && a.maxMessages > 0 && a.conversation.MessageCount() > a.maxMessages {
+			capCompacted = true
+			a.emit(Event{
+				Type:           EventTypeCompaction,
+				CompactionInfo: fmt.Sprintf("Conversation has %d messages (max_messages is %d)", a.conversation.MessageCount(), a.maxMessages),
+			})
+			if err := a.compact(ctx, true, 0); err != nil {
+				if log := logger.GetLogger(); log != nil {
+					log.LogError("compaction_error", err, map[string]interface{}{
+						"session_id": a.sessionID,
+					})
+				}
+			}
+		}
+
 		// Build request
 		req := &api.MessagesRequest{
-			System:   a.conversation.GetSystemMessage(),
-			Messages: a.conversation.GetMessages(),
-			Tools:    a.registry.ToAPITools(),
+			Model:         a.model,
+			System:        a.conversation.BuildSystemPrompt(),
+			Messages:      a.conversation.GetMessages(),
+			Tools:         a.registry.ToAPITools(),
+			Temperature:   a.temperature,
+			StopSequences: a.stopSequences,
+		}
+
+		// Compact before sending if the request alone would nearly fill the context window
+		if a.autoCompact && !preflightCompacted && a.compactOversized(ctx, req) {
+			preflightCompacted = true
+			req.Messages = a.conversation.GetMessages()
 		}
 
 		// Stream the response
 		stream, err := a.client.StreamMessage(ctx, req)
 		if err != nil {
+			// Cancelled by the user: not an error worth reporting
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Context window exceeded: compact and retry once
+			if api.IsContextLengthError(err) {
+				if !contextRetried && a.autoCompact {
+					contextRetried = true
+					a.emit(Event{
+						Type:           EventTypeCompaction,
+						CompactionInfo: "Context window exceeded, compacting conversation and retrying...",
+					})
+					cerr := a.compact(ctx, true, 0)
+					if cerr == nil {
+						continue
+					}
+					if log := logger.GetLogger(); log != nil {
+						log.LogError("emergency_compaction_error", cerr, map[string]interface{}{
+							"session_id": a.sessionID,
+						})
+					}
+				}
+				err = fmt.Errorf("the conversation no longer fits in the model's context window; use /clear or start a new session (%w)", err)
+			}
 			a.emit(Event{Type: EventTypeError, Error: err})
 			return fmt.Errorf("failed to send message: %w", err)
 		}
+		contextRetried = false
 
 		// Process stream and collect response
 		content, toolCalls, err := a.processStream(ctx, stream)
@@ -208,10 +607,34 @@ func (a *Agent) runLoop(ctx context.Context) error {
 
 		stream.Close()
 
+		// A cancelled turn drops the partial response, keeping the history valid
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		if err != nil {
+			// Overloaded or rate limited mid-response: drop the partial
+			// output and send the same request again after a backoff
+			if retry.IsRetryable(err) {
+				streamFailures++
+				a.emit(Event{
+					Type: EventTypeStreamRetry,
+					Text: fmt.Sprintf("Response interrupted (%s); partial output discarded", api.DescribeError(err)),
+				})
+				werr := a.client.WaitToRetry(ctx, streamFailures, err)
+				if werr == nil {
+					a.stepCount--
+					continue
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				err = werr
+			}
 			a.emit(Event{Type: EventTypeError, Error: err})
 			return fmt.Errorf("failed to process stream: %w", err)
 		}
+		streamFailures = 0
 
 		// Add assistant response to conversation
 		if len(content) > 0 {
@@ -230,7 +653,12 @@ func (a *Agent) runLoop(ctx context.Context) error {
 
 		// If no tool calls, we're done
 		if len(toolCalls) == 0 {
-			a.emit(Event{Type: EventTypeConversationEnd})
+			end := Event{Type: EventTypeConversationEnd}
+			if streamResp != nil {
+				end.StopReason = streamResp.StopReason
+				end.StopSequence = streamResp.StopSequence
+			}
+			a.emit(end)
 			return nil
 		}
 
@@ -240,8 +668,17 @@ func (a *Agent) runLoop(ctx context.Context) error {
 			return fmt.Errorf("failed to execute tools: %w", err)
 		}
 
-		// Add tool results to conversation
+		// Add tool results to conversation. After a cancel this still answers
+		// every tool call, so the next turn can continue from here.
 		a.conversation.AddToolResults(toolResults)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if a.turnHook != nil && a.checkpointSteps > 0 && a.stepCount%a.checkpointSteps == 0 {
+			a.turnHook(a.conversation.GetMessages())
+		}
 	}
 }
 
@@ -267,11 +704,16 @@ func (a *Agent) processStream(ctx context.Context, stream *api.StreamReader) ([]
 		if err != nil {
 			return nil, nil, err
 		}
+		if log := logger.GetLogger(); log.StreamLogging() {
+			log.LogStreamChunk(chunk.Type, streamChunkFields(chunk))
+		}
 
 		switch chunk.Type {
 		case "text":
 			currentText.WriteString(chunk.Text)
-			a.emit(Event{Type: EventTypeText, Text: chunk.Text})
+			if a.responseProcessor == nil {
+				a.emit(Event{Type: EventTypeText, Text: chunk.Text})
+			}
 
 		case "tool_use_start":
 			// Finalize any pending text
@@ -280,6 +722,7 @@ func (a *Agent) processStream(ctx context.Context, stream *api.StreamReader) ([]
 					Type: api.ContentTypeText,
 					Text: currentText.String(),
 				})
+				a.emitProcessedText(currentText.String())
 				currentText.Reset()
 			}
 
@@ -292,10 +735,35 @@ func (a *Agent) processStream(ctx context.Context, stream *api.StreamReader) ([]
 				ToolID:   chunk.ContentBlock.ID,
 			})
 
+		case "thinking":
+			a.emit(Event{Type: EventTypeThinking, Text: chunk.Text})
+
 		case "tool_use_delta":
 			currentToolInput.WriteString(chunk.PartialJSON)
 
 		case "content_block_stop":
+			// Keep thinking blocks (with their signatures) so follow-up turns can send them back
+			resp := stream.GetResponse()
+			if chunk.Index < len(resp.Content) {
+				block := resp.Content[chunk.Index]
+				if block.Type == api.ContentTypeThinking || block.Type == api.ContentTypeRedactedThinking {
+					if currentText.Len() > 0 {
+						content = append(content, api.Content{
+							Type: api.ContentTypeText,
+							Text: currentText.String(),
+						})
+						a.emitProcessedText(currentText.String())
+						currentText.Reset()
+					}
+					content = append(content, api.Content{
+						Type:      block.Type,
+						Thinking:  block.Thinking,
+						Signature: block.Signature,
+						Data:      block.Data,
+					})
+				}
+			}
+
 			if currentToolIndex >= 0 && chunk.Index == currentToolIndex {
 				// Get the content block from the stream response
 				resp := stream.GetResponse()
@@ -330,6 +798,7 @@ func (a *Agent) processStream(ctx context.Context, stream *api.StreamReader) ([]
 					Type: api.ContentTypeText,
 					Text: currentText.String(),
 				})
+				a.emitProcessedText(currentText.String())
 			}
 
 		case "error":
@@ -340,105 +809,454 @@ func (a *Agent) processStream(ctx context.Context, stream *api.StreamReader) ([]
 	return content, toolCalls, nil
 }
 
-// executeToolCalls executes all tool calls and returns results
-func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []api.Content) ([]api.Content, error) {
-	var results []api.Content
+// emitProcessedText emits a finalized text block through the response processor, if any
+func (a *Agent) emitProcessedText(text string) {
+	if a.responseProcessor == nil {
+		return
+	}
+	a.emit(Event{Type: EventTypeText, Text: a.responseProcessor(text)})
+}
+
+// MaxParallelTools bounds how many read-only tool calls run at once
+const MaxParallelTools = 4
+
+// parallelTools are read-only tools whose calls may run concurrently
+var parallelTools = map[string]bool{
+	"Read":             true,
+	"Glob":             true,
+	"Grep":             true,
+	"List":             true,
+	"Tree":             true,
+	"WebFetch":         true,
+	"WebSearch":        true,
+	"read_tool_output": true,
+	"bash_logs":        true,
+	"bash_list":        true,
+}
 
+// toolRun is a tool call that passed its permission checks
+type toolRun struct {
+	index       int // Position of the call's result
+	call        api.Content
+	inputEdited bool // The user changed the input before approving
+}
+
+// executeToolCalls executes all tool calls and returns results in call order.
+// Runs of consecutive read-only calls execute concurrently; every other call
+// runs on its own, after the calls before it have finished.
+func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []api.Content) ([]api.Content, error) {
 	// Get current agent permissions
 	agentInfo, err := a.agentRegistry.Get(a.currentAgent)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current agent: %w", err)
 	}
 
+	var calls []api.Content
 	for _, call := range toolCalls {
-		if call.Type != api.ContentTypeToolUse {
+		if call.Type == api.ContentTypeToolUse {
+			calls = append(calls, call)
+		}
+	}
+	results := make([]api.Content, len(calls))
+
+	// Permission checks stay sequential; approved read-only calls wait here
+	var batch []toolRun
+	flush := func() {
+		a.runParallel(ctx, batch, results)
+		batch = nil
+	}
+
+	for i, call := range calls {
+		parallel := a.parallelTools && parallelTools[call.Name]
+		if !parallel {
+			flush()
+		}
+
+		// Once cancelled, skip the remaining calls but still answer them
+		if ctx.Err() != nil {
+			results[i] = a.rejectToolCall(call, "Cancelled by the user before this tool ran")
 			continue
 		}
 
-		// Log tool call
-		if log := logger.GetLogger(); log != nil {
-			var inputMap map[string]interface{}
-			json.Unmarshal(call.Input, &inputMap)
-			log.LogToolCall(call.Name, call.ID, inputMap)
+		// Nothing to approve for a call that will not run
+		if a.simulates(call) {
+			results[i] = a.simulateToolCall(call, agentInfo.Permission)
+			continue
+		}
+
+		run, rejected := a.prepareToolCall(ctx, call, agentInfo.Permission)
+		if rejected != nil {
+			results[i] = *rejected
+			continue
 		}
+		run.index = i
+
+		if parallel {
+			batch = append(batch, run)
+			continue
+		}
+		results[i] = a.runToolCall(ctx, run)
+	}
+	flush()
 
-		// Check permissions before execution
+	return results, nil
+}
+
+// prepareToolCall runs the permission and repeated-call checks for a call,
+// asking the user if needed. It returns the call to run, or the error result
+// to send instead if the call must not run.
+func (a *Agent) prepareToolCall(ctx context.Context, call api.Content, ruleset permission.Ruleset) (toolRun, *api.Content) {
+	// Log tool call
+	if log := logger.GetLogger(); log != nil {
 		var inputMap map[string]interface{}
 		json.Unmarshal(call.Input, &inputMap)
+		log.LogToolCall(call.Name, call.ID, inputMap)
+	}
 
-		// Extract pattern from input for permission check
-		pattern := extractPattern(call.Name, inputMap)
-		action := a.permEvaluator.Evaluate(call.Name, pattern, agentInfo.Permission)
+	// Check permissions before execution
+	var inputMap map[string]interface{}
+	json.Unmarshal(call.Input, &inputMap)
 
-		// Handle permission denial
-		if action == permission.ActionDeny {
-			output := fmt.Sprintf("Permission denied: agent '%s' is not allowed to use tool '%s' with pattern '%s'",
-				a.currentAgent, call.Name, pattern)
+	// Malformed calls, and paths refused by the path policy, go back to the
+	// model without asking anyone
+	if checked := a.registry.CheckCall(call.Name, inputMap); checked != nil {
+		result := a.rejectToolCall(call, checked.Output)
+		return toolRun{}, &result
+	}
 
-			a.emit(Event{
-				Type:       EventTypeToolUseEnd,
-				ToolName:   call.Name,
-				ToolID:     call.ID,
-				ToolResult: output,
-				IsError:    true,
-			})
+	// Extract pattern from input for permission check
+	pattern := extractPattern(call.Name, inputMap)
+	action := a.evaluatePermission(call.Name, inputMap, pattern, ruleset)
+
+	// Ask without anyone to ask resolves to the fallback
+	if action == permission.ActionAsk && (a.skipPermissions || a.askFunc == nil) {
+		if !a.skipPermissions && a.askFallback == permission.ActionDeny {
+			output := fmt.Sprintf("Permission denied: %s with pattern '%s' needs approval, and no one can approve it in this session (ask_fallback is deny)",
+				call.Name, pattern)
+			result := a.rejectToolCall(call, output)
+			return toolRun{}, &result
+		}
+		action = permission.ActionAllow
+	}
 
-			results = append(results, api.Content{
-				Type:      api.ContentTypeToolResult,
-				ToolUseID: call.ID,
-				Content:   output,
-				IsError:   true,
-			})
-			continue
+	// Handle permission denial
+	if action == permission.ActionDeny {
+		output := fmt.Sprintf("Permission denied: agent '%s' is not allowed to use tool '%s' with pattern '%s'",
+			a.currentAgent, call.Name, pattern)
+		result := a.rejectToolCall(call, output)
+		return toolRun{}, &result
+	}
+
+	// Ask the user, who may also edit the input before approving.
+	// Either way, repeated identical calls must be confirmed.
+	inputEdited := false
+	if action == permission.ActionAsk && a.askFunc != nil {
+		edited, err := a.askApproval(ctx, call, inputMap, pattern, ruleset)
+		if err != nil {
+			result := a.rejectToolCall(call, err.Error())
+			return toolRun{}, &result
+		}
+		if edited != nil {
+			call.Input = edited
+			inputEdited = true
+		}
+	} else if err := a.checkDoomLoop(call, inputMap, pattern); err != nil {
+		result := a.rejectToolCall(call, err.Error())
+		return toolRun{}, &result
+	}
+
+	// Show file changes for review before they are made
+	if a.writeConfirmFunc != nil && !a.skipPermissions {
+		if inputEdited {
+			json.Unmarshal(call.Input, &inputMap)
+		}
+		if err := a.confirmWrite(call, inputMap); err != nil {
+			result := a.rejectToolCall(call, err.Error())
+			return toolRun{}, &result
 		}
+	}
 
-		// Execute the tool
-		startTime := time.Now()
-		result, err := a.registry.Execute(ctx, call.Name, call.Input)
-		duration := time.Since(startTime)
+	return toolRun{call: call, inputEdited: inputEdited}, nil
+}
 
-		var output string
-		var isError bool
+// runParallel executes approved calls concurrently on a bounded pool,
+// storing each result at the call's index
+func (a *Agent) runParallel(ctx context.Context, batch []toolRun, results []api.Content) {
+	if len(batch) == 1 {
+		results[batch[0].index] = a.runToolCall(ctx, batch[0])
+		return
+	}
 
-		if err != nil {
-			output = err.Error()
-			isError = true
-		} else {
-			output = result.Output
-			isError = result.IsError
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, MaxParallelTools)
+	for _, run := range batch {
+		wg.Add(1)
+		go func(r toolRun) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[r.index] = a.runToolCall(ctx, r)
+		}(run)
+	}
+	wg.Wait()
+}
+
+// runToolCall executes an approved call and returns its result
+func (a *Agent) runToolCall(ctx context.Context, run toolRun) api.Content {
+	call := run.call
+
+	// Execute the tool
+	a.emit(Event{Type: EventTypeToolRunning, ToolName: call.Name, ToolID: call.ID})
+	ctx = tools.WithProgress(ctx, func(p tools.Progress) {
+		a.emit(Event{Type: EventTypeToolProgress, ToolName: call.Name, ToolID: call.ID, Progress: &p})
+	})
+	startTime := time.Now()
+	result, err := a.registry.Execute(ctx, call.Name, call.Input)
+	duration := time.Since(startTime)
+
+	var output string
+	var isError bool
+	var blocks []api.Content
+
+	if err != nil {
+		output = err.Error()
+		isError = true
+	} else {
+		output = result.Output
+		isError = result.IsError
+		for _, img := range result.Images {
+			blocks = append(blocks, api.NewImageContent(img.MediaType, img.Data))
 		}
+	}
 
-		// Apply output truncation if needed
-		output = a.truncateOutput(output, call.Name, call.ID)
+	// The model's tool_use block still holds the original input
+	if run.inputEdited {
+		output = fmt.Sprintf("[The user edited the input before approving. Executed with: %s]\n%s", string(call.Input), output)
+	}
 
-		// Log tool result
-		if log := logger.GetLogger(); log != nil {
-			log.LogToolResult(call.Name, call.ID, output, isError, duration)
+	// Scan the full output for injected instructions before truncating it
+	var findings []injection.Finding
+	if a.injectionScan {
+		findings = injection.Scan(output)
+	}
+
+	// Apply output truncation if needed
+	output = a.truncateOutput(output, call.Name, call.ID)
+
+	if len(findings) > 0 {
+		output = injection.Wrap(call.Name, output, findings)
+	}
+
+	// Log tool result
+	if log := logger.GetLogger(); log != nil {
+		log.LogToolResult(call.Name, call.ID, output, isError, duration)
+	}
+	a.stats.toolCall(call.Name, call.Input, duration, isError)
+
+	a.emit(Event{
+		Type:       EventTypeToolUseEnd,
+		ToolName:   call.Name,
+		ToolID:     call.ID,
+		ToolInput:  string(call.Input),
+		ToolResult: output,
+		IsError:    isError,
+	})
+
+	return api.Content{
+		Type:      api.ContentTypeToolResult,
+		ToolUseID: call.ID,
+		Content:   output,
+		IsError:   isError,
+		Blocks:    blocks,
+	}
+}
+
+// rejectToolCall reports a tool call that was not executed and returns its error result
+func (a *Agent) rejectToolCall(call api.Content, output string) api.Content {
+	a.emit(Event{
+		Type:       EventTypeToolUseEnd,
+		ToolName:   call.Name,
+		ToolID:     call.ID,
+		ToolInput:  string(call.Input),
+		ToolResult: output,
+		IsError:    true,
+	})
+
+	return api.Content{
+		Type:      api.ContentTypeToolResult,
+		ToolUseID: call.ID,
+		Content:   output,
+		IsError:   true,
+	}
+}
+
+// checkDoomLoop asks the user to confirm a call that repeats an earlier one
+// with identical input. It returns an error if the call must not run.
+func (a *Agent) checkDoomLoop(call api.Content, inputMap map[string]interface{}, pattern string) error {
+	loopConfirm := a.loopConfirmFunc
+	if a.skipPermissions {
+		loopConfirm = nil
+	}
+	err := a.permManager.CheckDoomLoop(permission.CheckInput{
+		SessionID:  a.sessionID,
+		Permission: call.Name,
+		Pattern:    pattern,
+		Args:       inputMap,
+		AskFunc:    loopConfirm,
+	})
+	if permission.IsRejectedError(err) {
+		return fmt.Errorf("The user stopped this %s call because it repeats earlier calls with identical input. Try a different approach.", call.Name)
+	}
+	return err
+}
+
+// confirmWrite shows the diff of a file tool call to the user. It returns an
+// error if the user rejected it. Calls that change nothing, or would fail
+// anyway and report their own error, are let through.
+func (a *Agent) confirmWrite(call api.Content, inputMap map[string]interface{}) error {
+	change, err := a.registry.PreviewChange(call.Name, inputMap)
+	if err != nil || change == nil || change.Diff == "" || a.approvedWrites[change.Path] {
+		return nil
+	}
+
+	resp, err := a.writeConfirmFunc(permission.AskRequest{
+		Permission: call.Name,
+		Pattern:    change.Path,
+		Message:    fmt.Sprintf("Agent '%s' wants to change %s", a.currentAgent, change.Path),
+		Diff:       change.Diff,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Approved {
+		return fmt.Errorf("The user rejected this change to %s after reviewing the diff. Do not retry it unchanged; ask the user what they want instead.", change.Path)
+	}
+	if resp.Always {
+		if a.approvedWrites == nil {
+			a.approvedWrites = make(map[string]bool)
 		}
+		a.approvedWrites[change.Path] = true
+	}
+	return nil
+}
 
-		a.emit(Event{
-			Type:       EventTypeToolUseEnd,
-			ToolName:   call.Name,
-			ToolID:     call.ID,
-			ToolResult: output,
-			IsError:    isError,
-		})
+// askApproval asks the user to approve a tool call, first confirming it if
+// it repeats earlier identical calls. It returns the edited input if the
+// user changed it (nil otherwise), or an error if the call must not run.
+func (a *Agent) askApproval(ctx context.Context, call api.Content, inputMap map[string]interface{}, pattern string, ruleset permission.Ruleset) (json.RawMessage, error) {
+	message := fmt.Sprintf("Agent '%s' wants to use %s", a.currentAgent, call.Name)
+	if note := a.registry.ApprovalNote(call.Name, inputMap); note != "" {
+		message += " (" + note + ")"
+	}
+
+	var editedInput string
+	err := a.permManager.Check(ctx, permission.CheckInput{
+		SessionID:  a.sessionID,
+		Permission: call.Name,
+		Pattern:    pattern,
+		Args:       inputMap,
+		Ruleset:    ruleset,
+		Message:    message,
+		Input:      string(call.Input),
+		AskFunc: func(req permission.AskRequest) (permission.AskResponse, error) {
+			resp, err := a.askFunc(req)
+			editedInput = resp.EditedInput
+			return resp, err
+		},
+	})
+	if err != nil {
+		if permission.IsRejectedError(err) {
+			return nil, fmt.Errorf("The user did not approve this %s call", call.Name)
+		}
+		return nil, err
+	}
 
-		results = append(results, api.Content{
-			Type:      api.ContentTypeToolResult,
-			ToolUseID: call.ID,
-			Content:   output,
-			IsError:   isError,
-		})
+	if editedInput == "" || editedInput == string(call.Input) {
+		return nil, nil
 	}
 
-	return results, nil
+	var editedMap map[string]interface{}
+	if err := json.Unmarshal([]byte(editedInput), &editedMap); err != nil {
+		return nil, fmt.Errorf("The user's edited input for %s is not a valid JSON object: %v", call.Name, err)
+	}
+
+	// The edited call must still pass the deny rules
+	editedPattern := extractPattern(call.Name, editedMap)
+	if a.permManager.Evaluate(call.Name, editedPattern, ruleset) == permission.ActionDeny {
+		return nil, fmt.Errorf("Permission denied: agent '%s' is not allowed to use tool '%s' with pattern '%s'",
+			a.currentAgent, call.Name, editedPattern)
+	}
+
+	return json.RawMessage(editedInput), nil
+}
+
+// streamChunkFields returns the set fields of a stream chunk for logging
+func streamChunkFields(chunk *api.StreamChunk) map[string]interface{} {
+	fields := map[string]interface{}{"index": chunk.Index}
+	if chunk.Text != "" {
+		fields["text"] = chunk.Text
+	}
+	if chunk.PartialJSON != "" {
+		fields["partial_json"] = chunk.PartialJSON
+	}
+	if chunk.ContentBlock != nil {
+		fields["block_type"] = chunk.ContentBlock.Type
+		fields["tool_name"] = chunk.ContentBlock.Name
+		fields["tool_id"] = chunk.ContentBlock.ID
+	}
+	if chunk.StopReason != "" {
+		fields["stop_reason"] = chunk.StopReason
+	}
+	if chunk.StopSequence != "" {
+		fields["stop_sequence"] = chunk.StopSequence
+	}
+	if chunk.Error != nil {
+		fields["error"] = chunk.Error.Error()
+	}
+	return fields
+}
+
+// evaluatePermission returns the ruleset's action for a call with pattern.
+// A patch is checked file by file and gets the strictest action, so one
+// allowed file cannot carry a denied one along.
+func (a *Agent) evaluatePermission(toolName string, input map[string]interface{}, pattern string, ruleset permission.Ruleset) permission.Action {
+	if toolName != "ApplyPatch" {
+		return a.permManager.Evaluate(toolName, pattern, ruleset)
+	}
+	patch, _ := input["patch"].(string)
+	paths := tools.PatchPaths(patch)
+	if len(paths) == 0 {
+		return a.permManager.Evaluate(toolName, "*", ruleset)
+	}
+	action := permission.ActionAllow
+	for _, path := range paths {
+		switch a.permManager.Evaluate(toolName, path, ruleset) {
+		case permission.ActionDeny:
+			return permission.ActionDeny
+		case permission.ActionAsk:
+			action = permission.ActionAsk
+		}
+	}
+	return action
 }
 
 // extractPattern extracts the pattern from tool input for permission checking
 func extractPattern(toolName string, input map[string]interface{}) string {
-	switch toolName {
-	case "read", "write", "edit":
+	switch strings.ToLower(toolName) {
+	case "applypatch":
+		// Several paths are bracketed so that no rule for a single path
+		// matches the list; evaluatePermission checks them one by one
+		patch, _ := input["patch"].(string)
+		switch paths := tools.PatchPaths(patch); len(paths) {
+		case 0:
+		case 1:
+			return paths[0]
+		default:
+			return "[" + strings.Join(paths, ", ") + "]"
+		}
+	case "read", "write", "edit", "multiedit":
 		if path, ok := input["file_path"].(string); ok {
 			return path
 		}
@@ -454,26 +1272,155 @@ func extractPattern(toolName string, input map[string]interface{}) string {
 		if pattern, ok := input["pattern"].(string); ok {
 			return pattern
 		}
+	case "gitbranch":
+		if action, ok := input["action"].(string); ok {
+			if branch, ok := input["branch"].(string); ok && branch != "" {
+				return action + " " + branch
+			}
+			return action
+		}
 	}
 	return "*"
 }
 
 // checkAndCompact checks if compaction is needed and performs it
 func (a *Agent) checkAndCompact(ctx context.Context) error {
-	// Calculate current token usage
-	usage := compaction.TokenUsage{
-		Input:     a.totalInputTokens,
-		Output:    a.totalOutputTokens,
-		CacheRead: a.totalCacheReadTokens,
-	}
+	usage := a.contextUsage
+	limits := a.contextLimits()
+
+	a.warnHeadroom(usage, limits)
 
-	limits := compaction.DefaultModelLimits()
+	if !compaction.NeedsCompaction(usage, limits, a.compactThreshold) {
+		a.compactWarned = false
+		return nil
+	}
 
-	// Check if we need compaction (80% threshold)
-	if !compaction.NeedsCompaction(usage, limits) {
+	// Auto-compaction disabled: warn once instead of acting
+	if !a.autoCompact {
+		if !a.compactWarned {
+			a.compactWarned = true
+			a.emit(Event{
+				Type: EventTypeCompaction,
+				CompactionInfo: fmt.Sprintf("Warning: context is %.0f%% full and auto-compaction is disabled. Use /clear or start a new session to free up context.",
+					compaction.UsagePercentage(usage, limits)),
+			})
+		}
 		return nil
 	}
 
+	return a.compact(ctx, false, 0)
+}
+
+// warnHeadroom reports when the context passes the warning threshold, so
+// the user can /compact or wrap up before compaction reshapes the history,
+// and again when it drops back below it
+func (a *Agent) warnHeadroom(usage compaction.TokenUsage, limits compaction.ModelLimits) {
+	near := compaction.NeedsWarning(usage, limits, a.warnThreshold)
+	if near == a.nearLimit {
+		return
+	}
+	a.nearLimit = near
+
+	event := Event{Type: EventTypeContextWarning}
+	if near {
+		percent := compaction.UsagePercentage(usage, limits)
+		if a.autoCompact {
+			event.Text = fmt.Sprintf("Context is %.0f%% full; it will be compacted automatically at %.0f%%. Use /compact or wrap up the current task.",
+				percent, a.compactThreshold*100)
+		} else {
+			event.Text = fmt.Sprintf("Context is %.0f%% full. Use /compact or /clear to free up space.", percent)
+		}
+	}
+	a.emit(event)
+}
+
+// remeasureContext estimates the context after the history changed without
+// a response (compaction, a loaded session or a restored checkpoint), so
+// the warning and the next compaction check see the new size
+func (a *Agent) remeasureContext() {
+	a.contextUsage = compaction.TokenUsage{
+		Input: compaction.EstimateTokens(a.conversation.GetMessages(), a.conversation.BuildSystemPrompt(), a.registry.ToAPITools()),
+	}
+	a.warnHeadroom(a.contextUsage, a.contextLimits())
+}
+
+// contextLimits returns the current model's context window, reserving room
+// for the max_tokens each response may use
+func (a *Agent) contextLimits() compaction.ModelLimits {
+	return compaction.LimitsForModel(a.GetModel()).WithOutputReserve(a.outputReserve)
+}
+
+// compactOversized compacts the conversation when the size of req, counted
+// by the API or else estimated, is over the compaction threshold, reporting
+// whether it changed anything. This catches a single huge message before
+// the API rejects it, and compacts before the request rather than after.
+// It measures the same context checkAndCompact does, against the same
+// limits: the whole request, rather than the last response's usage.
+func (a *Agent) compactOversized(ctx context.Context, req *api.MessagesRequest) bool {
+	limits := a.contextLimits()
+	tokens, exact := a.requestTokens(ctx, req, limits)
+	if !compaction.EstimateNeedsCompaction(tokens, limits, a.compactThreshold) {
+		return false
+	}
+
+	size := fmt.Sprintf("estimated at ~%d", tokens)
+	if exact {
+		size = fmt.Sprintf("%d", tokens)
+	}
+	a.emit(Event{
+		Type:           EventTypeCompaction,
+		CompactionInfo: fmt.Sprintf("Request is %s tokens, compacting before sending...", size),
+	})
+
+	// Pruning first; summarize too if that was not enough
+	err := a.compact(ctx, false, 0)
+	if err == nil {
+		pruned := *req
+		pruned.Messages = a.conversation.GetMessages()
+		if after, _ := a.requestTokens(ctx, &pruned, limits); compaction.EstimateNeedsCompaction(after, limits, a.compactThreshold) {
+			err = a.compact(ctx, true, 0)
+		}
+	}
+	if err != nil {
+		// Send anyway; a context length error is still handled after the request
+		if log := logger.GetLogger(); log != nil {
+			log.LogError("preflight_compaction_error", err, map[string]interface{}{
+				"session_id": a.sessionID,
+				"tokens":     tokens,
+				"exact":      exact,
+			})
+		}
+	}
+	return true
+}
+
+// DefaultKeepRecent is how many recent exchanges compaction keeps verbatim
+const DefaultKeepRecent = 2
+
+// ErrNothingToCompact is returned by Compact when the conversation is too
+// short to compact
+var ErrNothingToCompact = errors.New("nothing to compact")
+
+// Compact compacts the conversation now instead of waiting for the
+// threshold: old tool output is pruned and everything except the last
+// keepRecent exchanges (0 = DefaultKeepRecent) is summarized
+func (a *Agent) Compact(ctx context.Context, keepRecent int) error {
+	if keepRecent <= 0 {
+		keepRecent = DefaultKeepRecent
+	}
+	if a.conversation.MessageCount() <= keepRecent*2 {
+		return ErrNothingToCompact
+	}
+	return a.compact(ctx, true, keepRecent)
+}
+
+// compact prunes old tool output and, if that is not enough or force is set,
+// summarizes all but the last keepRecent exchanges (0 = DefaultKeepRecent)
+func (a *Agent) compact(ctx context.Context, force bool, keepRecent int) error {
+	if keepRecent <= 0 {
+		keepRecent = DefaultKeepRecent
+	}
+
 	// Emit compaction start event
 	a.emit(Event{
 		Type:           EventTypeCompaction,
@@ -490,26 +1437,33 @@ func (a *Agent) checkAndCompact(ctx context.Context) error {
 			for _, msg := range pruneResult.Messages {
 				a.conversation.AddMessage(msg)
 			}
+			messages = pruneResult.Messages
 
 			info := fmt.Sprintf("Pruned %d tool results (%d chars)", pruneResult.PrunedCount, pruneResult.PrunedChars)
 			a.emit(Event{
 				Type:           EventTypeCompaction,
 				CompactionInfo: info,
 			})
-			return nil
+			if !force {
+				a.remeasureContext()
+				return nil
+			}
 		}
 	}
 
 	// If pruning not enough, do full compaction
 	compactResult, err := a.compactor.Compact(ctx, compaction.CompactInput{
 		Messages:   messages,
-		Model:      a.client.GetModel(),
+		Model:      a.GetModel(),
 		MaxTokens:  4000,
-		KeepRecent: 2,
+		KeepRecent: keepRecent,
 	})
 	if err != nil {
 		return fmt.Errorf("compaction failed: %w", err)
 	}
+	if force && compactResult.CompactedCount == 0 {
+		return fmt.Errorf("compaction failed: conversation is too short to compact")
+	}
 
 	// Replace conversation with compacted version
 	a.conversation.Clear()
@@ -522,13 +1476,49 @@ func (a *Agent) checkAndCompact(ctx context.Context) error {
 		Type:           EventTypeCompaction,
 		CompactionInfo: info,
 	})
+	a.remeasureContext()
 
 	return nil
 }
 
-// truncateOutput truncates tool output if needed
+// Summarize asks the model for a progress summary of the conversation
+// without modifying it
+func (a *Agent) Summarize(ctx context.Context) (string, error) {
+	return a.compactor.Summarize(ctx, a.conversation.GetMessages(), a.GetModel(), 0)
+}
+
+// GenerateTitle asks the model for a short session title for a first message
+func (a *Agent) GenerateTitle(ctx context.Context, firstMessage string) (string, error) {
+	return a.compactor.Title(ctx, firstMessage, a.GetModel())
+}
+
+// SavedOutputs lists the full outputs saved for this agent's truncated tool results
+func (a *Agent) SavedOutputs() []compaction.SavedOutput {
+	return compaction.SessionOutputs(a.sessionID)
+}
+
+// CleanupOutputs deletes the saved full outputs, typically when the session ends
+func (a *Agent) CleanupOutputs() error {
+	return compaction.CleanupOutputs(a.sessionID)
+}
+
+// SavedOutputPath returns the file holding the full output of a truncated
+// tool call from any agent in this process
+func SavedOutputPath(callID string) (string, bool) {
+	saved, ok := compaction.LookupOutput(callID)
+	return saved.Path, ok
+}
+
+// truncateOutput truncates tool output if needed, keeping the head and, for
+// tools like Bash whose errors come last, the tail
 func (a *Agent) truncateOutput(output string, toolName string, callID string) string {
-	result := compaction.TruncateOutput(output, a.sessionID, toolName, callID)
+	policy, ok := a.truncatePolicies[toolName]
+	if !ok {
+		if policy, ok = compaction.DefaultToolTruncatePolicies[toolName]; !ok {
+			policy = compaction.DefaultTruncatePolicy
+		}
+	}
+	result := compaction.TruncateWithPolicy(output, policy, a.sessionID, toolName, callID)
 	if result.Truncated {
 		return result.Content
 	}
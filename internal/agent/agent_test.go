@@ -0,0 +1,266 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/agentregistry"
+	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/permission"
+	"github.com/anthropics/claude-code-go/internal/retry"
+	"github.com/anthropics/claude-code-go/internal/tools"
+)
+
+// fakeClient is a MessageClient that records requests and counts tokens
+type fakeClient struct {
+	model string
+
+	mu       sync.Mutex
+	requests []*api.MessagesRequest
+	counts   int // CountTokens calls
+}
+
+func (c *fakeClient) CreateMessage(ctx context.Context, req *api.MessagesRequest) (*api.MessagesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	return &api.MessagesResponse{Content: []api.Content{{Type: api.ContentTypeText, Text: "ok"}}}, nil
+}
+
+func (c *fakeClient) StreamMessage(ctx context.Context, req *api.MessagesRequest) (*api.StreamReader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	return api.NewStreamReader(io.NopCloser(strings.NewReader(textStream("ok")))), nil
+}
+
+func (c *fakeClient) CountTokens(ctx context.Context, req *api.MessagesRequest) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts++
+	return 42, nil
+}
+
+func (c *fakeClient) GetModel() string                                              { return c.model }
+func (c *fakeClient) SetModel(model string)                                         { c.model = model }
+func (c *fakeClient) GetBaseURL() string                                            { return "" }
+func (c *fakeClient) ResetRetryBudget()                                             {}
+func (c *fakeClient) SetRateLimitCallback(fn func(api.RateLimitStatus))             {}
+func (c *fakeClient) SetRetryCallback(fn func(retry.RetryStats))                    {}
+func (c *fakeClient) WaitToRetry(ctx context.Context, attempt int, err error) error { return err }
+
+// textStream is the SSE stream of a response that says text and ends the turn
+func textStream(text string) string {
+	quoted, _ := json.Marshal(text)
+	return "event: message_start\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":1,"output_tokens":0}}}` + "\n\n" +
+		"event: content_block_start\n" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":` + string(quoted) + `}}` + "\n\n" +
+		"event: content_block_stop\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":1}}` + "\n\n" +
+		"event: message_stop\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+}
+
+// fakeTool records the inputs it is executed with
+type fakeTool struct {
+	name string
+
+	mu    sync.Mutex
+	calls []map[string]interface{}
+}
+
+func (t *fakeTool) Name() string        { return t.name }
+func (t *fakeTool) Description() string { return "fake " + t.name }
+func (t *fakeTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+func (t *fakeTool) Execute(ctx context.Context, params map[string]interface{}) (*tools.Result, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, params)
+	return tools.NewResult("ok"), nil
+}
+
+func (t *fakeTool) callCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.calls)
+}
+
+// newTestAgent returns an agent with the built-in agents and the given tools
+func newTestAgent(t *testing.T, client api.MessageClient, toolList ...tools.Tool) *Agent {
+	t.Helper()
+	agents := agentregistry.NewRegistry()
+	if err := agentregistry.RegisterBuiltinAgents(agents); err != nil {
+		t.Fatalf("RegisterBuiltinAgents: %v", err)
+	}
+	registry := tools.NewRegistry()
+	for _, tool := range toolList {
+		if err := registry.Register(tool); err != nil {
+			t.Fatalf("Register %s: %v", tool.Name(), err)
+		}
+	}
+	return NewAgent(client, registry, agents, t.TempDir())
+}
+
+// toolUse builds a tool_use block for name with input
+func toolUse(id, name string, input map[string]interface{}) api.Content {
+	raw, _ := json.Marshal(input)
+	return api.Content{Type: api.ContentTypeToolUse, ID: id, Name: name, Input: raw}
+}
+
+// runCalls executes calls and fails the test on an agent error
+func runCalls(t *testing.T, a *Agent, calls ...api.Content) []api.Content {
+	t.Helper()
+	results, err := a.executeToolCalls(context.Background(), calls)
+	if err != nil {
+		t.Fatalf("executeToolCalls: %v", err)
+	}
+	return results
+}
+
+func TestAskFlowAllowRuleRunsWithoutAsking(t *testing.T) {
+	read := &fakeTool{name: "Read"}
+	a := newTestAgent(t, nil, read)
+	asked := 0
+	a.SetAskFunc(func(permission.AskRequest) (permission.AskResponse, error) {
+		asked++
+		return permission.AskResponse{Rejected: true}, nil
+	})
+
+	results := runCalls(t, a, toolUse("1", "Read", map[string]interface{}{"file_path": "main.go"}))
+
+	if asked != 0 {
+		t.Errorf("Read was asked about %d times, want 0 (build allows read)", asked)
+	}
+	if results[0].IsError || read.callCount() != 1 {
+		t.Errorf("Read did not run: %+v", results[0])
+	}
+}
+
+func TestAskFlowRejectedCallDoesNotRun(t *testing.T) {
+	bash := &fakeTool{name: "Bash"}
+	a := newTestAgent(t, nil, bash)
+	var req permission.AskRequest
+	a.SetAskFunc(func(r permission.AskRequest) (permission.AskResponse, error) {
+		req = r
+		return permission.AskResponse{Rejected: true}, nil
+	})
+
+	results := runCalls(t, a, toolUse("1", "Bash", map[string]interface{}{"command": "rm -rf build"}))
+
+	if req.Pattern != "rm -rf build" {
+		t.Errorf("asked about pattern %q, want the command", req.Pattern)
+	}
+	if !results[0].IsError || !strings.Contains(results[0].Content, "did not approve") {
+		t.Errorf("rejected call result = %+v, want an approval error", results[0])
+	}
+	if bash.callCount() != 0 {
+		t.Error("rejected Bash call was executed")
+	}
+}
+
+func TestAskFlowAlwaysRecordsSessionApproval(t *testing.T) {
+	bash := &fakeTool{name: "Bash"}
+	a := newTestAgent(t, nil, bash)
+	asked := 0
+	a.SetAskFunc(func(permission.AskRequest) (permission.AskResponse, error) {
+		asked++
+		return permission.AskResponse{Approved: true, Always: true}, nil
+	})
+
+	call := toolUse("1", "Bash", map[string]interface{}{"command": "rm -rf build"})
+	runCalls(t, a, call)
+	runCalls(t, a, call)
+
+	if asked != 1 {
+		t.Errorf("asked %d times, want 1 after choosing always", asked)
+	}
+	if bash.callCount() != 2 {
+		t.Errorf("Bash ran %d times, want 2", bash.callCount())
+	}
+	if !a.GetSessionApprovals()["Bash:rm -rf build"] {
+		t.Errorf("session approvals = %v, want Bash:rm -rf build", a.GetSessionApprovals())
+	}
+}
+
+func TestAskFlowDenyRuleWinsOverSkipPermissions(t *testing.T) {
+	bash := &fakeTool{name: "Bash"}
+	a := newTestAgent(t, nil, bash)
+	a.SetSkipPermissions(true)
+
+	results := runCalls(t, a, toolUse("1", "Bash", map[string]interface{}{"command": "sudo reboot"}))
+
+	if !results[0].IsError || !strings.Contains(results[0].Content, "Permission denied") {
+		t.Errorf("sudo result = %+v, want permission denied", results[0])
+	}
+	if bash.callCount() != 0 {
+		t.Error("denied Bash call was executed")
+	}
+}
+
+// validatePanicTool panics while validating its input
+type validatePanicTool struct {
+	fakeTool
+}
+
+func (t *validatePanicTool) Validate(params map[string]interface{}) error {
+	var m map[string]int
+	m["x"] = 1
+	return nil
+}
+
+func TestToolCallSurvivesValidatePanic(t *testing.T) {
+	tool := &validatePanicTool{fakeTool{name: "Read"}}
+	a := newTestAgent(t, nil, tool)
+
+	results := runCalls(t, a, toolUse("1", "Read", map[string]interface{}{"file_path": "main.go"}))
+
+	if !results[0].IsError || !strings.Contains(results[0].Content, "crashed") {
+		t.Errorf("result = %+v, want the validation panic reported", results[0])
+	}
+	if tool.callCount() != 0 {
+		t.Error("tool ran after its validation panicked")
+	}
+}
+
+func TestEditedInputReachesExecute(t *testing.T) {
+	bash := &fakeTool{name: "Bash"}
+	a := newTestAgent(t, nil, bash)
+	a.SetAskFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+		return permission.AskResponse{Approved: true, EditedInput: `{"command":"rm -rf build/tmp"}`}, nil
+	})
+
+	results := runCalls(t, a, toolUse("1", "Bash", map[string]interface{}{"command": "rm -rf build"}))
+
+	if bash.callCount() != 1 || bash.calls[0]["command"] != "rm -rf build/tmp" {
+		t.Fatalf("Bash executed with %v, want the edited command", bash.calls)
+	}
+	if !strings.Contains(results[0].Content, "The user edited the input") {
+		t.Errorf("result %q does not tell the model about the edit", results[0].Content)
+	}
+}
+
+func TestEditedInputStillChecksDenyRules(t *testing.T) {
+	bash := &fakeTool{name: "Bash"}
+	a := newTestAgent(t, nil, bash)
+	a.SetAskFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+		return permission.AskResponse{Approved: true, EditedInput: `{"command":"sudo rm -rf build"}`}, nil
+	})
+
+	results := runCalls(t, a, toolUse("1", "Bash", map[string]interface{}{"command": "rm -rf build"}))
+
+	if bash.callCount() != 0 || !results[0].IsError {
+		t.Errorf("edited call into a denied command ran: %+v", results[0])
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+func TestCancelledChatReturnsPromptly(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never answer until the client goes away
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	a := newTestAgent(t, api.NewClient("key", api.WithBaseURL(server.URL)))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() { done <- a.Chat(ctx, "hello") }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Chat error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Chat did not return after its context was cancelled")
+	}
+
+	// The history stays valid for the next message
+	if msgs := a.GetConversation().GetMessages(); len(msgs) > 0 && msgs[len(msgs)-1].Role == api.RoleAssistant {
+		t.Errorf("cancelled turn left an assistant message: %+v", msgs[len(msgs)-1])
+	}
+}
+
+func TestCancelledTurnSkipsRemainingToolCalls(t *testing.T) {
+	read := &fakeTool{name: "Read"}
+	a := newTestAgent(t, nil, read)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := a.executeToolCalls(ctx, []api.Content{
+		toolUse("1", "Read", map[string]interface{}{"file_path": "a.go"}),
+		toolUse("2", "Read", map[string]interface{}{"file_path": "b.go"}),
+	})
+	if err != nil {
+		t.Fatalf("executeToolCalls: %v", err)
+	}
+	if read.callCount() != 0 {
+		t.Errorf("%d tool calls ran after cancellation, want 0", read.callCount())
+	}
+	for _, r := range results {
+		if !r.IsError || !strings.Contains(r.Content, "Cancelled") {
+			t.Errorf("result %+v, want a cancellation error", r)
+		}
+	}
+}
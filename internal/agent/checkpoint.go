@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+// Checkpoint is a named snapshot of the conversation history
+type Checkpoint struct {
+	Name     string
+	Messages []api.Message
+	Created  time.Time
+}
+
+// SaveCheckpoint snapshots the conversation under name, replacing an earlier
+// checkpoint of the same name. An empty name becomes the first free
+// "checkpoint-N", so it never replaces one.
+func (a *Agent) SaveCheckpoint(name string) Checkpoint {
+	if name == "" {
+		for n := len(a.checkpoints) + 1; ; n++ {
+			name = fmt.Sprintf("checkpoint-%d", n)
+			if !a.hasCheckpoint(name) {
+				break
+			}
+		}
+	}
+	cp := Checkpoint{
+		Name:     name,
+		Messages: a.conversation.Snapshot(),
+		Created:  time.Now(),
+	}
+	for i, existing := range a.checkpoints {
+		if existing.Name == name {
+			a.checkpoints = append(a.checkpoints[:i], a.checkpoints[i+1:]...)
+			break
+		}
+	}
+	a.checkpoints = append(a.checkpoints, cp)
+	return cp
+}
+
+// hasCheckpoint reports whether a checkpoint called name exists
+func (a *Agent) hasCheckpoint(name string) bool {
+	for _, cp := range a.checkpoints {
+		if cp.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreCheckpoint puts the conversation back to the checkpoint called name.
+// The checkpoint is kept, so it can be restored again.
+func (a *Agent) RestoreCheckpoint(name string) (Checkpoint, error) {
+	for _, cp := range a.checkpoints {
+		if cp.Name == name {
+			a.conversation.Restore(cp.Messages)
+			a.compactWarned = false
+			a.remeasureContext()
+			return cp, nil
+		}
+	}
+	return Checkpoint{}, fmt.Errorf("no checkpoint named %q", name)
+}
+
+// Checkpoints returns the saved checkpoints, oldest first
+func (a *Agent) Checkpoints() []Checkpoint {
+	checkpoints := make([]Checkpoint, len(a.checkpoints))
+	copy(checkpoints, a.checkpoints)
+	return checkpoints
+}
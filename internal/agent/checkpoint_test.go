@@ -0,0 +1,32 @@
+package agent
+
+import "testing"
+
+func TestSaveCheckpointDefaultNameNeverReplaces(t *testing.T) {
+	a := newTestAgent(t, &fakeClient{})
+
+	a.conversation.AddUserMessage("first")
+	a.SaveCheckpoint("checkpoint-2") // Named by the user like a default
+	a.conversation.AddUserMessage("second")
+	auto := a.SaveCheckpoint("")
+
+	if auto.Name == "checkpoint-2" {
+		t.Fatalf("default name %q replaced the user's checkpoint", auto.Name)
+	}
+	if got := len(a.Checkpoints()); got != 2 {
+		t.Fatalf("%d checkpoints, want 2", got)
+	}
+	cp, err := a.RestoreCheckpoint("checkpoint-2")
+	if err != nil {
+		t.Fatalf("RestoreCheckpoint: %v", err)
+	}
+	if len(cp.Messages) != 1 {
+		t.Errorf("checkpoint-2 has %d messages, want the 1 it was saved with", len(cp.Messages))
+	}
+
+	// An explicit name still replaces, keeping one checkpoint per name
+	a.SaveCheckpoint("checkpoint-2")
+	if got := len(a.Checkpoints()); got != 2 {
+		t.Errorf("%d checkpoints after saving checkpoint-2 again, want 2", got)
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/compaction"
+)
+
+func TestHeadroomWarningClearsAfterCompaction(t *testing.T) {
+	a := newTestAgent(t, &fakeClient{})
+	var warnings []string
+	compactions := 0
+	a.SetEventHandler(func(e Event) {
+		switch e.Type {
+		case EventTypeContextWarning:
+			warnings = append(warnings, e.Text)
+		case EventTypeCompaction:
+			compactions++
+		}
+	})
+	for i := 0; i < 4; i++ {
+		a.conversation.AddUserMessage("question")
+		a.conversation.AddAssistantMessage([]api.Content{{Type: api.ContentTypeText, Text: "answer"}})
+	}
+
+	// A response that fills most of the context, mostly from cache writes
+	available := compaction.CalculateAvailable(a.contextLimits())
+	a.trackTokens(api.Usage{InputTokens: 100, CacheCreationInputTokens: available * 9 / 10})
+	if err := a.checkAndCompact(context.Background()); err != nil {
+		t.Fatalf("checkAndCompact: %v", err)
+	}
+	if compactions == 0 {
+		t.Fatal("a response over the threshold did not compact")
+	}
+	if len(warnings) != 2 || warnings[0] == "" || warnings[1] != "" {
+		t.Fatalf("warnings = %q, want the warning then its clearing", warnings)
+	}
+
+	// The next response is measured on its own, not added to the session totals
+	compactions = 0
+	a.trackTokens(api.Usage{InputTokens: available / 10, OutputTokens: 100})
+	if err := a.checkAndCompact(context.Background()); err != nil {
+		t.Fatalf("checkAndCompact: %v", err)
+	}
+	if compactions != 0 {
+		t.Errorf("compacted again %d times after compaction freed the context", compactions)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %q, want no new warning", warnings)
+	}
+}
+
+func TestCompactionUsesModelLimitsAndOutputReserve(t *testing.T) {
+	cases := []struct {
+		model   string
+		reserve int
+		want    bool
+	}{
+		{"claude-sonnet-4-20250514", 8000, false},
+		{"claude-sonnet-4-20250514", 0, true}, // reserves the 64k maximum output
+		{"gpt-4o", 8000, true},                // 128k window
+	}
+	for _, c := range cases {
+		a := newTestAgent(t, &fakeClient{})
+		a.SetModel(c.model)
+		a.SetOutputReserve(c.reserve)
+		a.SetAutoCompact(false)
+		warned := false
+		a.SetEventHandler(func(e Event) {
+			if e.Type == EventTypeCompaction {
+				warned = true
+			}
+		})
+
+		// 120k tokens of context as the last response reported it
+		a.trackTokens(api.Usage{InputTokens: 20000, CacheReadInputTokens: 95000, OutputTokens: 5000})
+		if err := a.checkAndCompact(context.Background()); err != nil {
+			t.Fatalf("checkAndCompact: %v", err)
+		}
+		if warned != c.want {
+			t.Errorf("%s with reserve %d: over threshold = %v, want %v", c.model, c.reserve, warned, c.want)
+		}
+	}
+}
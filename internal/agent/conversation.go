@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/anthropics/claude-code-go/internal/api"
@@ -8,9 +10,10 @@ import (
 
 // Conversation manages the message history for a conversation
 type Conversation struct {
-	messages   []api.Message
-	systemMsg  string
-	mu         sync.RWMutex
+	messages  []api.Message
+	systemMsg string
+	pinned    []string // Notes kept outside the message list so compaction never touches them
+	mu        sync.RWMutex
 }
 
 // NewConversation creates a new conversation
@@ -33,6 +36,14 @@ func (c *Conversation) AddUserMessage(text string) {
 	c.AddMessage(api.NewTextMessage(api.RoleUser, text))
 }
 
+// AddUserMessageWithImages adds a user message with image blocks ahead of
+// the text
+func (c *Conversation) AddUserMessageWithImages(text string, images []api.Content) {
+	content := append([]api.Content(nil), images...)
+	content = append(content, api.Content{Type: api.ContentTypeText, Text: text})
+	c.AddMessage(api.Message{Role: api.RoleUser, Content: content})
+}
+
 // AddAssistantMessage adds an assistant message with content blocks
 func (c *Conversation) AddAssistantMessage(content []api.Content) {
 	c.mu.Lock()
@@ -81,13 +92,113 @@ func (c *Conversation) SetSystemMessage(msg string) {
 	c.systemMsg = msg
 }
 
-// Clear removes all messages from the conversation
+// BuildSystemPrompt returns the system message with pinned notes appended
+func (c *Conversation) BuildSystemPrompt() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.pinned) == 0 {
+		return c.systemMsg
+	}
+
+	var b strings.Builder
+	b.WriteString(c.systemMsg)
+	b.WriteString("\n\n# Pinned Context\n")
+	b.WriteString("The user pinned the following notes. Treat them as authoritative for the whole session.\n")
+	for _, note := range c.pinned {
+		b.WriteString("\n- ")
+		b.WriteString(note)
+	}
+	return b.String()
+}
+
+// Pin adds a note that is re-injected into every request
+func (c *Conversation) Pin(note string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinned = append(c.pinned, note)
+}
+
+// Unpin removes the pinned note at index (0-based)
+func (c *Conversation) Unpin(index int) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if index < 0 || index >= len(c.pinned) {
+		return "", fmt.Errorf("no pinned note #%d", index+1)
+	}
+	note := c.pinned[index]
+	c.pinned = append(c.pinned[:index], c.pinned[index+1:]...)
+	return note, nil
+}
+
+// ClearPins removes all pinned notes
+func (c *Conversation) ClearPins() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinned = nil
+}
+
+// Pinned returns a copy of the pinned notes
+func (c *Conversation) Pinned() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pinned := make([]string, len(c.pinned))
+	copy(pinned, c.pinned)
+	return pinned
+}
+
+// Clear removes all messages from the conversation.
+// Pinned notes are kept; use ClearPins to drop them.
 func (c *Conversation) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.messages = make([]api.Message, 0)
 }
 
+// PopLastExchange removes the response to the last user prompt: every
+// message after it, from the assistant's reply through any tool results and
+// follow-up replies. The prompt itself stays in place. It returns the removed
+// messages and false if there is no user prompt.
+func (c *Conversation) PopLastExchange() ([]api.Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if isUserPrompt(c.messages[i]) {
+			removed := append([]api.Message(nil), c.messages[i+1:]...)
+			c.messages = c.messages[:i+1]
+			return removed, true
+		}
+	}
+	return nil, false
+}
+
+// isUserPrompt reports whether msg was written by the user rather than
+// carrying tool results only
+func isUserPrompt(msg api.Message) bool {
+	if msg.Role != api.RoleUser {
+		return false
+	}
+	for _, block := range msg.Content {
+		if block.Type != api.ContentTypeToolResult {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns a copy of the messages that Restore can put back later
+func (c *Conversation) Snapshot() []api.Message {
+	return c.GetMessages()
+}
+
+// Restore replaces the messages with a snapshot. Pinned notes and the
+// system message are kept.
+func (c *Conversation) Restore(messages []api.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = make([]api.Message, len(messages))
+	copy(c.messages, messages)
+}
+
 // MessageCount returns the number of messages
 func (c *Conversation) MessageCount() int {
 	c.mu.RLock()
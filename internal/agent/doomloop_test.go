@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/permission"
+)
+
+func TestThreeIdenticalGrepCallsTriggerLoopWarning(t *testing.T) {
+	grep := &fakeTool{name: "Grep"}
+	a := newTestAgent(t, nil, grep)
+	var warnings []permission.AskRequest
+	a.SetLoopConfirmFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+		warnings = append(warnings, req)
+		return permission.AskResponse{Rejected: true}, nil
+	})
+
+	input := map[string]interface{}{"pattern": "TODO", "path": "."}
+	var last string
+	for i := 1; i <= 3; i++ {
+		results := runCalls(t, a, toolUse("call", "Grep", input))
+		last = results[0].Content
+		if i < 3 && len(warnings) != 0 {
+			t.Fatalf("loop warning after %d identical calls, want it on the third", i)
+		}
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("got %d loop warnings, want 1", len(warnings))
+	}
+	if warnings[0].Permission != "Grep" {
+		t.Errorf("warning is about %q, want Grep", warnings[0].Permission)
+	}
+	if grep.callCount() != 2 || !strings.Contains(last, "repeats earlier calls") {
+		t.Errorf("Grep ran %d times and the third result was %q; want the stopped third call not to run", grep.callCount(), last)
+	}
+}
+
+func TestLoopWarningConfirmedLetsCallRun(t *testing.T) {
+	grep := &fakeTool{name: "Grep"}
+	a := newTestAgent(t, nil, grep)
+	a.SetLoopConfirmFunc(func(req permission.AskRequest) (permission.AskResponse, error) {
+		return permission.AskResponse{Approved: true}, nil
+	})
+
+	input := map[string]interface{}{"pattern": "TODO"}
+	for i := 0; i < 4; i++ {
+		runCalls(t, a, toolUse("call", "Grep", input))
+	}
+	if grep.callCount() != 4 {
+		t.Errorf("Grep ran %d times, want 4 after the user confirmed", grep.callCount())
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/logger"
+	"github.com/anthropics/claude-code-go/internal/permission"
+	"github.com/anthropics/claude-code-go/internal/tools"
+)
+
+// dryRunPrefix marks every simulated tool result
+const dryRunPrefix = "[DRY RUN] This tool was NOT run and nothing changed."
+
+// maxDryRunInput bounds the input quoted in the description of other tools
+const maxDryRunInput = 500
+
+// SetDryRun simulates tool calls instead of running them: the model gets a
+// description of what each call would have done. With runReadOnly, read-only
+// tools (Read, Grep, ...) still run so the model works with real context.
+func (a *Agent) SetDryRun(enabled, runReadOnly bool) {
+	a.dryRun = enabled
+	a.dryRunReadOnly = runReadOnly
+}
+
+// simulates reports whether a call is only described in dry-run mode
+func (a *Agent) simulates(call api.Content) bool {
+	return a.dryRun && !(a.dryRunReadOnly && parallelTools[call.Name])
+}
+
+// simulateToolCall answers a call with what it would have done. The checks
+// that need no one to answer still apply, so invalid or denied calls are
+// reported as such; nothing is asked.
+func (a *Agent) simulateToolCall(call api.Content, ruleset permission.Ruleset) api.Content {
+	var params map[string]interface{}
+	if err := json.Unmarshal(call.Input, &params); err != nil {
+		return a.rejectToolCall(call, fmt.Sprintf("%s The input is not valid JSON: %v", dryRunPrefix, err))
+	}
+	if log := logger.GetLogger(); log != nil {
+		log.LogToolCall(call.Name, call.ID, params)
+	}
+
+	if checked := a.registry.CheckCall(call.Name, params); checked != nil {
+		return a.rejectToolCall(call, dryRunPrefix+" "+checked.Output)
+	}
+	pattern := extractPattern(call.Name, params)
+	if a.evaluatePermission(call.Name, params, pattern, ruleset) == permission.ActionDeny {
+		return a.rejectToolCall(call, fmt.Sprintf("%s It would be denied: agent '%s' is not allowed to use tool '%s' with pattern '%s'",
+			dryRunPrefix, a.currentAgent, call.Name, pattern))
+	}
+
+	intent, err := a.describeToolCall(call.Name, params)
+	if err != nil {
+		return a.rejectToolCall(call, fmt.Sprintf("%s The call would fail: %v", dryRunPrefix, err))
+	}
+	output := fmt.Sprintf("%s It would %s.\nDo not assume it succeeded or that its effects exist; later calls see the unchanged state.", dryRunPrefix, intent)
+
+	a.emit(Event{
+		Type:       EventTypeToolUseEnd,
+		ToolName:   call.Name,
+		ToolID:     call.ID,
+		ToolInput:  string(call.Input),
+		ToolResult: output,
+	})
+	return api.Content{
+		Type:      api.ContentTypeToolResult,
+		ToolUseID: call.ID,
+		Content:   output,
+	}
+}
+
+// describeToolCall says what a call would do, e.g. "write 40 lines to main.go"
+func (a *Agent) describeToolCall(name string, params map[string]interface{}) (string, error) {
+	// File tools can compute their change without making it
+	change, err := a.registry.PreviewChange(name, params)
+	if err != nil {
+		return "", err
+	}
+	if change != nil {
+		added, removed := diffStat(change.Diff)
+		if added == 0 && removed == 0 {
+			return fmt.Sprintf("leave %s unchanged", change.Path), nil
+		}
+		if name == "Write" {
+			content, _ := tools.GetString(params, "content")
+			return fmt.Sprintf("write %d lines to %s (+%d -%d)", strings.Count(content, "\n")+1, change.Path, added, removed), nil
+		}
+		return fmt.Sprintf("change %s (+%d -%d lines)", change.Path, added, removed), nil
+	}
+
+	if name == "Bash" {
+		command, _ := tools.GetString(params, "command")
+		if tools.GetBoolDefault(params, "run_in_background", false) {
+			return fmt.Sprintf("start in the background: %s", command), nil
+		}
+		return fmt.Sprintf("run: %s", command), nil
+	}
+
+	input, _ := json.Marshal(params)
+	if len(input) > maxDryRunInput {
+		input = append(input[:maxDryRunInput], "..."...)
+	}
+	return fmt.Sprintf("call %s with %s", name, input), nil
+}
+
+// diffStat counts the added and removed lines of a unified diff
+func diffStat(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/agentregistry"
+)
+
+func TestAgentModelOverridesRequestModel(t *testing.T) {
+	client := &fakeClient{model: "client-default"}
+	a := newTestAgent(t, client)
+	err := a.GetAgentRegistry().Register(agentregistry.AgentInfo{
+		Name:       "cheap",
+		Mode:       agentregistry.ModePrimary,
+		Model:      "claude-haiku-test",
+		Permission: agentregistry.BuildAgent().Permission,
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if err := a.Chat(context.Background(), "hello"); err != nil {
+		t.Fatalf("Chat as build: %v", err)
+	}
+	if err := a.SwitchAgent("cheap"); err != nil {
+		t.Fatalf("SwitchAgent: %v", err)
+	}
+	if err := a.Chat(context.Background(), "hello again"); err != nil {
+		t.Fatalf("Chat as cheap: %v", err)
+	}
+
+	if len(client.requests) != 2 {
+		t.Fatalf("client saw %d requests, want 2", len(client.requests))
+	}
+	if got := client.requests[0].Model; got != "" {
+		t.Errorf("build request model = %q, want empty so the client default applies", got)
+	}
+	if got := client.requests[1].Model; got != "claude-haiku-test" {
+		t.Errorf("cheap request model = %q, want claude-haiku-test", got)
+	}
+	if got := a.GetModel(); got != "claude-haiku-test" {
+		t.Errorf("GetModel = %q, want the agent's model", got)
+	}
+}
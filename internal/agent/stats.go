@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/tools"
+)
+
+// fileTools are the tools whose successful calls change the file in their
+// file_path parameter
+var fileTools = map[string]bool{
+	"Write":     true,
+	"Edit":      true,
+	"MultiEdit": true,
+}
+
+// Stats summarizes the work done in a conversation
+type Stats struct {
+	Started      time.Time
+	Turns        int            // User messages answered, including retries
+	Requests     int            // Model requests
+	ToolCalls    map[string]int // Executed calls by tool name
+	ToolErrors   int            // Executed calls that failed
+	ToolTime     time.Duration  // Total time spent running tools
+	FilesChanged []string       // Files written or edited, in the order first changed
+}
+
+// TotalToolCalls returns the number of executed tool calls
+func (s Stats) TotalToolCalls() int {
+	total := 0
+	for _, n := range s.ToolCalls {
+		total += n
+	}
+	return total
+}
+
+// ToolNames returns the tools called, most used first
+func (s Stats) ToolNames() []string {
+	names := make([]string, 0, len(s.ToolCalls))
+	for name := range s.ToolCalls {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.ToolCalls[names[i]] != s.ToolCalls[names[j]] {
+			return s.ToolCalls[names[i]] > s.ToolCalls[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// statsRecorder accumulates Stats; tool calls may be recorded concurrently
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats Stats
+	files map[string]bool // FilesChanged as a set
+}
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{
+		stats: Stats{Started: time.Now(), ToolCalls: make(map[string]int)},
+		files: make(map[string]bool),
+	}
+}
+
+func (r *statsRecorder) turn() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Turns++
+}
+
+func (r *statsRecorder) request() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Requests++
+}
+
+// toolCall records an executed call and the file it changed, if any
+func (r *statsRecorder) toolCall(name string, input json.RawMessage, duration time.Duration, isError bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.ToolCalls[name]++
+	r.stats.ToolTime += duration
+	if isError {
+		r.stats.ToolErrors++
+		return
+	}
+	var params struct {
+		FilePath string `json:"file_path"`
+		Patch    string `json:"patch"`
+	}
+	if json.Unmarshal(input, &params) != nil {
+		return
+	}
+	switch {
+	case fileTools[name]:
+		r.fileChanged(params.FilePath)
+	case name == "ApplyPatch":
+		for _, path := range tools.PatchPaths(params.Patch) {
+			r.fileChanged(path)
+		}
+	}
+}
+
+// fileChanged adds path to FilesChanged unless it is already there
+func (r *statsRecorder) fileChanged(path string) {
+	if path != "" && !r.files[path] {
+		r.files[path] = true
+		r.stats.FilesChanged = append(r.stats.FilesChanged, path)
+	}
+}
+
+// snapshot returns a copy of the stats
+func (r *statsRecorder) snapshot() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.ToolCalls = make(map[string]int, len(r.stats.ToolCalls))
+	for name, n := range r.stats.ToolCalls {
+		s.ToolCalls[name] = n
+	}
+	s.FilesChanged = append([]string(nil), r.stats.FilesChanged...)
+	return s
+}
+
+// GetStats returns the work done in the conversation so far
+func (a *Agent) GetStats() Stats {
+	return a.stats.snapshot()
+}
+
+// ResetStats starts counting afresh, e.g. for a new conversation
+func (a *Agent) ResetStats() {
+	a.stats = newStatsRecorder()
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/compaction"
+	"github.com/anthropics/claude-code-go/internal/logger"
+)
+
+// countTokensTimeout bounds a count_tokens call so budgeting cannot stall a turn
+const countTokensTimeout = 10 * time.Second
+
+// countTokensMargin is the fraction of the compaction threshold the estimate
+// must reach before the API is asked for an exact count. Below it the
+// estimate is trusted, which spares most steps a network round-trip.
+const countTokensMargin = 0.75
+
+// tokenCount is the counted size of a request, kept while the request is unchanged
+type tokenCount struct {
+	key    [sha256.Size]byte
+	tokens int
+}
+
+// requestTokens returns the input tokens of req: counted by the API when
+// the client supports it and the request may be near the compaction
+// threshold, otherwise estimated at about four characters per token. exact
+// reports which.
+func (a *Agent) requestTokens(ctx context.Context, req *api.MessagesRequest, limits compaction.ModelLimits) (tokens int, exact bool) {
+	estimate := compaction.EstimateTokens(req.Messages, req.System, req.Tools)
+	if !compaction.EstimateNeedsCompaction(estimate, limits, a.compactThreshold*countTokensMargin) {
+		return estimate, false
+	}
+
+	counter, ok := a.client.(api.TokenCounter)
+	if !ok {
+		return estimate, false
+	}
+
+	key, err := requestKey(req)
+	if err != nil {
+		return estimate, false
+	}
+	if a.lastCount != nil && a.lastCount.key == key {
+		return a.lastCount.tokens, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, countTokensTimeout)
+	defer cancel()
+	tokens, err = counter.CountTokens(ctx, req)
+	if err != nil {
+		if !errors.Is(err, api.ErrCountTokensUnsupported) {
+			if log := logger.GetLogger(); log != nil {
+				log.LogError("count_tokens_error", err, map[string]interface{}{
+					"session_id": a.sessionID,
+				})
+			}
+		}
+		return estimate, false
+	}
+
+	a.lastCount = &tokenCount{key: key, tokens: tokens}
+	return tokens, true
+}
+
+// requestKey identifies the counted parts of a request
+func requestKey(req *api.MessagesRequest) ([sha256.Size]byte, error) {
+	data, err := json.Marshal(struct {
+		System   string        `json:"system"`
+		Messages []api.Message `json:"messages"`
+		Tools    []api.Tool    `json:"tools"`
+	}{req.System, req.Messages, req.Tools})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/compaction"
+)
+
+func TestRequestTokensCountsOnlyNearThreshold(t *testing.T) {
+	client := &fakeClient{}
+	a := newTestAgent(t, client)
+	limits := compaction.ModelLimits{ContextLimit: 10000, OutputLimit: 0}
+
+	small := &api.MessagesRequest{Messages: []api.Message{api.NewTextMessage(api.RoleUser, "hello")}}
+	if tokens, exact := a.requestTokens(context.Background(), small, limits); exact || client.counts != 0 {
+		t.Errorf("small request: tokens=%d exact=%v with %d API counts, want an estimate without counting", tokens, exact, client.counts)
+	}
+
+	// About 7500 estimated tokens, within the margin of the 8000 token threshold
+	large := &api.MessagesRequest{Messages: []api.Message{api.NewTextMessage(api.RoleUser, strings.Repeat("x", 30000))}}
+	if tokens, exact := a.requestTokens(context.Background(), large, limits); !exact || tokens != 42 || client.counts != 1 {
+		t.Errorf("large request: tokens=%d exact=%v with %d API counts, want the API count", tokens, exact, client.counts)
+	}
+}
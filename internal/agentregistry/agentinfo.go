@@ -23,10 +23,12 @@ type AgentInfo struct {
 	Hidden      bool      `json:"hidden"`                 // 是否隐藏（不在列表中显示）
 
 	// 模型配置
-	Model       string  `json:"model,omitempty"`       // 默认模型（如果为空，使用全局配置）
-	Temperature float64 `json:"temperature,omitempty"` // 温度参数
-	TopP        float64 `json:"top_p,omitempty"`       // TopP 参数
-	MaxSteps    int     `json:"max_steps,omitempty"`   // 最大步数（0 表示无限制）
+	Model       string   `json:"model,omitempty"`       // 默认模型（如果为空，使用全局配置）
+	Temperature *float64 `json:"temperature,omitempty"` // 温度参数（nil 表示未设置，使用全局配置）
+	TopP        float64  `json:"top_p,omitempty"`       // TopP 参数
+	MaxSteps    int      `json:"max_steps,omitempty"`   // 最大步数（0 表示使用 Options["maxSteps"] 或默认值）
+
+	StopSequences []string `json:"stop_sequences,omitempty"` // 停止序列（nil 表示使用全局配置）
 
 	// 权限配置
 	Permission permission.Ruleset `json:"permission"` // 权限规则集
@@ -49,7 +51,7 @@ func DefaultAgentInfo(name string) AgentInfo {
 		Native:       false,
 		Hidden:       false,
 		Model:        "",
-		Temperature:  0,
+		Temperature:  nil,
 		TopP:         0,
 		MaxSteps:     0,
 		Permission:   permission.DefaultRuleset(),
@@ -122,7 +124,13 @@ func (a *AgentInfo) WithModel(model string) *AgentInfo {
 
 // WithTemperature 设置温度
 func (a *AgentInfo) WithTemperature(temp float64) *AgentInfo {
-	a.Temperature = temp
+	a.Temperature = &temp
+	return a
+}
+
+// WithStopSequences 设置停止序列
+func (a *AgentInfo) WithStopSequences(sequences ...string) *AgentInfo {
+	a.StopSequences = sequences
 	return a
 }
 
@@ -132,6 +140,21 @@ func (a *AgentInfo) WithMaxSteps(steps int) *AgentInfo {
 	return a
 }
 
+// StepLimit 返回最大步数：优先 MaxSteps，其次 Options["maxSteps"]，都未设置时返回 0
+func (a *AgentInfo) StepLimit() int {
+	if a.MaxSteps > 0 {
+		return a.MaxSteps
+	}
+	// JSON 解析的选项是 float64
+	switch v := a.Options["maxSteps"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
 // GetSystemPrompt 获取系统提示，如果有 workDir 则添加到提示中
 func (a *AgentInfo) GetSystemPrompt(workDir string) string {
 	if workDir == "" {
@@ -139,3 +162,12 @@ func (a *AgentInfo) GetSystemPrompt(workDir string) string {
 	}
 	return "Working Directory: " + workDir + "\n\n" + a.SystemPrompt
 }
+
+// GetSystemPromptWithContext 获取系统提示，并在末尾追加项目上下文（pc 为 nil 时不追加）
+func (a *AgentInfo) GetSystemPromptWithContext(workDir string, pc *ProjectContext) string {
+	prompt := a.GetSystemPrompt(workDir)
+	if pc == nil {
+		return prompt
+	}
+	return prompt + "\n\n" + pc.Section()
+}
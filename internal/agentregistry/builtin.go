@@ -29,7 +29,6 @@ func BuildAgent() AgentInfo {
 		Mode:        ModePrimary,
 		Native:      true,
 		Hidden:      false,
-		Temperature: 0,
 		Permission:  buildPermissions(),
 		SystemPrompt: `You are a helpful AI assistant for software development. You have access to various tools to help with coding, file management, and system operations.
 
@@ -50,7 +49,6 @@ func PlanAgent() AgentInfo {
 		Mode:        ModePrimary,
 		Native:      true,
 		Hidden:      false,
-		Temperature: 0,
 		Permission:  planPermissions(),
 		SystemPrompt: `You are a planning and analysis assistant. Your role is to:
 1. Analyze the codebase and understand requirements
@@ -73,7 +71,6 @@ func ExploreAgent() AgentInfo {
 		Mode:        ModeSubagent,
 		Native:      true,
 		Hidden:      false,
-		Temperature: 0,
 		Permission:  explorePermissions(),
 		SystemPrompt: `You are a code exploration specialist. Your task is to quickly navigate and understand codebases.
 
@@ -96,8 +93,14 @@ func buildPermissions() permission.Ruleset {
 			// 允许常见的只读操作
 			{Permission: "read", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "glob", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "tree", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "grep", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "webfetch", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "read_tool_output", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "plan_list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "bash_list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "bash_logs", Pattern: "*", Action: permission.ActionAllow},
 
 			// 编辑操作需要确认
 			{Permission: "edit", Pattern: "*.go", Action: permission.ActionAllow},
@@ -105,11 +108,14 @@ func buildPermissions() permission.Ruleset {
 			{Permission: "edit", Pattern: "*.ts", Action: permission.ActionAllow},
 			{Permission: "edit", Pattern: "*.py", Action: permission.ActionAllow},
 			{Permission: "write", Pattern: "*.md", Action: permission.ActionAllow},
+			{Permission: "gitbranch", Pattern: "list", Action: permission.ActionAllow},
 
 			// 危险操作需要询问
 			{Permission: "bash", Pattern: "rm *", Action: permission.ActionAsk},
 			{Permission: "bash", Pattern: "sudo *", Action: permission.ActionDeny},
 			{Permission: "edit", Pattern: "/etc/*", Action: permission.ActionDeny},
+			{Permission: "multiedit", Pattern: "/etc/*", Action: permission.ActionDeny},
+			{Permission: "applypatch", Pattern: "/etc/*", Action: permission.ActionDeny},
 		},
 		AllowAll:   false,
 		DenyAll:    false,
@@ -124,21 +130,35 @@ func planPermissions() permission.Ruleset {
 			// 只读工具全部允许
 			{Permission: "read", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "glob", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "tree", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "grep", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "webfetch", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "read_tool_output", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "plan_list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "bash_list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "bash_logs", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "websearch", Pattern: "*", Action: permission.ActionAllow},
 
 			// 允许写入计划文件
 			{Permission: "write", Pattern: ".gmain-agent/plans/*", Action: permission.ActionAllow},
 			{Permission: "edit", Pattern: ".gmain-agent/plans/*", Action: permission.ActionAllow},
+			{Permission: "multiedit", Pattern: ".gmain-agent/plans/*", Action: permission.ActionAllow},
+			{Permission: "applypatch", Pattern: ".gmain-agent/plans/*", Action: permission.ActionAllow},
 
 			// bash 命令需要询问（只允许安全的只读命令）
 			{Permission: "bash", Pattern: "ls *", Action: permission.ActionAllow},
 			{Permission: "bash", Pattern: "cat *", Action: permission.ActionAllow},
 			{Permission: "bash", Pattern: "*", Action: permission.ActionAsk},
 
+			// 只允许列出分支，不允许切换
+			{Permission: "gitbranch", Pattern: "list", Action: permission.ActionAllow},
+			{Permission: "gitbranch", Pattern: "*", Action: permission.ActionDeny},
+
 			// 禁止所有写入操作（除了计划文件）
 			{Permission: "edit", Pattern: "*", Action: permission.ActionDeny},
+			{Permission: "multiedit", Pattern: "*", Action: permission.ActionDeny},
+			{Permission: "applypatch", Pattern: "*", Action: permission.ActionDeny},
 			{Permission: "write", Pattern: "*", Action: permission.ActionDeny},
 		},
 		AllowAll:   false,
@@ -154,19 +174,26 @@ func explorePermissions() permission.Ruleset {
 			// 只允许只读工具
 			{Permission: "read", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "glob", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "list", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "tree", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "grep", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "webfetch", Pattern: "*", Action: permission.ActionAllow},
+			{Permission: "read_tool_output", Pattern: "*", Action: permission.ActionAllow},
 			{Permission: "websearch", Pattern: "*", Action: permission.ActionAllow},
 
 			// 允许安全的 bash 命令
 			{Permission: "bash", Pattern: "ls *", Action: permission.ActionAllow},
 			{Permission: "bash", Pattern: "find *", Action: permission.ActionAllow},
 			{Permission: "bash", Pattern: "tree *", Action: permission.ActionAllow},
+			{Permission: "gitbranch", Pattern: "list", Action: permission.ActionAllow},
 
 			// 禁止所有写入操作
 			{Permission: "edit", Pattern: "*", Action: permission.ActionDeny},
+			{Permission: "multiedit", Pattern: "*", Action: permission.ActionDeny},
+			{Permission: "applypatch", Pattern: "*", Action: permission.ActionDeny},
 			{Permission: "write", Pattern: "*", Action: permission.ActionDeny},
 			{Permission: "bash", Pattern: "*", Action: permission.ActionDeny},
+			{Permission: "gitbranch", Pattern: "*", Action: permission.ActionDeny},
 		},
 		AllowAll:   false,
 		DenyAll:    false,
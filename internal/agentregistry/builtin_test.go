@@ -0,0 +1,64 @@
+package agentregistry
+
+import (
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/permission"
+)
+
+// ruleCase is a tool call, by its real tool name, and the action the
+// built-in agent's rules must give it
+type ruleCase struct {
+	agent   string
+	tool    string
+	pattern string
+	want    permission.Action
+}
+
+func checkRules(t *testing.T, cases []ruleCase) {
+	t.Helper()
+	evaluator := permission.NewEvaluator()
+	rulesets := map[string]permission.Ruleset{
+		"build":   buildPermissions(),
+		"plan":    planPermissions(),
+		"explore": explorePermissions(),
+	}
+	for _, c := range cases {
+		got := evaluator.Evaluate(c.tool, c.pattern, rulesets[c.agent])
+		if got != c.want {
+			t.Errorf("%s: Evaluate(%s, %q) = %s, want %s", c.agent, c.tool, c.pattern, got, c.want)
+		}
+	}
+}
+
+func TestBuiltinGitBranchRules(t *testing.T) {
+	checkRules(t, []ruleCase{
+		{"build", "GitBranch", "list", permission.ActionAllow},
+		{"build", "GitBranch", "checkout main", permission.ActionAsk},
+		{"plan", "GitBranch", "list", permission.ActionAllow},
+		{"plan", "GitBranch", "checkout main", permission.ActionDeny},
+		{"plan", "GitBranch", "create feature", permission.ActionDeny},
+		{"explore", "GitBranch", "list", permission.ActionAllow},
+		{"explore", "GitBranch", "checkout main", permission.ActionDeny},
+	})
+}
+
+func TestBuiltinMultiEditRules(t *testing.T) {
+	checkRules(t, []ruleCase{
+		{"build", "MultiEdit", "/etc/hosts", permission.ActionDeny},
+		{"build", "MultiEdit", "main.go", permission.ActionAsk},
+		{"plan", "MultiEdit", ".gmain-agent/plans/p.md", permission.ActionAllow},
+		{"plan", "MultiEdit", "main.go", permission.ActionDeny},
+		{"explore", "MultiEdit", "main.go", permission.ActionDeny},
+	})
+}
+
+func TestBuiltinApplyPatchRules(t *testing.T) {
+	checkRules(t, []ruleCase{
+		{"build", "ApplyPatch", "/etc/hosts", permission.ActionDeny},
+		{"build", "ApplyPatch", "main.go", permission.ActionAsk},
+		{"plan", "ApplyPatch", ".gmain-agent/plans/p.md", permission.ActionAllow},
+		{"plan", "ApplyPatch", "main.go", permission.ActionDeny},
+		{"explore", "ApplyPatch", "main.go", permission.ActionDeny},
+	})
+}
@@ -0,0 +1,68 @@
+package agentregistry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultContextFiles 默认查找的项目上下文文件（相对于工作目录，使用第一个存在的）
+var DefaultContextFiles = []string{"AGENTS.md", ".gmain-agent/context.md"}
+
+// DefaultContextMaxBytes 项目上下文文件的默认大小上限
+const DefaultContextMaxBytes = 32 * 1024
+
+// ProjectContext 项目上下文文件（团队约定等），追加到所有 Agent 的系统提示
+type ProjectContext struct {
+	Path      string // 文件路径（相对于工作目录）
+	Content   string // 文件内容（可能已截断）
+	Size      int    // 原始文件大小
+	Truncated bool   // 超过大小上限被截断
+}
+
+// LoadProjectContext 在 workDir 中查找 files 中第一个存在的文件并读取
+// 超过 maxBytes 的部分被截断（maxBytes <= 0 时使用默认上限）；都不存在时返回 nil
+func LoadProjectContext(workDir string, files []string, maxBytes int) (*ProjectContext, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultContextMaxBytes
+	}
+
+	for _, name := range files {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, name)
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read context file %s: %w", name, err)
+		}
+
+		pc := &ProjectContext{Path: name, Size: len(data)}
+		if len(data) > maxBytes {
+			data = data[:maxBytes]
+			pc.Truncated = true
+		}
+		pc.Content = strings.TrimSpace(strings.ToValidUTF8(string(data), ""))
+		if pc.Content == "" {
+			continue
+		}
+		return pc, nil
+	}
+	return nil, nil
+}
+
+// Section 返回追加到系统提示的部分
+func (c *ProjectContext) Section() string {
+	var b strings.Builder
+	b.WriteString("# Project Context\n")
+	b.WriteString(fmt.Sprintf("The following is from %s in the working directory. Follow its conventions.\n\n", c.Path))
+	b.WriteString(c.Content)
+	if c.Truncated {
+		b.WriteString(fmt.Sprintf("\n\n(%s was truncated: only the first %d of %d bytes are included)", c.Path, len(c.Content), c.Size))
+	}
+	return b.String()
+}
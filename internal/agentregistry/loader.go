@@ -0,0 +1,84 @@
+package agentregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectAgentsDir 项目自定义 Agent 目录（相对于工作目录）
+const ProjectAgentsDir = ".gmain-agent/agents"
+
+// projectAgentFile 自定义 Agent 文件格式：AgentInfo 加上是否覆盖同名 Agent
+type projectAgentFile struct {
+	AgentInfo
+	Override bool `json:"override,omitempty"` // 覆盖同名的已注册 Agent（如内置 Agent）
+}
+
+// LoadProjectAgents 从 .gmain-agent/agents/*.json 加载自定义 Agent 并注册
+// 目录不存在时不做任何事；返回已注册的 Agent 名称
+func LoadProjectAgents(registry *Registry, workDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(workDir, ProjectAgentsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var loaded []string
+	for _, file := range files {
+		info, override, err := loadAgentFile(file)
+		if err != nil {
+			return loaded, fmt.Errorf("failed to load agent %s: %w", file, err)
+		}
+
+		if registry.Exists(info.Name) {
+			if !override {
+				return loaded, fmt.Errorf("failed to load agent %s: agent %s already exists; set \"override\": true to replace it", file, info.Name)
+			}
+			registry.Unregister(info.Name)
+		}
+		if err := registry.Register(info); err != nil {
+			return loaded, fmt.Errorf("failed to load agent %s: %w", file, err)
+		}
+		loaded = append(loaded, info.Name)
+	}
+
+	return loaded, nil
+}
+
+// loadAgentFile 解析并校验单个 Agent 文件，未设置的字段使用 DefaultAgentInfo 的值
+func loadAgentFile(path string) (AgentInfo, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AgentInfo{}, false, err
+	}
+
+	file := projectAgentFile{AgentInfo: DefaultAgentInfo("")}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return AgentInfo{}, false, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	info := file.AgentInfo
+	// 未指定名称时使用文件名
+	if info.Name == "" {
+		info.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	info.Native = false
+
+	switch info.Mode {
+	case ModePrimary, ModeSubagent, ModeAll:
+	case "":
+		info.Mode = ModePrimary
+	default:
+		return AgentInfo{}, false, fmt.Errorf("invalid mode %q (use primary, subagent or all)", info.Mode)
+	}
+
+	if err := info.Permission.Validate(); err != nil {
+		return AgentInfo{}, false, fmt.Errorf("invalid permission: %w", err)
+	}
+
+	return info, file.Override, nil
+}
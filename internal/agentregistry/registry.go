@@ -11,6 +11,8 @@ type Registry struct {
 	agents map[string]*AgentInfo // name -> AgentInfo
 
 	defaultAgent string // 默认 Agent 名称
+
+	projectContext *ProjectContext // 追加到系统提示的项目上下文（nil 表示无）
 }
 
 // NewRegistry 创建新的 Agent 注册表
@@ -184,3 +186,17 @@ func (r *Registry) GetNames(includeHidden bool) []string {
 
 	return names
 }
+
+// SetProjectContext 设置追加到所有 Agent 系统提示的项目上下文（nil 表示清除）
+func (r *Registry) SetProjectContext(pc *ProjectContext) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.projectContext = pc
+}
+
+// ProjectContext 返回项目上下文（未设置时为 nil）
+func (r *Registry) ProjectContext() *ProjectContext {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.projectContext
+}
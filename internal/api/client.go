@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anthropics/claude-code-go/internal/logger"
@@ -20,6 +22,7 @@ const (
 	DefaultModel      = "claude-sonnet-4-20250514"
 	DefaultMaxTokens  = 8192
 	AnthropicVersion  = "2023-06-01"
+	PromptCachingBeta = "prompt-caching-2024-07-31"
 	DefaultTimeout    = 5 * time.Minute
 	// MessagesEndpoint is the API endpoint for messages
 	MessagesEndpoint  = "v1/messages"
@@ -33,15 +36,44 @@ const (
 	AuthTypeBearer AuthType = "bearer"
 )
 
+// MessageClient is the interface the agent uses to talk to a model. Client
+// implements it for the Anthropic Messages API and OpenAIClient for
+// OpenAI-compatible chat completions APIs.
+type MessageClient interface {
+	CreateMessage(ctx context.Context, req *MessagesRequest) (*MessagesResponse, error)
+	StreamMessage(ctx context.Context, req *MessagesRequest) (*StreamReader, error)
+	GetModel() string
+	SetModel(model string)
+	GetBaseURL() string
+	ResetRetryBudget()
+	SetRateLimitCallback(fn func(RateLimitStatus))
+	SetRetryCallback(fn func(retry.RetryStats))
+	WaitToRetry(ctx context.Context, attempt int, err error) error
+}
+
 // Client is the Anthropic API client
 type Client struct {
 	credential string
 	authType   AuthType
+	refresher  RefreshableCredential // Supplies the credential per request when set
 	baseURL    string
 	httpClient *http.Client
 	retrier    *retry.Retrier
-	model      string
 	maxTokens  int
+
+	mu          sync.RWMutex // Guards model and the rate limit state, which change between requests
+	model       string
+	rateLimit   *RateLimitStatus      // From the most recent response (nil until seen)
+	onRateLimit func(RateLimitStatus) // Called after each response with rate limit headers
+
+	promptCaching  bool     // Mark the system prompt and tools as cacheable
+	thinkingBudget int      // Extended thinking budget for streamed turns (0 = off)
+	temperature    *float64 // Default temperature for streamed turns (nil = model default)
+	stopSequences  []string // Default stop sequences for streamed turns
+
+	streamIdleTimeout time.Duration // Longest silence on a stream before it is abandoned (0 = no limit)
+
+	countUnsupported atomic.Bool // The backend has no count_tokens endpoint
 }
 
 // ClientOption is a function that configures the client
@@ -75,6 +107,77 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
+// WithStreamIdleTimeout sets how long a stream may receive nothing before it
+// fails with a retryable StreamIdleError (0 or less disables the check)
+func WithStreamIdleTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.streamIdleTimeout = timeout
+	}
+}
+
+// WithRetryBudget caps the cumulative retry delay per turn (0 disables the cap)
+func WithRetryBudget(limit time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retrier.Budget = retry.NewBudget(limit)
+	}
+}
+
+// RetryConfig tunes how failed requests are retried; zero fields keep the defaults
+type RetryConfig struct {
+	MaxRetries   int           // Attempts per request, including the first (default 3)
+	JitterFactor float64       // Randomizes backoff delays by up to this fraction, e.g. 0.2 = ±20% (default 0)
+	BaseDelay    time.Duration // First backoff delay (default 500ms)
+	MaxDelay     time.Duration // Longest backoff delay without a Retry-After header (default 2s)
+}
+
+// WithRetryConfig sets the retry attempts, jitter and backoff delays
+func WithRetryConfig(cfg RetryConfig) ClientOption {
+	return func(c *Client) {
+		retry.WithMaxRetries(cfg.MaxRetries)(c.retrier)
+		retry.WithJitter(cfg.JitterFactor)(c.retrier)
+		retry.WithDelays(cfg.BaseDelay, cfg.MaxDelay)(c.retrier)
+	}
+}
+
+// WithPromptCaching enables prompt caching of the system prompt and tool definitions
+func WithPromptCaching(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.promptCaching = enabled
+	}
+}
+
+// WithThinking enables extended thinking with the given token budget on
+// streamed requests. One-off calls such as compaction are left unchanged.
+func WithThinking(budgetTokens int) ClientOption {
+	return func(c *Client) {
+		c.thinkingBudget = budgetTokens
+	}
+}
+
+// WithTemperature sets the temperature used for streamed requests that do
+// not set their own
+func WithTemperature(temperature float64) ClientOption {
+	return func(c *Client) {
+		c.temperature = &temperature
+	}
+}
+
+// WithStopSequences sets the stop sequences used for streamed requests that
+// do not set their own
+func WithStopSequences(sequences ...string) ClientOption {
+	return func(c *Client) {
+		c.stopSequences = sequences
+	}
+}
+
+// WithRateLimitCallback sets a function called with the rate limit state
+// after every response that carries rate limit headers
+func WithRateLimitCallback(fn func(RateLimitStatus)) ClientOption {
+	return func(c *Client) {
+		c.onRateLimit = fn
+	}
+}
+
 // WithAuthType sets the authentication type
 func WithAuthType(authType AuthType) ClientOption {
 	return func(c *Client) {
@@ -95,6 +198,7 @@ func NewClient(credential string, opts ...ClientOption) *Client {
 		retrier: retry.NewRetrier(),
 		model:     DefaultModel,
 		maxTokens: DefaultMaxTokens,
+		streamIdleTimeout: DefaultStreamIdleTimeout,
 	}
 
 	for _, opt := range opts {
@@ -106,9 +210,36 @@ func NewClient(credential string, opts ...ClientOption) *Client {
 
 // GetModel returns the current model
 func (c *Client) GetModel() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.model
 }
 
+// SetModel changes the model used for subsequent requests
+func (c *Client) SetModel(model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.model = model
+}
+
+// SetRetryCallback sets a function called before each retry of a failed
+// request, with the attempt count and the upcoming delay
+func (c *Client) SetRetryCallback(fn func(retry.RetryStats)) {
+	c.retrier.OnStats = fn
+}
+
+// WaitToRetry backs off before a request is sent again after failing outside
+// the retrier, e.g. a stream that broke off mid-response. attempt counts the
+// failures so far; a non-nil result means the request should not be retried.
+func (c *Client) WaitToRetry(ctx context.Context, attempt int, err error) error {
+	return c.retrier.Wait(ctx, attempt, err)
+}
+
+// ResetRetryBudget resets the cumulative retry delay, typically at the start of a turn
+func (c *Client) ResetRetryBudget() {
+	c.retrier.Budget.Reset()
+}
+
 // GetBaseURL returns the current base URL
 func (c *Client) GetBaseURL() string {
 	return c.baseURL
@@ -124,14 +255,14 @@ func (c *Client) buildURL(endpoint string) string {
 // CreateMessage sends a non-streaming message request
 func (c *Client) CreateMessage(ctx context.Context, req *MessagesRequest) (*MessagesResponse, error) {
 	if req.Model == "" {
-		req.Model = c.model
+		req.Model = c.GetModel()
 	}
 	if req.MaxTokens == 0 {
 		req.MaxTokens = c.maxTokens
 	}
 	req.Stream = false
 
-	body, err := json.Marshal(req)
+	body, err := json.Marshal(c.withCacheControl(req))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -141,7 +272,9 @@ func (c *Client) CreateMessage(ctx context.Context, req *MessagesRequest) (*Mess
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	c.setHeaders(httpReq)
+	if err := c.setHeaders(httpReq); err != nil {
+		return nil, err
+	}
 
 	// Log request
 	if log := logger.GetLogger(); log != nil {
@@ -160,7 +293,7 @@ func (c *Client) CreateMessage(ctx context.Context, req *MessagesRequest) (*Mess
 
 	// Use retrier to handle retries
 	resp, err := c.retrier.Do(ctx, func() (*http.Response, error) {
-		return c.httpClient.Do(httpReq)
+		return c.httpClient.Do(rewind(httpReq))
 	})
 
 	duration := time.Since(startTime)
@@ -176,6 +309,8 @@ func (c *Client) CreateMessage(ctx context.Context, req *MessagesRequest) (*Mess
 	}
 	defer resp.Body.Close()
 
+	c.recordRateLimit(resp)
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, c.handleErrorResponse(resp)
 	}
@@ -209,14 +344,31 @@ func (c *Client) CreateMessage(ctx context.Context, req *MessagesRequest) (*Mess
 // StreamMessage sends a streaming message request
 func (c *Client) StreamMessage(ctx context.Context, req *MessagesRequest) (*StreamReader, error) {
 	if req.Model == "" {
-		req.Model = c.model
+		req.Model = c.GetModel()
 	}
 	if req.MaxTokens == 0 {
 		req.MaxTokens = c.maxTokens
 	}
+	if c.thinkingBudget > 0 && req.Thinking == nil {
+		req.Thinking = &ThinkingConfig{Type: "enabled", BudgetTokens: c.thinkingBudget}
+		// max_tokens includes the thinking budget and must exceed it
+		if req.MaxTokens <= c.thinkingBudget {
+			req.MaxTokens = c.thinkingBudget + c.maxTokens
+		}
+	}
+	if req.Temperature == nil {
+		req.Temperature = c.temperature
+	}
+	if req.StopSequences == nil {
+		req.StopSequences = c.stopSequences
+	}
+	// The API only accepts the default temperature with extended thinking
+	if req.Thinking != nil {
+		req.Temperature = nil
+	}
 	req.Stream = true
 
-	body, err := json.Marshal(req)
+	body, err := json.Marshal(c.withCacheControl(req))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -226,7 +378,9 @@ func (c *Client) StreamMessage(ctx context.Context, req *MessagesRequest) (*Stre
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	c.setHeaders(httpReq)
+	if err := c.setHeaders(httpReq); err != nil {
+		return nil, err
+	}
 
 	// Log request
 	if log := logger.GetLogger(); log != nil {
@@ -241,8 +395,11 @@ func (c *Client) StreamMessage(ctx context.Context, req *MessagesRequest) (*Stre
 		log.LogAPIRequest("POST", httpReq.URL.String(), headers, bodyMap)
 	}
 
+	// Only establishing the stream is retried; a stream that fails midway is not
 	startTime := time.Now()
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.retrier.Do(ctx, func() (*http.Response, error) {
+		return c.httpClient.Do(rewind(httpReq))
+	})
 	if err != nil {
 		if log := logger.GetLogger(); log != nil {
 			log.LogError("http_request_failed", err, map[string]interface{}{
@@ -253,6 +410,9 @@ func (c *Client) StreamMessage(ctx context.Context, req *MessagesRequest) (*Stre
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
+	// Rate limit headers are only on the initial response, not the event stream
+	c.recordRateLimit(resp)
+
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		if log := logger.GetLogger(); log != nil {
@@ -278,31 +438,91 @@ func (c *Client) StreamMessage(ctx context.Context, req *MessagesRequest) (*Stre
 		log.LogAPIResponse(resp.StatusCode, respHeaders, "stream_started", time.Since(startTime))
 	}
 
-	return NewStreamReader(resp.Body), nil
+	return NewStreamReader(newIdleReader(resp.Body, c.streamIdleTimeout)), nil
+}
+
+// rewind resets the request body so that a retried request sends it again
+func rewind(req *http.Request) *http.Request {
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			req.Body = body
+		}
+	}
+	return req
 }
 
-func (c *Client) setHeaders(req *http.Request) {
+func (c *Client) setHeaders(req *http.Request) error {
+	credential, err := c.currentCredential(req.Context())
+	if err != nil {
+		return err
+	}
+
 	req.Header.Set("Content-Type", "application/json")
 
 	// Set authentication header based on auth type
 	switch c.authType {
 	case AuthTypeBearer:
-		req.Header.Set("Authorization", "Bearer "+c.credential)
+		req.Header.Set("Authorization", "Bearer "+credential)
 	default:
 		// Default to x-api-key for standard Anthropic API
-		req.Header.Set("x-api-key", c.credential)
+		req.Header.Set("x-api-key", credential)
 	}
 
 	req.Header.Set("anthropic-version", AnthropicVersion)
+
+	if c.promptCaching {
+		req.Header.Set("anthropic-beta", PromptCachingBeta)
+	}
+	return nil
+}
+
+// withCacheControl returns a copy of req with cache breakpoints on the last
+// system block and the last tool definition, or req itself if caching is off
+func (c *Client) withCacheControl(req *MessagesRequest) *MessagesRequest {
+	if !c.promptCaching {
+		return req
+	}
+
+	cached := *req
+	if len(cached.SystemBlocks) > 0 {
+		cached.SystemBlocks = append([]Content(nil), cached.SystemBlocks...)
+	} else if cached.System != "" {
+		cached.SystemBlocks = []Content{{Type: ContentTypeText, Text: cached.System}}
+	}
+	if n := len(cached.SystemBlocks); n > 0 {
+		cached.SystemBlocks[n-1].CacheControl = EphemeralCache()
+	}
+
+	if n := len(cached.Tools); n > 0 {
+		cached.Tools = append([]Tool(nil), cached.Tools...)
+		cached.Tools[n-1].CacheControl = EphemeralCache()
+	}
+
+	return &cached
 }
 
 func (c *Client) handleErrorResponse(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 
+	// OpenAI-compatible servers use x-request-id
+	requestID := resp.Header.Get("request-id")
+	if requestID == "" {
+		requestID = resp.Header.Get("x-request-id")
+	}
+
 	var errResp ErrorResponse
 	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-		return fmt.Errorf("API error (%d): %s - %s", resp.StatusCode, errResp.Error.Type, errResp.Error.Message)
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Type:       errResp.Error.Type,
+			Message:    errResp.Error.Message,
+			RequestID:  requestID,
+		}
 	}
 
-	return fmt.Errorf("API error (%d): %s", resp.StatusCode, string(body))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+		RequestID:  requestID,
+	}
 }
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// CountTokensEndpoint is the API endpoint for counting the input tokens of a request
+const CountTokensEndpoint = "v1/messages/count_tokens"
+
+// TokenCounter is implemented by clients that can count the input tokens of
+// a request before sending it
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req *MessagesRequest) (int, error)
+}
+
+// ErrCountTokensUnsupported is returned by CountTokens when the backend has
+// no count_tokens endpoint, e.g. a proxy that only serves messages
+var ErrCountTokensUnsupported = errors.New("count_tokens is not supported by this API")
+
+// countTokensFields are the request fields count_tokens accepts
+var countTokensFields = []string{"model", "system", "messages", "tools", "thinking"}
+
+// CountTokens returns the number of input tokens req would use. After the
+// backend once reports the endpoint missing, or rejects a count with any
+// other 4xx status, it fails with ErrCountTokensUnsupported without sending
+// anything.
+func (c *Client) CountTokens(ctx context.Context, req *MessagesRequest) (int, error) {
+	if c.countUnsupported.Load() {
+		return 0, ErrCountTokensUnsupported
+	}
+
+	counted := *req
+	if counted.Model == "" {
+		counted.Model = c.GetModel()
+	}
+	body, err := countTokensBody(c.withCacheControl(&counted))
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.buildURL(CountTokensEndpoint), bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := c.setHeaders(httpReq); err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		c.countUnsupported.Store(true)
+		return 0, ErrCountTokensUnsupported
+	default:
+		// A request the backend refuses now would be refused every step
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			c.countUnsupported.Store(true)
+		}
+		return 0, c.handleErrorResponse(resp)
+	}
+
+	var result struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.InputTokens, nil
+}
+
+// countTokensBody encodes req with only the fields count_tokens accepts
+func countTokensBody(req *MessagesRequest) ([]byte, error) {
+	full, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(full, &fields); err != nil {
+		return nil, err
+	}
+	body := make(map[string]json.RawMessage, len(countTokensFields))
+	for _, name := range countTokensFields {
+		if v, ok := fields[name]; ok {
+			body[name] = v
+		}
+	}
+	return json.Marshal(body)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCountTokensStopsAfterClientError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"unknown field"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient("key", WithBaseURL(server.URL))
+	req := &MessagesRequest{Messages: []Message{NewTextMessage(RoleUser, "hi")}}
+
+	if _, err := c.CountTokens(context.Background(), req); err == nil {
+		t.Fatal("first CountTokens succeeded, want the 400 error")
+	}
+	if _, err := c.CountTokens(context.Background(), req); !errors.Is(err, ErrCountTokensUnsupported) {
+		t.Errorf("second CountTokens error = %v, want ErrCountTokensUnsupported", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("sent %d count_tokens requests, want 1", n)
+	}
+}
+
+func TestOpenAICountTokensUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	c := NewOpenAIClient("key", WithBaseURL(server.URL))
+	var counter TokenCounter = c
+	if _, err := counter.CountTokens(context.Background(), &MessagesRequest{}); !errors.Is(err, ErrCountTokensUnsupported) {
+		t.Errorf("CountTokens error = %v, want ErrCountTokensUnsupported", err)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// RefreshableCredential supplies a credential that can change between
+// requests, such as a bearer token that expires. When one is set, the client
+// asks it for the credential before every request.
+type RefreshableCredential interface {
+	// Token returns a current credential, renewing it first if needed
+	Token(ctx context.Context) (string, error)
+}
+
+// WithRefreshableCredential makes the client take its credential from src
+// instead of the fixed one given to the constructor
+func WithRefreshableCredential(src RefreshableCredential) ClientOption {
+	return func(c *Client) {
+		c.refresher = src
+	}
+}
+
+// currentCredential returns the credential to send with the next request
+func (c *Client) currentCredential(ctx context.Context) (string, error) {
+	if c.refresher == nil {
+		return c.credential, nil
+	}
+	token, err := c.refresher.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh credential: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// APIError is an error response returned by the Messages API, either as
+// an HTTP error status or as an error event in the middle of a stream
+type APIError struct {
+	StatusCode int    // HTTP status; for stream errors, the status matching Type
+	Type       string // e.g. "invalid_request_error", "overloaded_error"
+	Message    string
+	RequestID  string // Value of the request-id response header, if any
+}
+
+func (e *APIError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("API error (%d): %s - %s", e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether sending the same request again may succeed:
+// rate limits, overloads, timeouts and server errors
+func (e *APIError) Retryable() bool {
+	switch e.Type {
+	case "rate_limit_error", "overloaded_error", "api_error", "timeout_error":
+		return true
+	}
+	switch {
+	case e.StatusCode == 408, e.StatusCode == 429:
+		return true
+	case e.StatusCode >= 500 && e.StatusCode < 600:
+		return true
+	}
+	return false
+}
+
+// Summary is a short human-readable description of the error, such as
+// "Invalid API key" or "Server overloaded"
+func (e *APIError) Summary() string {
+	switch {
+	case e.Type == "authentication_error" || e.StatusCode == 401:
+		return "Invalid API key"
+	case e.Type == "permission_error" || e.StatusCode == 403:
+		return "API key lacks permission for this request"
+	case e.Type == "not_found_error" || e.StatusCode == 404:
+		return "Model or endpoint not found"
+	case e.Type == "request_too_large" || e.StatusCode == 413:
+		return "Request too large"
+	case e.Type == "rate_limit_error" || e.StatusCode == 429:
+		return "Rate limited"
+	case e.Type == "overloaded_error" || e.StatusCode == 529:
+		return "Server overloaded"
+	case e.Type == "timeout_error" || e.StatusCode == 408 || e.StatusCode == 504:
+		return "Request timed out"
+	case e.Type == "api_error" || e.StatusCode >= 500:
+		return "Server error"
+	case e.Type == "invalid_request_error" || e.StatusCode == 400:
+		return "Invalid request"
+	}
+	return "API error"
+}
+
+// statusForErrorType maps the error types the API sends in stream error
+// events to the HTTP status it uses for the same error
+var statusForErrorType = map[string]int{
+	"invalid_request_error": 400,
+	"authentication_error":  401,
+	"permission_error":      403,
+	"not_found_error":       404,
+	"request_too_large":     413,
+	"rate_limit_error":      429,
+	"api_error":             500,
+	"timeout_error":         504,
+	"overloaded_error":      529,
+}
+
+// AsAPIError returns the APIError in err's chain, if there is one
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// IsAuthError reports whether err is a rejected or missing API key
+func IsAuthError(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && (apiErr.Type == "authentication_error" || apiErr.StatusCode == 401)
+}
+
+// IsRateLimitError reports whether err is a rate limit rejection
+func IsRateLimitError(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && (apiErr.Type == "rate_limit_error" || apiErr.StatusCode == 429)
+}
+
+// IsOverloadedError reports whether err means the API is overloaded
+func IsOverloadedError(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && (apiErr.Type == "overloaded_error" || apiErr.StatusCode == 529)
+}
+
+// DescribeError returns a message for showing err to the user. API errors
+// lead with their summary and carry the request ID for support requests;
+// anything else is returned as is.
+func DescribeError(err error) string {
+	if err == nil {
+		return ""
+	}
+	apiErr, ok := AsAPIError(err)
+	if !ok {
+		return err.Error()
+	}
+	msg := apiErr.Summary()
+	if apiErr.Message != "" {
+		msg += ": " + apiErr.Message
+	}
+	if apiErr.RequestID != "" {
+		msg += " (request " + apiErr.RequestID + ")"
+	}
+	return msg
+}
+
+// newStreamError turns the payload of a stream error event into an
+// APIError, falling back to the raw payload when it cannot be parsed
+func newStreamError(data string) error {
+	var errResp ErrorResponse
+	if err := json.Unmarshal([]byte(data), &errResp); err != nil || errResp.Error.Type == "" {
+		return fmt.Errorf("stream error: %s", data)
+	}
+	return &APIError{
+		StatusCode: statusForErrorType[errResp.Error.Type],
+		Type:       errResp.Error.Type,
+		Message:    errResp.Error.Message,
+	}
+}
+
+// contextLengthMarkers are message fragments the API uses when a request exceeds the context window
+var contextLengthMarkers = []string{
+	"prompt is too long",
+	"context length",
+	"context window",
+	"maximum context",
+	"too many tokens",
+}
+
+// IsContextLengthError reports whether err means the request exceeded the model's context window
+func IsContextLengthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != 400 && apiErr.StatusCode != 413 {
+		return false
+	}
+
+	msg := strings.ToLower(apiErr.Message)
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anthropics/claude-code-go/internal/retry"
+)
+
+func TestAPIErrorRetryableAndSummary(t *testing.T) {
+	tests := []struct {
+		err       APIError
+		retryable bool
+		summary   string
+	}{
+		{APIError{StatusCode: 400, Type: "invalid_request_error"}, false, "Invalid request"},
+		{APIError{StatusCode: 401, Type: "authentication_error"}, false, "Invalid API key"},
+		{APIError{StatusCode: 403, Type: "permission_error"}, false, "API key lacks permission for this request"},
+		{APIError{StatusCode: 404, Type: "not_found_error"}, false, "Model or endpoint not found"},
+		{APIError{StatusCode: 413, Type: "request_too_large"}, false, "Request too large"},
+		{APIError{StatusCode: 429, Type: "rate_limit_error"}, true, "Rate limited"},
+		{APIError{StatusCode: 500, Type: "api_error"}, true, "Server error"},
+		{APIError{StatusCode: 504, Type: "timeout_error"}, true, "Request timed out"},
+		{APIError{StatusCode: 529, Type: "overloaded_error"}, true, "Server overloaded"},
+		// Status only, as from a proxy that sends no error body
+		{APIError{StatusCode: 408}, true, "Request timed out"},
+		{APIError{StatusCode: 502}, true, "Server error"},
+		{APIError{StatusCode: 418}, false, "API error"},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%d %s", tt.err.StatusCode, tt.err.Type)
+		t.Run(name, func(t *testing.T) {
+			if got := tt.err.Retryable(); got != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", got, tt.retryable)
+			}
+			if got := tt.err.Summary(); got != tt.summary {
+				t.Errorf("Summary() = %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
+
+func TestNewStreamError(t *testing.T) {
+	tests := []struct {
+		data   string
+		status int
+		typ    string
+	}{
+		{`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, 529, "overloaded_error"},
+		{`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, 429, "rate_limit_error"},
+		{`{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long"}}`, 400, "invalid_request_error"},
+		{`{"type":"error","error":{"type":"api_error","message":"Internal"}}`, 500, "api_error"},
+	}
+	for _, tt := range tests {
+		err := newStreamError(tt.data)
+		apiErr, ok := AsAPIError(err)
+		if !ok {
+			t.Errorf("newStreamError(%s) = %v, want an APIError", tt.data, err)
+			continue
+		}
+		if apiErr.StatusCode != tt.status || apiErr.Type != tt.typ {
+			t.Errorf("newStreamError(%s) = %d %s, want %d %s", tt.data, apiErr.StatusCode, apiErr.Type, tt.status, tt.typ)
+		}
+	}
+
+	for _, data := range []string{`not json`, `{"type":"error","error":{}}`} {
+		err := newStreamError(data)
+		if _, ok := AsAPIError(err); ok || err == nil {
+			t.Errorf("newStreamError(%s) = %v, want a plain stream error", data, err)
+		}
+	}
+}
+
+func TestStatusForErrorTypeMatchesSummary(t *testing.T) {
+	// A stream error and an HTTP error of the same kind read the same
+	for typ, status := range statusForErrorType {
+		byType := &APIError{Type: typ, StatusCode: status}
+		byStatus := &APIError{StatusCode: status}
+		if byType.Summary() != byStatus.Summary() || byType.Retryable() != byStatus.Retryable() {
+			t.Errorf("%s and status %d classify differently", typ, status)
+		}
+	}
+}
+
+func TestIsContextLengthError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"prompt too long", &APIError{StatusCode: 400, Message: "prompt is too long: 210000 tokens > 200000 maximum"}, true},
+		{"wrapped", fmt.Errorf("send: %w", &APIError{StatusCode: 400, Message: "Input exceeds the context window"}), true},
+		{"request too large", &APIError{StatusCode: 413, Message: "Too many tokens in request"}, true},
+		{"other invalid request", &APIError{StatusCode: 400, Message: "max_tokens must be positive"}, false},
+		{"server error mentioning context", &APIError{StatusCode: 500, Message: "context length"}, false},
+		{"plain error", errors.New("prompt is too long"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsContextLengthError(tt.err); got != tt.want {
+			t.Errorf("%s: IsContextLengthError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	err := fmt.Errorf("send: %w", &APIError{StatusCode: 401, Type: "authentication_error", Message: "invalid x-api-key", RequestID: "req_123"})
+	if got, want := DescribeError(err), "Invalid API key: invalid x-api-key (request req_123)"; got != want {
+		t.Errorf("DescribeError = %q, want %q", got, want)
+	}
+	if got := DescribeError(errors.New("dial tcp: refused")); got != "dial tcp: refused" {
+		t.Errorf("DescribeError of a plain error = %q", got)
+	}
+}
+
+func TestClassifyExhaustedBudgetIsTerminal(t *testing.T) {
+	overloaded := &APIError{StatusCode: 529, Type: "overloaded_error", Message: "Overloaded"}
+	if !retry.IsRetryable(overloaded) {
+		t.Fatal("overloaded error is not retryable")
+	}
+	exhausted := &retry.BudgetExhaustedError{LastErr: overloaded}
+	if retry.IsRetryable(exhausted) || retry.IsRetryable(fmt.Errorf("send: %w", exhausted)) {
+		t.Error("an exhausted retry budget is classified as retryable")
+	}
+	// The last API error is still reachable for describing it
+	if _, ok := AsAPIError(exhausted); !ok {
+		t.Error("the exhausted budget no longer exposes the last API error")
+	}
+}
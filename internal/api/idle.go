@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultStreamIdleTimeout is how long a stream may go without receiving any
+// data, keepalive pings included, before it is treated as dead
+const DefaultStreamIdleTimeout = 60 * time.Second
+
+// StreamIdleError is returned when a stream receives nothing for longer than
+// the idle timeout. It is retryable, so the agent resends the request like
+// any other broken stream.
+type StreamIdleError struct {
+	Timeout time.Duration
+}
+
+func (e *StreamIdleError) Error() string {
+	return fmt.Sprintf("stream idle timeout: no data received for %s", e.Timeout)
+}
+
+// Retryable reports that a stalled stream is worth another attempt
+func (e *StreamIdleError) Retryable() bool { return true }
+
+// idleReader closes the body when no data has arrived for timeout, which
+// unblocks a Read stuck on a half-open connection
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+
+	mu       sync.Mutex
+	timedOut bool
+}
+
+// newIdleReader wraps body with an idle timeout; a timeout <= 0 returns body
+// unchanged
+func newIdleReader(body io.ReadCloser, timeout time.Duration) io.ReadCloser {
+	if timeout <= 0 {
+		return body
+	}
+	r := &idleReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, r.expire)
+	return r
+}
+
+func (r *idleReader) expire() {
+	r.mu.Lock()
+	r.timedOut = true
+	r.mu.Unlock()
+	r.body.Close()
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.mu.Lock()
+	timedOut := r.timedOut
+	r.mu.Unlock()
+	if timedOut {
+		return n, &StreamIdleError{Timeout: r.timeout}
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	return r.body.Close()
+}
@@ -21,6 +21,9 @@ const (
 	ContentTypeToolUse    ContentType = "tool_use"
 	ContentTypeToolResult ContentType = "tool_result"
 	ContentTypeImage      ContentType = "image"
+
+	ContentTypeThinking         ContentType = "thinking"
+	ContentTypeRedactedThinking ContentType = "redacted_thinking"
 )
 
 // ToolStatus represents the status of a tool execution
@@ -43,6 +46,17 @@ type Content struct {
 	ToolUseID string          `json:"tool_use_id,omitempty"`
 	Content   string          `json:"content,omitempty"`
 	IsError   bool            `json:"is_error,omitempty"`
+	Source    *ImageSource    `json:"source,omitempty"`
+
+	// Extended thinking blocks; sent back unchanged on follow-up turns
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"` // Encrypted redacted_thinking content
+
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+
+	// Extra tool_result blocks (e.g. images), sent after Content as an array
+	Blocks []Content `json:"-"`
 
 	// Compaction support (internal use only, not sent to API)
 	Pruned   bool      `json:"-"` // 是否已被修剪
@@ -55,6 +69,89 @@ type Content struct {
 	ToolError     string     `json:"-"` // 工具错误信息
 }
 
+// CacheControl marks a prompt prefix for caching
+type CacheControl struct {
+	Type string `json:"type"`
+}
+
+// EphemeralCache returns the cache_control value for ephemeral prompt caching
+func EphemeralCache() *CacheControl {
+	return &CacheControl{Type: "ephemeral"}
+}
+
+// ImageSource holds the data of an image content block
+type ImageSource struct {
+	Type      string `json:"type"`       // Always "base64"
+	MediaType string `json:"media_type"` // e.g. image/png
+	Data      string `json:"data"`       // Base64-encoded image bytes
+}
+
+// NewImageContent creates an image content block from base64 data
+func NewImageContent(mediaType, data string) Content {
+	return Content{
+		Type: ContentTypeImage,
+		Source: &ImageSource{
+			Type:      "base64",
+			MediaType: mediaType,
+			Data:      data,
+		},
+	}
+}
+
+// contentAlias has Content's fields without its JSON methods
+type contentAlias Content
+
+// MarshalJSON encodes tool_result content as a block array when it carries
+// extra blocks, and as a plain string otherwise
+func (c Content) MarshalJSON() ([]byte, error) {
+	if c.Type != ContentTypeToolResult || len(c.Blocks) == 0 {
+		return json.Marshal(contentAlias(c))
+	}
+
+	blocks := make([]Content, 0, len(c.Blocks)+1)
+	if c.Content != "" {
+		blocks = append(blocks, Content{Type: ContentTypeText, Text: c.Content})
+	}
+	blocks = append(blocks, c.Blocks...)
+
+	return json.Marshal(struct {
+		contentAlias
+		Content []Content `json:"content"`
+	}{contentAlias(c), blocks})
+}
+
+// UnmarshalJSON accepts tool_result content as either a string or a block array
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		contentAlias
+		Content json.RawMessage `json:"content,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = Content(raw.contentAlias)
+
+	if len(raw.Content) == 0 || string(raw.Content) == "null" {
+		return nil
+	}
+	if raw.Content[0] == '"' {
+		return json.Unmarshal(raw.Content, &c.Content)
+	}
+
+	var blocks []Content
+	if err := json.Unmarshal(raw.Content, &blocks); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if block.Type == ContentTypeText && c.Content == "" && len(c.Blocks) == 0 {
+			c.Content = block.Text
+			continue
+		}
+		c.Blocks = append(c.Blocks, block)
+	}
+	return nil
+}
+
 // Message represents a conversation message
 type Message struct {
 	Role    Role      `json:"role"`
@@ -92,22 +189,65 @@ func NewToolResultMessage(toolUseID string, result string, isError bool) Message
 	}
 }
 
+// NewImageToolResultMessage creates a tool result message carrying an image
+func NewImageToolResultMessage(toolUseID string, mediaType, base64Data string) Message {
+	return Message{
+		Role: RoleUser,
+		Content: []Content{
+			{
+				Type:      ContentTypeToolResult,
+				ToolUseID: toolUseID,
+				Blocks:    []Content{NewImageContent(mediaType, base64Data)},
+			},
+		},
+	}
+}
+
 // Tool represents a tool definition for Claude
 type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	InputSchema map[string]interface{} `json:"input_schema"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	InputSchema  map[string]interface{} `json:"input_schema"`
+	CacheControl *CacheControl          `json:"cache_control,omitempty"`
 }
 
 // MessagesRequest represents a request to the Messages API
 type MessagesRequest struct {
-	Model       string    `json:"model"`
-	MaxTokens   int       `json:"max_tokens"`
-	System      string    `json:"system,omitempty"`
-	Messages    []Message `json:"messages"`
-	Tools       []Tool    `json:"tools,omitempty"`
-	Stream      bool      `json:"stream,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
+	Model       string          `json:"model"`
+	MaxTokens   int             `json:"max_tokens"`
+	System      string          `json:"system,omitempty"`
+	Messages    []Message       `json:"messages"`
+	Tools       []Tool          `json:"tools,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"` // nil = model default
+	Thinking    *ThinkingConfig `json:"thinking,omitempty"`
+
+	// StopSequences end generation when the model emits one of them
+	// (nil = the client's default)
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	// SystemBlocks replaces System with text blocks when set (e.g. to attach cache_control)
+	SystemBlocks []Content `json:"-"`
+}
+
+// ThinkingConfig enables extended thinking for a request
+type ThinkingConfig struct {
+	Type         string `json:"type"` // Always "enabled"
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// messagesRequestAlias has MessagesRequest's fields without its JSON methods
+type messagesRequestAlias MessagesRequest
+
+// MarshalJSON sends system as a block array when SystemBlocks is set
+func (r MessagesRequest) MarshalJSON() ([]byte, error) {
+	if len(r.SystemBlocks) == 0 {
+		return json.Marshal(messagesRequestAlias(r))
+	}
+	return json.Marshal(struct {
+		messagesRequestAlias
+		System []Content `json:"system"`
+	}{messagesRequestAlias(r), r.SystemBlocks})
 }
 
 // MessagesResponse represents a non-streaming response from the Messages API
@@ -144,10 +284,13 @@ type StreamEvent struct {
 
 // Delta represents incremental content in a streaming response
 type Delta struct {
-	Type        string `json:"type,omitempty"`
-	Text        string `json:"text,omitempty"`
-	PartialJSON string `json:"partial_json,omitempty"`
-	StopReason  string `json:"stop_reason,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Text         string `json:"text,omitempty"`
+	PartialJSON  string `json:"partial_json,omitempty"`
+	Thinking     string `json:"thinking,omitempty"`
+	Signature    string `json:"signature,omitempty"`
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
 }
 
 // ErrorResponse represents an error response from the API
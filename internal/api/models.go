@@ -0,0 +1,17 @@
+package api
+
+// ModelInfo describes a model offered in model pickers
+type ModelInfo struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// KnownModels lists the models offered for interactive selection.
+// Any other model ID can still be set via --model or the config file.
+var KnownModels = []ModelInfo{
+	{ID: "claude-sonnet-4-20250514", Name: "Claude Sonnet 4", Description: "Balanced speed and capability (default)"},
+	{ID: "claude-opus-4-20250514", Name: "Claude Opus 4", Description: "Most capable, slower and more expensive"},
+	{ID: "claude-3-7-sonnet-20250219", Name: "Claude Sonnet 3.7", Description: "Previous generation Sonnet"},
+	{ID: "claude-3-5-haiku-20241022", Name: "Claude Haiku 3.5", Description: "Fastest and cheapest"},
+}
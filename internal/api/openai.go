@@ -0,0 +1,603 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/logger"
+)
+
+const (
+	// DefaultOpenAIBaseURL includes the /v1 path, as OpenAI-compatible servers expect
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	// ChatCompletionsEndpoint is the OpenAI chat completions endpoint
+	ChatCompletionsEndpoint = "chat/completions"
+)
+
+// OpenAIClient talks to an OpenAI-compatible /chat/completions endpoint
+// (OpenAI, Ollama, vLLM, LM Studio, ...) and translates requests and
+// responses to and from the Messages API types, so the agent loop does not
+// need to know which backend it is using
+type OpenAIClient struct {
+	*Client // Shared settings: model, max tokens, HTTP client, retrier, rate limits
+}
+
+// NewOpenAIClient creates a client for an OpenAI-compatible API. The
+// credential is sent as a Bearer token and may be empty for local servers.
+func NewOpenAIClient(credential string, opts ...ClientOption) *OpenAIClient {
+	opts = append([]ClientOption{WithBaseURL(DefaultOpenAIBaseURL)}, opts...)
+	return &OpenAIClient{Client: NewClient(credential, opts...)}
+}
+
+// CountTokens always fails with ErrCountTokensUnsupported: OpenAI-compatible
+// APIs have no count_tokens endpoint, and the embedded Client would send
+// them an Anthropic-format request
+func (c *OpenAIClient) CountTokens(ctx context.Context, req *MessagesRequest) (int, error) {
+	return 0, ErrCountTokensUnsupported
+}
+
+// DetectOpenAIBaseURL reports whether baseURL looks like an OpenAI-compatible
+// endpoint: api.openai.com, or a base URL ending in /v1 (Anthropic base URLs
+// are given without the version path)
+func DetectOpenAIBaseURL(baseURL string) bool {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "api.openai.com" {
+		return true
+	}
+	return strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/v1")
+}
+
+// openAIRequest is a chat completions request
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	Stop          []string             `json:"stop,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIMessage is a chat message. Content is a string, or a part array when
+// the message carries images.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAITool struct {
+	Type     string             `json:"type"` // Always "function"
+	Function openAIToolFunction `json:"function"`
+}
+
+type openAIToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+type openAIToolCall struct {
+	Index    *int               `json:"index,omitempty"` // Only in stream deltas
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// openAIResponse is a chat completions response or stream chunk
+type openAIResponse struct {
+	ID      string         `json:"id"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+	Error   *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type openAIChoice struct {
+	Message      *openAIResponseMessage `json:"message,omitempty"`
+	Delta        *openAIResponseMessage `json:"delta,omitempty"`
+	FinishReason string                 `json:"finish_reason,omitempty"`
+}
+
+type openAIResponseMessage struct {
+	Content          string           `json:"content,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"` // DeepSeek and others
+	ToolCalls        []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// CreateMessage sends a non-streaming chat completions request
+func (c *OpenAIClient) CreateMessage(ctx context.Context, req *MessagesRequest) (*MessagesResponse, error) {
+	if req.Model == "" {
+		req.Model = c.GetModel()
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = c.maxTokens
+	}
+
+	httpReq, err := c.newRequest(ctx, toOpenAIRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	resp, err := c.retrier.Do(ctx, func() (*http.Response, error) {
+		return c.httpClient.Do(rewind(httpReq))
+	})
+	duration := time.Since(startTime)
+
+	if err != nil {
+		if log := logger.GetLogger(); log != nil {
+			log.LogError("http_request_failed", err, map[string]interface{}{
+				"url":      httpReq.URL.String(),
+				"duration": duration.String(),
+			})
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	c.recordRateLimit(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if log := logger.GetLogger(); log != nil {
+		var respBodyMap map[string]interface{}
+		json.Unmarshal(respBody, &respBodyMap)
+		log.LogAPIResponse(resp.StatusCode, firstHeaderValues(resp.Header), respBodyMap, duration)
+	}
+
+	var result openAIResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 || result.Choices[0].Message == nil {
+		return nil, fmt.Errorf("response contains no choices")
+	}
+
+	choice := result.Choices[0]
+	out := &MessagesResponse{
+		ID:         result.ID,
+		Type:       "message",
+		Role:       RoleAssistant,
+		Model:      result.Model,
+		StopReason: openAIStopReason(choice.FinishReason),
+	}
+	if choice.Message.Content != "" {
+		out.Content = append(out.Content, Content{Type: ContentTypeText, Text: choice.Message.Content})
+	}
+	for _, call := range choice.Message.ToolCalls {
+		out.Content = append(out.Content, Content{
+			Type:  ContentTypeToolUse,
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: toolArguments(call.Function.Arguments),
+		})
+	}
+	if result.Usage != nil {
+		out.Usage = Usage{InputTokens: result.Usage.PromptTokens, OutputTokens: result.Usage.CompletionTokens}
+	}
+
+	return out, nil
+}
+
+// StreamMessage sends a streaming chat completions request. The returned
+// reader yields the same chunk sequence as the Messages API stream.
+func (c *OpenAIClient) StreamMessage(ctx context.Context, req *MessagesRequest) (*StreamReader, error) {
+	if req.Model == "" {
+		req.Model = c.GetModel()
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = c.maxTokens
+	}
+	if req.Temperature == nil {
+		req.Temperature = c.temperature
+	}
+	if req.StopSequences == nil {
+		req.StopSequences = c.stopSequences
+	}
+
+	httpReq, err := c.newRequest(ctx, toOpenAIRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	resp, err := c.retrier.Do(ctx, func() (*http.Response, error) {
+		return c.httpClient.Do(rewind(httpReq))
+	})
+	if err != nil {
+		if log := logger.GetLogger(); log != nil {
+			log.LogError("http_request_failed", err, map[string]interface{}{
+				"url":      httpReq.URL.String(),
+				"duration": time.Since(startTime).String(),
+			})
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	c.recordRateLimit(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if log := logger.GetLogger(); log != nil {
+			log.LogAPIResponse(resp.StatusCode, firstHeaderValues(resp.Header), "error response", time.Since(startTime))
+		}
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	if log := logger.GetLogger(); log != nil {
+		log.LogAPIResponse(resp.StatusCode, firstHeaderValues(resp.Header), "stream_started", time.Since(startTime))
+	}
+
+	stream := NewStreamReader(newIdleReader(resp.Body, c.streamIdleTimeout))
+	stream.decoder = &openAIStreamDecoder{response: stream.response, textIndex: -1}
+	return stream, nil
+}
+
+// newRequest builds and logs a chat completions HTTP request
+func (c *OpenAIClient) newRequest(ctx context.Context, body *openAIRequest) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.buildURL(ChatCompletionsEndpoint), bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	credential, err := c.currentCredential(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if credential != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+credential)
+	}
+
+	if log := logger.GetLogger(); log != nil {
+		var bodyMap map[string]interface{}
+		json.Unmarshal(data, &bodyMap)
+		log.LogAPIRequest("POST", httpReq.URL.String(), firstHeaderValues(httpReq.Header), bodyMap)
+	}
+
+	return httpReq, nil
+}
+
+// firstHeaderValues flattens headers for logging
+func firstHeaderValues(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range h {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+	return headers
+}
+
+// toOpenAIRequest translates a Messages API request. Thinking blocks and
+// settings have no equivalent and are dropped.
+func toOpenAIRequest(req *MessagesRequest, stream bool) *openAIRequest {
+	out := &openAIRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stop:        req.StopSequences,
+		Stream:      stream,
+	}
+	if stream {
+		out.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	system := req.System
+	if len(req.SystemBlocks) > 0 {
+		var parts []string
+		for _, block := range req.SystemBlocks {
+			parts = append(parts, block.Text)
+		}
+		system = strings.Join(parts, "\n\n")
+	}
+	if system != "" {
+		out.Messages = append(out.Messages, openAIMessage{Role: "system", Content: system})
+	}
+
+	for _, msg := range req.Messages {
+		out.Messages = append(out.Messages, toOpenAIMessages(msg)...)
+	}
+
+	for _, tool := range req.Tools {
+		out.Tools = append(out.Tools, openAITool{
+			Type: "function",
+			Function: openAIToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+
+	return out
+}
+
+// toOpenAIMessages translates one message. Tool results become separate
+// "tool" messages, which must directly follow the assistant's tool calls.
+func toOpenAIMessages(msg Message) []openAIMessage {
+	var messages []openAIMessage
+	var text []string
+	var parts []openAIContentPart
+	var calls []openAIToolCall
+
+	for _, block := range msg.Content {
+		switch block.Type {
+		case ContentTypeText:
+			text = append(text, block.Text)
+			parts = append(parts, openAIContentPart{Type: "text", Text: block.Text})
+		case ContentTypeImage:
+			if block.Source != nil {
+				parts = append(parts, openAIImagePart(block.Source))
+			}
+		case ContentTypeToolUse:
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			calls = append(calls, openAIToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: openAIFunctionCall{Name: block.Name, Arguments: args},
+			})
+		case ContentTypeToolResult:
+			content := block.Content
+			if block.IsError {
+				content = "Error: " + content
+			}
+			messages = append(messages, openAIMessage{Role: "tool", Content: content, ToolCallID: block.ToolUseID})
+			// Chat completions only accept images in user messages
+			for _, extra := range block.Blocks {
+				if extra.Type == ContentTypeImage && extra.Source != nil {
+					parts = append(parts, openAIImagePart(extra.Source))
+				}
+			}
+		}
+	}
+
+	if msg.Role == RoleAssistant {
+		if len(text) > 0 || len(calls) > 0 {
+			m := openAIMessage{Role: "assistant", ToolCalls: calls}
+			if len(text) > 0 {
+				m.Content = strings.Join(text, "")
+			}
+			messages = append(messages, m)
+		}
+		return messages
+	}
+
+	if len(parts) > len(text) {
+		messages = append(messages, openAIMessage{Role: "user", Content: parts})
+	} else if len(text) > 0 {
+		messages = append(messages, openAIMessage{Role: "user", Content: strings.Join(text, "\n\n")})
+	}
+	return messages
+}
+
+// openAIImagePart converts an image source into a data URI content part
+func openAIImagePart(src *ImageSource) openAIContentPart {
+	return openAIContentPart{
+		Type:     "image_url",
+		ImageURL: &openAIImageURL{URL: "data:" + src.MediaType + ";base64," + src.Data},
+	}
+}
+
+// openAIStopReason maps a finish_reason to the Messages API stop reason
+func openAIStopReason(reason string) string {
+	switch reason {
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	case "":
+		return ""
+	default:
+		return "end_turn"
+	}
+}
+
+// toolArguments returns tool call arguments as JSON, falling back to an
+// empty object when a model emits none
+func toolArguments(args string) json.RawMessage {
+	if strings.TrimSpace(args) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(args)
+}
+
+// openAIToolCallState accumulates one streamed tool call
+type openAIToolCallState struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// openAIStreamDecoder turns chat completion chunks into stream chunks.
+// Text is forwarded as it arrives; tool calls are accumulated, since their
+// arguments may be split over many chunks and interleaved by index, and
+// emitted as start/delta/stop once the choice finishes.
+type openAIStreamDecoder struct {
+	response  *MessagesResponse
+	textIndex int // Index of the text block in response.Content (-1 until text arrives)
+	calls     map[int]*openAIToolCallState
+	finished  bool
+}
+
+func (d *openAIStreamDecoder) decode(data string) ([]*StreamChunk, error) {
+	var event openAIResponse
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	if log := logger.GetLogger(); log != nil {
+		log.LogStreamChunk("chat.completion.chunk", event)
+	}
+
+	if event.Error != nil {
+		return []*StreamChunk{{Type: "error", Error: fmt.Errorf("stream error: %s", event.Error.Message)}}, nil
+	}
+
+	if event.ID != "" && d.response.ID == "" {
+		d.response.ID = event.ID
+		d.response.Model = event.Model
+		d.response.Role = RoleAssistant
+	}
+	// With include_usage the usage arrives in a final chunk without choices
+	if event.Usage != nil {
+		d.response.Usage.InputTokens = event.Usage.PromptTokens
+		d.response.Usage.OutputTokens = event.Usage.CompletionTokens
+	}
+
+	var chunks []*StreamChunk
+	for _, choice := range event.Choices {
+		if delta := choice.Delta; delta != nil {
+			if delta.ReasoningContent != "" {
+				chunks = append(chunks, &StreamChunk{Type: "thinking", Text: delta.ReasoningContent})
+			}
+			if delta.Content != "" {
+				if d.textIndex < 0 {
+					d.textIndex = len(d.response.Content)
+					d.response.Content = append(d.response.Content, Content{Type: ContentTypeText})
+				}
+				d.response.Content[d.textIndex].Text += delta.Content
+				chunks = append(chunks, &StreamChunk{Type: "text", Text: delta.Content, Index: d.textIndex})
+			}
+			for _, call := range delta.ToolCalls {
+				d.addToolCallDelta(call)
+			}
+		}
+		if choice.FinishReason != "" && !d.finished {
+			d.response.StopReason = openAIStopReason(choice.FinishReason)
+			chunks = append(chunks, d.flush()...)
+		}
+	}
+	return chunks, nil
+}
+
+// addToolCallDelta merges a tool call fragment into its accumulated call
+func (d *openAIStreamDecoder) addToolCallDelta(call openAIToolCall) {
+	index := len(d.calls)
+	if call.Index != nil {
+		index = *call.Index
+	}
+	if d.calls == nil {
+		d.calls = make(map[int]*openAIToolCallState)
+	}
+	state, ok := d.calls[index]
+	if !ok {
+		state = &openAIToolCallState{}
+		d.calls[index] = state
+	}
+	if call.ID != "" {
+		state.id = call.ID
+	}
+	if call.Function.Name != "" {
+		state.name = call.Function.Name
+	}
+	state.arguments.WriteString(call.Function.Arguments)
+}
+
+// finish is called at the end of the stream; servers that never send a
+// finish_reason still get their tool calls and message_stop emitted
+func (d *openAIStreamDecoder) finish() []*StreamChunk {
+	if d.finished {
+		return nil
+	}
+	if d.response.StopReason == "" {
+		if len(d.calls) > 0 {
+			d.response.StopReason = "tool_use"
+		} else {
+			d.response.StopReason = "end_turn"
+		}
+	}
+	return d.flush()
+}
+
+// flush emits the accumulated tool calls as complete content blocks followed by message_stop
+func (d *openAIStreamDecoder) flush() []*StreamChunk {
+	d.finished = true
+
+	indexes := make([]int, 0, len(d.calls))
+	for index := range d.calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var chunks []*StreamChunk
+	for n, index := range indexes {
+		state := d.calls[index]
+		if state.id == "" {
+			state.id = fmt.Sprintf("call_%d", n)
+		}
+		block := Content{
+			Type:  ContentTypeToolUse,
+			ID:    state.id,
+			Name:  state.name,
+			Input: toolArguments(state.arguments.String()),
+		}
+		blockIndex := len(d.response.Content)
+		d.response.Content = append(d.response.Content, block)
+
+		chunks = append(chunks,
+			&StreamChunk{Type: "tool_use_start", ContentBlock: &block, Index: blockIndex},
+			&StreamChunk{Type: "tool_use_delta", PartialJSON: string(block.Input), Index: blockIndex},
+			&StreamChunk{Type: "content_block_stop", Index: blockIndex},
+		)
+	}
+
+	return append(chunks, &StreamChunk{Type: "message_stop", StopReason: d.response.StopReason})
+}
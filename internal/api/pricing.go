@@ -0,0 +1,71 @@
+package api
+
+import "strings"
+
+// ModelPricing holds a model's rates in USD per million tokens
+type ModelPricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read"`
+	CacheWrite float64 `json:"cache_write"`
+}
+
+// DefaultPricing maps model ID prefixes to their list prices. Dated model
+// IDs match the longest prefix, e.g. claude-sonnet-4-20250514 uses
+// claude-sonnet-4.
+var DefaultPricing = map[string]ModelPricing{
+	"claude-opus-4":     {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-sonnet": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+	"claude-3-opus":     {Input: 15, Output: 75, CacheRead: 1.5, CacheWrite: 18.75},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.3},
+}
+
+// LookupPricing returns the rates for model, preferring the longest matching
+// prefix in overrides and then in DefaultPricing
+func LookupPricing(model string, overrides map[string]ModelPricing) (ModelPricing, bool) {
+	for _, table := range []map[string]ModelPricing{overrides, DefaultPricing} {
+		best := ""
+		for prefix := range table {
+			if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+				best = prefix
+			}
+		}
+		if best != "" {
+			return table[best], true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// CostBreakdown is an estimated cost in USD by token category
+type CostBreakdown struct {
+	Input      float64
+	Output     float64
+	CacheRead  float64
+	CacheWrite float64
+
+	// CacheSavings is what the cache reads would have cost at the full input
+	// rate, minus what they did cost
+	CacheSavings float64
+}
+
+// Total returns the total estimated cost
+func (c CostBreakdown) Total() float64 {
+	return c.Input + c.Output + c.CacheRead + c.CacheWrite
+}
+
+// EstimateCost prices token usage at the given rates
+func EstimateCost(p ModelPricing, usage Usage) CostBreakdown {
+	const perToken = 1.0 / 1_000_000
+	cost := CostBreakdown{
+		Input:      float64(usage.InputTokens) * p.Input * perToken,
+		Output:     float64(usage.OutputTokens) * p.Output * perToken,
+		CacheRead:  float64(usage.CacheReadInputTokens) * p.CacheRead * perToken,
+		CacheWrite: float64(usage.CacheCreationInputTokens) * p.CacheWrite * perToken,
+	}
+	cost.CacheSavings = float64(usage.CacheReadInputTokens)*p.Input*perToken - cost.CacheRead
+	return cost
+}
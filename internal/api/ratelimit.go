@@ -0,0 +1,126 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitWarnRatio is the fraction of a limit below which NearLimit reports true
+const RateLimitWarnRatio = 0.1
+
+// RateLimitStatus is the rate limit state reported by the anthropic-ratelimit-*
+// response headers. Counts are -1 when the API did not send them.
+type RateLimitStatus struct {
+	RequestsLimit     int
+	RequestsRemaining int
+	RequestsReset     time.Time
+	TokensLimit       int
+	TokensRemaining   int
+	TokensReset       time.Time
+	UpdatedAt         time.Time // When the headers were received
+}
+
+// parseRateLimitHeaders reads the rate limit headers from a response,
+// reporting false when none are present (e.g. behind a proxy)
+func parseRateLimitHeaders(h http.Header) (RateLimitStatus, bool) {
+	status := RateLimitStatus{
+		RequestsLimit:     headerInt(h, "anthropic-ratelimit-requests-limit"),
+		RequestsRemaining: headerInt(h, "anthropic-ratelimit-requests-remaining"),
+		RequestsReset:     headerTime(h, "anthropic-ratelimit-requests-reset"),
+		TokensLimit:       headerInt(h, "anthropic-ratelimit-tokens-limit"),
+		TokensRemaining:   headerInt(h, "anthropic-ratelimit-tokens-remaining"),
+		TokensReset:       headerTime(h, "anthropic-ratelimit-tokens-reset"),
+		UpdatedAt:         time.Now(),
+	}
+	if status.RequestsRemaining < 0 && status.TokensRemaining < 0 {
+		return RateLimitStatus{}, false
+	}
+	return status, true
+}
+
+func headerInt(h http.Header, key string) int {
+	n, err := strconv.Atoi(h.Get(key))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func headerTime(h http.Header, key string) time.Time {
+	t, _ := time.Parse(time.RFC3339, h.Get(key))
+	return t
+}
+
+// NearLimit reports whether the remaining requests or tokens have dropped
+// below RateLimitWarnRatio of their limit
+func (s RateLimitStatus) NearLimit() bool {
+	return nearLimit(s.RequestsRemaining, s.RequestsLimit) || nearLimit(s.TokensRemaining, s.TokensLimit)
+}
+
+func nearLimit(remaining, limit int) bool {
+	return remaining >= 0 && limit > 0 && float64(remaining) < float64(limit)*RateLimitWarnRatio
+}
+
+// Summary describes what is left, e.g. "3 requests, 12000 tokens left; resets 14:05:10"
+func (s RateLimitStatus) Summary() string {
+	var left string
+	switch {
+	case s.RequestsRemaining >= 0 && s.TokensRemaining >= 0:
+		left = fmt.Sprintf("%d requests, %d tokens left", s.RequestsRemaining, s.TokensRemaining)
+	case s.RequestsRemaining >= 0:
+		left = fmt.Sprintf("%d requests left", s.RequestsRemaining)
+	default:
+		left = fmt.Sprintf("%d tokens left", s.TokensRemaining)
+	}
+
+	// Report the earliest reset among the limits that are running low
+	var reset time.Time
+	if nearLimit(s.RequestsRemaining, s.RequestsLimit) {
+		reset = s.RequestsReset
+	}
+	if nearLimit(s.TokensRemaining, s.TokensLimit) && (reset.IsZero() || (!s.TokensReset.IsZero() && s.TokensReset.Before(reset))) {
+		reset = s.TokensReset
+	}
+	if !reset.IsZero() {
+		left += "; resets " + reset.Local().Format("15:04:05")
+	}
+	return left
+}
+
+// GetRateLimitStatus returns the rate limit state from the most recent
+// response, reporting false if no response has carried rate limit headers
+func (c *Client) GetRateLimitStatus() (RateLimitStatus, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.rateLimit == nil {
+		return RateLimitStatus{}, false
+	}
+	return *c.rateLimit, true
+}
+
+// SetRateLimitCallback sets a function called with the rate limit state
+// after every response that carries rate limit headers
+func (c *Client) SetRateLimitCallback(fn func(RateLimitStatus)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRateLimit = fn
+}
+
+// recordRateLimit stores the rate limit state from resp and notifies the callback
+func (c *Client) recordRateLimit(resp *http.Response) {
+	status, ok := parseRateLimitHeaders(resp.Header)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	c.rateLimit = &status
+	fn := c.onRateLimit
+	c.mu.Unlock()
+
+	if fn != nil {
+		fn(status)
+	}
+}
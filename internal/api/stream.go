@@ -16,6 +16,15 @@ type StreamReader struct {
 	body     io.ReadCloser
 	closed   bool
 	response *MessagesResponse
+
+	decoder streamDecoder  // Decodes non-Anthropic event streams (nil = Messages API events)
+	pending []*StreamChunk // Decoded chunks not yet returned
+}
+
+// streamDecoder translates another API's SSE payloads into stream chunks
+type streamDecoder interface {
+	decode(data string) ([]*StreamChunk, error)
+	finish() []*StreamChunk // Chunks still owed when the stream ends
 }
 
 // NewStreamReader creates a new stream reader
@@ -31,59 +40,101 @@ func NewStreamReader(body io.ReadCloser) *StreamReader {
 
 // StreamChunk represents a chunk of streamed data
 type StreamChunk struct {
-	Type         string   // "text", "tool_use_start", "tool_use_delta", "content_block_stop", "message_stop", "error"
-	Text         string   // For text chunks
+	Type         string   // "text", "thinking", "tool_use_start", "tool_use_delta", "content_block_stop", "message_stop", "error"
+	Text         string   // For text and thinking chunks
 	ContentBlock *Content // For tool use starts
 	Index        int      // Content block index
 	PartialJSON  string   // For tool use input deltas
 	StopReason   string   // For message stop
+	StopSequence string   // For message stop with reason "stop_sequence"
 	Error        error    // For errors
 }
 
 // Next reads the next event from the stream
 func (s *StreamReader) Next() (*StreamChunk, error) {
+	if len(s.pending) > 0 {
+		chunk := s.pending[0]
+		s.pending = s.pending[1:]
+		return chunk, nil
+	}
 	if s.closed {
 		return nil, io.EOF
 	}
 
 	for {
-		line, err := s.reader.ReadString('\n')
+		data, err := s.readEvent()
+		if err == io.EOF {
+			s.Close()
+			return s.finish()
+		}
 		if err != nil {
-			if err == io.EOF {
-				s.Close()
-			}
 			return nil, err
 		}
 
-		line = strings.TrimSpace(line)
+		// Check for stream end
+		if data == "[DONE]" {
+			s.Close()
+			return s.finish()
+		}
 
-		// Skip empty lines
-		if line == "" {
+		if s.decoder != nil {
+			chunks, err := s.decoder.decode(data)
+			if err != nil {
+				return &StreamChunk{Type: "error", Error: err}, nil
+			}
+			if len(chunks) > 0 {
+				s.pending = chunks[1:]
+				return chunks[0], nil
+			}
 			continue
 		}
 
-		// Parse SSE data
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
+		chunk, err := s.parseEvent(data)
+		if err != nil {
+			return &StreamChunk{Type: "error", Error: err}, nil
+		}
+		if chunk != nil {
+			return chunk, nil
+		}
+	}
+}
 
-			// Check for stream end
-			if data == "[DONE]" {
-				s.Close()
-				return nil, io.EOF
-			}
+// readEvent reads the next SSE event with data and returns the data: its
+// data lines joined by newlines. Events end at a blank line; other fields
+// (event, id, retry) and comments are skipped. Lines may be of any length.
+// Data left without a closing blank line at the end of the stream is still
+// returned, then io.EOF.
+func (s *StreamReader) readEvent() (string, error) {
+	var data []string
+	hasData := false
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		eof := err == io.EOF
+		line = strings.TrimRight(line, "\r\n")
 
-			chunk, err := s.parseEvent(data)
-			if err != nil {
-				return &StreamChunk{Type: "error", Error: err}, nil
+		switch {
+		case line == "":
+			if hasData {
+				return strings.Join(data, "\n"), nil
 			}
-			if chunk != nil {
-				return chunk, nil
+		case strings.HasPrefix(line, ":"):
+			// Comment, e.g. a keepalive
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			if field == "data" {
+				data = append(data, strings.TrimPrefix(value, " "))
+				hasData = true
 			}
 		}
 
-		// Skip event type lines
-		if strings.HasPrefix(line, "event: ") {
-			continue
+		if eof {
+			if hasData {
+				return strings.Join(data, "\n"), nil
+			}
+			return "", io.EOF
 		}
 	}
 }
@@ -144,6 +195,24 @@ func (s *StreamReader) parseEvent(data string) (*StreamChunk, error) {
 				}, nil
 			}
 
+			// Handle thinking deltas; the signature is only kept for the history
+			if event.Delta.Thinking != "" {
+				if event.Index < len(s.response.Content) {
+					s.response.Content[event.Index].Thinking += event.Delta.Thinking
+				}
+				return &StreamChunk{
+					Type:  "thinking",
+					Text:  event.Delta.Thinking,
+					Index: event.Index,
+				}, nil
+			}
+			if event.Delta.Signature != "" {
+				if event.Index < len(s.response.Content) {
+					s.response.Content[event.Index].Signature += event.Delta.Signature
+				}
+				return nil, nil
+			}
+
 			// Handle tool input delta
 			if event.Delta.PartialJSON != "" {
 				return &StreamChunk{
@@ -164,6 +233,7 @@ func (s *StreamReader) parseEvent(data string) (*StreamChunk, error) {
 	case "message_delta":
 		if event.Delta != nil && event.Delta.StopReason != "" {
 			s.response.StopReason = event.Delta.StopReason
+			s.response.StopSequence = event.Delta.StopSequence
 		}
 		if event.Usage != nil {
 			s.response.Usage.OutputTokens = event.Usage.OutputTokens
@@ -172,8 +242,9 @@ func (s *StreamReader) parseEvent(data string) (*StreamChunk, error) {
 
 	case "message_stop":
 		return &StreamChunk{
-			Type:       "message_stop",
-			StopReason: s.response.StopReason,
+			Type:         "message_stop",
+			StopReason:   s.response.StopReason,
+			StopSequence: s.response.StopSequence,
 		}, nil
 
 	case "ping":
@@ -182,13 +253,27 @@ func (s *StreamReader) parseEvent(data string) (*StreamChunk, error) {
 	case "error":
 		return &StreamChunk{
 			Type:  "error",
-			Error: fmt.Errorf("stream error: %s", data),
+			Error: newStreamError(data),
 		}, nil
 	}
 
 	return nil, nil
 }
 
+// finish returns the chunks the decoder still owes at the end of the
+// stream, one at a time, then io.EOF
+func (s *StreamReader) finish() (*StreamChunk, error) {
+	if s.decoder != nil {
+		s.pending = append(s.pending, s.decoder.finish()...)
+	}
+	if len(s.pending) > 0 {
+		chunk := s.pending[0]
+		s.pending = s.pending[1:]
+		return chunk, nil
+	}
+	return nil, io.EOF
+}
+
 // GetResponse returns the accumulated response
 func (s *StreamReader) GetResponse() *MessagesResponse {
 	return s.response
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// chunkedBody returns a body that delivers at most n bytes per Read, so
+// frames and lines are split across reads
+func chunkedBody(stream string, n int) io.ReadCloser {
+	return io.NopCloser(&chunkReader{r: strings.NewReader(stream), n: n})
+}
+
+type chunkReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(p) > c.n {
+		p = p[:c.n]
+	}
+	return c.r.Read(p)
+}
+
+// readChunks drains a stream reader
+func readChunks(t *testing.T, s *StreamReader) []*StreamChunk {
+	t.Helper()
+	var chunks []*StreamChunk
+	for {
+		chunk, err := s.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestStreamReaderChunkedMultiLineFrames(t *testing.T) {
+	bigInput := `{\"content\":\"` + strings.Repeat("x", 200*1024) + `\"}`
+	stream := ": keepalive comment\r\n" +
+		"event: message_start\r\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":5,"output_tokens":0}}}` + "\r\n\r\n" +
+		"event: content_block_start\n" +
+		// One JSON event split over two data lines
+		`data: {"type":"content_block_start","index":0,` + "\n" +
+		`data: "content_block":{"type":"tool_use","id":"tu_1","name":"Write","input":{}}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"` + bigInput + `"}}` + "\n\n" +
+		"event: content_block_stop\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}` + "\n\n" +
+		"event: message_stop\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	for _, n := range []int{1, 7, 4096} {
+		s := NewStreamReader(chunkedBody(stream, n))
+		chunks := readChunks(t, s)
+
+		var start *StreamChunk
+		var partial strings.Builder
+		stop := ""
+		for _, c := range chunks {
+			switch c.Type {
+			case "tool_use_start":
+				start = c
+			case "tool_use_delta":
+				partial.WriteString(c.PartialJSON)
+			case "message_stop":
+				stop = c.StopReason
+			case "error":
+				t.Fatalf("read %d bytes at a time: error chunk %v", n, c.Error)
+			}
+		}
+		if start == nil || start.ContentBlock.Name != "Write" {
+			t.Errorf("read %d bytes at a time: missing the tool_use start split over two data lines", n)
+		}
+		if got := partial.Len(); got != len(bigInput)-4 {
+			t.Errorf("read %d bytes at a time: tool input is %d bytes, want %d", n, got, len(bigInput)-4)
+		}
+		if stop != "tool_use" {
+			t.Errorf("read %d bytes at a time: stop reason %q, want tool_use", n, stop)
+		}
+	}
+}
+
+func TestStreamReaderDataWithoutTrailingBlankLine(t *testing.T) {
+	s := NewStreamReader(io.NopCloser(iotest.OneByteReader(strings.NewReader(
+		`data: {"type":"message_stop"}`))))
+	chunks := readChunks(t, s)
+	if len(chunks) == 0 || chunks[len(chunks)-1].Type != "message_stop" {
+		t.Errorf("chunks = %+v, want the unterminated message_stop", chunks)
+	}
+}
@@ -10,11 +10,11 @@ import (
 
 // Compactor 压缩器
 type Compactor struct {
-	client *api.Client
+	client api.MessageClient
 }
 
 // NewCompactor 创建新的压缩器
-func NewCompactor(client *api.Client) *Compactor {
+func NewCompactor(client api.MessageClient) *Compactor {
 	return &Compactor{
 		client: client,
 	}
@@ -117,6 +117,53 @@ Focus on:
 
 Keep the summary clear and organized.`
 
+	// 3. 调用 API
+	return c.requestSummary(ctx, systemPrompt,
+		fmt.Sprintf("Please summarize the following conversation:\n\n%s", historyText), model, maxTokens)
+}
+
+// Summarize 生成面向用户的进度摘要（只读，不修改会话）
+func (c *Compactor) Summarize(ctx context.Context, messages []api.Message, model string, maxTokens int) (string, error) {
+	if len(messages) == 0 {
+		return "", fmt.Errorf("nothing to summarize yet")
+	}
+	if model == "" {
+		model = "claude-sonnet-4-20250514"
+	}
+	if maxTokens == 0 {
+		maxTokens = 2000
+	}
+
+	systemPrompt := `You are a summarization assistant. Write a short progress report for a user who stepped away from a coding session.
+
+Cover:
+- What the user asked for
+- What has been done so far (files read or changed, commands run, decisions made)
+- Current status and any open questions or next steps
+
+Use brief markdown bullet points. Do not invent details that are not in the conversation.`
+
+	return c.requestSummary(ctx, systemPrompt,
+		fmt.Sprintf("Summarize the progress of this conversation so far:\n\n%s", c.buildHistoryText(messages)), model, maxTokens)
+}
+
+// Title 用模型为会话生成简短标题
+func (c *Compactor) Title(ctx context.Context, firstMessage, model string) (string, error) {
+	if model == "" {
+		model = "claude-sonnet-4-20250514"
+	}
+	// 按字符截断，避免切断多字节字符
+	if runes := []rune(firstMessage); len(runes) > 2000 {
+		firstMessage = string(runes[:2000])
+	}
+
+	systemPrompt := `Generate a short title (at most 6 words) for a coding session that starts with the user's message below. Reply with the title only: no quotes, no trailing punctuation.`
+
+	return c.requestSummary(ctx, systemPrompt, firstMessage, model, 30)
+}
+
+// requestSummary 调用模型（不带工具）生成摘要文本
+func (c *Compactor) requestSummary(ctx context.Context, systemPrompt, prompt, model string, maxTokens int) (string, error) {
 	req := &api.MessagesRequest{
 		Model:     model,
 		MaxTokens: maxTokens,
@@ -126,7 +173,7 @@ Keep the summary clear and organized.`
 				Content: []api.Content{
 					{
 						Type: api.ContentTypeText,
-						Text: fmt.Sprintf("Please summarize the following conversation:\n\n%s", historyText),
+						Text: prompt,
 					},
 				},
 			},
@@ -134,13 +181,12 @@ Keep the summary clear and organized.`
 		System: systemPrompt,
 	}
 
-	// 3. 调用 API
 	resp, err := c.client.CreateMessage(ctx, req)
 	if err != nil {
 		return "", err
 	}
 
-	// 4. 提取摘要文本
+	// 提取摘要文本
 	if len(resp.Content) > 0 && resp.Content[0].Type == api.ContentTypeText {
 		return resp.Content[0].Text, nil
 	}
@@ -186,5 +232,5 @@ func (c *Compactor) buildHistoryText(messages []api.Message) string {
 
 // ShouldCompact 检查是否应该压缩
 func (c *Compactor) ShouldCompact(usage TokenUsage, limits ModelLimits) bool {
-	return NeedsCompaction(usage, limits)
+	return NeedsCompaction(usage, limits, CompactionThreshold)
 }
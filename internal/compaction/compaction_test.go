@@ -0,0 +1,38 @@
+package compaction
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+// promptRecorder is a MessageClient that records the prompt it is sent
+type promptRecorder struct {
+	api.MessageClient
+	prompt string
+}
+
+func (c *promptRecorder) CreateMessage(ctx context.Context, req *api.MessagesRequest) (*api.MessagesResponse, error) {
+	c.prompt = req.Messages[0].Content[0].Text
+	return &api.MessagesResponse{Content: []api.Content{{Type: api.ContentTypeText, Text: "Title"}}}, nil
+}
+
+func TestTitleTruncatesByRunes(t *testing.T) {
+	client := &promptRecorder{}
+	c := NewCompactor(client)
+
+	// 1999 ASCII bytes then three-byte runes: a byte cut would split one
+	message := strings.Repeat("a", 1999) + strings.Repeat("界", 10)
+	if _, err := c.Title(context.Background(), message, ""); err != nil {
+		t.Fatalf("Title: %v", err)
+	}
+	if !utf8.ValidString(client.prompt) {
+		t.Error("title prompt is not valid UTF-8")
+	}
+	if want := strings.Repeat("a", 1999) + "界"; client.prompt != want {
+		t.Errorf("title prompt has %d runes, want the first 2000", utf8.RuneCountInString(client.prompt))
+	}
+}
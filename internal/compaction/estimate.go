@@ -0,0 +1,64 @@
+package compaction
+
+import (
+	"encoding/json"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+const (
+	// CharsPerToken 估算时每个 token 对应的字符数
+	CharsPerToken = 4
+
+	// ImageTokens 每张图片的估算 token 数（API 会把大图缩放到约 1600 token）
+	ImageTokens = 1600
+
+	// messageOverhead 每条消息和内容块的结构开销
+	messageOverhead = 4
+)
+
+// EstimateTokens 在发送请求前粗略估算 token 数（约 4 个字符一个 token）
+// 包括系统提示、所有消息和工具定义
+func EstimateTokens(messages []api.Message, systemPrompt string, tools []api.Tool) int {
+	chars := len(systemPrompt)
+	tokens := 0
+
+	for _, msg := range messages {
+		tokens += messageOverhead
+		for _, block := range msg.Content {
+			t, c := estimateContent(block)
+			tokens += t
+			chars += c
+		}
+	}
+
+	for _, tool := range tools {
+		if data, err := json.Marshal(tool); err == nil {
+			chars += len(data)
+		}
+	}
+
+	return tokens + chars/CharsPerToken
+}
+
+// estimateContent 返回内容块的固定 token 数（图片、结构开销）和文本字符数
+func estimateContent(block api.Content) (int, int) {
+	if block.Type == api.ContentTypeImage {
+		return ImageTokens, 0
+	}
+
+	tokens := messageOverhead
+	chars := len(block.Text) + len(block.Name) + len(block.Input) + len(block.Content) +
+		len(block.Thinking) + len(block.Data)
+	for _, extra := range block.Blocks {
+		t, c := estimateContent(extra)
+		tokens += t
+		chars += c
+	}
+	return tokens, chars
+}
+
+// EstimateNeedsCompaction 检查估算的请求大小是否超过压缩阈值 threshold
+func EstimateNeedsCompaction(estimate int, limits ModelLimits, threshold float64) bool {
+	return exceeds(estimate, limits, threshold)
+}
@@ -1,10 +1,20 @@
 package compaction
 
+import "strings"
+
+const (
+	// CompactionThreshold 默认触发压缩的使用比例
+	CompactionThreshold = 0.8
+	// WarnMargin 默认在压缩阈值之前多少比例发出接近上限的警告
+	WarnMargin = 0.1
+)
+
 // TokenUsage Token 使用量
 type TokenUsage struct {
-	Input     int
-	Output    int
-	CacheRead int
+	Input      int
+	Output     int
+	CacheRead  int
+	CacheWrite int // 本次写入缓存的输入 token，同样占用上下文
 }
 
 // ModelLimits 模型限制
@@ -13,7 +23,7 @@ type ModelLimits struct {
 	OutputLimit  int // 输出 token 限制
 }
 
-// DefaultModelLimits 返回默认模型限制（Claude Sonnet 4）
+// DefaultModelLimits 返回默认模型限制（Claude Sonnet 4），用于未知模型
 func DefaultModelLimits() ModelLimits {
 	return ModelLimits{
 		ContextLimit: 200000, // 200K context
@@ -21,10 +31,61 @@ func DefaultModelLimits() ModelLimits {
 	}
 }
 
+// KnownModelLimits 按模型 ID 前缀给出上下文窗口和最大输出；
+// 带日期或后缀的 ID 匹配最长前缀（如 claude-sonnet-4-20250514 匹配 claude-sonnet-4）
+var KnownModelLimits = map[string]ModelLimits{
+	"claude-opus-4":     {ContextLimit: 200000, OutputLimit: 32000},
+	"claude-sonnet-4":   {ContextLimit: 200000, OutputLimit: 64000},
+	"claude-3-7-sonnet": {ContextLimit: 200000, OutputLimit: 64000},
+	"claude-3-5-sonnet": {ContextLimit: 200000, OutputLimit: 8192},
+	"claude-3-5-haiku":  {ContextLimit: 200000, OutputLimit: 8192},
+	"claude-3-opus":     {ContextLimit: 200000, OutputLimit: 4096},
+	"claude-3-haiku":    {ContextLimit: 200000, OutputLimit: 4096},
+	"gpt-4.1":           {ContextLimit: 1047576, OutputLimit: 32768},
+	"gpt-4o":            {ContextLimit: 128000, OutputLimit: 16384},
+	"gpt-4-turbo":       {ContextLimit: 128000, OutputLimit: 4096},
+	"gpt-4":             {ContextLimit: 8192, OutputLimit: 4096},
+	"gpt-3.5-turbo":     {ContextLimit: 16385, OutputLimit: 4096},
+	"o1":                {ContextLimit: 200000, OutputLimit: 100000},
+	"o3":                {ContextLimit: 200000, OutputLimit: 100000},
+	"o4-mini":           {ContextLimit: 200000, OutputLimit: 100000},
+	"deepseek-chat":     {ContextLimit: 64000, OutputLimit: 8192},
+	"qwen2.5":           {ContextLimit: 32768, OutputLimit: 8192},
+	"llama3.1":          {ContextLimit: 131072, OutputLimit: 4096},
+	"llama3":            {ContextLimit: 8192, OutputLimit: 2048},
+}
+
+// LimitsForModel 返回模型的限制：按最长前缀匹配 KnownModelLimits，
+// 忽略 "openai/gpt-4o" 这类提供商前缀；未知模型使用 DefaultModelLimits
+func LimitsForModel(model string) ModelLimits {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	best := ""
+	for prefix := range KnownModelLimits {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return DefaultModelLimits()
+	}
+	return KnownModelLimits[best]
+}
+
+// WithOutputReserve 返回为输出预留 reserve 个 token（即请求的 max_tokens）的限制；
+// reserve <= 0 时保持模型的最大输出
+func (l ModelLimits) WithOutputReserve(reserve int) ModelLimits {
+	if reserve > 0 {
+		l.OutputLimit = reserve
+	}
+	return l
+}
+
 // IsOverflow 检查是否上下文溢出
 func IsOverflow(usage TokenUsage, limits ModelLimits) bool {
 	// 计算已用 token
-	used := usage.Input + usage.CacheRead + usage.Output
+	used := CalculateUsage(usage)
 
 	// 计算可用 token（上下文限制 - 输出限制）
 	available := limits.ContextLimit - limits.OutputLimit
@@ -33,19 +94,26 @@ func IsOverflow(usage TokenUsage, limits ModelLimits) bool {
 	return used > available
 }
 
-// NeedsCompaction 检查是否需要压缩
-// 当使用量超过 80% 时建议压缩
-func NeedsCompaction(usage TokenUsage, limits ModelLimits) bool {
-	used := usage.Input + usage.CacheRead + usage.Output
-	available := limits.ContextLimit - limits.OutputLimit
+// NeedsCompaction 检查是否需要压缩：使用量超过可用空间的 threshold 比例
+// （如 0.8 = 80%）时返回 true
+func NeedsCompaction(usage TokenUsage, limits ModelLimits, threshold float64) bool {
+	return exceeds(CalculateUsage(usage), limits, threshold)
+}
+
+// NeedsWarning 检查是否接近上限：使用量超过 warnThreshold 比例时返回 true，
+// 用于在自动压缩之前提醒用户
+func NeedsWarning(usage TokenUsage, limits ModelLimits, warnThreshold float64) bool {
+	return exceeds(CalculateUsage(usage), limits, warnThreshold)
+}
 
-	threshold := float64(available) * 0.8
-	return float64(used) > threshold
+// exceeds 检查 used 是否超过可用空间的 ratio 比例
+func exceeds(used int, limits ModelLimits, ratio float64) bool {
+	return float64(used) > float64(CalculateAvailable(limits))*ratio
 }
 
 // CalculateUsage 计算总使用量
 func CalculateUsage(usage TokenUsage) int {
-	return usage.Input + usage.CacheRead + usage.Output
+	return usage.Input + usage.CacheRead + usage.CacheWrite + usage.Output
 }
 
 // CalculateAvailable 计算可用空间
@@ -18,6 +18,7 @@ const (
 )
 
 // ProtectedTools 特殊工具不被修剪
+// 用户固定（/pin）的内容保存在系统提示中，不在消息列表里，修剪和总结都不会触及
 var ProtectedTools = map[string]bool{
 	"skill":      true,
 	"plan_exit":  true,
@@ -81,6 +82,7 @@ func Prune(messages []api.Message) PruneResult {
 			originalLen := len(content.Content)
 			if originalLen > 0 {
 				content.Content = "[Output pruned to save context]"
+				content.Blocks = nil
 				content.Pruned = true
 				content.PrunedAt = time.Now()
 
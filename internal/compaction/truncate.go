@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 const (
@@ -12,8 +16,99 @@ const (
 
 	// TruncateMessage 截断提示消息
 	TruncateMessage = "\n\n... (output truncated, %d more characters) ...\n\nFull output saved to: %s"
+
+	// ReadOutputHint 告诉模型如何读取完整输出
+	ReadOutputHint = "\nUse the read_tool_output tool with call_id %q to read the rest."
+
+	// failedToSave 替代无法保存时的文件路径
+	failedToSave = "(failed to save)"
 )
 
+// SavedOutput 已保存的完整工具输出
+type SavedOutput struct {
+	SessionID string
+	ToolName  string
+	CallID    string
+	Path      string
+	Size      int
+}
+
+// savedOutputs 按 callID 记录已保存的输出，供 read_tool_output 和 /output 查找
+var savedOutputs = struct {
+	sync.Mutex
+	byCallID map[string]SavedOutput
+}{byCallID: make(map[string]SavedOutput)}
+
+// OutputDir 返回会话保存完整输出的目录
+func OutputDir(sessionID string) string {
+	return filepath.Join(os.TempDir(), "gmain-agent", sessionID, "outputs")
+}
+
+// saveOutput 保存完整输出并登记，失败时返回 failedToSave
+func saveOutput(output, sessionID, toolName, callID string) string {
+	outputDir := OutputDir(sessionID)
+	filePath := filepath.Join(outputDir, fmt.Sprintf("%s-%s.txt", toolName, callID))
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return failedToSave
+	}
+	if err := os.WriteFile(filePath, []byte(output), 0644); err != nil {
+		// 如果写入失败，不影响主流程，只是无法保存完整输出
+		return failedToSave
+	}
+
+	savedOutputs.Lock()
+	savedOutputs.byCallID[callID] = SavedOutput{
+		SessionID: sessionID,
+		ToolName:  toolName,
+		CallID:    callID,
+		Path:      filePath,
+		Size:      len(output),
+	}
+	savedOutputs.Unlock()
+	return filePath
+}
+
+// LookupOutput 按 callID 查找已保存的完整输出
+func LookupOutput(callID string) (SavedOutput, bool) {
+	savedOutputs.Lock()
+	defer savedOutputs.Unlock()
+	saved, ok := savedOutputs.byCallID[callID]
+	if ok {
+		if _, err := os.Stat(saved.Path); err != nil {
+			return SavedOutput{}, false
+		}
+	}
+	return saved, ok
+}
+
+// SessionOutputs 列出会话保存的完整输出，按路径排序
+func SessionOutputs(sessionID string) []SavedOutput {
+	savedOutputs.Lock()
+	defer savedOutputs.Unlock()
+	var outputs []SavedOutput
+	for _, saved := range savedOutputs.byCallID {
+		if saved.SessionID == sessionID {
+			outputs = append(outputs, saved)
+		}
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Path < outputs[j].Path })
+	return outputs
+}
+
+// CleanupOutputs 删除会话保存的完整输出
+func CleanupOutputs(sessionID string) error {
+	savedOutputs.Lock()
+	for callID, saved := range savedOutputs.byCallID {
+		if saved.SessionID == sessionID {
+			delete(savedOutputs.byCallID, callID)
+		}
+	}
+	savedOutputs.Unlock()
+
+	return os.RemoveAll(filepath.Dir(OutputDir(sessionID)))
+}
+
 // TruncateResult 截断结果
 type TruncateResult struct {
 	Content   string // 截断后的内容
@@ -23,44 +118,61 @@ type TruncateResult struct {
 	Final     int    // 最终长度
 }
 
-// TruncateOutput 截断工具输出
+// TruncateOutput 截断工具输出，只保留开头
 func TruncateOutput(output string, sessionID, toolName, callID string) TruncateResult {
-	originalLen := len(output)
+	return TruncateWithPolicy(output, DefaultTruncatePolicy, sessionID, toolName, callID)
+}
+
+// TruncatePolicy 截断策略：保留开头 Head 个字符和结尾 Tail 个字符
+type TruncatePolicy struct {
+	Head int `json:"head"`
+	Tail int `json:"tail,omitempty"` // 0 = 只保留开头
+}
+
+// DefaultTruncatePolicy 默认只保留开头 MaxOutputLength 个字符
+var DefaultTruncatePolicy = TruncatePolicy{Head: MaxOutputLength}
+
+// DefaultToolTruncatePolicies 各工具的默认策略；
+// Bash 的测试失败、堆栈等错误信息通常在末尾，因此保留结尾
+var DefaultToolTruncatePolicies = map[string]TruncatePolicy{
+	"Bash": {Head: MaxOutputLength / 3, Tail: MaxOutputLength * 2 / 3},
+}
+
+// OmittedMessage 首尾截断时放在中间的提示
+const OmittedMessage = "\n\n... (%d characters omitted) ...\n\n"
 
-	// 如果输出未超出限制，直接返回
-	if originalLen <= MaxOutputLength {
+// lineSnap 截断点向行边界对齐时最多移动的字符数
+const lineSnap = 200
+
+// TruncateWithPolicy 按策略截断，超出时保存完整输出
+func TruncateWithPolicy(output string, policy TruncatePolicy, sessionID, toolName, callID string) TruncateResult {
+	originalLen := len(output)
+	if originalLen <= policy.Head+policy.Tail {
 		return TruncateResult{
 			Content:   output,
 			Truncated: false,
-			FilePath:  "",
 			Original:  originalLen,
 			Final:     originalLen,
 		}
 	}
 
-	// 截断输出
-	truncated := output[:MaxOutputLength]
-	remaining := originalLen - MaxOutputLength
-
-	// 生成文件路径
-	outputDir := filepath.Join(os.TempDir(), "gmain-agent", sessionID, "outputs")
-	filename := fmt.Sprintf("%s-%s.txt", toolName, callID)
-	filePath := filepath.Join(outputDir, filename)
-
-	// 创建目录
-	if err := os.MkdirAll(outputDir, 0755); err == nil {
-		// 保存完整输出到文件
-		if err := os.WriteFile(filePath, []byte(output), 0644); err != nil {
-			// 如果写入失败，不影响主流程，只是无法保存完整输出
-			filePath = "(failed to save)"
-		}
-	} else {
-		filePath = "(failed to save)"
+	filePath := saveOutput(output, sessionID, toolName, callID)
+	hint := ""
+	if filePath != failedToSave {
+		hint = fmt.Sprintf(ReadOutputHint, callID)
 	}
 
-	// 添加截断提示
-	message := fmt.Sprintf(TruncateMessage, remaining, filePath)
-	finalContent := truncated + message
+	var finalContent string
+	if policy.Tail <= 0 {
+		cut := cutHead(output, policy.Head)
+		finalContent = output[:cut] + fmt.Sprintf(TruncateMessage, originalLen-cut, filePath) + hint
+	} else {
+		head := output[:cutHead(output, policy.Head)]
+		tail := output[cutTail(output, originalLen-policy.Tail):]
+		omitted := originalLen - len(head) - len(tail)
+		finalContent = head + fmt.Sprintf(OmittedMessage, omitted) + tail +
+			fmt.Sprintf("\n\nFull output saved to: %s", filePath) + hint
+	}
 
 	return TruncateResult{
 		Content:   finalContent,
@@ -71,6 +183,28 @@ func TruncateOutput(output string, sessionID, toolName, callID string) TruncateR
 	}
 }
 
+// cutHead 返回开头部分的结束位置：不超过 n，尽量落在行尾，且不拆开 UTF-8 字符
+func cutHead(s string, n int) int {
+	if i := strings.LastIndexByte(s[:n], '\n'); i >= 0 && n-i <= lineSnap {
+		return i + 1
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// cutTail 返回结尾部分的起始位置：不小于 n，尽量落在行首，且不拆开 UTF-8 字符
+func cutTail(s string, n int) int {
+	if i := strings.IndexByte(s[n:], '\n'); i >= 0 && i < lineSnap {
+		return n + i + 1
+	}
+	for n < len(s) && !utf8.RuneStart(s[n]) {
+		n++
+	}
+	return n
+}
+
 // ShouldTruncate 检查是否应该截断
 func ShouldTruncate(output string) bool {
 	return len(output) > MaxOutputLength
@@ -78,35 +212,5 @@ func ShouldTruncate(output string) bool {
 
 // TruncateWithLimit 使用自定义限制截断
 func TruncateWithLimit(output string, limit int, sessionID, toolName, callID string) TruncateResult {
-	originalLen := len(output)
-
-	if originalLen <= limit {
-		return TruncateResult{
-			Content:   output,
-			Truncated: false,
-			Original:  originalLen,
-			Final:     originalLen,
-		}
-	}
-
-	truncated := output[:limit]
-	remaining := originalLen - limit
-
-	outputDir := filepath.Join(os.TempDir(), "gmain-agent", sessionID, "outputs")
-	filename := fmt.Sprintf("%s-%s.txt", toolName, callID)
-	filePath := filepath.Join(outputDir, filename)
-
-	os.MkdirAll(outputDir, 0755)
-	os.WriteFile(filePath, []byte(output), 0644)
-
-	message := fmt.Sprintf(TruncateMessage, remaining, filePath)
-	finalContent := truncated + message
-
-	return TruncateResult{
-		Content:   finalContent,
-		Truncated: true,
-		FilePath:  filePath,
-		Original:  originalLen,
-		Final:     len(finalContent),
-	}
+	return TruncateWithPolicy(output, TruncatePolicy{Head: limit}, sessionID, toolName, callID)
 }
@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/compaction"
 )
 
 const (
@@ -20,6 +25,12 @@ const (
 	AuthTypeBearer AuthType = "bearer"
 )
 
+// API formats for api_format
+const (
+	APIFormatAnthropic = "anthropic"
+	APIFormatOpenAI    = "openai"
+)
+
 // Config represents the application configuration
 type Config struct {
 	// API settings
@@ -29,21 +40,233 @@ type Config struct {
 	BaseURL   string   `json:"base_url,omitempty"`
 	Model     string   `json:"model,omitempty"`
 
+	// CredentialSource selects where the API credential comes from:
+	// "default" (config file, overridden by environment variables), "env",
+	// "file", "keychain" or "command"
+	CredentialSource string `json:"credential_source,omitempty"`
+
+	// CredentialCommand prints a bearer token for credential_source
+	// "command". It runs again once the token is older than
+	// CredentialTTLSeconds (default 300).
+	CredentialCommand    string `json:"credential_command,omitempty"`
+	CredentialTTLSeconds int    `json:"credential_ttl_seconds,omitempty"`
+
+	// Keychain names the item read by credential_source "keychain"; set
+	// auth_type to "bearer" if it holds a bearer token
+	Keychain KeychainConfig `json:"keychain,omitempty"`
+
+	// APIFormat selects the wire format: "anthropic" (Messages API) or
+	// "openai" (chat completions, for OpenAI, Ollama, vLLM, ...). When unset
+	// it is "openai" for base URLs ending in /v1 and "anthropic" otherwise.
+	APIFormat string `json:"api_format,omitempty"`
+
+	// Retry tunes how failed API requests are retried
+	Retry RetryConfig `json:"retry,omitempty"`
+
+	// RetryBudgetSeconds caps the total time spent waiting on retries per turn (0 = unlimited)
+	RetryBudgetSeconds int `json:"retry_budget_seconds,omitempty"`
+
+	// StreamIdleTimeoutSeconds is how long a streamed response may receive
+	// nothing, not even a keepalive ping, before it is abandoned and the
+	// request retried (0 = default 60s, negative = never)
+	StreamIdleTimeoutSeconds int `json:"stream_idle_timeout_seconds,omitempty"`
+
+	// DisableAutoCompact turns off automatic pruning/summarization near the context limit
+	DisableAutoCompact bool `json:"disable_auto_compact,omitempty"`
+
+	// CompactionThreshold is the fraction of the context window at which the
+	// conversation is compacted (0 = default 0.8). CompactionWarnThreshold
+	// is where the user is warned first (0 = 0.1 below the threshold).
+	CompactionThreshold     float64 `json:"compaction_threshold,omitempty"`
+	CompactionWarnThreshold float64 `json:"compaction_warn_threshold,omitempty"`
+
+	// MaxMessages compacts the conversation once it holds more messages than
+	// this, however few tokens they use (0 = no limit)
+	MaxMessages int `json:"max_messages,omitempty"`
+
+	// ResponseHook is a shell command that receives finalized assistant text on stdin
+	// and whose stdout is displayed instead (display only, never sent back to the API)
+	ResponseHook string `json:"response_hook,omitempty"`
+
+	// DetectPromptInjection fences tool output that looks like injected
+	// instructions and warns the model not to follow it
+	DetectPromptInjection bool `json:"detect_prompt_injection,omitempty"`
+
+	// PromptCaching marks the system prompt and tool definitions as cacheable
+	PromptCaching bool `json:"prompt_caching,omitempty"`
+
+	// ThinkingBudget enables extended thinking with this many tokens per turn (0 = off)
+	ThinkingBudget int `json:"thinking_budget,omitempty"`
+
+	// Temperature is used when the current agent sets none (nil = model default)
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// StopSequences end a response when the model emits one of them, for
+	// agents that set none
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	// ToolTimeouts caps how long each tool may run, by tool name, as Go
+	// durations (e.g. {"Grep": "30s"}). Tools not listed are not limited
+	// beyond their own defaults (Bash: 15s unless the call sets a timeout).
+	ToolTimeouts map[string]string `json:"tool_timeouts,omitempty"`
+
+	// OutputTruncation sets, by tool name, how many characters of a long
+	// tool result the model sees from the start and from the end, e.g.
+	// {"Bash": {"head": 5000, "tail": 25000}}. The full output is saved to a
+	// file either way. Defaults: Bash keeps 10000 + 20000, others the first 30000.
+	OutputTruncation map[string]compaction.TruncatePolicy `json:"output_truncation,omitempty"`
+
+	// DisableParallelTools runs read-only tool calls one at a time instead of
+	// concurrently (useful for debugging)
+	DisableParallelTools bool `json:"disable_parallel_tools,omitempty"`
+
+	// BashGuard adjusts which destructive commands the Bash tool refuses to run
+	BashGuard BashGuardConfig `json:"bash_guard,omitempty"`
+
+	// WebFetchAllowedHosts lets WebFetch reach internal addresses it
+	// otherwise blocks: host names, IP addresses or CIDR ranges
+	// (e.g. ["localhost", "10.0.0.0/8"])
+	WebFetchAllowedHosts []string `json:"web_fetch_allowed_hosts,omitempty"`
+
+	// RestrictToWorkDir makes Read, Write, Edit, MultiEdit, ApplyPatch, Glob,
+	// Grep and List refuse paths outside the working directory, including
+	// through ".." or symlinks. Off by default; recommended.
+	RestrictToWorkDir bool `json:"restrict_to_workdir,omitempty"`
+
+	// AskFallback decides tool calls whose permission is "ask" when there is
+	// no one to ask: "allow" (default) or "deny"
+	AskFallback string `json:"ask_fallback,omitempty"`
+
+	// SkipPermissions approves every call whose permission is "ask" without
+	// asking and turns off all confirmations. Deny rules still apply. Only
+	// set by --dangerously-skip-permissions, never read from the config file.
+	SkipPermissions bool `json:"-"`
+
+	// DryRun describes tool calls to the model instead of running them.
+	// Read-only tools still run unless DryRunAll is set. Only set by
+	// --dry-run and --dry-run-all, never read from the config file.
+	DryRun    bool `json:"-"`
+	DryRunAll bool `json:"-"`
+
+	// ConfirmWrites shows every Write, Edit, MultiEdit and ApplyPatch call as
+	// a diff to be approved before the file is changed, whatever the agent's
+	// permissions. "Allow Always" skips the review for that file until exit.
+	ConfirmWrites bool `json:"confirm_writes,omitempty"`
+
+	// PathPolicy sets the paths Read, Write, Edit, MultiEdit and ApplyPatch
+	// refuse and Grep skips, together with the project's .gmain-agentignore.
+	// Glob, LS and Tree still list the names of denied files.
+	PathPolicy PathPolicyConfig `json:"path_policy,omitempty"`
+
+	// ContextFile is the project context file appended to every agent's
+	// system prompt, relative to the working directory. When unset, AGENTS.md
+	// and then .gmain-agent/context.md are tried; "none" disables it.
+	ContextFile string `json:"context_file,omitempty"`
+
+	// ContextMaxBytes caps how much of the context file is included (0 = 32KB)
+	ContextMaxBytes int `json:"context_max_bytes,omitempty"`
+
+	// InitialPrompt is sent automatically once at startup in interactive mode
+	InitialPrompt string `json:"initial_prompt,omitempty"`
+
+	// Pricing overrides or adds per-model rates (USD per million tokens) used
+	// by /cost, keyed by model ID prefix
+	Pricing map[string]api.ModelPricing `json:"pricing,omitempty"`
+
 	// UI settings
 	MaxTokens   int  `json:"max_tokens,omitempty"`
 	ColorOutput bool `json:"color_output,omitempty"`
 
+	// ShowCost adds the estimated session cost to the TUI status bar
+	ShowCost bool `json:"show_cost,omitempty"`
+
+	// ToolDisplay controls how simple mode prints tool results
+	ToolDisplay ToolDisplayConfig `json:"tool_display,omitempty"`
+
+	// DisableScrollPause keeps the TUI pinned to the bottom while output
+	// streams, even when the user has scrolled up
+	DisableScrollPause bool `json:"disable_scroll_pause,omitempty"`
+
+	// Theme is the TUI color scheme: "dark" (default) or "light"
+	Theme string `json:"theme,omitempty"`
+
+	// ThemeFile is a JSON file with a custom TUI color scheme; it takes
+	// precedence over Theme
+	ThemeFile string `json:"theme_file,omitempty"`
+
 	// Session settings
 	AutoSaveSession bool   `json:"auto_save_session,omitempty"`
 	SessionDir      string `json:"session_dir,omitempty"`
+
+	// CheckpointSteps also saves the session every this many agent steps
+	// within a turn, so a crash in a long tool loop loses little
+	// (0 = save only after each turn)
+	CheckpointSteps int `json:"checkpoint_steps,omitempty"`
+
+	// SessionTitles controls auto-naming of saved sessions:
+	// "heuristic" (default, from the first message), "model" (cheap model call) or "off"
+	SessionTitles string `json:"session_titles,omitempty"`
+
+	// Credentials as read from the config file, before environment overrides
+	fileAPIKey    string
+	fileAuthToken string
+
+	providerOnce sync.Once
+	provider     CredentialProvider
 }
 
-// GetAuthCredential returns the authentication credential and type
+// ToolDisplayConfig controls the simple-mode tool display
+type ToolDisplayConfig struct {
+	MaxChars  int  `json:"max_chars,omitempty"`  // Characters of output shown (0 = 500, -1 = unlimited)
+	MaxLines  int  `json:"max_lines,omitempty"`  // Lines of output shown (0 = unlimited)
+	ShowInput bool `json:"show_input,omitempty"` // Print the tool input before its result
+	NoColor   bool `json:"no_color,omitempty"`   // Print tool output without styling
+
+	StatusLine bool `json:"status_line,omitempty"` // Show the running tool and elapsed time in place
+}
+
+// RetryConfig tunes API request retries; zero fields keep the defaults
+type RetryConfig struct {
+	MaxRetries  int     `json:"max_retries,omitempty"`   // Attempts per request, including the first (default 3)
+	Jitter      float64 `json:"jitter,omitempty"`        // Randomize backoff delays by up to this fraction, 0-1 (default 0)
+	BaseDelayMs int     `json:"base_delay_ms,omitempty"` // First backoff delay (default 500)
+	MaxDelayMs  int     `json:"max_delay_ms,omitempty"`  // Longest backoff delay without a Retry-After header (default 2000)
+}
+
+// PathPolicyConfig adjusts the paths the file tools refuse
+type PathPolicyConfig struct {
+	Deny       []string `json:"deny,omitempty"`        // Extra globs to refuse, e.g. "secrets/**"
+	Allow      []string `json:"allow,omitempty"`       // Globs allowed even when denied
+	NoDefaults bool     `json:"no_defaults,omitempty"` // Drop the built-in .env*, *.pem, *_rsa and .git/* denials
+}
+
+// BashGuardConfig adjusts the Bash tool's dangerous command checks
+type BashGuardConfig struct {
+	Disable  []string `json:"disable,omitempty"`  // Built-in checks to turn off by name, or "all"
+	Patterns []string `json:"patterns,omitempty"` // Extra regular expressions to refuse
+}
+
+// GetAuthCredential returns the authentication credential and type from the
+// configured credential provider ("" if it has none or fails; Validate
+// reports the failure)
 func (c *Config) GetAuthCredential() (string, AuthType) {
-	if c.AuthToken != "" {
-		return c.AuthToken, AuthTypeBearer
+	credential, authType, err := c.CredentialProvider().Credential()
+	if err != nil {
+		return "", authType
+	}
+	return credential, authType
+}
+
+// GetAPIFormat returns the configured API format, detecting it from the
+// base URL when unset
+func (c *Config) GetAPIFormat() string {
+	if c.APIFormat != "" {
+		return c.APIFormat
+	}
+	if c.BaseURL != "" && api.DetectOpenAIBaseURL(c.BaseURL) {
+		return APIFormatOpenAI
 	}
-	return c.APIKey, AuthTypeAPIKey
+	return APIFormatAnthropic
 }
 
 // DefaultConfig returns the default configuration
@@ -69,6 +292,7 @@ func LoadConfig() (*Config, error) {
 			}
 		}
 	}
+	cfg.fileAPIKey, cfg.fileAuthToken = cfg.APIKey, cfg.AuthToken
 
 	// Override with environment variables
 	// ANTHROPIC_AUTH_TOKEN takes precedence (Bearer token for proxies/custom endpoints)
@@ -95,6 +319,11 @@ func LoadConfig() (*Config, error) {
 		cfg.Model = model
 	}
 
+	// OPENAI_API_KEY is used for OpenAI-compatible APIs when no other key is set
+	if cfg.GetAPIFormat() == APIFormatOpenAI && cfg.APIKey == "" && cfg.AuthToken == "" {
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+
 	return cfg, nil
 }
 
@@ -147,7 +376,29 @@ func GetConfigDir() (string, error) {
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.APIKey == "" && c.AuthToken == "" {
+	switch c.APIFormat {
+	case "", APIFormatAnthropic, APIFormatOpenAI:
+	default:
+		return fmt.Errorf("invalid api_format %q: use %q or %q", c.APIFormat, APIFormatAnthropic, APIFormatOpenAI)
+	}
+
+	switch c.CredentialSource {
+	case "", CredentialSourceDefault, CredentialSourceEnv, CredentialSourceFile, CredentialSourceKeychain:
+	case CredentialSourceCommand:
+		if c.CredentialCommand == "" {
+			return fmt.Errorf("credential_source \"command\" requires credential_command")
+		}
+	default:
+		return fmt.Errorf("invalid credential_source %q: use default, env, file, keychain or command", c.CredentialSource)
+	}
+
+	credential, _, err := c.CredentialProvider().Credential()
+	if err != nil {
+		return fmt.Errorf("failed to get API credential: %w", err)
+	}
+
+	// Local OpenAI-compatible servers usually need no key
+	if credential == "" && c.GetAPIFormat() != APIFormatOpenAI {
 		return fmt.Errorf("API key or auth token is required. Set ANTHROPIC_API_KEY or ANTHROPIC_AUTH_TOKEN environment variable, or configure in ~/.claude-code/config.json")
 	}
 
@@ -155,5 +406,62 @@ func (c *Config) Validate() error {
 		c.MaxTokens = 8192
 	}
 
+	switch c.AskFallback {
+	case "", "allow", "deny":
+	default:
+		return fmt.Errorf("invalid ask_fallback %q: use \"allow\" or \"deny\"", c.AskFallback)
+	}
+
+	switch c.Theme {
+	case "", "dark", "light":
+	default:
+		return fmt.Errorf("invalid theme %q: use \"dark\" or \"light\", or theme_file for a custom theme", c.Theme)
+	}
+
+	if c.Retry.MaxRetries < 0 || c.Retry.BaseDelayMs < 0 || c.Retry.MaxDelayMs < 0 {
+		return fmt.Errorf("invalid retry settings: max_retries, base_delay_ms and max_delay_ms must not be negative")
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return fmt.Errorf("invalid retry jitter %v: use a fraction between 0 and 1", c.Retry.Jitter)
+	}
+
+	if c.CompactionThreshold < 0 || c.CompactionThreshold > 1 || c.CompactionWarnThreshold < 0 || c.CompactionWarnThreshold > 1 {
+		return fmt.Errorf("invalid compaction thresholds: compaction_threshold and compaction_warn_threshold are fractions between 0 and 1")
+	}
+	threshold := c.CompactionThreshold
+	if threshold == 0 {
+		threshold = compaction.CompactionThreshold
+	}
+	if c.CompactionWarnThreshold >= threshold {
+		return fmt.Errorf("invalid compaction_warn_threshold %v: it must be below the compaction threshold %v", c.CompactionWarnThreshold, threshold)
+	}
+
+	if c.MaxMessages < 0 || c.CheckpointSteps < 0 {
+		return fmt.Errorf("invalid session settings: max_messages and checkpoint_steps must not be negative")
+	}
+
+	for name, policy := range c.OutputTruncation {
+		if policy.Head <= 0 || policy.Tail < 0 {
+			return fmt.Errorf("invalid output_truncation for tool %s: head must be positive and tail must not be negative", name)
+		}
+	}
+
+	if _, err := c.GetToolTimeouts(); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// GetToolTimeouts parses ToolTimeouts
+func (c *Config) GetToolTimeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(c.ToolTimeouts))
+	for name, value := range c.ToolTimeouts {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q for tool %s in tool_timeouts: use a positive duration such as \"30s\"", value, name)
+		}
+		timeouts[name] = d
+	}
+	return timeouts, nil
+}
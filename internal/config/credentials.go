@@ -0,0 +1,177 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credential sources for credential_source
+const (
+	CredentialSourceDefault  = "default"  // Config file, overridden by environment variables
+	CredentialSourceEnv      = "env"      // Environment variables only
+	CredentialSourceFile     = "file"     // Config file only
+	CredentialSourceKeychain = "keychain" // OS keychain
+	CredentialSourceCommand  = "command"  // Bearer token printed by credential_command
+)
+
+// Keychain defaults for credential_source "keychain"
+const (
+	DefaultKeychainService = "claude-code-go"
+	DefaultKeychainAccount = "api-key"
+)
+
+// DefaultCredentialTTL is how long a credential_command token is reused
+// when credential_ttl_seconds is unset
+const DefaultCredentialTTL = 5 * time.Minute
+
+// CredentialProvider supplies the API credential
+type CredentialProvider interface {
+	// Credential returns the credential and how to send it. An empty
+	// credential means none is configured.
+	Credential() (string, AuthType, error)
+}
+
+// KeychainConfig names the keychain item holding the credential
+type KeychainConfig struct {
+	Service string `json:"service,omitempty"` // Default "claude-code-go"
+	Account string `json:"account,omitempty"` // Default "api-key"
+}
+
+// CredentialProvider returns the provider selected by credential_source.
+// It is created once, so a command's token is cached between calls.
+func (c *Config) CredentialProvider() CredentialProvider {
+	c.providerOnce.Do(func() {
+		c.provider = c.newCredentialProvider()
+	})
+	return c.provider
+}
+
+func (c *Config) newCredentialProvider() CredentialProvider {
+	switch c.CredentialSource {
+	case CredentialSourceEnv:
+		return envProvider{openAI: c.GetAPIFormat() == APIFormatOpenAI}
+	case CredentialSourceFile:
+		return staticProvider{apiKey: c.fileAPIKey, authToken: c.fileAuthToken}
+	case CredentialSourceKeychain:
+		authType := AuthTypeAPIKey
+		if c.AuthType == AuthTypeBearer {
+			authType = AuthTypeBearer
+		}
+		return keychainProvider{service: c.Keychain.Service, account: c.Keychain.Account, authType: authType}
+	case CredentialSourceCommand:
+		ttl := DefaultCredentialTTL
+		if c.CredentialTTLSeconds > 0 {
+			ttl = time.Duration(c.CredentialTTLSeconds) * time.Second
+		}
+		return &CommandCredential{Command: c.CredentialCommand, TTL: ttl}
+	default:
+		return staticProvider{apiKey: c.APIKey, authToken: c.AuthToken}
+	}
+}
+
+// staticProvider returns a fixed key or token; the token wins if both are set
+type staticProvider struct {
+	apiKey    string
+	authToken string
+}
+
+func (p staticProvider) Credential() (string, AuthType, error) {
+	if p.authToken != "" {
+		return p.authToken, AuthTypeBearer, nil
+	}
+	return p.apiKey, AuthTypeAPIKey, nil
+}
+
+// envProvider reads ANTHROPIC_AUTH_TOKEN, then ANTHROPIC_API_KEY, then
+// OPENAI_API_KEY for OpenAI-compatible APIs
+type envProvider struct {
+	openAI bool
+}
+
+func (p envProvider) Credential() (string, AuthType, error) {
+	if token := os.Getenv("ANTHROPIC_AUTH_TOKEN"); token != "" {
+		return token, AuthTypeBearer, nil
+	}
+	if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
+		return key, AuthTypeAPIKey, nil
+	}
+	if p.openAI {
+		return os.Getenv("OPENAI_API_KEY"), AuthTypeAPIKey, nil
+	}
+	return "", AuthTypeAPIKey, nil
+}
+
+// keychainProvider reads the credential from the OS keychain
+type keychainProvider struct {
+	service  string
+	account  string
+	authType AuthType
+}
+
+func (p keychainProvider) Credential() (string, AuthType, error) {
+	service, account := p.service, p.account
+	if service == "" {
+		service = DefaultKeychainService
+	}
+	if account == "" {
+		account = DefaultKeychainAccount
+	}
+	secret, err := readKeychain(service, account)
+	if err != nil {
+		return "", p.authType, fmt.Errorf("keychain item %s/%s: %w", service, account, err)
+	}
+	return secret, p.authType, nil
+}
+
+// CommandCredential is a bearer token printed by a shell command, such as an
+// OAuth helper. The token is reused until TTL has passed, then the command
+// is run again. It implements api.RefreshableCredential so the client can
+// renew the token before each request.
+type CommandCredential struct {
+	Command string
+	TTL     time.Duration
+
+	mu      sync.Mutex
+	token   string
+	fetched time.Time
+}
+
+// Credential returns the current token, running the command if needed
+func (p *CommandCredential) Credential() (string, AuthType, error) {
+	token, err := p.Token(context.Background())
+	return token, AuthTypeBearer, err
+}
+
+// Token returns the current token, running the command if it has expired
+func (p *CommandCredential) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Since(p.fetched) < p.TTL {
+		return p.token, nil
+	}
+	if p.Command == "" {
+		return "", fmt.Errorf("credential_command is not set")
+	}
+
+	out, err := exec.CommandContext(ctx, "sh", "-c", p.Command).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("credential_command failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("credential_command failed: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("credential_command printed no token")
+	}
+
+	p.token = token
+	p.fetched = time.Now()
+	return token, nil
+}
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package config
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// readKeychain looks up a generic password in the macOS keychain
+func readKeychain(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", fmt.Errorf("not found (add it with: security add-generic-password -s %s -a %s -w)", service, account)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package config
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// readKeychain looks up a secret in the Secret Service keyring via secret-tool
+func readKeychain(service, account string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", fmt.Errorf("secret-tool is not installed (it ships with libsecret)")
+	}
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	secret := strings.TrimSpace(string(out))
+	if err != nil || secret == "" {
+		return "", fmt.Errorf("not found (add it with: secret-tool store --label=%s service %s account %s)", service, service, account)
+	}
+	return secret, nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package config
+
+import "fmt"
+
+// readKeychain is not supported on this platform
+func readKeychain(service, account string) (string, error) {
+	return "", fmt.Errorf("the keychain credential source is not supported on this platform")
+}
@@ -0,0 +1,52 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/logger"
+)
+
+// DefaultHookTimeout is how long a hook command may run before it is abandoned
+const DefaultHookTimeout = 10 * time.Second
+
+// TextProcessor transforms text before it is displayed
+type TextProcessor func(text string) string
+
+// CommandProcessor returns a TextProcessor that pipes text through a shell command.
+// The command receives the text on stdin and its stdout replaces the text.
+// On failure or timeout the original text is returned unchanged.
+func CommandProcessor(command string, timeout time.Duration) TextProcessor {
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+
+	return func(text string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "bash", "-c", command)
+		cmd.Env = os.Environ()
+		cmd.Stdin = strings.NewReader(text)
+
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			if log := logger.GetLogger(); log != nil {
+				log.LogError("response_hook_failed", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String())), map[string]interface{}{
+					"command": command,
+				})
+			}
+			return text
+		}
+
+		return stdout.String()
+	}
+}
@@ -14,8 +14,21 @@ type Logger struct {
 	file   *os.File
 	mu     sync.Mutex
 	pretty bool
+
+	// Stream chunks are queued and written by a background goroutine so
+	// logging never holds up reading the stream (nil = not logged)
+	chunks    chan LogEntry
+	chunksEnd chan struct{}
+	dropped   int  // Chunks dropped because the queue was full
+	closed    bool // Close was called; later chunks are ignored
 }
 
+// StreamChunkQueue is how many stream chunks may wait to be written
+const StreamChunkQueue = 1024
+
+// MaxStreamChunkText is how much of each text field of a stream chunk is logged
+const MaxStreamChunkText = 500
+
 // LogEntry represents a single log entry
 type LogEntry struct {
 	Timestamp   string                 `json:"timestamp"`
@@ -90,7 +103,11 @@ func (l *Logger) Log(entry LogEntry) error {
 	if l == nil || l.file == nil {
 		return nil // Logging disabled
 	}
+	return l.write(entry, true)
+}
 
+// write writes an entry, syncing the file afterwards if sync is set
+func (l *Logger) write(entry LogEntry, sync bool) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -121,6 +138,10 @@ func (l *Logger) Log(entry LogEntry) error {
 		return err
 	}
 
+	if !sync {
+		return nil
+	}
+
 	// Flush to ensure data is written
 	return l.file.Sync()
 }
@@ -178,9 +199,48 @@ func (l *Logger) LogAPIResponse(statusCode int, headers map[string]string, body
 	return l.Log(entry)
 }
 
-// LogStreamChunk logs a streaming response chunk
+// EnableStreamLogging makes LogStreamChunk log chunks; they are written in
+// the background without syncing the file, since there are many
+func (l *Logger) EnableStreamLogging() {
+	if l == nil || l.file == nil || l.chunks != nil {
+		return
+	}
+	l.chunks = make(chan LogEntry, StreamChunkQueue)
+	l.chunksEnd = make(chan struct{})
+	go func() {
+		defer close(l.chunksEnd)
+		for entry := range l.chunks {
+			l.write(entry, false)
+		}
+	}()
+}
+
+// StreamLogging reports whether stream chunks are logged
+func (l *Logger) StreamLogging() bool {
+	return l != nil && l.chunks != nil
+}
+
+// LogStreamChunk queues a streaming response chunk for logging, if stream
+// logging is enabled. String values of data are truncated to
+// MaxStreamChunkText; a chunk is dropped rather than waited for when the
+// queue is full.
 func (l *Logger) LogStreamChunk(chunkType string, data interface{}) error {
+	if !l.StreamLogging() {
+		return nil
+	}
+	if fields, ok := data.(map[string]interface{}); ok {
+		truncated := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			if s, ok := v.(string); ok {
+				v = truncateString(s, MaxStreamChunkText)
+			}
+			truncated[k] = v
+		}
+		data = truncated
+	}
+
 	entry := LogEntry{
+		Timestamp: time.Now().Format(time.RFC3339Nano),
 		Type:      "stream_chunk",
 		Direction: "incoming",
 		Metadata: map[string]interface{}{
@@ -188,7 +248,17 @@ func (l *Logger) LogStreamChunk(chunkType string, data interface{}) error {
 			"data":       data,
 		},
 	}
-	return l.Log(entry)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	select {
+	case l.chunks <- entry:
+	default:
+		l.dropped++
+	}
+	return nil
 }
 
 // LogToolCall logs a tool call
@@ -235,9 +305,22 @@ func (l *Logger) Close() error {
 		return nil
 	}
 
+	// Write the queued stream chunks first
+	if l.chunks != nil {
+		l.mu.Lock()
+		l.closed = true
+		close(l.chunks)
+		l.mu.Unlock()
+		<-l.chunksEnd
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.dropped > 0 {
+		l.file.WriteString(fmt.Sprintf("\n(%d stream chunks not logged: the queue was full)\n", l.dropped))
+	}
+
 	// Write footer
 	footer := fmt.Sprintf("\n=== Claude Agent Log Ended at %s ===\n", time.Now().Format(time.RFC3339))
 	l.file.WriteString(footer)
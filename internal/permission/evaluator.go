@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -29,6 +30,7 @@ type AskInput struct {
 	Pattern    string
 	Ruleset    Ruleset
 	Message    string
+	Input      string // 工具输入（JSON），会传给 AskFunc 供用户编辑
 	AskFunc    func(AskRequest) (AskResponse, error)
 }
 
@@ -37,13 +39,16 @@ type AskRequest struct {
 	Permission string
 	Pattern    string
 	Message    string
+	Input      string // 工具输入（JSON），为空表示不可编辑
+	Diff       string // 待写入文件的 diff（写入确认时）
 }
 
 // AskResponse 权限响应
 type AskResponse struct {
-	Approved bool // 是否批准
-	Rejected bool // 是否拒绝
-	Always   bool // 是否总是允许（会话级别）
+	Approved    bool   // 是否批准
+	Rejected    bool   // 是否拒绝
+	Always      bool   // 是否总是允许（会话级别）
+	EditedInput string // 用户编辑后的工具输入（为空表示未修改）
 }
 
 // Ask 请求权限
@@ -82,6 +87,7 @@ func (e *Evaluator) Ask(ctx context.Context, input AskInput) error {
 			Permission: input.Permission,
 			Pattern:    input.Pattern,
 			Message:    input.Message,
+			Input:      input.Input,
 		})
 		if err != nil {
 			return err
@@ -118,8 +124,8 @@ func (e *Evaluator) Evaluate(permission, pattern string, ruleset Ruleset) Action
 
 	// 2. 遍历规则，寻找匹配
 	for _, rule := range ruleset.Rules {
-		// 检查权限是否匹配
-		if rule.Permission != permission && rule.Permission != "*" {
+		// 检查权限是否匹配（规则使用小写工具名，如 "read" 匹配 Read 工具）
+		if !strings.EqualFold(rule.Permission, permission) && rule.Permission != "*" {
 			continue
 		}
 
@@ -27,12 +27,32 @@ type CheckInput struct {
 	Args       interface{}
 	Ruleset    Ruleset
 	Message    string
+	Input      string // 工具输入（JSON），会传给 AskFunc 供用户编辑
 	AskFunc    func(AskRequest) (AskResponse, error)
 }
 
 // Check 检查权限（包含 Doom Loop 检测）
 func (m *Manager) Check(ctx context.Context, input CheckInput) error {
 	// 1. Doom Loop 检测
+	if err := m.CheckDoomLoop(input); err != nil {
+		return err
+	}
+
+	// 2. 权限评估
+	return m.evaluator.Ask(ctx, AskInput{
+		SessionID:  input.SessionID,
+		Permission: input.Permission,
+		Pattern:    input.Pattern,
+		Ruleset:    input.Ruleset,
+		Message:    input.Message,
+		Input:      input.Input,
+		AskFunc:    input.AskFunc,
+	})
+}
+
+// CheckDoomLoop 仅执行 Doom Loop 检测（不评估规则）
+// 同一工具使用相同参数重复调用时询问用户是否继续；没有 AskFunc 时不拦截
+func (m *Manager) CheckDoomLoop(input CheckInput) error {
 	if m.doomLoopDetector.Check(input.SessionID, input.Permission, input.Args) {
 		count := m.doomLoopDetector.GetCount(input.SessionID, input.Permission, input.Args)
 
@@ -66,16 +86,7 @@ func (m *Manager) Check(ctx context.Context, input CheckInput) error {
 			m.doomLoopDetector.ResetTool(input.SessionID, input.Permission)
 		}
 	}
-
-	// 2. 权限评估
-	return m.evaluator.Ask(ctx, AskInput{
-		SessionID:  input.SessionID,
-		Permission: input.Permission,
-		Pattern:    input.Pattern,
-		Ruleset:    input.Ruleset,
-		Message:    input.Message,
-		AskFunc:    input.AskFunc,
-	})
+	return nil
 }
 
 // CheckSimple 简单权限检查（不包含 Doom Loop 检测）
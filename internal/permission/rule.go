@@ -1,6 +1,11 @@
 package permission
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
 
 // Action 权限动作
 type Action string
@@ -88,6 +93,29 @@ func (r *Ruleset) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Validate 检查规则集是否有效
+func (r *Ruleset) Validate() error {
+	if r.AllowAll && r.DenyAll {
+		return fmt.Errorf("allow_all and deny_all cannot both be set")
+	}
+
+	for i, rule := range r.Rules {
+		if rule.Permission == "" {
+			return fmt.Errorf("rule %d: permission is required", i+1)
+		}
+		switch rule.Action {
+		case ActionAllow, ActionDeny, ActionAsk:
+		default:
+			return fmt.Errorf("rule %d: invalid action %q (use allow, deny or ask)", i+1, rule.Action)
+		}
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("rule %d: invalid pattern %q: %w", i+1, rule.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
 // AddRule 添加规则
 func (r *Ruleset) AddRule(permission, pattern string, action Action) {
 	r.Rules = append(r.Rules, Rule{
@@ -96,3 +124,33 @@ func (r *Ruleset) AddRule(permission, pattern string, action Action) {
 		Action:     action,
 	})
 }
+
+// FormatTable 以可读表格形式输出规则集
+func (r *Ruleset) FormatTable() string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("AllowAll: %t  DenyAll: %t  DefaultAsk: %t\n", r.AllowAll, r.DenyAll, r.DefaultAsk))
+
+	if len(r.Rules) == 0 {
+		b.WriteString("(no rules)")
+		return b.String()
+	}
+
+	// 计算列宽
+	permWidth, patternWidth := len("PERMISSION"), len("PATTERN")
+	for _, rule := range r.Rules {
+		if len(rule.Permission) > permWidth {
+			permWidth = len(rule.Permission)
+		}
+		if len(rule.Pattern) > patternWidth {
+			patternWidth = len(rule.Pattern)
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("%-3s %-*s  %-*s  %s\n", "#", permWidth, "PERMISSION", patternWidth, "PATTERN", "ACTION"))
+	for i, rule := range r.Rules {
+		b.WriteString(fmt.Sprintf("%-3d %-*s  %-*s  %s\n", i+1, permWidth, rule.Permission, patternWidth, rule.Pattern, rule.Action))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
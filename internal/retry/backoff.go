@@ -2,6 +2,7 @@ package retry
 
 import (
 	"math"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"time"
@@ -91,14 +92,15 @@ func CalculateBackoff(attempt int) time.Duration {
 // CalculateBackoffWithJitter 计算带抖动的退避延迟
 // 抖动可以避免多个客户端同时重试造成的"雷群效应"
 func CalculateBackoffWithJitter(attempt int, jitterFactor float64) time.Duration {
-	baseDelay := CalculateBackoff(attempt)
+	return applyJitter(CalculateBackoff(attempt), jitterFactor)
+}
 
-	// 添加抖动：delay * (1 ± jitterFactor)
-	// 例如 jitterFactor = 0.1 表示 ±10% 的抖动
-	if jitterFactor > 0 {
-		jitter := float64(baseDelay) * jitterFactor * (2.0*float64(time.Now().UnixNano()%1000)/1000.0 - 1.0)
-		return time.Duration(float64(baseDelay) + jitter)
+// applyJitter 为延迟添加抖动：delay * (1 ± jitterFactor)
+// 例如 jitterFactor = 0.1 表示 ±10% 的抖动
+func applyJitter(delay time.Duration, jitterFactor float64) time.Duration {
+	if jitterFactor <= 0 {
+		return delay
 	}
-
-	return baseDelay
+	jitter := float64(delay) * jitterFactor * (2.0*rand.Float64() - 1.0)
+	return time.Duration(float64(delay) + jitter)
 }
@@ -0,0 +1,96 @@
+package retry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Budget 重试时间预算
+// 跨多次请求累计重试等待时间，避免一轮对话中反复重试悄悄耗掉数分钟
+type Budget struct {
+	mu    sync.Mutex
+	limit time.Duration
+	spent time.Duration
+}
+
+// NewBudget 创建新的重试预算（limit <= 0 表示不限制）
+func NewBudget(limit time.Duration) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Reserve 尝试从预算中扣除一次重试延迟，预算不足时返回 false
+func (b *Budget) Reserve(delay time.Duration) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		b.spent += delay
+		return true
+	}
+
+	if b.spent+delay > b.limit {
+		return false
+	}
+
+	b.spent += delay
+	return true
+}
+
+// Reset 重置已用预算（通常在每轮对话开始时调用）
+func (b *Budget) Reset() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent = 0
+}
+
+// Spent 返回已用的重试时间
+func (b *Budget) Spent() time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Limit 返回预算上限
+func (b *Budget) Limit() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// BudgetExhaustedError 重试预算耗尽错误
+type BudgetExhaustedError struct {
+	Limit   time.Duration
+	Spent   time.Duration
+	LastErr error
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	msg := fmt.Sprintf("retry budget exhausted: already waited %v of %v allowed per turn", e.Spent, e.Limit)
+	if e.LastErr != nil {
+		msg += fmt.Sprintf(" (last error: %v)", e.LastErr)
+	}
+	return msg
+}
+
+func (e *BudgetExhaustedError) Unwrap() error {
+	return e.LastErr
+}
+
+// Retryable 预算耗尽后不再重试，即使 LastErr 本身可重试
+func (e *BudgetExhaustedError) Retryable() bool {
+	return false
+}
@@ -14,12 +14,26 @@ const (
 	ErrorTypeNonRetryable ErrorType = "non_retryable"
 )
 
-// ClassifyError 分类错误
+// retryableError 由能自行判断是否可重试的错误实现（如 api.APIError）
+type retryableError interface {
+	Retryable() bool
+}
+
+// ClassifyError 分类错误：优先使用错误链中的类型化信息，
+// 只有普通错误（如网络错误）才退回到错误信息的字符串匹配
 func ClassifyError(err error) ErrorType {
 	if err == nil {
 		return ErrorTypeNonRetryable
 	}
 
+	var re retryableError
+	if errors.As(err, &re) {
+		if re.Retryable() {
+			return ErrorTypeRetryable
+		}
+		return ErrorTypeNonRetryable
+	}
+
 	errMsg := err.Error()
 	errMsgLower := strings.ToLower(errMsg)
 
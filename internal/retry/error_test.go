@@ -0,0 +1,41 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// typedError is an error that knows whether it is retryable, like api.APIError
+type typedError struct {
+	msg       string
+	retryable bool
+}
+
+func (e *typedError) Error() string   { return e.msg }
+func (e *typedError) Retryable() bool { return e.retryable }
+
+func TestClassifyError(t *testing.T) {
+	overloaded := &typedError{msg: "API error (529): overloaded_error", retryable: true}
+	tests := []struct {
+		name string
+		err  error
+		want ErrorType
+	}{
+		{"nil", nil, ErrorTypeNonRetryable},
+		{"overloaded", overloaded, ErrorTypeRetryable},
+		{"wrapped overloaded", fmt.Errorf("send: %w", overloaded), ErrorTypeRetryable},
+		{"invalid request", &typedError{msg: "API error (400): rate limit field is invalid"}, ErrorTypeNonRetryable},
+		{"network", errors.New("read tcp: connection reset by peer"), ErrorTypeRetryable},
+		{"budget exhausted", &BudgetExhaustedError{Limit: time.Minute, Spent: time.Minute, LastErr: overloaded}, ErrorTypeNonRetryable},
+		{"wrapped budget exhausted", fmt.Errorf("send: %w", &BudgetExhaustedError{LastErr: overloaded}), ErrorTypeNonRetryable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
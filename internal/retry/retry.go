@@ -3,36 +3,104 @@ package retry
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 )
 
 // Retrier 重试器
 type Retrier struct {
-	MaxRetries int
-	OnRetry    func(attempt int, err error, delay time.Duration) // 重试回调
+	MaxRetries   int           // 最大尝试次数（含第一次）
+	JitterFactor float64       // 退避延迟的抖动比例（0 表示不抖动，0.2 表示 ±20%）
+	BaseDelay    time.Duration // 指数退避的初始延迟
+	MaxDelay     time.Duration // 无 Retry-After 头时的最大延迟
+
+	OnRetry func(attempt int, err error, delay time.Duration) // 重试回调
+	OnStats func(stats RetryStats)                            // 每次重试等待前调用，附带本次请求的统计
+	Budget  *Budget                                           // 累计重试时间预算（可选）
 }
 
-// NewRetrier 创建新的重试器
-func NewRetrier() *Retrier {
-	return &Retrier{
+// Option 重试器选项
+type Option func(*Retrier)
+
+// WithMaxRetries 设置最大尝试次数（n <= 0 时保持默认值）
+func WithMaxRetries(n int) Option {
+	return func(r *Retrier) {
+		if n > 0 {
+			r.MaxRetries = n
+		}
+	}
+}
+
+// WithJitter 设置退避延迟的抖动比例（0-1）
+func WithJitter(factor float64) Option {
+	return func(r *Retrier) {
+		r.JitterFactor = math.Min(math.Max(factor, 0), 1)
+	}
+}
+
+// WithDelays 设置指数退避的初始延迟和最大延迟（<= 0 时保持默认值）
+func WithDelays(base, max time.Duration) Option {
+	return func(r *Retrier) {
+		if base > 0 {
+			r.BaseDelay = base
+		}
+		if max > 0 {
+			r.MaxDelay = max
+		}
+	}
+}
+
+// NewRetrier 创建新的重试器；不带选项时与默认常量一致（不抖动）
+func NewRetrier(opts ...Option) *Retrier {
+	r := &Retrier{
 		MaxRetries: MaxRetries,
+		BaseDelay:  InitialDelay,
+		MaxDelay:   MaxDelayNoHeader,
 		OnRetry:    nil,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // NewRetrierWithCallback 创建带回调的重试器
 func NewRetrierWithCallback(onRetry func(attempt int, err error, delay time.Duration)) *Retrier {
-	return &Retrier{
-		MaxRetries: MaxRetries,
-		OnRetry:    onRetry,
+	r := NewRetrier()
+	r.OnRetry = onRetry
+	return r
+}
+
+// delay 计算第 attempt 次失败后的等待时间：优先使用 Retry-After 头，否则为带抖动的指数退避
+func (r *Retrier) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay := parseRetryAfter(resp.Header); delay > 0 {
+			return min(delay, MaxDelayWithHeader)
+		}
+	}
+
+	base, maxDelay := r.BaseDelay, r.MaxDelay
+	if base <= 0 {
+		base = InitialDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = MaxDelayNoHeader
 	}
+	// 服务端返回了错误响应时允许更长的等待
+	if resp != nil && maxDelay < MaxDelayWithHeader {
+		maxDelay = MaxDelayWithHeader
+	}
+
+	delay := time.Duration(float64(base) * math.Pow(BackoffFactor, float64(attempt-1)))
+	return applyJitter(min(delay, maxDelay), r.JitterFactor)
 }
 
 // Do 执行带重试的操作
 func (r *Retrier) Do(ctx context.Context, fn func() (*http.Response, error)) (*http.Response, error) {
 	var lastResp *http.Response
 	var lastErr error
+	stats := RetryStats{MaxAttempts: r.MaxRetries}
 
 	for attempt := 1; attempt <= r.MaxRetries; attempt++ {
 		// 检查上下文是否已取消
@@ -72,12 +140,44 @@ func (r *Retrier) Do(ctx context.Context, fn func() (*http.Response, error)) (*h
 		}
 
 		// 计算延迟
-		delay := CalculateDelay(attempt, resp)
+		delay := r.delay(attempt, resp)
+
+		// 检查重试预算
+		if !r.Budget.Reserve(delay) {
+			lastErr := err
+			if lastErr == nil && resp != nil {
+				lastErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+			}
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, &BudgetExhaustedError{
+				Limit:   r.Budget.Limit(),
+				Spent:   r.Budget.Spent(),
+				LastErr: lastErr,
+			}
+		}
 
 		// 调用重试回调
 		if r.OnRetry != nil {
 			r.OnRetry(attempt, err, delay)
 		}
+		stats.TotalAttempts = attempt
+		stats.FailureCount = attempt
+		stats.TotalDelay += delay
+		stats.NextDelay = delay
+		stats.LastErr = err
+		if stats.LastErr == nil && resp != nil {
+			stats.LastErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		if r.OnStats != nil {
+			r.OnStats(stats)
+		}
+
+		// 放弃这次的错误响应
+		if resp != nil {
+			resp.Body.Close()
+		}
 
 		// 等待
 		select {
@@ -91,6 +191,45 @@ func (r *Retrier) Do(ctx context.Context, fn func() (*http.Response, error)) (*h
 	return lastResp, lastErr
 }
 
+// Wait 在 Do 之外的失败（如流式响应中途出错）后等待退避时间。
+// attempt 为已失败的次数；返回 nil 表示可以重试，否则返回不再重试的原因
+// （不可重试的错误、次数用尽、预算耗尽或上下文取消）
+func (r *Retrier) Wait(ctx context.Context, attempt int, err error) error {
+	if !IsRetryable(err) || attempt >= r.MaxRetries {
+		return err
+	}
+
+	delay := r.delay(attempt, nil)
+	if !r.Budget.Reserve(delay) {
+		return &BudgetExhaustedError{
+			Limit:   r.Budget.Limit(),
+			Spent:   r.Budget.Spent(),
+			LastErr: err,
+		}
+	}
+
+	if r.OnRetry != nil {
+		r.OnRetry(attempt, err, delay)
+	}
+	if r.OnStats != nil {
+		r.OnStats(RetryStats{
+			TotalAttempts: attempt,
+			MaxAttempts:   r.MaxRetries,
+			FailureCount:  attempt,
+			TotalDelay:    delay,
+			NextDelay:     delay,
+			LastErr:       err,
+		})
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
 // DoWithFunc 执行带重试的操作（泛型版本）
 func (r *Retrier) DoWithFunc(ctx context.Context, fn func() error) error {
 	var lastErr error
@@ -122,7 +261,16 @@ func (r *Retrier) DoWithFunc(ctx context.Context, fn func() error) error {
 		}
 
 		// 计算延迟
-		delay := CalculateDelay(attempt, nil)
+		delay := r.delay(attempt, nil)
+
+		// 检查重试预算
+		if !r.Budget.Reserve(delay) {
+			return &BudgetExhaustedError{
+				Limit:   r.Budget.Limit(),
+				Spent:   r.Budget.Spent(),
+				LastErr: err,
+			}
+		}
 
 		// 调用重试回调
 		if r.OnRetry != nil {
@@ -193,9 +341,17 @@ func DoWithValue[T any](ctx context.Context, fn func() (T, error), maxRetries in
 // RetryStats 重试统计信息
 type RetryStats struct {
 	TotalAttempts int
+	MaxAttempts   int // 最大尝试次数
 	SuccessCount  int
 	FailureCount  int
 	TotalDelay    time.Duration
+	NextDelay     time.Duration // 下一次尝试前的等待时间
+	LastErr       error         // 最近一次失败的原因
+}
+
+// NextAttempt 返回即将进行的尝试序号（从 1 开始）
+func (s *RetryStats) NextAttempt() int {
+	return s.TotalAttempts + 1
 }
 
 // String 返回统计信息的字符串表示
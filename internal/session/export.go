@@ -0,0 +1,325 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+// Exporter renders a conversation transcript as a shareable Markdown or
+// HTML document
+type Exporter struct {
+	Title        string    // Document heading (default "Conversation")
+	Model        string    // Shown under the heading when set
+	SystemPrompt string    // Included in a collapsed section when set
+	Usage        api.Usage // Token totals listed at the end
+	ExportedAt   time.Time // Defaults to the time of export
+}
+
+// NewExporter creates an exporter for a conversation with the given system prompt
+func NewExporter(systemPrompt string) *Exporter {
+	return &Exporter{SystemPrompt: systemPrompt}
+}
+
+// Exporter returns an exporter carrying the session's title, system prompt
+// and usage
+func (s *Session) Exporter() *Exporter {
+	e := NewExporter(s.SystemPrompt)
+	e.Title = s.Title()
+	e.Usage = s.Usage
+	return e
+}
+
+// ExportFile writes the transcript to path, as HTML if the extension is
+// .html or .htm and as Markdown otherwise
+func (e *Exporter) ExportFile(path string, messages []api.Message) error {
+	var doc string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		doc = e.HTML(messages)
+	default:
+		doc = e.Markdown(messages)
+	}
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// exportTurn is one rendered message: a user prompt or an assistant reply
+// with its tool calls and their results
+type exportTurn struct {
+	role   api.Role
+	blocks []exportBlock
+}
+
+// exportBlock is a text, thinking, image or tool call block of a turn
+type exportBlock struct {
+	kind   api.ContentType
+	text   string
+	tool   string
+	input  string // Pretty-printed JSON
+	result *api.Content
+}
+
+// turns groups messages for rendering: tool results are attached to the
+// tool calls that produced them, and user messages holding only tool
+// results are dropped, so consecutive messages from one role merge
+func turns(messages []api.Message) []exportTurn {
+	results := make(map[string]*api.Content)
+	for i := range messages {
+		for j := range messages[i].Content {
+			c := &messages[i].Content[j]
+			if c.Type == api.ContentTypeToolResult {
+				results[c.ToolUseID] = c
+			}
+		}
+	}
+
+	var out []exportTurn
+	for _, msg := range messages {
+		turn := exportTurn{role: msg.Role}
+		for _, c := range msg.Content {
+			switch c.Type {
+			case api.ContentTypeText:
+				if strings.TrimSpace(c.Text) != "" {
+					turn.blocks = append(turn.blocks, exportBlock{kind: c.Type, text: c.Text})
+				}
+			case api.ContentTypeThinking:
+				if strings.TrimSpace(c.Thinking) != "" {
+					turn.blocks = append(turn.blocks, exportBlock{kind: c.Type, text: c.Thinking})
+				}
+			case api.ContentTypeImage:
+				mediaType := "image"
+				if c.Source != nil {
+					mediaType = c.Source.MediaType
+				}
+				turn.blocks = append(turn.blocks, exportBlock{kind: c.Type, text: mediaType})
+			case api.ContentTypeToolUse:
+				turn.blocks = append(turn.blocks, exportBlock{
+					kind:   c.Type,
+					tool:   c.Name,
+					input:  prettyJSON(c.Input),
+					result: results[c.ID],
+				})
+			}
+		}
+		switch {
+		case len(turn.blocks) == 0:
+		case len(out) > 0 && out[len(out)-1].role == turn.role:
+			// An assistant reply continuing after tool results
+			out[len(out)-1].blocks = append(out[len(out)-1].blocks, turn.blocks...)
+		default:
+			out = append(out, turn)
+		}
+	}
+	return out
+}
+
+// Markdown renders the transcript as a Markdown document. Tool calls are
+// collapsible <details> sections with the input as JSON and the output
+// fenced.
+func (e *Exporter) Markdown(messages []api.Message) string {
+	var b strings.Builder
+	b.WriteString("# " + e.title() + "\n\n")
+	b.WriteString("_" + e.subtitle() + "_\n\n")
+
+	if e.SystemPrompt != "" {
+		b.WriteString("<details>\n<summary>System prompt</summary>\n\n")
+		b.WriteString(fence(e.SystemPrompt, ""))
+		b.WriteString("\n</details>\n\n")
+	}
+
+	for _, turn := range turns(messages) {
+		b.WriteString("## " + roleName(turn.role) + "\n\n")
+		for _, blk := range turn.blocks {
+			switch blk.kind {
+			case api.ContentTypeText:
+				b.WriteString(strings.TrimSpace(blk.text) + "\n\n")
+			case api.ContentTypeThinking:
+				b.WriteString("<details>\n<summary>Thinking</summary>\n\n")
+				b.WriteString(strings.TrimSpace(blk.text) + "\n\n</details>\n\n")
+			case api.ContentTypeImage:
+				b.WriteString(fmt.Sprintf("_[%s attached]_\n\n", blk.text))
+			case api.ContentTypeToolUse:
+				b.WriteString(fmt.Sprintf("<details>\n<summary>Tool: %s</summary>\n\n", html.EscapeString(blk.tool)))
+				b.WriteString("**Input**\n\n")
+				b.WriteString(fence(blk.input, "json"))
+				if blk.result != nil {
+					label := "Output"
+					if blk.result.IsError {
+						label = "Error"
+					}
+					b.WriteString("\n**" + label + "**\n\n")
+					b.WriteString(fence(resultText(blk.result), ""))
+				}
+				b.WriteString("\n</details>\n\n")
+			}
+		}
+	}
+
+	b.WriteString("---\n\n")
+	b.WriteString("| Tokens | Count |\n|---|---:|\n")
+	for _, row := range e.usageRows() {
+		b.WriteString(fmt.Sprintf("| %s | %d |\n", row.label, row.count))
+	}
+	return b.String()
+}
+
+// exportCSS styles the HTML export
+const exportCSS = `body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 860px; margin: 2em auto; padding: 0 1em; color: #1f2328; line-height: 1.5; }
+h1 { margin-bottom: 0.2em; }
+.meta { color: #656d76; margin-top: 0; }
+.turn { border-left: 4px solid #d0d7de; padding: 0.2em 1em; margin: 1.2em 0; }
+.turn.user { border-color: #0969da; }
+.turn.assistant { border-color: #8250df; }
+.role { font-weight: 600; margin: 0.4em 0; }
+.text { white-space: pre-wrap; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; border-radius: 6px; }
+details { margin: 0.6em 0; }
+summary { cursor: pointer; color: #656d76; }
+.error { color: #cf222e; }
+table { border-collapse: collapse; }
+td { padding: 0.2em 1em 0.2em 0; }
+td.num { text-align: right; }`
+
+// HTML renders the transcript as a standalone HTML page
+func (e *Exporter) HTML(messages []api.Message) string {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + esc(e.title()) + "</title>\n")
+	b.WriteString("<style>\n" + exportCSS + "\n</style>\n</head>\n<body>\n")
+	b.WriteString("<h1>" + esc(e.title()) + "</h1>\n")
+	b.WriteString("<p class=\"meta\">" + esc(e.subtitle()) + "</p>\n")
+
+	if e.SystemPrompt != "" {
+		b.WriteString("<details>\n<summary>System prompt</summary>\n<pre>" + esc(e.SystemPrompt) + "</pre>\n</details>\n")
+	}
+
+	for _, turn := range turns(messages) {
+		b.WriteString(fmt.Sprintf("<div class=\"turn %s\">\n<p class=\"role\">%s</p>\n", turn.role, roleName(turn.role)))
+		for _, blk := range turn.blocks {
+			switch blk.kind {
+			case api.ContentTypeText:
+				b.WriteString("<div class=\"text\">" + esc(strings.TrimSpace(blk.text)) + "</div>\n")
+			case api.ContentTypeThinking:
+				b.WriteString("<details>\n<summary>Thinking</summary>\n<div class=\"text\">" + esc(strings.TrimSpace(blk.text)) + "</div>\n</details>\n")
+			case api.ContentTypeImage:
+				b.WriteString("<p><em>[" + esc(blk.text) + " attached]</em></p>\n")
+			case api.ContentTypeToolUse:
+				b.WriteString("<details>\n<summary>Tool: " + esc(blk.tool) + "</summary>\n")
+				b.WriteString("<p>Input</p>\n<pre>" + esc(blk.input) + "</pre>\n")
+				if blk.result != nil {
+					if blk.result.IsError {
+						b.WriteString("<p class=\"error\">Error</p>\n")
+					} else {
+						b.WriteString("<p>Output</p>\n")
+					}
+					b.WriteString("<pre>" + esc(resultText(blk.result)) + "</pre>\n")
+				}
+				b.WriteString("</details>\n")
+			}
+		}
+		b.WriteString("</div>\n")
+	}
+
+	b.WriteString("<hr>\n<table>\n")
+	for _, row := range e.usageRows() {
+		b.WriteString(fmt.Sprintf("<tr><td>%s</td><td class=\"num\">%d</td></tr>\n", row.label, row.count))
+	}
+	b.WriteString("</table>\n</body>\n</html>\n")
+	return b.String()
+}
+
+func (e *Exporter) title() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return "Conversation"
+}
+
+func (e *Exporter) subtitle() string {
+	at := e.ExportedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	s := "Exported " + at.Format("2006-01-02 15:04")
+	if e.Model != "" {
+		s += " · " + e.Model
+	}
+	return s
+}
+
+type usageRow struct {
+	label string
+	count int
+}
+
+func (e *Exporter) usageRows() []usageRow {
+	u := e.Usage
+	return []usageRow{
+		{"Input", u.InputTokens},
+		{"Output", u.OutputTokens},
+		{"Cache read", u.CacheReadInputTokens},
+		{"Cache write", u.CacheCreationInputTokens},
+		{"Total", u.InputTokens + u.OutputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens},
+	}
+}
+
+func roleName(role api.Role) string {
+	if role == api.RoleAssistant {
+		return "Assistant"
+	}
+	return "User"
+}
+
+// resultText returns a tool result's text, noting any attached images
+func resultText(c *api.Content) string {
+	text := c.Content
+	for _, blk := range c.Blocks {
+		if blk.Type == api.ContentTypeImage && blk.Source != nil {
+			text += fmt.Sprintf("\n[%s attached]", blk.Source.MediaType)
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return "(no output)"
+	}
+	return strings.TrimRight(text, "\n")
+}
+
+// prettyJSON indents a tool input, falling back to the raw text
+func prettyJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return "{}"
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// fence wraps text in a code fence longer than any backtick run inside it
+func fence(text, lang string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + marker + "\n"
+}
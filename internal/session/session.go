@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/anthropics/claude-code-go/internal/api"
@@ -20,11 +22,22 @@ type Session struct {
 	WorkDir     string        `json:"work_dir"`
 	Messages    []api.Message `json:"messages"`
 	SystemPrompt string       `json:"system_prompt,omitempty"`
+
+	// Usage is the token usage of the whole session, across resumes
+	Usage api.Usage `json:"usage"`
 }
 
+// maxTitleLength is the maximum length of an auto-generated title
+const maxTitleLength = 50
+
+// TitleFunc generates a session title from the first user message
+type TitleFunc func(firstMessage string) (string, error)
+
 // SessionManager manages session persistence
 type SessionManager struct {
 	sessionDir string
+	autoTitle  bool      // Name unnamed sessions when they are saved
+	titleFunc  TitleFunc // Optional title generator (nil = HeuristicTitle)
 }
 
 // NewSessionManager creates a new session manager
@@ -56,17 +69,42 @@ func (m *SessionManager) CreateSession(workDir string) *Session {
 	}
 }
 
+// SetAutoTitle enables naming unnamed sessions on save. gen may be nil to
+// use HeuristicTitle only; if gen fails the heuristic title is used instead.
+func (m *SessionManager) SetAutoTitle(enabled bool, gen TitleFunc) {
+	m.autoTitle = enabled
+	m.titleFunc = gen
+}
+
 // SaveSession saves a session to disk
 func (m *SessionManager) SaveSession(session *Session) error {
 	session.UpdatedAt = time.Now()
 
+	if m.autoTitle && session.Name == "" {
+		session.AutoTitle(m.titleFunc)
+	}
+
 	data, err := json.MarshalIndent(session, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal session: %w", err)
 	}
 
+	// Write a temporary file and rename it over the old one, so a crash
+	// mid-save never leaves a truncated session
 	filename := filepath.Join(m.sessionDir, session.ID+".json")
-	if err := os.WriteFile(filename, data, 0600); err != nil {
+	tmp, err := os.CreateTemp(m.sessionDir, session.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
 		return fmt.Errorf("failed to write session file: %w", err)
 	}
 
@@ -129,6 +167,48 @@ func (m *SessionManager) ListSessions() ([]*Session, error) {
 	return sessions, nil
 }
 
+// ListSessionsPage lists sessions most recent first, filtered by query and paginated.
+// page is 1-indexed. It returns the sessions on the page and the total number of matches.
+func (m *SessionManager) ListSessionsPage(query string, page, pageSize int) ([]*Session, int, error) {
+	sessions, err := m.ListSessions()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if query != "" {
+		filtered := sessions[:0]
+		for _, s := range sessions {
+			if s.Matches(query) {
+				filtered = append(filtered, s)
+			}
+		}
+		sessions = filtered
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+
+	total := len(sessions)
+	if pageSize <= 0 {
+		return sessions, total, nil
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * pageSize
+	if start >= total {
+		return []*Session{}, total, nil
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return sessions[start:end], total, nil
+}
+
 // GetLatestSession returns the most recently updated session for a work directory
 func (m *SessionManager) GetLatestSession(workDir string) (*Session, error) {
 	sessions, err := m.ListSessions()
@@ -164,3 +244,94 @@ func (s *Session) ClearMessages() {
 	s.Messages = make([]api.Message, 0)
 	s.UpdatedAt = time.Now()
 }
+
+// Title returns the session name, or the first user message if unnamed
+func (s *Session) Title() string {
+	if s.Name != "" {
+		return s.Name
+	}
+
+	if text := s.FirstUserMessage(); text != "" {
+		title := strings.Join(strings.Fields(text), " ")
+		if runes := []rune(title); len(runes) > 60 {
+			title = string(runes[:57]) + "..."
+		}
+		return title
+	}
+
+	return "(untitled)"
+}
+
+// FirstUserMessage returns the text of the first user text block, if any
+func (s *Session) FirstUserMessage() string {
+	for _, msg := range s.Messages {
+		if msg.Role != api.RoleUser {
+			continue
+		}
+		for _, content := range msg.Content {
+			if content.Type == api.ContentTypeText && content.Text != "" {
+				return content.Text
+			}
+		}
+	}
+	return ""
+}
+
+// AutoTitle names an unnamed session from its first user message.
+// It reports whether a title was set.
+func (s *Session) AutoTitle(gen TitleFunc) bool {
+	if s.Name != "" {
+		return false
+	}
+	text := s.FirstUserMessage()
+	if text == "" {
+		return false
+	}
+
+	title := ""
+	if gen != nil {
+		if generated, err := gen(text); err == nil {
+			title = cleanTitle(generated)
+		}
+	}
+	if title == "" {
+		title = HeuristicTitle(text)
+	}
+	s.Name = title
+	return title != ""
+}
+
+// HeuristicTitle derives a short title from a message: its first non-empty
+// line, whitespace-collapsed and cut at a word boundary
+func HeuristicTitle(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if title := cleanTitle(line); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// cleanTitle normalizes whitespace, strips quotes and limits the length
+func cleanTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	title = strings.Trim(title, `"'` + "`" + `#*. `)
+	runes := []rune(title)
+	if len(runes) <= maxTitleLength {
+		return title
+	}
+
+	cut := string(runes[:maxTitleLength])
+	if i := strings.LastIndex(cut, " "); i > maxTitleLength/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, ",;:- ") + "..."
+}
+
+// Matches reports whether the session ID, title or work directory contains query (case-insensitive)
+func (s *Session) Matches(query string) bool {
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(s.ID), query) ||
+		strings.Contains(strings.ToLower(s.Title()), query) ||
+		strings.Contains(strings.ToLower(s.WorkDir), query)
+}
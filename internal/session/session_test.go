@@ -0,0 +1,34 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+func TestTitleTruncatesByRunes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"short", "fix the build", "fix the build"},
+		{"long ascii", strings.Repeat("a", 70), strings.Repeat("a", 57) + "..."},
+		{"long multi-byte", strings.Repeat("修", 70), strings.Repeat("修", 57) + "..."},
+		{"exactly sixty runes", strings.Repeat("é", 60), strings.Repeat("é", 60)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{Messages: []api.Message{api.NewTextMessage(api.RoleUser, tt.text)}}
+			got := s.Title()
+			if !utf8.ValidString(got) {
+				t.Fatalf("Title = %q is not valid UTF-8", got)
+			}
+			if got != tt.want {
+				t.Errorf("Title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,26 +6,82 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	DefaultBashTimeout    = 15 * time.Second // 缩短默认超时，避免卡住太久
 	MaxBashTimeout        = 2 * time.Minute
-	BackgroundCmdTimeout  = 5 * time.Second  // 后台命令应该快速返回
 	MaxOutputSize         = 30000
+	MaxCapturedOutput     = 1024 * 1024 // Bash output kept before the agent truncates it
+
+	// bashWaitDelay bounds how long a killed command may keep its output pipes open
+	bashWaitDelay = 2 * time.Second
 )
 
 // BashTool executes bash commands
 type BashTool struct {
-	workDir string
+	workDir   string
+	dangerous []DangerousCommand // Commands refused before execution
+
+	mu  sync.Mutex
+	cwd string // Directory commands run in; a cd in one call carries over to the next
+
+	bgMu       sync.Mutex
+	background map[int]*backgroundProc // Processes started with run_in_background, by PID
 }
 
 // NewBashTool creates a new Bash tool
 func NewBashTool(workDir string) *BashTool {
-	return &BashTool{workDir: workDir}
+	return &BashTool{
+		workDir:   workDir,
+		dangerous: DefaultDangerousCommands,
+		cwd:       workDir,
+	}
+}
+
+// Cwd returns the directory the next command runs in
+func (t *BashTool) Cwd() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cwd
+}
+
+// ResetCwd moves the shell back to the project directory
+func (t *BashTool) ResetCwd() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cwd = t.workDir
+}
+
+// ApprovalNote tells the user approving a command where it will run, when an
+// earlier cd moved the shell out of the project directory
+func (t *BashTool) ApprovalNote(params map[string]interface{}) string {
+	if cwd := t.Cwd(); cwd != t.workDir {
+		return "in " + cwd
+	}
+	return ""
+}
+
+// setCwd records the directory a command finished in, reporting whether it changed
+func (t *BashTool) setCwd(dir string) bool {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if dir == t.cwd {
+		return false
+	}
+	t.cwd = dir
+	return true
+}
+
+// SetDangerousCommands replaces the commands the tool refuses to run
+func (t *BashTool) SetDangerousCommands(commands []DangerousCommand) {
+	t.dangerous = commands
 }
 
 func (t *BashTool) Name() string {
@@ -42,19 +98,27 @@ For processes that run indefinitely (dev servers, watch tasks, daemons):
 - Set "run_in_background": true, OR
 - Append & to the command (e.g., "npm run dev &")
 The process will run in background and return immediately with PID and log file path.
+Use bash_logs to read its output, bash_list to see all background processes and
+bash_kill to stop one. Background processes are stopped when the session ends.
 
 Examples:
   {"command": "npm run dev", "run_in_background": true}  // Background server
   {"command": "npm run dev &"}                            // Alternative syntax
   {"command": "npm install"}                              // Normal command
 
+Working directory:
+- A cd persists: later commands run in the directory the previous command ended in
+- Background commands run in the current directory at launch time
+- Other tools still resolve relative paths against the project directory
+
 Timeouts:
 - Default timeout: 15 seconds (not 2 minutes anymore!)
 - Max timeout: 2 minutes
-- Background commands: 5 seconds to start
 
 Output:
-- Output exceeding 30000 characters will be truncated`
+- Output exceeding 30000 characters will be truncated to its beginning and end; the full output is saved
+
+Obviously destructive commands (rm -rf /, dd to a disk, fork bombs, curl | sh, ...) are refused.`
 }
 
 func (t *BashTool) Parameters() map[string]interface{} {
@@ -89,6 +153,11 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]interface{}) (
 		return NewErrorResultString("command parameter is required"), nil
 	}
 
+	// 拒绝明显具有破坏性的命令，不受 agent 权限规则影响
+	if cmd, ok := checkDangerous(command, t.dangerous); ok {
+		return NewErrorResultString(fmt.Sprintf("Command refused: it %s (bash guard check %q). If this is really intended, ask the user to run it themselves.", cmd.Reason, cmd.Name)), nil
+	}
+
 	// 检查是否明确指定后台运行
 	runInBackground := GetBoolDefault(params, "run_in_background", false)
 
@@ -100,58 +169,9 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]interface{}) (
 	isBackground := runInBackground || hasAmpersand
 
 	if isBackground {
-		// 处理后台命令：移除 &，使用 nohup 正确后台化
-		command = strings.TrimSuffix(trimmedCmd, "&")
-		command = strings.TrimSpace(command)
-
-		// 创建日志文件路径
-		logFile := filepath.Join(os.TempDir(), fmt.Sprintf("bg-cmd-%d.log", time.Now().Unix()))
-
-		// 转义命令中的单引号
-		escapedCmd := strings.ReplaceAll(command, "'", "'\\''")
-
-		// 使用 nohup 将命令完全后台化，并记录输出到日志文件
-		bgCommand := fmt.Sprintf(
-			"nohup bash -c '%s' > %s 2>&1 & echo \"Background process started. PID: $! | Log file: %s\"",
-			escapedCmd,
-			logFile,
-			logFile,
-		)
-
-		// 后台命令应该快速返回，使用短超时
-		ctx, cancel := context.WithTimeout(ctx, BackgroundCmdTimeout)
-		defer cancel()
-
-		cmd := exec.CommandContext(ctx, "bash", "-c", bgCommand)
-		cmd.Dir = t.workDir
-		cmd.Env = os.Environ()
-
-		var stdout, stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		err := cmd.Run()
-
-		result := stdout.String()
-		if stderr.Len() > 0 {
-			if result != "" {
-				result += "\n"
-			}
-			result += stderr.String()
-		}
-
-		if err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				return NewErrorResultString(fmt.Sprintf("Background command failed to start within %v\n%s", BackgroundCmdTimeout, result)), nil
-			}
-			return &Result{Output: result, IsError: true}, nil
-		}
-
-		if result == "" {
-			result = "Background process started (no immediate output)"
-		}
-
-		return NewResult(result), nil
+		// 移除 &，在独立进程组中启动并登记，供 bash_logs/bash_kill/bash_list 使用
+		command = strings.TrimSpace(strings.TrimSuffix(trimmedCmd, "&"))
+		return t.startBackground(command)
 	}
 
 	// 普通命令的处理逻辑（原代码）
@@ -168,10 +188,21 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]interface{}) (
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	// Record the final directory in a temp file so a cd carries over
+	pwdFile, err := os.CreateTemp("", "bash-pwd-*")
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+	pwdFile.Close()
+	defer os.Remove(pwdFile.Name())
+	wrapped := fmt.Sprintf("%s\n__status=$?\npwd > %s 2>/dev/null\nexit $__status", command, shellQuote(pwdFile.Name()))
+
 	// Create command
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
-	cmd.Dir = t.workDir
-	cmd.Env = os.Environ()
+	cmd := exec.CommandContext(ctx, "bash", "-c", wrapped)
+	cmd.Dir = t.Cwd()
+	cmd.Env = append(os.Environ(), "PWD="+cmd.Dir) // Keep pwd logical across symlinks
+	killProcessGroup(cmd)
+	cmd.WaitDelay = bashWaitDelay
 
 	// Capture output
 	var stdout, stderr bytes.Buffer
@@ -179,7 +210,7 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]interface{}) (
 	cmd.Stderr = &stderr
 
 	// Run command
-	err := cmd.Run()
+	err = cmd.Run()
 
 	// Build output
 	var output strings.Builder
@@ -195,22 +226,34 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]interface{}) (
 
 	result := output.String()
 
-	// Truncate if necessary
-	if len(result) > MaxOutputSize {
-		result = result[:MaxOutputSize] + "\n... (output truncated)"
+	// Bound what is kept; the agent truncates further and saves the full output
+	if len(result) > MaxCapturedOutput {
+		half := MaxCapturedOutput / 2
+		result = result[:half] + "\n... (output truncated) ...\n" + result[len(result)-half:]
+	}
+
+	// Tell the model when the directory changed
+	var cwdNote string
+	if data, readErr := os.ReadFile(pwdFile.Name()); readErr == nil {
+		if dir := strings.TrimSpace(string(data)); dir != "" && t.setCwd(dir) {
+			cwdNote = fmt.Sprintf("\n(working directory is now %s)", dir)
+		}
 	}
 
 	// Handle errors
 	if ctx.Err() == context.DeadlineExceeded {
 		return NewErrorResultString(fmt.Sprintf("Command timed out after %v\n%s", timeout, result)), nil
 	}
+	if ctx.Err() == context.Canceled {
+		return NewErrorResultString(fmt.Sprintf("Command cancelled\n%s", result)), nil
+	}
 
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if result == "" {
 				result = fmt.Sprintf("Command exited with code %d", exitErr.ExitCode())
 			}
-			return &Result{Output: result, IsError: true}, nil
+			return &Result{Output: result + cwdNote, IsError: true}, nil
 		}
 		return NewErrorResult(err), nil
 	}
@@ -219,5 +262,10 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]interface{}) (
 		result = "(no output)"
 	}
 
-	return NewResult(result), nil
+	return NewResult(result + cwdNote), nil
+}
+
+// shellQuote quotes s for use as a single bash word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
 }
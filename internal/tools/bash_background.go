@@ -0,0 +1,440 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBashLogLines = 100
+	MaxBashLogWait      = 30 * time.Second
+
+	// backgroundStartupWait is how long a launch waits to catch commands
+	// that fail immediately
+	backgroundStartupWait = 300 * time.Millisecond
+	// bashKillGrace is how long a killed process may take to exit after
+	// SIGTERM before it gets SIGKILL
+	bashKillGrace = 3 * time.Second
+	// bashLogPollInterval is how often bash_logs checks for new output
+	bashLogPollInterval = 200 * time.Millisecond
+)
+
+// BackgroundProcess is a command started with run_in_background
+type BackgroundProcess struct {
+	PID      int
+	Command  string
+	Dir      string
+	LogFile  string
+	Started  time.Time
+	Exited   bool
+	ExitCode int
+}
+
+// Status describes whether the process is still running
+func (p BackgroundProcess) Status() string {
+	if !p.Exited {
+		return "running"
+	}
+	return fmt.Sprintf("exited (code %d)", p.ExitCode)
+}
+
+// backgroundProc tracks a launched process until it is killed or the
+// session ends
+type backgroundProc struct {
+	info BackgroundProcess
+	done chan struct{} // Closed when the process exits
+}
+
+// startBackground launches command detached from the tool call, with its
+// output written to a log file, and registers it
+func (t *BashTool) startBackground(command string) (*Result, error) {
+	logFile, err := os.CreateTemp("", "bg-cmd-*.log")
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	cmd := exec.Command("bash", "-c", command)
+	cmd.Dir = t.Cwd()
+	cmd.Env = append(os.Environ(), "PWD="+cmd.Dir)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		os.Remove(logFile.Name())
+		return NewErrorResultString(fmt.Sprintf("Background command failed to start: %v", err)), nil
+	}
+	// The child holds its own copy of the descriptor
+	logFile.Close()
+
+	proc := &backgroundProc{
+		info: BackgroundProcess{
+			PID:     cmd.Process.Pid,
+			Command: command,
+			Dir:     cmd.Dir,
+			LogFile: logFile.Name(),
+			Started: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	t.bgMu.Lock()
+	if t.background == nil {
+		t.background = make(map[int]*backgroundProc)
+	}
+	t.background[proc.info.PID] = proc
+	t.bgMu.Unlock()
+
+	go func() {
+		cmd.Wait()
+		t.bgMu.Lock()
+		proc.info.Exited = true
+		proc.info.ExitCode = cmd.ProcessState.ExitCode()
+		t.bgMu.Unlock()
+		close(proc.done)
+	}()
+
+	result := fmt.Sprintf("Background process started. PID: %d | Log file: %s\nUse bash_logs to view its output and bash_kill to stop it.", proc.info.PID, proc.info.LogFile)
+
+	// Report commands that fail straight away, such as a typo in the name
+	select {
+	case <-proc.done:
+		info, _ := t.Background(proc.info.PID)
+		output, _, _ := readLogTail(info.LogFile, 0, DefaultBashLogLines)
+		result = fmt.Sprintf("Background process %d %s immediately. Log file: %s\n%s", info.PID, info.Status(), info.LogFile, output)
+		if info.ExitCode != 0 {
+			return &Result{Output: result, IsError: true}, nil
+		}
+	case <-time.After(backgroundStartupWait):
+	}
+	return NewResult(result), nil
+}
+
+// BackgroundProcesses returns the processes started in the background,
+// oldest first
+func (t *BashTool) BackgroundProcesses() []BackgroundProcess {
+	t.bgMu.Lock()
+	defer t.bgMu.Unlock()
+	procs := make([]BackgroundProcess, 0, len(t.background))
+	for _, p := range t.background {
+		procs = append(procs, p.info)
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].Started.Before(procs[j].Started)
+	})
+	return procs
+}
+
+// Background returns a background process by PID
+func (t *BashTool) Background(pid int) (BackgroundProcess, bool) {
+	t.bgMu.Lock()
+	defer t.bgMu.Unlock()
+	p, ok := t.background[pid]
+	if !ok {
+		return BackgroundProcess{}, false
+	}
+	return p.info, true
+}
+
+// findBackground looks a background process up by PID or log file path
+func (t *BashTool) findBackground(pid int, logFile string) (BackgroundProcess, error) {
+	if pid > 0 {
+		if p, ok := t.Background(pid); ok {
+			return p, nil
+		}
+		return BackgroundProcess{}, fmt.Errorf("no background process with PID %d; use bash_list to see them", pid)
+	}
+	for _, p := range t.BackgroundProcesses() {
+		if p.LogFile == logFile {
+			return p, nil
+		}
+	}
+	return BackgroundProcess{}, fmt.Errorf("no background process logs to %s; use bash_list to see them", logFile)
+}
+
+// KillBackground stops a background process and its children, first with
+// SIGTERM and then SIGKILL, removes it from the list and deletes its log
+func (t *BashTool) KillBackground(pid int) (BackgroundProcess, error) {
+	t.bgMu.Lock()
+	proc, ok := t.background[pid]
+	if ok {
+		delete(t.background, pid)
+	}
+	t.bgMu.Unlock()
+	if !ok {
+		return BackgroundProcess{}, fmt.Errorf("no background process with PID %d; use bash_list to see them", pid)
+	}
+
+	select {
+	case <-proc.done:
+	default:
+		signalProcessGroup(pid, false)
+		select {
+		case <-proc.done:
+		case <-time.After(bashKillGrace):
+			signalProcessGroup(pid, true)
+			<-proc.done
+		}
+	}
+
+	os.Remove(proc.info.LogFile)
+	t.bgMu.Lock()
+	info := proc.info
+	t.bgMu.Unlock()
+	return info, nil
+}
+
+// Cleanup kills every background process still running and deletes their
+// logs, typically when the session ends
+func (t *BashTool) Cleanup() {
+	for _, p := range t.BackgroundProcesses() {
+		t.KillBackground(p.PID)
+	}
+}
+
+// readLogTail returns the log contents from byte offset since, cut to the
+// last lines lines, and the offset to continue from
+func readLogTail(path string, since int64, lines int) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	size := info.Size()
+	if since > size {
+		since = 0 // The log was truncated
+	}
+
+	// Reading more than the output limit is pointless
+	start := since
+	if size-start > MaxOutputSize*2 {
+		start = size - MaxOutputSize*2
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, size-start))
+	if err != nil {
+		return "", 0, err
+	}
+
+	text := string(data)
+	all := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if lines > 0 && len(all) > lines {
+		text = strings.Join(all[len(all)-lines:], "\n") + "\n"
+	}
+	if len(text) > MaxOutputSize {
+		text = text[len(text)-MaxOutputSize:]
+	}
+	return text, size, nil
+}
+
+// BashLogsTool shows the output of a background process
+type BashLogsTool struct {
+	bash *BashTool
+}
+
+// NewBashLogsTool creates a new bash_logs tool for the Bash tool's background processes
+func NewBashLogsTool(bash *BashTool) *BashLogsTool {
+	return &BashLogsTool{bash: bash}
+}
+
+func (t *BashLogsTool) Name() string {
+	return "bash_logs"
+}
+
+func (t *BashLogsTool) Description() string {
+	return fmt.Sprintf(`Shows the output of a process started with the Bash tool's run_in_background.
+
+Usage:
+- Identify the process by pid or by log_file, both returned when it was started
+- Returns the last %d lines by default; use lines to change that
+- To follow the log, pass the next_offset from the previous call as since: only newer output is returned
+- Set wait_ms to wait up to that long (max %d000) for new output, e.g. while a server starts`, DefaultBashLogLines, int(MaxBashLogWait/time.Second))
+}
+
+func (t *BashLogsTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"pid": map[string]interface{}{
+				"type":        "number",
+				"description": "The PID of the background process",
+			},
+			"log_file": map[string]interface{}{
+				"type":        "string",
+				"description": "The log file path of the background process (alternative to pid)",
+			},
+			"lines": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("How many of the last lines to return (default %d)", DefaultBashLogLines),
+			},
+			"since": map[string]interface{}{
+				"type":        "number",
+				"description": "Only return output after this byte offset (the next_offset of an earlier call)",
+			},
+			"wait_ms": map[string]interface{}{
+				"type":        "number",
+				"description": "Wait up to this many milliseconds for new output if there is none yet",
+			},
+		},
+	}
+}
+
+func (t *BashLogsTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	pid := GetIntDefault(params, "pid", 0)
+	logFile := GetStringDefault(params, "log_file", "")
+	if pid <= 0 && logFile == "" {
+		return NewErrorResultString("pid or log_file is required"), nil
+	}
+	proc, err := t.bash.findBackground(pid, logFile)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	lines := GetIntDefault(params, "lines", DefaultBashLogLines)
+	since := int64(GetIntDefault(params, "since", 0))
+	wait := min(time.Duration(GetIntDefault(params, "wait_ms", 0))*time.Millisecond, MaxBashLogWait)
+
+	output, next, err := readLogTail(proc.LogFile, since, lines)
+	if err != nil {
+		return NewErrorResultString(fmt.Sprintf("Failed to read log: %v", err)), nil
+	}
+	if output == "" && wait > 0 {
+		deadline := time.Now().Add(wait)
+		for output == "" && time.Now().Before(deadline) && !proc.Exited {
+			select {
+			case <-ctx.Done():
+				return NewErrorResultString("Cancelled while waiting for output"), nil
+			case <-time.After(bashLogPollInterval):
+			}
+			if output, next, err = readLogTail(proc.LogFile, since, lines); err != nil {
+				return NewErrorResultString(fmt.Sprintf("Failed to read log: %v", err)), nil
+			}
+			proc, _ = t.bash.Background(proc.PID)
+		}
+	}
+
+	// Re-read the status: the process may have exited while we waited
+	if p, ok := t.bash.Background(proc.PID); ok {
+		proc = p
+	}
+	header := fmt.Sprintf("PID %d (%s): %s\nnext_offset: %d\n", proc.PID, proc.Status(), proc.Command, next)
+	if output == "" {
+		output = "(no new output)\n"
+	}
+	return NewResult(header + "\n" + output), nil
+}
+
+// BashKillTool stops a background process
+type BashKillTool struct {
+	bash *BashTool
+}
+
+// NewBashKillTool creates a new bash_kill tool for the Bash tool's background processes
+func NewBashKillTool(bash *BashTool) *BashKillTool {
+	return &BashKillTool{bash: bash}
+}
+
+func (t *BashKillTool) Name() string {
+	return "bash_kill"
+}
+
+func (t *BashKillTool) Description() string {
+	return `Stops a process started with the Bash tool's run_in_background, together with its child processes.
+
+The process gets SIGTERM, then SIGKILL if it has not exited after a few seconds. Its last output is returned and its log file deleted.`
+}
+
+func (t *BashKillTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"pid": map[string]interface{}{
+				"type":        "number",
+				"description": "The PID of the background process",
+			},
+		},
+		"required": []string{"pid"},
+	}
+}
+
+func (t *BashKillTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	pid := GetIntDefault(params, "pid", 0)
+	if pid <= 0 {
+		return NewErrorResultString("pid is required"), nil
+	}
+	proc, ok := t.bash.Background(pid)
+	if !ok {
+		return NewErrorResultString(fmt.Sprintf("no background process with PID %d; use bash_list to see them", pid)), nil
+	}
+
+	// Keep the last output before the log is deleted
+	tail, _, _ := readLogTail(proc.LogFile, 0, 20)
+
+	wasRunning := !proc.Exited
+	proc, err := t.bash.KillBackground(pid)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	var b strings.Builder
+	if wasRunning {
+		b.WriteString(fmt.Sprintf("Killed background process %d: %s\n", pid, proc.Command))
+	} else {
+		b.WriteString(fmt.Sprintf("Background process %d had already %s; removed it: %s\n", pid, proc.Status(), proc.Command))
+	}
+	if tail != "" {
+		b.WriteString("\nLast output:\n" + tail)
+	}
+	return NewResult(b.String()), nil
+}
+
+// BashListTool lists the background processes
+type BashListTool struct {
+	bash *BashTool
+}
+
+// NewBashListTool creates a new bash_list tool for the Bash tool's background processes
+func NewBashListTool(bash *BashTool) *BashListTool {
+	return &BashListTool{bash: bash}
+}
+
+func (t *BashListTool) Name() string {
+	return "bash_list"
+}
+
+func (t *BashListTool) Description() string {
+	return `Lists the processes started with the Bash tool's run_in_background in this session, with their PID, status, log file and command.`
+}
+
+func (t *BashListTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+func (t *BashListTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	procs := t.bash.BackgroundProcesses()
+	if len(procs) == 0 {
+		return NewResult("No background processes"), nil
+	}
+	var b strings.Builder
+	for _, p := range procs {
+		b.WriteString(fmt.Sprintf("PID %d  %s  started %s\n  log: %s\n  command: %s\n",
+			p.PID, p.Status(), p.Started.Format("15:04:05"), p.LogFile, p.Command))
+	}
+	return NewResult(b.String()), nil
+}
@@ -0,0 +1,27 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBashApprovalNoteShowsChangedDirectory(t *testing.T) {
+	workDir := t.TempDir()
+	sub := filepath.Join(workDir, "build")
+	os.Mkdir(sub, 0755)
+
+	tool := NewBashTool(workDir)
+	defer tool.Cleanup()
+	if note := tool.ApprovalNote(nil); note != "" {
+		t.Errorf("note in the project directory = %q, want none", note)
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"command": "cd build"}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if note, want := tool.ApprovalNote(nil), "in "+tool.Cwd(); note != want || filepath.Base(tool.Cwd()) != "build" {
+		t.Errorf("note after cd = %q, want %q with the build directory", note, want)
+	}
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DangerousCommand is a bash command pattern the Bash tool refuses to run,
+// whatever the agent's permission rules say
+type DangerousCommand struct {
+	Name    string // Used in config to turn the check off, e.g. "rm-root"
+	Pattern *regexp.Regexp
+	Reason  string
+}
+
+// protectedPaths matches the root, home and top-level system directories as an rm target
+const protectedPaths = `(?:/\*?|~/?\*?|\$\{?HOME\}?/?\*?|/(?:bin|boot|dev|etc|home|lib|lib64|opt|proc|root|sbin|sys|usr|var)/?\*?)`
+
+// DefaultDangerousCommands are the checks enabled unless turned off in config
+var DefaultDangerousCommands = []DangerousCommand{
+	{
+		Name:    "rm-root",
+		Pattern: regexp.MustCompile(`\brm\s+(?:-\S+\s+)*(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s+(?:-\S+\s+)*["']?` + protectedPaths + `["']?(?:\s|;|&|\||$)`),
+		Reason:  "recursively deletes the root, home or a system directory",
+	},
+	{
+		Name:    "dd-device",
+		Pattern: regexp.MustCompile(`\bdd\b[^|;&]*\bof=/dev/(?:sd|hd|vd|xvd|nvme|mmcblk|disk)`),
+		Reason:  "writes directly to a block device",
+	},
+	{
+		Name:    "write-device",
+		Pattern: regexp.MustCompile(`>\s*/dev/(?:sd|hd|vd|xvd|nvme|mmcblk|disk)`),
+		Reason:  "overwrites a block device",
+	},
+	{
+		Name:    "mkfs",
+		Pattern: regexp.MustCompile(`\bmkfs(?:\.\w+)?\s`),
+		Reason:  "formats a filesystem",
+	},
+	{
+		Name:    "fork-bomb",
+		Pattern: regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`),
+		Reason:  "is a fork bomb",
+	},
+	{
+		Name:    "curl-pipe-shell",
+		Pattern: regexp.MustCompile(`\b(?:curl|wget)\b[^|;&]*\|\s*(?:sudo\s+)?(?:ba|z|da|k)?sh\b`),
+		Reason:  "pipes a downloaded script straight into a shell",
+	},
+	{
+		Name:    "chmod-root",
+		Pattern: regexp.MustCompile(`\bch(?:mod|own)\s+(?:-\S+\s+)*-[a-zA-Z]*R[a-zA-Z]*\s+(?:-\S+\s+)*\S+\s+["']?/["']?(?:\s|;|&|\||$)`),
+		Reason:  "recursively changes permissions or ownership of the whole filesystem",
+	},
+}
+
+// DangerousCommands returns the default checks minus the disabled names
+// ("all" disables every default) plus extra patterns from config
+func DangerousCommands(disabled, extra []string) ([]DangerousCommand, error) {
+	off := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		off[name] = true
+	}
+
+	var commands []DangerousCommand
+	if !off["all"] {
+		for _, cmd := range DefaultDangerousCommands {
+			if !off[cmd.Name] {
+				commands = append(commands, cmd)
+			}
+		}
+	}
+
+	for _, pattern := range extra {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bash guard pattern %q: %w", pattern, err)
+		}
+		commands = append(commands, DangerousCommand{
+			Name:    pattern,
+			Pattern: re,
+			Reason:  "matches a blocked pattern from the config",
+		})
+	}
+
+	return commands, nil
+}
+
+// checkDangerous returns the first check the command matches, if any
+func checkDangerous(command string, commands []DangerousCommand) (DangerousCommand, bool) {
+	// Collapse line continuations and runs of whitespace
+	normalized := strings.Join(strings.Fields(strings.ReplaceAll(command, "\\\n", " ")), " ")
+	for _, cmd := range commands {
+		if cmd.Pattern.MatchString(normalized) {
+			return cmd, true
+		}
+	}
+	return DangerousCommand{}, false
+}
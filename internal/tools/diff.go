@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	MaxDiffLines = 200 // Diff lines included in Edit and Write results
+	diffContext  = 3   // Unchanged lines shown around each change
+
+	// maxDiffCells bounds the line-matching table; larger changes are shown
+	// as the old lines removed and the new lines added
+	maxDiffCells = 4 * 1024 * 1024
+)
+
+// FileChange is the change a file tool call would make
+type FileChange struct {
+	Path string // Absolute path of the file
+	Diff string // Unified diff against the current content ("" if unchanged)
+}
+
+// changePreviewer is implemented by tools that can compute the change a
+// call would make without making it
+type changePreviewer interface {
+	PreviewChange(params map[string]interface{}) (*FileChange, error)
+}
+
+// diffOp is one line of a line diff
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// UnifiedDiff returns a unified diff of oldContent and newContent labelled
+// with name, truncated to MaxDiffLines lines, or "" if they are equal
+func UnifiedDiff(name, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var b strings.Builder
+	b.WriteString("--- a/" + name + "\n")
+	b.WriteString("+++ b/" + name + "\n")
+
+	written := 0
+	for _, hunk := range diffHunks(ops) {
+		for _, line := range hunk {
+			if written >= MaxDiffLines {
+				b.WriteString(fmt.Sprintf("... (diff truncated after %d lines)\n", MaxDiffLines))
+				return strings.TrimSuffix(b.String(), "\n")
+			}
+			b.WriteString(line + "\n")
+			written++
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// diffName labels a diff with path relative to workDir when it is inside it
+func diffName(workDir, path string) string {
+	rel, err := filepath.Rel(workDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return strings.TrimPrefix(filepath.ToSlash(path), "/")
+	}
+	return filepath.ToSlash(rel)
+}
+
+// splitLines splits content into lines without their line endings
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines computes a line diff. Common leading and trailing lines are
+// matched directly and only the changed middle goes through the LCS table.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range midB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		ops = append(ops, lcsDiff(midA, midB)...)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// lcsDiff diffs a and b with a longest-common-subsequence table
+func lcsDiff(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// diffHunks groups changes with diffContext lines of context into hunks,
+// each starting with its @@ header
+func diffHunks(ops []diffOp) [][]string {
+	var hunks [][]string
+
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until a run of unchanged lines is long enough to split on
+		from := max(start-diffContext, 0)
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				break
+			}
+			end = run
+		}
+		to := min(end+diffContext, len(ops))
+
+		// Line numbers of the hunk start in the old and new content
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+
+		var oldCount, newCount int
+		lines := []string{""}
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+			lines = append(lines, string(op.kind)+op.text)
+		}
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+		lines[0] = fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldLine, oldCount, newLine, newCount)
+		hunks = append(hunks, lines)
+
+		start = to
+	}
+
+	return hunks
+}
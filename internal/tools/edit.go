@@ -2,15 +2,17 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
 // EditTool performs string replacements in files
 type EditTool struct {
-	workDir string
+	workDir  string
+	restrict bool         // Refuse paths outside workDir
+	history  *FileHistory // Records changes for /undo (nil = not recorded)
 }
 
 // NewEditTool creates a new Edit tool
@@ -18,6 +20,16 @@ func NewEditTool(workDir string) *EditTool {
 	return &EditTool{workDir: workDir}
 }
 
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *EditTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+// SetFileHistory makes the tool record the previous state of files it changes
+func (t *EditTool) SetFileHistory(h *FileHistory) {
+	t.history = h
+}
+
 func (t *EditTool) Name() string {
 	return "Edit"
 }
@@ -27,7 +39,9 @@ func (t *EditTool) Description() string {
 
 Usage:
 - The edit will FAIL if old_string is not unique in the file. Either provide a larger string with more surrounding context to make it unique or use replace_all to change every instance of old_string.
-- Use replace_all for replacing and renaming strings across the file. This parameter is useful if you want to rename a variable for instance.`
+- Use replace_all for replacing and renaming strings across the file. This parameter is useful if you want to rename a variable for instance.
+- Set start_line and/or end_line (1-indexed, inclusive, as shown by Read) to only match old_string within those lines. A short old_string that repeats elsewhere in the file then only needs to be unique within the range.
+- The result includes a unified diff of the change; set no_diff to leave it out.`
 }
 
 func (t *EditTool) Parameters() map[string]interface{} {
@@ -51,75 +65,196 @@ func (t *EditTool) Parameters() map[string]interface{} {
 				"description": "Replace all occurrences of old_string (default false)",
 				"default":     false,
 			},
+			"start_line": map[string]interface{}{
+				"type":        "number",
+				"description": "First line (1-indexed) of the range old_string must be in (default: start of file)",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "number",
+				"description": "Last line (inclusive) of the range old_string must be in (default: end of file)",
+			},
+			"no_diff": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Leave the diff out of the result (default false)",
+				"default":     false,
+			},
 		},
 		"required": []string{"file_path", "old_string", "new_string"},
 	}
 }
 
 func (t *EditTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	filePath, content, newContent, count, err := t.apply(params)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	// Write file
+	t.history.Record(t.Name(), filePath)
+	if err := os.WriteFile(filePath, []byte(newContent), 0644); err != nil {
+		return NewErrorResult(fmt.Errorf("failed to write file: %w", err)), nil
+	}
+
+	msg := fmt.Sprintf("Successfully edited %s", filePath)
+	if GetBoolDefault(params, "replace_all", false) {
+		msg = fmt.Sprintf("Successfully replaced %d occurrence(s) in %s", count, filePath)
+	}
+	if !GetBoolDefault(params, "no_diff", false) {
+		if diff := UnifiedDiff(diffName(t.workDir, filePath), content, newContent); diff != "" {
+			msg += "\n\n" + diff
+		}
+	}
+	return NewResult(msg), nil
+}
+
+// PreviewChange returns the change the call would make without writing it
+func (t *EditTool) PreviewChange(params map[string]interface{}) (*FileChange, error) {
+	filePath, content, newContent, _, err := t.apply(params)
+	if err != nil {
+		return nil, err
+	}
+	return &FileChange{
+		Path: filePath,
+		Diff: UnifiedDiff(diffName(t.workDir, filePath), content, newContent),
+	}, nil
+}
+
+// apply computes the edit in memory, returning the resolved path, the
+// current and new content and the number of occurrences replaced
+func (t *EditTool) apply(params map[string]interface{}) (filePath, content, newContent string, count int, err error) {
 	filePath, ok := GetString(params, "file_path")
 	if !ok || filePath == "" {
-		return NewErrorResultString("file_path parameter is required"), nil
+		return "", "", "", 0, errors.New("file_path parameter is required")
 	}
 
 	oldString, ok := GetString(params, "old_string")
 	if !ok {
-		return NewErrorResultString("old_string parameter is required"), nil
+		return "", "", "", 0, errors.New("old_string parameter is required")
 	}
 
 	newString, ok := GetString(params, "new_string")
 	if !ok {
-		return NewErrorResultString("new_string parameter is required"), nil
+		return "", "", "", 0, errors.New("new_string parameter is required")
 	}
 
 	if oldString == newString {
-		return NewErrorResultString("old_string and new_string must be different"), nil
+		return "", "", "", 0, errors.New("old_string and new_string must be different")
 	}
 
 	replaceAll := GetBoolDefault(params, "replace_all", false)
 
 	// Resolve path
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(t.workDir, filePath)
+	filePath, err = resolvePath(t.workDir, filePath, t.restrict)
+	if err != nil {
+		return "", "", "", 0, err
 	}
 
 	// Read file
-	content, err := os.ReadFile(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return NewErrorResultString(fmt.Sprintf("File not found: %s", filePath)), nil
+			return "", "", "", 0, fmt.Errorf("File not found: %s", filePath)
+		}
+		return "", "", "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	content = string(data)
+
+	// Restrict the replacement to a line range if one is given
+	target, prefix, suffix := content, "", ""
+	startLine, hasStart := GetInt(params, "start_line")
+	endLine, hasEnd := GetInt(params, "end_line")
+	hasRange := hasStart || hasEnd
+	if hasRange {
+		if !hasStart {
+			startLine = 1
+		}
+		from, to, last, err := lineRangeOffsets(target, startLine, endLine, hasEnd)
+		if err != nil {
+			return "", "", "", 0, err
+		}
+		endLine = last
+		prefix, target, suffix = target[:from], target[from:to], target[to:]
+
+		if count := strings.Count(target, oldString); count > 1 && !replaceAll {
+			return "", "", "", 0, fmt.Errorf("old_string found %d times in lines %d-%d. Narrow the line range, provide more context, or set replace_all to true.", count, startLine, endLine)
 		}
-		return NewErrorResult(fmt.Errorf("failed to read file: %w", err)), nil
 	}
 
-	fileContent := string(content)
+	replaced, count, err := applyEdit(target, oldString, newString, replaceAll)
+	if err == errOldStringNotFound {
+		if hasRange {
+			return "", "", "", 0, fmt.Errorf("old_string not found in lines %d-%d of %s", startLine, endLine, filePath)
+		}
+		return "", "", "", 0, fmt.Errorf("old_string not found in file: %s", filePath)
+	}
+	if err != nil {
+		return "", "", "", 0, err
+	}
+	return filePath, content, prefix + replaced + suffix, count, nil
+}
 
-	// Count occurrences
-	count := strings.Count(fileContent, oldString)
+// lineRangeOffsets returns the byte offsets spanning lines start through
+// end (1-indexed, inclusive, including the last line's newline) and the last
+// line number. Without hasEnd, or with end past the end of the file, the
+// range runs to the last line.
+func lineRangeOffsets(content string, start, end int, hasEnd bool) (from, to, last int, err error) {
+	total := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		total++
+	}
 
-	if count == 0 {
-		return NewErrorResultString(fmt.Sprintf("old_string not found in file: %s", filePath)), nil
+	if start < 1 {
+		return 0, 0, 0, fmt.Errorf("start_line must be at least 1")
+	}
+	if start > total {
+		return 0, 0, 0, fmt.Errorf("start_line %d is past the end of the file (%d lines)", start, total)
+	}
+	if !hasEnd || end > total {
+		end = total
+	}
+	if end < start {
+		return 0, 0, 0, fmt.Errorf("end_line %d is before start_line %d", end, start)
 	}
 
-	if count > 1 && !replaceAll {
-		return NewErrorResultString(fmt.Sprintf("old_string found %d times in file. Either provide a larger string with more context to make it unique, or set replace_all to true.", count)), nil
+	// Find the start of line start and the end of line end
+	line := 1
+	from = 0
+	to = len(content)
+	for i := 0; i < len(content); i++ {
+		if content[i] != '\n' {
+			continue
+		}
+		if line == start-1 {
+			from = i + 1
+		}
+		if line == end {
+			to = i + 1
+			break
+		}
+		line++
 	}
+	return from, to, end, nil
+}
 
-	// Perform replacement
-	var newContent string
-	if replaceAll {
-		newContent = strings.ReplaceAll(fileContent, oldString, newString)
-	} else {
-		newContent = strings.Replace(fileContent, oldString, newString, 1)
+// errOldStringNotFound is returned by applyEdit when old_string does not occur
+var errOldStringNotFound = errors.New("old_string not found in file")
+
+// applyEdit replaces oldString in content. Unless replaceAll is set,
+// oldString must occur exactly once. It returns the new content and the
+// number of occurrences replaced.
+func applyEdit(content, oldString, newString string, replaceAll bool) (string, int, error) {
+	count := strings.Count(content, oldString)
+
+	if count == 0 {
+		return "", 0, errOldStringNotFound
 	}
 
-	// Write file
-	if err := os.WriteFile(filePath, []byte(newContent), 0644); err != nil {
-		return NewErrorResult(fmt.Errorf("failed to write file: %w", err)), nil
+	if count > 1 && !replaceAll {
+		return "", count, fmt.Errorf("old_string found %d times in file. Either provide a larger string with more context to make it unique, or set replace_all to true.", count)
 	}
 
 	if replaceAll {
-		return NewResult(fmt.Sprintf("Successfully replaced %d occurrence(s) in %s", count, filePath)), nil
+		return strings.ReplaceAll(content, oldString, newString), count, nil
 	}
-	return NewResult(fmt.Sprintf("Successfully edited %s", filePath)), nil
+	return strings.Replace(content, oldString, newString, 1), count, nil
 }
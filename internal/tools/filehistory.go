@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// MaxFileHistory is how many file changes a session's history keeps for /undo
+const MaxFileHistory = 100
+
+// ErrNothingToUndo is returned by Undo when no file changes are recorded
+var ErrNothingToUndo = errors.New("no file changes to undo")
+
+// FileSnapshot is the state of a file before a tool changed it
+type FileSnapshot struct {
+	Tool    string      // Tool that made the change
+	Path    string      // Absolute path of the file
+	Existed bool        // False if the tool created the file
+	Content []byte      // Previous content (Existed only)
+	Mode    os.FileMode // Previous permissions (Existed only)
+	Time    time.Time   // When the change was made
+}
+
+// FileHistory is a bounded in-memory journal of the files the file tools
+// changed in a session, newest last
+type FileHistory struct {
+	max     int
+	entries []FileSnapshot
+	mu      sync.Mutex
+}
+
+// NewFileHistory creates a history keeping the last max changes
+func NewFileHistory(max int) *FileHistory {
+	return &FileHistory{max: max}
+}
+
+// historyRecorder is implemented by tools that record their file changes
+type historyRecorder interface {
+	SetFileHistory(h *FileHistory)
+}
+
+// Record notes the state of path before tool changes it. It reads the file
+// itself, so it must be called before the change is written.
+func (h *FileHistory) Record(tool, path string) {
+	if h == nil {
+		return
+	}
+	snap := FileSnapshot{Tool: tool, Path: path, Time: time.Now()}
+	if info, err := os.Stat(path); err == nil {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		snap.Existed = true
+		snap.Content = content
+		snap.Mode = info.Mode().Perm()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, snap)
+	if len(h.entries) > h.max {
+		h.entries = append([]FileSnapshot(nil), h.entries[len(h.entries)-h.max:]...)
+	}
+}
+
+// Len returns the number of changes that can be undone
+func (h *FileHistory) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.entries)
+}
+
+// Undo reverts the last n changes, newest first, restoring the previous
+// content or deleting files the tools created. It returns the changes
+// reverted; on error the ones before the failing change stay reverted.
+func (h *FileHistory) Undo(n int) ([]FileSnapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.entries) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	n = min(n, len(h.entries))
+
+	var undone []FileSnapshot
+	for range n {
+		snap := h.entries[len(h.entries)-1]
+		if err := snap.restore(); err != nil {
+			return undone, err
+		}
+		h.entries = h.entries[:len(h.entries)-1]
+		undone = append(undone, snap)
+	}
+	return undone, nil
+}
+
+// restore puts the file back in the recorded state
+func (s FileSnapshot) restore() error {
+	if !s.Existed {
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", s.Path, err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(s.Path, s.Content, s.Mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", s.Path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(s.Path, s.Mode)
+}
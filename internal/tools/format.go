@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Formatter is implemented by tools that render their results in the TUI
+// better than the generic truncated output. FormatTUI receives the call's
+// input (JSON) and output and returns the text to show, or "" to fall back
+// to the generic rendering.
+type Formatter interface {
+	FormatTUI(input, output string) string
+}
+
+// maxFormattedLines bounds the lines a formatter shows
+const maxFormattedLines = 20
+
+var (
+	formatDim       = lipgloss.NewStyle().Faint(true)
+	formatBold      = lipgloss.NewStyle().Bold(true)
+	formatDone      = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+	formatHighlight = lipgloss.NewStyle().Bold(true).Reverse(true)
+)
+
+// FormatTUI renders the new todo list as checkboxes
+func (t *TodoWriteTool) FormatTUI(input, output string) string {
+	var params struct {
+		Todos []TodoItem `json:"todos"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil || len(params.Todos) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(params.Todos))
+	done := 0
+	for _, todo := range params.Todos {
+		switch todo.Status {
+		case TodoStatusCompleted:
+			done++
+			lines = append(lines, "[x] "+formatDone.Render(todo.Content))
+		case TodoStatusInProgress:
+			lines = append(lines, "[~] "+formatBold.Render(todo.ActiveForm))
+		default:
+			lines = append(lines, "[ ] "+todo.Content)
+		}
+	}
+	lines = capLines(lines)
+	lines = append(lines, formatDim.Render(fmt.Sprintf("%d/%d done", done, len(params.Todos))))
+	return strings.Join(lines, "\n")
+}
+
+// FormatTUI highlights the matches in content output and the counts in
+// count output
+func (t *GrepTool) FormatTUI(input, output string) string {
+	var params struct {
+		Pattern         string `json:"pattern"`
+		CaseInsensitive bool   `json:"-i"`
+		OutputMode      string `json:"output_mode"`
+		ShowLineNumbers *bool  `json:"-n"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil || params.Pattern == "" {
+		return ""
+	}
+	if output == "No matches found" {
+		return ""
+	}
+
+	pattern := params.Pattern
+	if params.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ""
+	}
+	numbered := params.ShowLineNumbers == nil || *params.ShowLineNumbers
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		switch params.OutputMode {
+		case "content":
+			lines[i] = formatGrepLine(line, re, numbered)
+		case "count":
+			if sep := strings.LastIndex(line, ":"); sep > 0 {
+				lines[i] = line[:sep+1] + formatBold.Render(line[sep+1:])
+			}
+		}
+	}
+	return strings.Join(capLines(lines), "\n")
+}
+
+// formatGrepLine dims the "path:line:" prefix of a content line and
+// highlights the matches in the rest
+func formatGrepLine(line string, re *regexp.Regexp, numbered bool) string {
+	fields := 2
+	if numbered {
+		fields = 3
+	}
+	parts := strings.SplitN(line, ":", fields)
+	if len(parts) < fields {
+		return line
+	}
+	prefix := strings.Join(parts[:fields-1], ":") + ":"
+	text := parts[fields-1]
+
+	var b strings.Builder
+	b.WriteString(formatDim.Render(prefix))
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(formatHighlight.Render(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// capLines keeps the first maxFormattedLines lines and notes how many were left out
+func capLines(lines []string) []string {
+	if len(lines) <= maxFormattedLines {
+		return lines
+	}
+	hidden := len(lines) - maxFormattedLines
+	return append(lines[:maxFormattedLines:maxFormattedLines], formatDim.Render(fmt.Sprintf("... (%d more lines)", hidden)))
+}
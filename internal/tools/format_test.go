@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// plain removes terminal styling so tests compare the text
+func plain(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+func TestTodoWriteFormatTUI(t *testing.T) {
+	tool := &TodoWriteTool{}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "statuses",
+			input: `{"todos":[{"content":"Write tests","activeForm":"Writing tests","status":"completed"},{"content":"Fix bug","activeForm":"Fixing bug","status":"in_progress"},{"content":"Ship","activeForm":"Shipping","status":"pending"}]}`,
+			want:  "[x] Write tests\n[~] Fixing bug\n[ ] Ship\n1/3 done",
+		},
+		{
+			name:  "empty list falls back",
+			input: `{"todos":[]}`,
+			want:  "",
+		},
+		{
+			name:  "invalid input falls back",
+			input: `not json`,
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plain(tool.FormatTUI(tt.input, "")); got != tt.want {
+				t.Errorf("FormatTUI = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTodoWriteFormatTUICapsLines(t *testing.T) {
+	var todos []string
+	for i := 0; i < maxFormattedLines+5; i++ {
+		todos = append(todos, fmt.Sprintf(`{"content":"task %d","activeForm":"doing %d","status":"pending"}`, i, i))
+	}
+	got := plain((&TodoWriteTool{}).FormatTUI(`{"todos":[`+strings.Join(todos, ",")+`]}`, ""))
+	if !strings.Contains(got, "... (5 more lines)") || strings.Contains(got, "task 20") {
+		t.Errorf("FormatTUI of a long list = %q, want it capped", got)
+	}
+}
+
+func TestGrepFormatTUI(t *testing.T) {
+	tool := &GrepTool{}
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		want   string
+	}{
+		{
+			name:   "content with line numbers",
+			input:  `{"pattern":"TODO","output_mode":"content"}`,
+			output: "main.go:12:// TODO: fix\nutil.go:3:x := 1 // TODO",
+			want:   "main.go:12:// TODO: fix\nutil.go:3:x := 1 // TODO",
+		},
+		{
+			name:   "count",
+			input:  `{"pattern":"x","output_mode":"count"}`,
+			output: "a.go:3\nb.go:1",
+			want:   "a.go:3\nb.go:1",
+		},
+		{
+			name:   "no matches falls back",
+			input:  `{"pattern":"x","output_mode":"content"}`,
+			output: "No matches found",
+			want:   "",
+		},
+		{
+			name:   "invalid regex falls back",
+			input:  `{"pattern":"(","output_mode":"content"}`,
+			output: "a.go:1:(",
+			want:   "",
+		},
+		{
+			name:   "missing pattern falls back",
+			input:  `{}`,
+			output: "a.go",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plain(tool.FormatTUI(tt.input, tt.output)); got != tt.want {
+				t.Errorf("FormatTUI = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatGrepLineHighlightsMatches(t *testing.T) {
+	re := regexp.MustCompile("TODO")
+	line := "main.go:12:a TODO and TODO"
+	got := formatGrepLine(line, re, true)
+	if plain(got) != line {
+		t.Errorf("formatGrepLine changed the text: %q", plain(got))
+	}
+	want := "a " + formatHighlight.Render("TODO") + " and " + formatHighlight.Render("TODO")
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("formatGrepLine = %q, want the matches highlighted (%q)", got, want)
+	}
+}
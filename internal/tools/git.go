@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const GitCommandTimeout = 10 * time.Second
+
+// runGit runs a git command in dir and returns its stdout
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, GitCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+	}
+
+	return stdout.String(), nil
+}
+
+// blameLine holds the provenance of a single line
+type blameLine struct {
+	Hash   string
+	Author string
+	Date   time.Time
+}
+
+// gitBlame returns blame info for lines [start, end] of filePath, keyed by line number
+func gitBlame(ctx context.Context, filePath string, start, end int) (map[int]blameLine, error) {
+	dir := filepath.Dir(filePath)
+	out, err := runGit(ctx, dir, "blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", start, end), "--", filepath.Base(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	// Porcelain output only includes author details the first time a commit appears
+	commits := make(map[string]*blameLine)
+	result := make(map[int]blameLine)
+
+	var current *blameLine
+	var currentLine int
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "\t") {
+			// Content line terminates the entry
+			if current != nil {
+				result[currentLine] = *current
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && len(fields[0]) == 40 {
+			hash := fields[0]
+			currentLine, _ = strconv.Atoi(fields[2])
+			if commits[hash] == nil {
+				commits[hash] = &blameLine{Hash: hash}
+			}
+			current = commits[hash]
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "author "):
+			current.Author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			if ts, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+				current.Date = time.Unix(ts, 0)
+			}
+		}
+	}
+
+	return result, scanner.Err()
+}
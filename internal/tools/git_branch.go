@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// GitBranchTool lists, creates and switches git branches with a guard against losing work
+type GitBranchTool struct {
+	workDir string
+}
+
+// NewGitBranchTool creates a new GitBranch tool
+func NewGitBranchTool(workDir string) *GitBranchTool {
+	return &GitBranchTool{workDir: workDir}
+}
+
+func (t *GitBranchTool) Name() string {
+	return "GitBranch"
+}
+
+func (t *GitBranchTool) Description() string {
+	return `Lists, creates and switches git branches safely.
+
+Actions:
+- list: Show local branches (current branch marked with *)
+- checkout: Switch to an existing branch
+- create: Create a new branch and switch to it
+
+Usage notes:
+- checkout refuses to run when the working tree has uncommitted changes, to prevent losing work
+- Set force to true to skip that guard (git will still refuse if changes would be overwritten)
+- Prefer this tool over running git checkout through Bash`
+}
+
+func (t *GitBranchTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "checkout", "create"},
+				"description": "The branch operation to perform",
+			},
+			"branch": map[string]interface{}{
+				"type":        "string",
+				"description": "The branch name (required for checkout and create)",
+			},
+			"start_point": map[string]interface{}{
+				"type":        "string",
+				"description": "Commit or branch to create the new branch from (create only, defaults to HEAD)",
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Skip the uncommitted-changes guard on checkout",
+				"default":     false,
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *GitBranchTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	action, ok := GetString(params, "action")
+	if !ok || action == "" {
+		return NewErrorResultString("action parameter is required"), nil
+	}
+
+	if _, err := runGit(ctx, t.workDir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return NewErrorResultString(fmt.Sprintf("Not a git repository: %s", t.workDir)), nil
+	}
+
+	switch action {
+	case "list":
+		return t.list(ctx)
+	case "checkout", "create":
+		branch, _ := GetString(params, "branch")
+		if branch == "" {
+			return NewErrorResultString(fmt.Sprintf("branch parameter is required for %s", action)), nil
+		}
+		if strings.HasPrefix(branch, "-") {
+			return NewErrorResultString(fmt.Sprintf("Invalid branch name: %s", branch)), nil
+		}
+		if action == "create" {
+			return t.create(ctx, branch, GetStringDefault(params, "start_point", ""))
+		}
+		return t.checkout(ctx, branch, GetBoolDefault(params, "force", false))
+	default:
+		return NewErrorResultString(fmt.Sprintf("Unknown action: %s (expected list, checkout or create)", action)), nil
+	}
+}
+
+func (t *GitBranchTool) list(ctx context.Context) (*Result, error) {
+	out, err := runGit(ctx, t.workDir, "branch", "--list", "--format=%(HEAD) %(refname:short) %(objectname:short) %(committerdate:short)")
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+	out = strings.TrimRight(out, "\n")
+	if out == "" {
+		return NewResult("No branches found (repository has no commits yet)"), nil
+	}
+	return NewResult(out), nil
+}
+
+func (t *GitBranchTool) checkout(ctx context.Context, branch string, force bool) (*Result, error) {
+	if !force {
+		status, err := runGit(ctx, t.workDir, "status", "--porcelain", "--untracked-files=no")
+		if err != nil {
+			return NewErrorResult(err), nil
+		}
+		if strings.TrimSpace(status) != "" {
+			return NewErrorResultString(fmt.Sprintf("Refusing to checkout %s: working tree has uncommitted changes:\n%s\nCommit or stash them first, or set force to true.",
+				branch, strings.TrimRight(status, "\n"))), nil
+		}
+	}
+
+	if _, err := runGit(ctx, t.workDir, "checkout", branch, "--"); err != nil {
+		return NewErrorResult(err), nil
+	}
+	return NewResult(fmt.Sprintf("Switched to branch %s", branch)), nil
+}
+
+func (t *GitBranchTool) create(ctx context.Context, branch, startPoint string) (*Result, error) {
+	args := []string{"checkout", "-b", branch}
+	if startPoint != "" {
+		args = append(args, startPoint)
+	}
+	args = append(args, "--")
+
+	if _, err := runGit(ctx, t.workDir, args...); err != nil {
+		return NewErrorResult(err), nil
+	}
+	if startPoint != "" {
+		return NewResult(fmt.Sprintf("Created and switched to branch %s (from %s)", branch, startPoint)), nil
+	}
+	return NewResult(fmt.Sprintf("Created and switched to branch %s", branch)), nil
+}
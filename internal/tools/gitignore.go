@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	pattern  string // Slash-separated glob, relative to the .gitignore's directory
+	negate   bool   // "!pattern" re-includes matching paths
+	dirOnly  bool   // "pattern/" only matches directories
+	anchored bool   // Contains a slash, so it matches from the .gitignore's directory
+}
+
+// GitIgnore matches paths against the .gitignore files under a root
+// directory. Nested .gitignore files are loaded on demand.
+type GitIgnore struct {
+	root  string
+	rules map[string][]ignoreRule // Keyed by directory relative to root ("" = root)
+}
+
+// NewGitIgnore creates a matcher for the .gitignore files under root
+func NewGitIgnore(root string) *GitIgnore {
+	return &GitIgnore{
+		root:  root,
+		rules: make(map[string][]ignoreRule),
+	}
+}
+
+// ignoreRoot picks the directory whose .gitignore files apply to searchPath:
+// the working directory if searchPath is inside it, otherwise searchPath itself
+func ignoreRoot(workDir, searchPath string) string {
+	rel, err := filepath.Rel(workDir, searchPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return searchPath
+	}
+	return workDir
+}
+
+// Ignored reports whether path is excluded by a .gitignore. Paths outside
+// the root are never ignored; the .git directory always is.
+func (g *GitIgnore) Ignored(path string, isDir bool) bool {
+	rel, err := filepath.Rel(g.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	for _, part := range parts {
+		if part == ".git" {
+			return true
+		}
+	}
+
+	// Nothing inside an ignored directory can be re-included
+	for i := 1; i < len(parts); i++ {
+		if g.match(parts[:i], true) {
+			return true
+		}
+	}
+	return g.match(parts, isDir)
+}
+
+// match applies the rules of each .gitignore from the root down to the
+// path's directory; the last matching rule wins
+func (g *GitIgnore) match(parts []string, isDir bool) bool {
+	ignored := false
+	for depth := 0; depth < len(parts); depth++ {
+		dir := strings.Join(parts[:depth], "/")
+		rel := strings.Join(parts[depth:], "/")
+		name := parts[len(parts)-1]
+
+		for _, rule := range g.load(dir) {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			target := name
+			if rule.anchored {
+				target = rel
+			}
+			if ok, _ := doublestar.Match(rule.pattern, target); ok {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// load returns the rules of the .gitignore in dir, reading it on first use
+func (g *GitIgnore) load(dir string) []ignoreRule {
+	if rules, ok := g.rules[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	file, err := os.Open(filepath.Join(g.root, filepath.FromSlash(dir), ".gitignore"))
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+		file.Close()
+	}
+
+	g.rules[dir] = rules
+	return rules
+}
+
+// parseIgnoreLine parses one .gitignore line; ok is false for blanks and comments
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, "\\!"), strings.HasPrefix(line, "\\#"):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = line
+	return rule, true
+}
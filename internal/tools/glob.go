@@ -3,7 +3,9 @@ package tools
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -15,7 +17,8 @@ const MaxGlobResults = 1000
 
 // GlobTool performs glob pattern matching
 type GlobTool struct {
-	workDir string
+	workDir  string
+	restrict bool // Refuse paths outside workDir
 }
 
 // NewGlobTool creates a new Glob tool
@@ -23,6 +26,11 @@ func NewGlobTool(workDir string) *GlobTool {
 	return &GlobTool{workDir: workDir}
 }
 
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *GlobTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
 func (t *GlobTool) Name() string {
 	return "Glob"
 }
@@ -32,6 +40,8 @@ func (t *GlobTool) Description() string {
 
 - Supports glob patterns like "**/*.js" or "src/**/*.ts"
 - Returns matching file paths sorted by modification time
+- Files excluded by .gitignore are skipped unless no_ignore is set
+- Set include_dirs to also match directories (shown with a trailing /) and absolute for absolute paths
 - Use this tool when you need to find files by name patterns`
 }
 
@@ -47,6 +57,18 @@ func (t *GlobTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "The directory to search in. If not specified, the current working directory will be used.",
 			},
+			"no_ignore": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Include files excluded by .gitignore (default: false)",
+			},
+			"include_dirs": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also return matching directories, marked with a trailing / (default: false)",
+			},
+			"absolute": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Return absolute paths instead of paths relative to the working directory (default: false)",
+			},
 		},
 		"required": []string{"pattern"},
 	}
@@ -55,6 +77,7 @@ func (t *GlobTool) Parameters() map[string]interface{} {
 type fileInfo struct {
 	path    string
 	modTime int64
+	isDir   bool
 }
 
 func (t *GlobTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
@@ -66,11 +89,11 @@ func (t *GlobTool) Execute(ctx context.Context, params map[string]interface{}) (
 	// Get search path
 	searchPath := t.workDir
 	if path, ok := GetString(params, "path"); ok && path != "" {
-		if filepath.IsAbs(path) {
-			searchPath = path
-		} else {
-			searchPath = filepath.Join(t.workDir, path)
+		resolved, err := resolvePath(t.workDir, path, t.restrict)
+		if err != nil {
+			return NewErrorResult(err), nil
 		}
+		searchPath = resolved
 	}
 
 	// Verify path exists
@@ -88,32 +111,66 @@ func (t *GlobTool) Execute(ctx context.Context, params map[string]interface{}) (
 	// Combine path and pattern
 	fullPattern := filepath.Join(searchPath, pattern)
 
+	// A pattern such as "../**" must not reach outside either
+	if t.restrict {
+		base, _ := doublestar.SplitPattern(filepath.ToSlash(fullPattern))
+		if _, err := resolvePath(t.workDir, filepath.FromSlash(base), true); err != nil {
+			if outside, ok := err.(*OutsideWorkDirError); ok {
+				outside.Path = pattern
+			}
+			return NewErrorResult(err), nil
+		}
+	}
+
 	// Find matches using doublestar
-	matches, err := doublestar.FilepathGlob(fullPattern)
+	matches, err := globFiles(ctx, fullPattern)
 	if err != nil {
 		return NewErrorResult(fmt.Errorf("invalid glob pattern: %w", err)), nil
 	}
 
 	if len(matches) == 0 {
+		if ctx.Err() != nil {
+			return NewResult("No files found\n" + stoppedEarlyNote(ctx)), nil
+		}
 		return NewResult("No files found"), nil
 	}
 
+	// Skip files excluded by .gitignore unless asked not to
+	var ignore *GitIgnore
+	if !GetBoolDefault(params, "no_ignore", false) {
+		ignore = NewGitIgnore(ignoreRoot(t.workDir, searchPath))
+	}
+
+	includeDirs := GetBoolDefault(params, "include_dirs", false)
+	absolute := GetBoolDefault(params, "absolute", false)
+
 	// Get file info for sorting by modification time
 	var files []fileInfo
 	for _, match := range matches {
+		if ctx.Err() != nil {
+			break
+		}
 		info, err := os.Stat(match)
 		if err != nil {
 			continue
 		}
-		if info.IsDir() {
+		if info.IsDir() && (!includeDirs || match == filepath.Clean(searchPath)) {
+			continue
+		}
+		if ignore != nil && ignore.Ignored(match, info.IsDir()) {
 			continue
 		}
 		files = append(files, fileInfo{
 			path:    match,
 			modTime: info.ModTime().Unix(),
+			isDir:   info.IsDir(),
 		})
 	}
 
+	if len(files) == 0 {
+		return NewResult("No files found"), nil
+	}
+
 	// Sort by modification time (most recent first)
 	sort.Slice(files, func(i, j int) bool {
 		return files[i].modTime > files[j].modTime
@@ -131,17 +188,57 @@ func (t *GlobTool) Execute(ctx context.Context, params map[string]interface{}) (
 	for _, f := range files {
 		// Make path relative to work directory if possible
 		relPath, err := filepath.Rel(t.workDir, f.path)
-		if err != nil || strings.HasPrefix(relPath, "..") {
+		if absolute || err != nil || strings.HasPrefix(relPath, "..") {
 			output.WriteString(f.path)
 		} else {
 			output.WriteString(relPath)
 		}
+		if f.isDir {
+			output.WriteString(string(filepath.Separator))
+		}
 		output.WriteString("\n")
 	}
 
 	if truncated {
 		output.WriteString(fmt.Sprintf("\n... (showing first %d of many results)", MaxGlobResults))
 	}
+	if ctx.Err() != nil {
+		output.WriteString("\n" + stoppedEarlyNote(ctx))
+	}
 
 	return NewResult(strings.TrimSuffix(output.String(), "\n")), nil
 }
+
+// ctxFS is a filesystem that stops opening files once ctx is done, which
+// cuts a long glob walk short
+type ctxFS struct {
+	fs.FS
+	ctx context.Context
+}
+
+func (f ctxFS) Open(name string) (fs.File, error) {
+	if err := f.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.FS.Open(name)
+}
+
+// globFiles works like doublestar.FilepathGlob but stops walking when ctx
+// is done, returning the matches found so far
+func globFiles(ctx context.Context, pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	base, rest := doublestar.SplitPattern(pattern)
+	if rest == "" || rest == "." || rest == ".." {
+		// No wildcards to walk
+		return doublestar.FilepathGlob(pattern)
+	}
+
+	matches, err := doublestar.Glob(ctxFS{FS: os.DirFS(base), ctx: ctx}, rest)
+	if err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i] = filepath.FromSlash(path.Join(base, matches[i]))
+	}
+	return matches, nil
+}
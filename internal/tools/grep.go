@@ -3,11 +3,14 @@ package tools
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 )
@@ -15,11 +18,23 @@ import (
 const (
 	MaxGrepResults = 500
 	DefaultContext = 0
+
+	// MaxMultilineFileSize is the largest file a multiline search reads whole;
+	// larger files are skipped
+	MaxMultilineFileSize = 10 * 1024 * 1024
 )
 
+// isNoiseDir reports whether a directory is hidden or a common non-code
+// directory that searches and listings skip
+func isNoiseDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || name == "__pycache__"
+}
+
 // GrepTool searches file contents using regex
 type GrepTool struct {
-	workDir string
+	workDir  string
+	restrict bool        // Refuse paths outside workDir
+	policy   *PathPolicy // Files never searched (nil = none)
 }
 
 // NewGrepTool creates a new Grep tool
@@ -27,6 +42,16 @@ func NewGrepTool(workDir string) *GrepTool {
 	return &GrepTool{workDir: workDir}
 }
 
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *GrepTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+// SetPathPolicy makes the tool skip files the path policy denies
+func (t *GrepTool) SetPathPolicy(policy *PathPolicy) {
+	t.policy = policy
+}
+
 func (t *GrepTool) Name() string {
 	return "Grep"
 }
@@ -38,7 +63,9 @@ Usage:
 - Supports full regex syntax (e.g., "log.*Error", "function\\s+\\w+")
 - Filter files with glob parameter (e.g., "*.js", "**/*.tsx")
 - Output modes: "content" shows matching lines, "files_with_matches" shows only file paths (default), "count" shows match counts
-- Use -A, -B, or -C for context lines around matches`
+- Use -A, -B, or -C for context lines around matches
+- Set multiline to match patterns across lines (e.g. "func.*\\{.*\\}"); "." then also matches newlines. Files over 10MB are skipped in this mode
+- Set sort_by_mtime to list files_with_matches most recently modified first`
 }
 
 func (t *GrepTool) Parameters() map[string]interface{} {
@@ -57,6 +84,10 @@ func (t *GrepTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Glob pattern to filter files (e.g. \"*.js\", \"*.{ts,tsx}\")",
 			},
+			"no_ignore": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also search files excluded by .gitignore (default: false)",
+			},
 			"output_mode": map[string]interface{}{
 				"type":        "string",
 				"enum":        []string{"content", "files_with_matches", "count"},
@@ -86,6 +117,14 @@ func (t *GrepTool) Parameters() map[string]interface{} {
 				"type":        "number",
 				"description": "Limit output to first N lines/entries",
 			},
+			"multiline": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Match across lines: search each file as a whole with . matching newlines (default: false)",
+			},
+			"sort_by_mtime": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Sort files_with_matches output by modification time, newest first (default: false, path order)",
+			},
 		},
 		"required": []string{"pattern"},
 	}
@@ -102,6 +141,8 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 	showLineNumbers := GetBoolDefault(params, "-n", true)
 	outputMode := GetStringDefault(params, "output_mode", "files_with_matches")
 	headLimit := GetIntDefault(params, "head_limit", 0)
+	multiline := GetBoolDefault(params, "multiline", false)
+	sortByMtime := outputMode == "files_with_matches" && GetBoolDefault(params, "sort_by_mtime", false)
 
 	// Get context lines
 	contextLines := GetIntDefault(params, "-C", 0)
@@ -109,13 +150,17 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 	afterLines := GetIntDefault(params, "-A", contextLines)
 
 	// Compile regex
-	var re *regexp.Regexp
-	var err error
+	flags := ""
 	if caseInsensitive {
-		re, err = regexp.Compile("(?i)" + pattern)
-	} else {
-		re, err = regexp.Compile(pattern)
+		flags += "i"
+	}
+	if multiline {
+		flags += "s"
 	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return NewErrorResultString(fmt.Sprintf("Invalid regex pattern: %s", err.Error())), nil
 	}
@@ -123,16 +168,22 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 	// Get search path
 	searchPath := t.workDir
 	if path, ok := GetString(params, "path"); ok && path != "" {
-		if filepath.IsAbs(path) {
-			searchPath = path
-		} else {
-			searchPath = filepath.Join(t.workDir, path)
+		resolved, err := resolvePath(t.workDir, path, t.restrict)
+		if err != nil {
+			return NewErrorResult(err), nil
 		}
+		searchPath = resolved
 	}
 
 	// Get file filter
 	globPattern, _ := GetString(params, "glob")
 
+	// Skip files excluded by .gitignore unless asked not to
+	var ignore *GitIgnore
+	if !GetBoolDefault(params, "no_ignore", false) {
+		ignore = NewGitIgnore(ignoreRoot(t.workDir, searchPath))
+	}
+
 	// Find files to search
 	var files []string
 	info, err := os.Stat(searchPath)
@@ -146,13 +197,19 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 	if info.IsDir() {
 		// Walk directory
 		err = filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
+			// Stop walking once the call is cancelled or times out
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
 			if err != nil {
 				return nil // Skip errors
 			}
 			if info.IsDir() {
 				// Skip hidden and common non-code directories
-				base := filepath.Base(path)
-				if strings.HasPrefix(base, ".") || base == "node_modules" || base == "vendor" || base == "__pycache__" {
+				if isNoiseDir(filepath.Base(path)) {
+					return filepath.SkipDir
+				}
+				if ignore != nil && path != searchPath && ignore.Ignored(path, true) {
 					return filepath.SkipDir
 				}
 				return nil
@@ -161,6 +218,18 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 			if strings.HasPrefix(filepath.Base(path), ".") {
 				return nil
 			}
+			// A link inside the working directory may point out of it
+			if t.restrict && info.Mode()&os.ModeSymlink != 0 {
+				if _, err := resolvePath(t.workDir, path, true); err != nil {
+					return nil
+				}
+			}
+			if ignore != nil && ignore.Ignored(path, false) {
+				return nil
+			}
+			if t.policy != nil && t.policy.Check(path) != nil {
+				return nil
+			}
 			// Apply glob filter if specified
 			if globPattern != "" {
 				matched, _ := doublestar.PathMatch(globPattern, filepath.Base(path))
@@ -176,7 +245,7 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 			files = append(files, path)
 			return nil
 		})
-		if err != nil {
+		if err != nil && ctx.Err() == nil {
 			return NewErrorResult(err), nil
 		}
 	} else {
@@ -187,13 +256,26 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 	var output strings.Builder
 	matchCount := 0
 	resultCount := 0
+	skippedLarge := 0
+	var sorted []matchedFile // Files to list by mtime once all are searched
 
 	for _, file := range files {
 		if headLimit > 0 && resultCount >= headLimit {
 			break
 		}
+		if ctx.Err() != nil {
+			break
+		}
 
-		matches, err := searchFile(file, re, beforeLines, afterLines)
+		var matches []match
+		if multiline {
+			matches, err = searchFileMultiline(file, re)
+			if err == errFileTooLarge {
+				skippedLarge++
+			}
+		} else {
+			matches, err = searchFile(file, re, beforeLines, afterLines)
+		}
 		if err != nil {
 			continue // Skip files that can't be read
 		}
@@ -212,6 +294,14 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 
 		switch outputMode {
 		case "files_with_matches":
+			if sortByMtime {
+				var modTime time.Time
+				if info, err := os.Stat(file); err == nil {
+					modTime = info.ModTime()
+				}
+				sorted = append(sorted, matchedFile{path: relPath, modTime: modTime})
+				continue
+			}
 			output.WriteString(relPath)
 			output.WriteString("\n")
 			resultCount++
@@ -225,21 +315,56 @@ func (t *GrepTool) Execute(ctx context.Context, params map[string]interface{}) (
 				if headLimit > 0 && resultCount >= headLimit {
 					break
 				}
-				if showLineNumbers {
-					output.WriteString(fmt.Sprintf("%s:%d:%s\n", relPath, m.lineNum, m.line))
-				} else {
-					output.WriteString(fmt.Sprintf("%s:%s\n", relPath, m.line))
+				// A multiline match prints every line it spans
+				for i, line := range strings.Split(m.line, "\n") {
+					if showLineNumbers {
+						output.WriteString(fmt.Sprintf("%s:%d:%s\n", relPath, m.lineNum+i, line))
+					} else {
+						output.WriteString(fmt.Sprintf("%s:%s\n", relPath, line))
+					}
 				}
 				resultCount++
 			}
 		}
 	}
 
+	if sortByMtime {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].modTime.After(sorted[j].modTime)
+		})
+		if headLimit > 0 && len(sorted) > headLimit {
+			sorted = sorted[:headLimit]
+		}
+		for _, f := range sorted {
+			output.WriteString(f.path)
+			output.WriteString("\n")
+		}
+	}
+
+	var skippedNote string
+	if skippedLarge > 0 {
+		skippedNote = fmt.Sprintf("\n(%d file(s) over %dMB skipped in multiline mode)", skippedLarge, MaxMultilineFileSize/(1024*1024))
+	}
+
+	// Report what was found before a timeout or cancel
+	if ctx.Err() != nil {
+		if output.Len() == 0 {
+			return NewResult("No matches found\n" + stoppedEarlyNote(ctx) + skippedNote), nil
+		}
+		return NewResult(output.String() + "\n" + stoppedEarlyNote(ctx) + skippedNote), nil
+	}
+
 	if output.Len() == 0 {
-		return NewResult("No matches found"), nil
+		return NewResult("No matches found" + skippedNote), nil
 	}
 
-	return NewResult(strings.TrimSuffix(output.String(), "\n")), nil
+	return NewResult(strings.TrimSuffix(output.String(), "\n") + skippedNote), nil
+}
+
+// matchedFile is a file with matches, listed by modification time
+type matchedFile struct {
+	path    string
+	modTime time.Time
 }
 
 type match struct {
@@ -272,3 +397,44 @@ func searchFile(filePath string, re *regexp.Regexp, beforeLines, afterLines int)
 
 	return matches, scanner.Err()
 }
+
+// errFileTooLarge is returned by searchFileMultiline for files over
+// MaxMultilineFileSize
+var errFileTooLarge = errors.New("file too large for multiline search")
+
+// searchFileMultiline matches re against the whole file. Each match is
+// reported at the line it starts on with the full lines it spans.
+func searchFileMultiline(filePath string, re *regexp.Regexp) ([]match, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > MaxMultilineFileSize {
+		return nil, errFileTooLarge
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+
+	var matches []match
+	lineNum, counted := 1, 0
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		lineNum += strings.Count(content[counted:start], "\n")
+		counted = start
+
+		// Widen to whole lines, leaving out the newline a match may end with
+		if end > start && content[end-1] == '\n' {
+			end--
+		}
+		from := strings.LastIndexByte(content[:start], '\n') + 1
+		to := len(content)
+		if i := strings.IndexByte(content[end:], '\n'); i >= 0 {
+			to = end + i
+		}
+		matches = append(matches, match{lineNum: lineNum, line: strings.TrimSuffix(content[from:to], "\r")})
+	}
+	return matches, nil
+}
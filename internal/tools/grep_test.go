@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGrep runs a Grep call and returns its output
+func runGrep(t *testing.T, tool *GrepTool, params map[string]interface{}) *Result {
+	t.Helper()
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	return result
+}
+
+func TestGrepRestrictRefusesParentPath(t *testing.T) {
+	root := t.TempDir()
+	workDir := filepath.Join(root, "work")
+	os.MkdirAll(workDir, 0755)
+	os.WriteFile(filepath.Join(root, "secret.txt"), []byte("token=hunter2\n"), 0644)
+
+	tool := NewGrepTool(workDir)
+	tool.SetRestrictToWorkDir(true)
+	result := runGrep(t, tool, map[string]interface{}{"pattern": "token", "path": "..", "output_mode": "content"})
+
+	if !result.IsError || strings.Contains(result.Output, "hunter2") {
+		t.Errorf("Grep of .. = %+v, want it refused", result)
+	}
+}
+
+func TestGrepRestrictSkipsLinksOutOfWorkDir(t *testing.T) {
+	root := t.TempDir()
+	workDir := filepath.Join(root, "work")
+	os.MkdirAll(workDir, 0755)
+	outside := filepath.Join(root, "secret.txt")
+	os.WriteFile(outside, []byte("token=hunter2\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "inside.txt"), []byte("token=visible\n"), 0644)
+	if err := os.Symlink(outside, filepath.Join(workDir, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	tool := NewGrepTool(workDir)
+	tool.SetRestrictToWorkDir(true)
+	result := runGrep(t, tool, map[string]interface{}{"pattern": "token", "output_mode": "content"})
+
+	if strings.Contains(result.Output, "hunter2") {
+		t.Errorf("Grep printed a file outside the working directory:\n%s", result.Output)
+	}
+	if !strings.Contains(result.Output, "visible") {
+		t.Errorf("Grep output = %q, want the file inside the working directory", result.Output)
+	}
+}
@@ -0,0 +1,346 @@
+package tools
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	mdTrailingSpaceRe = regexp.MustCompile(`[ \t]+\n`)
+	mdBlankLinesRe    = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlSkipped lists elements whose content never makes it into the output
+var htmlSkipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Head:     true,
+}
+
+// htmlBlocks lists elements that start and end a paragraph
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Main: true, atom.Header: true, atom.Footer: true, atom.Nav: true,
+	atom.Aside: true, atom.Blockquote: true, atom.Table: true, atom.Form: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Dl: true, atom.Hr: true,
+	atom.Ul: true, atom.Ol: true, atom.Details: true, atom.Summary: true,
+}
+
+// htmlLines lists elements that only need to start on a new line
+var htmlLines = map[atom.Atom]bool{
+	atom.Tr: true, atom.Dt: true, atom.Dd: true, atom.Caption: true,
+}
+
+// htmlToMarkdown converts an HTML page to markdown in a single pass over its
+// tokens. Headings, links, list items, inline code and <pre> blocks keep
+// their structure; everything else becomes plain paragraphs. Relative links
+// are resolved against base when it is set. The page title is returned
+// separately.
+func htmlToMarkdown(src string, base *url.URL) (title, markdown string) {
+	c := &mdConverter{base: base}
+	z := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break // io.EOF or malformed input; keep what we have
+		}
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			c.start(tok, tt == html.SelfClosingTagToken)
+		case html.EndTagToken:
+			c.end(tok)
+		case html.TextToken:
+			c.text(tok.Data)
+		}
+	}
+	return strings.Join(strings.Fields(c.title.String()), " "), c.finish()
+}
+
+// htmlTitle returns the contents of the page's <title> element, if any
+func htmlTitle(src string) string {
+	z := html.NewTokenizer(strings.NewReader(src))
+	inTitle := false
+	var title strings.Builder
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(title.String()), " ")
+		case html.StartTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Title {
+				inTitle = true
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Title {
+				return strings.Join(strings.Fields(title.String()), " ")
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		}
+	}
+}
+
+// mdConverter holds the state of an HTML-to-markdown conversion
+type mdConverter struct {
+	base *url.URL
+	out  strings.Builder
+
+	title   strings.Builder
+	inTitle bool
+
+	skip   int      // depth inside skipped elements
+	pre    int      // depth inside <pre>
+	lists  int      // list nesting depth
+	links  []string // hrefs of open <a> elements ("" when not rendered as a link)
+	spaced bool     // a space is owed before the next word
+}
+
+func (c *mdConverter) start(tok html.Token, selfClosing bool) {
+	a := tok.DataAtom
+	if a == atom.Title {
+		c.inTitle = !selfClosing
+		return
+	}
+	if htmlSkipped[a] {
+		if !selfClosing {
+			c.skip++
+		}
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+
+	switch {
+	case a == atom.Br:
+		if c.pre > 0 {
+			c.out.WriteString("\n")
+		} else {
+			c.newline()
+		}
+	case a == atom.Pre:
+		c.paragraph()
+		c.out.WriteString("```\n")
+		c.pre++
+	case a == atom.Code:
+		if c.pre == 0 {
+			c.word("`")
+		}
+	case a == atom.Strong || a == atom.B:
+		c.word("**")
+	case a == atom.Em || a == atom.I:
+		c.word("_")
+	case a == atom.A:
+		href := c.resolve(attr(tok, "href"))
+		c.links = append(c.links, href)
+		if href != "" {
+			c.word("[")
+		}
+	case a == atom.Li:
+		c.newline()
+		c.out.WriteString(strings.Repeat("  ", max(c.lists-1, 0)) + "- ")
+	case a == atom.Ul || a == atom.Ol:
+		if c.lists == 0 {
+			c.paragraph()
+		} else {
+			c.newline()
+		}
+		c.lists++
+	case headingLevel(a) > 0:
+		c.paragraph()
+		c.out.WriteString(strings.Repeat("#", headingLevel(a)) + " ")
+	case a == atom.Td || a == atom.Th:
+		c.spaced = true
+	case htmlBlocks[a]:
+		c.paragraph()
+	case htmlLines[a]:
+		c.newline()
+	}
+}
+
+func (c *mdConverter) end(tok html.Token) {
+	a := tok.DataAtom
+	if a == atom.Title {
+		c.inTitle = false
+		return
+	}
+	if htmlSkipped[a] {
+		if c.skip > 0 {
+			c.skip--
+		}
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+
+	switch {
+	case a == atom.Pre:
+		if c.pre > 0 {
+			c.pre--
+			if !strings.HasSuffix(c.out.String(), "\n") {
+				c.out.WriteString("\n")
+			}
+			c.out.WriteString("```")
+			c.paragraph()
+		}
+	case a == atom.Code:
+		if c.pre == 0 {
+			c.close("`")
+		}
+	case a == atom.Strong || a == atom.B:
+		c.close("**")
+	case a == atom.Em || a == atom.I:
+		c.close("_")
+	case a == atom.A:
+		if n := len(c.links); n > 0 {
+			if href := c.links[n-1]; href != "" {
+				c.close("](" + href + ")")
+			}
+			c.links = c.links[:n-1]
+		}
+	case a == atom.Ul || a == atom.Ol:
+		if c.lists > 0 {
+			c.lists--
+		}
+		if c.lists == 0 {
+			c.paragraph()
+		} else {
+			c.newline()
+		}
+	case headingLevel(a) > 0, htmlBlocks[a]:
+		c.paragraph()
+	case htmlLines[a], a == atom.Li:
+		c.newline()
+	}
+}
+
+func (c *mdConverter) text(s string) {
+	if c.inTitle {
+		c.title.WriteString(s)
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+	if c.pre > 0 {
+		c.out.WriteString(s)
+		return
+	}
+
+	if s != "" && isSpace(s[0]) {
+		c.spaced = true
+	}
+	fields := strings.Fields(s)
+	for i, f := range fields {
+		if i > 0 {
+			c.spaced = true
+		}
+		c.word(f)
+	}
+	if len(fields) > 0 && isSpace(s[len(s)-1]) {
+		c.spaced = true
+	}
+}
+
+// word writes s, preceded by a single space if one is owed and the output
+// is not at the start of a line
+func (c *mdConverter) word(s string) {
+	if c.spaced && c.out.Len() > 0 && !c.atLineStart() && !strings.HasSuffix(c.out.String(), "[") {
+		c.out.WriteString(" ")
+	}
+	c.spaced = false
+	c.out.WriteString(s)
+}
+
+// close writes a closing marker directly after the preceding text
+func (c *mdConverter) close(s string) {
+	c.out.WriteString(s)
+}
+
+// newline ends the current line unless the output is already at a line start
+func (c *mdConverter) newline() {
+	c.spaced = false
+	if c.out.Len() > 0 && !c.atLineStart() {
+		c.out.WriteString("\n")
+	}
+}
+
+// paragraph ends the current paragraph with a blank line
+func (c *mdConverter) paragraph() {
+	c.newline()
+	if c.out.Len() > 0 && !strings.HasSuffix(c.out.String(), "\n\n") {
+		c.out.WriteString("\n")
+	}
+}
+
+func (c *mdConverter) atLineStart() bool {
+	s := c.out.String()
+	return s == "" || s[len(s)-1] == '\n' || strings.HasSuffix(s, "- ") || strings.HasSuffix(s, "# ")
+}
+
+// resolve makes href absolute and drops links that are useless out of the
+// page (fragments and javascript:)
+func (c *mdConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+	if c.base == nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return c.base.ResolveReference(ref).String()
+}
+
+func (c *mdConverter) finish() string {
+	out := mdTrailingSpaceRe.ReplaceAllString(c.out.String(), "\n")
+	out = mdBlankLinesRe.ReplaceAllString(out, "\n\n")
+	return strings.TrimSpace(out)
+}
+
+// headingLevel returns 1-6 for <h1>-<h6> and 0 otherwise
+func headingLevel(a atom.Atom) int {
+	switch a {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
+
+// attr returns the value of the named attribute, or "" if it is not set
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxImageFileSize is the largest image file Read returns as an image
+const MaxImageFileSize = 3 * 1024 * 1024 // 3MB (about 4MB once base64-encoded)
+
+// supportedImageTypes are the image media types the model accepts
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imageExtensions maps image file extensions to media types
+var imageExtensions = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// toImage validates image bytes and encodes them for a tool result
+func toImage(data []byte, contentType string, maxSize int) (*Image, error) {
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxSize)
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if !supportedImageTypes[mediaType] {
+		// Servers often send generic types; trust the bytes instead
+		mediaType = http.DetectContentType(data)
+	}
+	if !supportedImageTypes[mediaType] {
+		return nil, fmt.Errorf("unsupported image type %s", mediaType)
+	}
+
+	return &Image{
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// LoadImageFile reads an image file to attach to a user message, checking
+// its size and type
+func LoadImageFile(path string) (*Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("image not found: %s", path)
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > MaxImageFileSize {
+		return nil, fmt.Errorf("image %s is too large (%d bytes, max %d)", path, info.Size(), MaxImageFileSize)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := toImage(data, imageExtensions[strings.ToLower(filepath.Ext(path))], MaxImageFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot attach %s: %w", path, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	DefaultListDepth = 3
+	MaxListDepth     = 10
+	MaxListEntries   = 1000
+)
+
+// ListTool lists a directory as a tree
+type ListTool struct {
+	workDir  string
+	restrict bool // Refuse paths outside workDir
+}
+
+// NewListTool creates a new List tool
+func NewListTool(workDir string) *ListTool {
+	return &ListTool{workDir: workDir}
+}
+
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *ListTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+func (t *ListTool) Name() string {
+	return "List"
+}
+
+func (t *ListTool) Description() string {
+	return `Lists files and directories as a tree, directories first.
+
+Usage:
+- The path parameter defaults to the current working directory
+- Hidden directories, node_modules, vendor, __pycache__ and files excluded by .gitignore are skipped
+- Use ignore to skip more entries with glob patterns (e.g. "*.log", "testdata/**")
+- Prefer Glob or Grep when you know what you are looking for`
+}
+
+func (t *ListTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "The directory to list. Defaults to current working directory.",
+			},
+			"ignore": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Glob patterns of entries to skip, matched against names and paths relative to the listed directory",
+			},
+			"depth": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("How many directory levels to descend (default: %d, max: %d)", DefaultListDepth, MaxListDepth),
+			},
+		},
+	}
+}
+
+func (t *ListTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	// Resolve path
+	dir := t.workDir
+	if path, ok := GetString(params, "path"); ok && path != "" {
+		resolved, err := resolvePath(t.workDir, path, t.restrict)
+		if err != nil {
+			return NewErrorResult(err), nil
+		}
+		dir = resolved
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewErrorResultString(fmt.Sprintf("Path not found: %s", dir)), nil
+		}
+		return NewErrorResult(err), nil
+	}
+	if !info.IsDir() {
+		return NewErrorResultString(fmt.Sprintf("%s is a file, not a directory. Use Read to view it.", dir)), nil
+	}
+
+	depth := GetIntDefault(params, "depth", DefaultListDepth)
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > MaxListDepth {
+		depth = MaxListDepth
+	}
+
+	ignore, _ := GetStringArray(params, "ignore")
+	l := &lister{
+		root:      dir,
+		maxDepth:  depth,
+		ignore:    ignore,
+		gitignore: NewGitIgnore(ignoreRoot(t.workDir, dir)),
+	}
+
+	var b strings.Builder
+	b.WriteString(dir + "/\n")
+	if err := l.list(ctx, &b, dir, 1); err != nil {
+		return NewErrorResult(err), nil
+	}
+	if l.entries == 0 {
+		b.WriteString("  (empty)\n")
+	}
+	if l.truncated {
+		fmt.Fprintf(&b, "\n(Listing truncated at %d entries. List a subdirectory or use Glob to narrow it down.)\n", MaxListEntries)
+	}
+
+	return NewResult(b.String()), nil
+}
+
+// lister writes one directory tree
+type lister struct {
+	root      string
+	maxDepth  int
+	ignore    []string
+	gitignore *GitIgnore
+	entries   int
+	truncated bool
+}
+
+// list writes the entries of dir, indented for the given depth
+func (l *lister) list(ctx context.Context, b *strings.Builder, dir string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(b, "%s(cannot read: %s)\n", strings.Repeat("  ", depth), err.Error())
+		return nil
+	}
+
+	// Directories first, then files, each sorted by name
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	indent := strings.Repeat("  ", depth)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if l.skip(path, entry) {
+			continue
+		}
+		if l.entries >= MaxListEntries {
+			l.truncated = true
+			return nil
+		}
+		l.entries++
+
+		if !entry.IsDir() {
+			b.WriteString(indent + entry.Name() + "\n")
+			continue
+		}
+		b.WriteString(indent + entry.Name() + "/\n")
+		if depth < l.maxDepth {
+			if err := l.list(ctx, b, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skip reports whether an entry is noise, ignored by .gitignore or matches an ignore glob
+func (l *lister) skip(path string, entry os.DirEntry) bool {
+	if entry.IsDir() && isNoiseDir(entry.Name()) {
+		return true
+	}
+	if l.gitignore.Ignored(path, entry.IsDir()) {
+		return true
+	}
+
+	rel, _ := filepath.Rel(l.root, path)
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range l.ignore {
+		if ok, _ := doublestar.Match(pattern, entry.Name()); ok {
+			return true
+		}
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
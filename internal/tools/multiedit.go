@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MultiEditTool applies several string replacements to one file atomically
+type MultiEditTool struct {
+	workDir  string
+	restrict bool         // Refuse paths outside workDir
+	history  *FileHistory // Records changes for /undo (nil = not recorded)
+}
+
+// fileEdit is a single replacement within a MultiEdit call
+type fileEdit struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all"`
+}
+
+// NewMultiEditTool creates a new MultiEdit tool
+func NewMultiEditTool(workDir string) *MultiEditTool {
+	return &MultiEditTool{workDir: workDir}
+}
+
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *MultiEditTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+// SetFileHistory makes the tool record the previous state of files it changes
+func (t *MultiEditTool) SetFileHistory(h *FileHistory) {
+	t.history = h
+}
+
+func (t *MultiEditTool) Name() string {
+	return "MultiEdit"
+}
+
+func (t *MultiEditTool) Description() string {
+	return `Makes multiple exact string replacements in a single file in one operation.
+
+Usage:
+- Edits are applied in order, each one to the result of the previous edit
+- Each edit follows the same rules as the Edit tool: old_string must be unique unless replace_all is set
+- The edits are atomic: if any edit fails, none are applied and the file is left untouched
+- Prefer this over several Edit calls when changing multiple places in the same file`
+}
+
+func (t *MultiEditTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "The absolute path to the file to modify",
+			},
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "Edits to apply in order",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"old_string": map[string]interface{}{
+							"type":        "string",
+							"description": "The text to replace",
+						},
+						"new_string": map[string]interface{}{
+							"type":        "string",
+							"description": "The text to replace it with (must be different from old_string)",
+						},
+						"replace_all": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Replace all occurrences of old_string (default false)",
+							"default":     false,
+						},
+					},
+					"required": []string{"old_string", "new_string"},
+				},
+			},
+		},
+		"required": []string{"file_path", "edits"},
+	}
+}
+
+func (t *MultiEditTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	filePath, _, newContent, edits, total, err := t.apply(params)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	// Write file
+	t.history.Record(t.Name(), filePath)
+	if err := os.WriteFile(filePath, []byte(newContent), 0644); err != nil {
+		return NewErrorResult(fmt.Errorf("failed to write file: %w", err)), nil
+	}
+
+	return NewResult(fmt.Sprintf("Successfully applied %d edit(s) (%d replacement(s)) to %s", edits, total, filePath)), nil
+}
+
+// PreviewChange returns the change the call would make without writing it
+func (t *MultiEditTool) PreviewChange(params map[string]interface{}) (*FileChange, error) {
+	filePath, content, newContent, _, _, err := t.apply(params)
+	if err != nil {
+		return nil, err
+	}
+	return &FileChange{
+		Path: filePath,
+		Diff: UnifiedDiff(diffName(t.workDir, filePath), content, newContent),
+	}, nil
+}
+
+// apply computes the edits in memory, returning the resolved path, the
+// current and new content, the number of edits and of replacements
+func (t *MultiEditTool) apply(params map[string]interface{}) (filePath, content, newContent string, edited, total int, err error) {
+	filePath, ok := GetString(params, "file_path")
+	if !ok || filePath == "" {
+		return "", "", "", 0, 0, errors.New("file_path parameter is required")
+	}
+
+	editsRaw, ok := params["edits"]
+	if !ok {
+		return "", "", "", 0, 0, errors.New("edits parameter is required")
+	}
+
+	// Convert to JSON and back to parse the edits
+	editsJSON, err := json.Marshal(editsRaw)
+	if err != nil {
+		return "", "", "", 0, 0, err
+	}
+	var edits []fileEdit
+	if err := json.Unmarshal(editsJSON, &edits); err != nil {
+		return "", "", "", 0, 0, fmt.Errorf("Invalid edits format: %s", err.Error())
+	}
+	if len(edits) == 0 {
+		return "", "", "", 0, 0, errors.New("At least one edit is required")
+	}
+
+	// Resolve path
+	filePath, err = resolvePath(t.workDir, filePath, t.restrict)
+	if err != nil {
+		return "", "", "", 0, 0, err
+	}
+
+	// Read file
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", "", 0, 0, fmt.Errorf("File not found: %s", filePath)
+		}
+		return "", "", "", 0, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	content = string(data)
+
+	// Apply every edit in memory first; the file is only written if all succeed
+	newContent = content
+	for i, edit := range edits {
+		if edit.OldString == edit.NewString {
+			return "", "", "", 0, 0, fmt.Errorf("Edit %d: old_string and new_string must be different. No changes were made.", i+1)
+		}
+
+		var count int
+		newContent, count, err = applyEdit(newContent, edit.OldString, edit.NewString, edit.ReplaceAll)
+		if err != nil {
+			return "", "", "", 0, 0, fmt.Errorf("Edit %d: %s. No changes were made.", i+1, strings.TrimSuffix(err.Error(), "."))
+		}
+		total += count
+	}
+	return filePath, content, newContent, len(edits), total, nil
+}
@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// blockedPrefixes are ranges outside the checks in netip.Addr that still
+// reach internal services
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+}
+
+// BlockedAddressError reports a fetch refused because the host resolves to
+// an internal address
+type BlockedAddressError struct {
+	Host string
+	Addr netip.Addr
+}
+
+func (e *BlockedAddressError) Error() string {
+	if e.Host == e.Addr.String() {
+		return fmt.Sprintf("blocked internal address %s; add it to web_fetch_allowed_hosts to allow fetching it", e.Host)
+	}
+	return fmt.Sprintf("blocked internal address: %s resolves to %s; add the host to web_fetch_allowed_hosts to allow fetching it", e.Host, e.Addr)
+}
+
+// isInternalAddr reports whether addr is loopback, private, link-local
+// (including the 169.254.169.254 metadata endpoint), unspecified, multicast
+// or otherwise not publicly routable
+func isInternalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostGuard rejects hosts that resolve to internal addresses, except those
+// on its allowlist
+type hostGuard struct {
+	hosts    map[string]bool // lower-cased host names and literal IPs
+	prefixes []netip.Prefix
+	lookup   func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// newHostGuard builds a guard. Allowlist entries are host names
+// ("localhost"), IP addresses or CIDR ranges ("10.0.0.0/8").
+func newHostGuard(allow []string) *hostGuard {
+	g := &hostGuard{hosts: make(map[string]bool), lookup: lookupHost}
+	for _, entry := range allow {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(entry); err == nil {
+			g.prefixes = append(g.prefixes, p.Masked())
+			continue
+		}
+		g.hosts[strings.Trim(entry, "[]")] = true
+	}
+	return g
+}
+
+// lookupHost resolves host with the default resolver
+func lookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// allowed reports whether the allowlist covers addr
+func (g *hostGuard) allowed(addr netip.Addr) bool {
+	if g.hosts[addr.String()] || g.hosts[addr.Unmap().String()] {
+		return true
+	}
+	for _, p := range g.prefixes {
+		if p.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// check resolves host and returns a *BlockedAddressError if any of its
+// addresses is internal and not allowlisted
+func (g *hostGuard) check(ctx context.Context, host string) error {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if g.hosts[host] {
+		return nil
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else {
+		ips, err := g.lookup(ctx, host)
+		if err != nil {
+			return fmt.Errorf("cannot resolve %s: %w", host, err)
+		}
+		addrs = ips
+	}
+
+	for _, addr := range addrs {
+		if err := g.checkAddr(host, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAddr returns a *BlockedAddressError if addr, which host resolved to,
+// is internal and not allowlisted
+func (g *hostGuard) checkAddr(host string, addr netip.Addr) error {
+	if isInternalAddr(addr) && !g.allowed(addr) {
+		return &BlockedAddressError{Host: host, Addr: addr.Unmap()}
+	}
+	return nil
+}
+
+// dialContext connects to address for an HTTP transport. check runs before
+// the request, but the host may resolve differently by the time it is
+// dialed (DNS rebinding), so the dialer checks the address it actually
+// connects to as well. Proxies are dialed the same way: an internal proxy
+// must be allowlisted.
+func (g *hostGuard) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !g.hosts[host] {
+		dialer.Control = func(_, dialed string, _ syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(dialed)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return err
+			}
+			return g.checkAddr(host, addr)
+		}
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else if addrs, err = g.lookup(ctx, host); err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("cannot resolve %s: no addresses", host)
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
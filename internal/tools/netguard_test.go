@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+)
+
+// rebindingLookup answers with a public address the first time and a
+// loopback address after that, like a DNS rebinding attack
+func rebindingLookup() func(ctx context.Context, host string) ([]netip.Addr, error) {
+	var calls atomic.Int32
+	return func(ctx context.Context, host string) ([]netip.Addr, error) {
+		if calls.Add(1) == 1 {
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+	}
+}
+
+func TestWebFetchBlocksDNSRebinding(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("metadata secrets"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tool := NewWebFetchTool()
+	tool.guard.lookup = rebindingLookup()
+
+	ctx := context.Background()
+	if err := tool.guard.check(ctx, "rebind.example"); err != nil {
+		t.Fatalf("check of the public answer failed: %v", err)
+	}
+
+	resp, err := tool.httpClient.Get("http://rebind.example:" + port + "/")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a host rebound to loopback succeeded")
+	}
+	var blocked *BlockedAddressError
+	if !errors.As(err, &blocked) || blocked.Addr.String() != "127.0.0.1" {
+		t.Errorf("error = %v, want a *BlockedAddressError for 127.0.0.1", err)
+	}
+	if hits.Load() != 0 {
+		t.Error("the internal server was reached")
+	}
+}
+
+func TestWebFetchDialAllowsAllowlistedHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tool := NewWebFetchTool()
+	tool.SetAllowedHosts([]string{"127.0.0.1"})
+
+	resp, err := tool.httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request to an allowlisted address failed: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,537 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxFailedHunkLines is how many lines of a failed hunk are quoted in the error
+const maxFailedHunkLines = 3
+
+// ApplyPatchTool applies a unified diff to files, possibly several at once
+type ApplyPatchTool struct {
+	workDir  string
+	restrict bool         // Refuse paths outside workDir
+	history  *FileHistory // Records changes for /undo (nil = not recorded)
+}
+
+// NewApplyPatchTool creates a new ApplyPatch tool
+func NewApplyPatchTool(workDir string) *ApplyPatchTool {
+	return &ApplyPatchTool{workDir: workDir}
+}
+
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *ApplyPatchTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+// SetFileHistory makes the tool record the previous state of files it changes
+func (t *ApplyPatchTool) SetFileHistory(h *FileHistory) {
+	t.history = h
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "ApplyPatch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return `Applies a unified diff (as produced by diff -u or git diff) to one or more files.
+
+Usage:
+- Each file starts with "--- old/path" and "+++ new/path" lines; git's a/ and b/ prefixes are removed
+- Use /dev/null as the old path to create a file and as the new path to delete one
+- Hunks start with "@@ -l,n +l,n @@"; their lines start with " " (context), "-" (removed) or "+" (added)
+- Context is looked for near the line numbers given, so they do not need to be exact; lines that differ only in whitespace still match
+- The patch is atomic: if any hunk fails, no file is changed and the failed hunks are listed
+- Prefer Edit or MultiEdit for small changes; use this for changes across several files or a diff you already have`
+}
+
+func (t *ApplyPatchTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"patch": map[string]interface{}{
+				"type":        "string",
+				"description": "The unified diff to apply. Relative paths are resolved against the working directory.",
+			},
+		},
+		"required": []string{"patch"},
+	}
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	changes, err := t.apply(params)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	var summary []string
+	for _, c := range changes {
+		if err := t.write(c); err != nil {
+			return NewErrorResult(fmt.Errorf("failed to write %s: %w (files listed before it were changed)", c.path, err)), nil
+		}
+		summary = append(summary, c.summary(t.workDir))
+	}
+	return NewResult(fmt.Sprintf("Applied patch to %d file(s):\n%s", len(changes), strings.Join(summary, "\n"))), nil
+}
+
+// PreviewChange returns the change the call would make without writing it
+func (t *ApplyPatchTool) PreviewChange(params map[string]interface{}) (*FileChange, error) {
+	changes, err := t.apply(params)
+	if err != nil {
+		return nil, err
+	}
+	var paths, diffs []string
+	for _, c := range changes {
+		paths = append(paths, c.path)
+		if diff := UnifiedDiff(diffName(t.workDir, c.path), c.oldContent, c.newContent); diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	return &FileChange{
+		Path: strings.Join(paths, ", "),
+		Diff: strings.Join(diffs, "\n"),
+	}, nil
+}
+
+// patchChange is the outcome of a patch for one file, computed in memory
+type patchChange struct {
+	path       string // Resolved path written (or deleted)
+	from       string // Resolved path renamed from ("" = not renamed)
+	create     bool
+	delete     bool
+	oldContent string
+	newContent string
+	hunks      int // Hunks applied
+	fuzzy      int // Hunks matched ignoring whitespace
+}
+
+// summary describes the change in one line
+func (c *patchChange) summary(workDir string) string {
+	name := diffName(workDir, c.path)
+	switch {
+	case c.create:
+		return fmt.Sprintf("  A %s: created (%d lines)", name, len(splitLines(c.newContent)))
+	case c.delete:
+		return fmt.Sprintf("  D %s: deleted", name)
+	}
+	line := fmt.Sprintf("  M %s: %d hunk(s) applied", name, c.hunks)
+	if c.from != "" {
+		line = fmt.Sprintf("  R %s -> %s: %d hunk(s) applied", diffName(workDir, c.from), name, c.hunks)
+	}
+	if c.fuzzy > 0 {
+		line += fmt.Sprintf(", %d ignoring whitespace differences", c.fuzzy)
+	}
+	return line
+}
+
+// write makes the change on disk
+func (t *ApplyPatchTool) write(c *patchChange) error {
+	if c.delete {
+		t.history.Record(t.Name(), c.path)
+		return os.Remove(c.path)
+	}
+
+	t.history.Record(t.Name(), c.path)
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, []byte(c.newContent), 0644); err != nil {
+		return err
+	}
+	if c.from != "" {
+		t.history.Record(t.Name(), c.from)
+		return os.Remove(c.from)
+	}
+	return nil
+}
+
+// apply parses the patch and applies it in memory. It fails if any file or
+// hunk cannot be applied, listing every failure.
+func (t *ApplyPatchTool) apply(params map[string]interface{}) ([]*patchChange, error) {
+	patch, ok := GetString(params, "patch")
+	if !ok || strings.TrimSpace(patch) == "" {
+		return nil, errors.New("patch parameter is required")
+	}
+
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*patchChange
+	var failures []string
+	for _, f := range files {
+		c, failed, err := t.applyFile(f)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", f.path(), err.Error()))
+			continue
+		}
+		failures = append(failures, failed...)
+		changes = append(changes, c)
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("The patch could not be applied; no files were changed.\n%s", strings.Join(failures, "\n"))
+	}
+	return changes, nil
+}
+
+// applyFile applies the hunks of one file, returning the change and a
+// description of each hunk that failed
+func (t *ApplyPatchTool) applyFile(f *patchFile) (*patchChange, []string, error) {
+	c := &patchChange{create: f.oldPath == "", delete: f.newPath == ""}
+
+	source := f.oldPath
+	if c.create {
+		source = f.newPath
+	}
+	path, err := resolvePath(t.workDir, source, t.restrict)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.path = path
+
+	data, err := os.ReadFile(path)
+	switch {
+	case c.create && err == nil:
+		return nil, nil, errors.New("file already exists")
+	case c.create:
+	case os.IsNotExist(err):
+		return nil, nil, errors.New("file not found")
+	case err != nil:
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	c.oldContent = string(data)
+
+	if c.delete {
+		return c, nil, nil
+	}
+
+	// A different new path renames the file
+	if !c.create && f.newPath != f.oldPath {
+		target, err := resolvePath(t.workDir, f.newPath, t.restrict)
+		if err != nil {
+			return nil, nil, err
+		}
+		if target != path {
+			if _, err := os.Stat(target); err == nil {
+				return nil, nil, fmt.Errorf("cannot rename to %s: file already exists", f.newPath)
+			}
+			c.from, c.path = path, target
+		}
+	}
+
+	lines := splitLines(c.oldContent)
+	endNewline := c.create || c.oldContent == "" || strings.HasSuffix(c.oldContent, "\n")
+
+	var failed []string
+	delta := 0 // How far the hunks applied so far moved the following lines
+	next := 0  // Hunks must apply in order, after the previous one
+	for i, h := range f.hunks {
+		want := max(h.oldStart-1, 0) + delta
+		at, fuzzy, ok := findHunk(lines, h, want, next)
+		if !ok {
+			failed = append(failed, h.failure(f.path(), i+1))
+			continue
+		}
+
+		replacement := h.replacement(lines[at : at+h.oldLen()])
+		lines = append(lines[:at], append(replacement, lines[at+h.oldLen():]...)...)
+		delta = at - max(h.oldStart-1, 0) + len(replacement) - h.oldLen()
+		next = at + len(replacement)
+		c.hunks++
+		if fuzzy {
+			c.fuzzy++
+		}
+
+		if h.newNoNewline {
+			endNewline = false
+		} else if h.oldNoNewline {
+			endNewline = true
+		}
+	}
+
+	c.newContent = strings.Join(lines, "\n")
+	if endNewline && len(lines) > 0 {
+		c.newContent += "\n"
+	}
+	return c, failed, nil
+}
+
+// patchFile is the part of a patch for one file
+type patchFile struct {
+	oldPath string // "" for /dev/null: the file is created
+	newPath string // "" for /dev/null: the file is deleted
+	hunks   []*patchHunk
+}
+
+// path names the file in messages
+func (f *patchFile) path() string {
+	if f.newPath != "" {
+		return f.newPath
+	}
+	return f.oldPath
+}
+
+// patchLine is one line of a hunk
+type patchLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// patchHunk is one @@ section of a patch
+type patchHunk struct {
+	header       string
+	oldStart     int // 1-indexed line the hunk starts at in the original
+	lines        []patchLine
+	oldNoNewline bool // The original has no newline at the end
+	newNoNewline bool // The result has no newline at the end
+}
+
+// old returns the lines the hunk expects to find
+func (h *patchHunk) old() []string {
+	var old []string
+	for _, l := range h.lines {
+		if l.kind != '+' {
+			old = append(old, l.text)
+		}
+	}
+	return old
+}
+
+func (h *patchHunk) oldLen() int {
+	return len(h.old())
+}
+
+// replacement returns the lines that replace matched, the file's lines the
+// hunk matched. Context lines keep the file's version, which may differ in
+// whitespace from the patch.
+func (h *patchHunk) replacement(matched []string) []string {
+	var out []string
+	i := 0
+	for _, l := range h.lines {
+		switch l.kind {
+		case ' ':
+			out = append(out, matched[i])
+			i++
+		case '-':
+			i++
+		case '+':
+			out = append(out, l.text)
+		}
+	}
+	return out
+}
+
+// failure describes a hunk whose lines were not found
+func (h *patchHunk) failure(path string, n int) string {
+	msg := fmt.Sprintf("%s: hunk %d (%s) failed: its context and removed lines were not found", path, n, h.header)
+	old := h.old()
+	if len(old) == 0 {
+		return msg
+	}
+	msg += "; expected:"
+	for i, line := range old {
+		if i == maxFailedHunkLines {
+			msg += fmt.Sprintf("\n    ... (%d more lines)", len(old)-i)
+			break
+		}
+		msg += "\n    " + line
+	}
+	return msg
+}
+
+// findHunk finds where the hunk's old lines are in lines, at or after from,
+// starting at want and moving outwards. Exact matches are preferred over
+// ones ignoring whitespace.
+func findHunk(lines []string, h *patchHunk, want, from int) (at int, fuzzy, ok bool) {
+	old := h.old()
+	last := len(lines) - len(old)
+	if last < from {
+		return 0, false, false
+	}
+	if len(old) == 0 {
+		// Pure addition without context: insert at the given line
+		return min(max(want, from), len(lines)), false, true
+	}
+
+	for _, loose := range []bool{false, true} {
+		for d := 0; want-d >= from || want+d <= last; d++ {
+			for _, pos := range []int{want - d, want + d} {
+				if pos >= from && pos <= last && linesMatch(lines[pos:pos+len(old)], old, loose) {
+					return pos, loose, true
+				}
+			}
+		}
+	}
+	return 0, false, false
+}
+
+// linesMatch compares lines, ignoring leading and trailing whitespace if loose
+func linesMatch(a, b []string, loose bool) bool {
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		if !loose || strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkHeaderRe matches "@@ -l,n +l,n @@" with optional counts
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// parsePatch splits a unified diff into files and hunks. Text outside the
+// file headers and hunks, such as "diff --git" and "index" lines, is ignored.
+// The line counts in hunk headers are not trusted: a hunk ends at the next
+// hunk or file header.
+func parsePatch(patch string) ([]*patchFile, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+
+	var files []*patchFile
+	var file *patchFile
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isFileHeader(lines, i):
+			file = &patchFile{
+				oldPath: patchPath(strings.TrimPrefix(line, "--- ")),
+				newPath: patchPath(strings.TrimPrefix(lines[i+1], "+++ ")),
+			}
+			if file.oldPath == "" && file.newPath == "" {
+				return nil, fmt.Errorf("line %d: both paths of a file header are /dev/null", i+1)
+			}
+			stripGitPrefixes(file)
+			files = append(files, file)
+			i += 2
+
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk %q comes before any ---/+++ file header", i+1, line)
+			}
+			m := hunkHeaderRe.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q; expected \"@@ -l,n +l,n @@\"", i+1, line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			h := &patchHunk{header: m[0], oldStart: start}
+			i++
+
+			for ; i < len(lines) && !strings.HasPrefix(lines[i], "@@") && !isFileHeader(lines, i) && !strings.HasPrefix(lines[i], "diff "); i++ {
+				l := lines[i]
+				switch {
+				case l == "":
+					// Editors and models often drop the space of empty context lines
+					h.lines = append(h.lines, patchLine{kind: ' '})
+				case l[0] == ' ' || l[0] == '-' || l[0] == '+':
+					h.lines = append(h.lines, patchLine{kind: l[0], text: l[1:]})
+				case strings.HasPrefix(l, `\`):
+					// "\ No newline at end of file" applies to the line before it
+					if n := len(h.lines); n > 0 {
+						if h.lines[n-1].kind != '+' {
+							h.oldNoNewline = true
+						}
+						if h.lines[n-1].kind != '-' {
+							h.newNoNewline = true
+						}
+					}
+				default:
+					return nil, fmt.Errorf("line %d: %q in hunk %s does not start with \" \", \"-\" or \"+\"", i+1, l, h.header)
+				}
+			}
+
+			// Blank lines at the end separate the hunk from what follows
+			for j := i - 1; lines[j] == "" && len(h.lines) > 0 && h.lines[len(h.lines)-1] == (patchLine{kind: ' '}); j-- {
+				h.lines = h.lines[:len(h.lines)-1]
+			}
+			if len(h.lines) == 0 {
+				return nil, fmt.Errorf("hunk %s of %s is empty", h.header, file.path())
+			}
+			file.hunks = append(file.hunks, h)
+
+		default:
+			i++
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("no file headers found; a unified diff starts each file with \"--- path\" and \"+++ path\" lines")
+	}
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if len(f.hunks) == 0 && f.newPath != "" {
+			return nil, fmt.Errorf("%s has no hunks", f.path())
+		}
+		if seen[f.path()] {
+			return nil, fmt.Errorf("%s appears more than once; put all its hunks under one file header", f.path())
+		}
+		seen[f.path()] = true
+	}
+	return files, nil
+}
+
+// isFileHeader reports whether a "--- " line followed by a "+++ " line
+// starts at lines[i]
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// patchPath extracts the path of a file header, dropping a timestamp after
+// a tab; /dev/null becomes ""
+func patchPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// stripGitPrefixes removes git's a/ and b/ path prefixes when the header
+// uses them on every path it has
+func stripGitPrefixes(f *patchFile) {
+	if (f.oldPath == "" || strings.HasPrefix(f.oldPath, "a/")) && (f.newPath == "" || strings.HasPrefix(f.newPath, "b/")) {
+		f.oldPath = strings.TrimPrefix(f.oldPath, "a/")
+		f.newPath = strings.TrimPrefix(f.newPath, "b/")
+	}
+}
+
+// PatchPaths returns the paths a unified diff touches, as written in it
+// (git's a/ and b/ prefixes removed), or nil if it cannot be parsed
+func PatchPaths(patch string) (paths []string) {
+	// Called on untrusted input before any recovering wrapper runs
+	defer func() {
+		if recover() != nil {
+			paths = nil
+		}
+	}()
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil
+	}
+	for _, f := range files {
+		for _, p := range []string{f.oldPath, f.newPath} {
+			if p != "" && (len(paths) == 0 || paths[len(paths)-1] != p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// patchParamPaths returns the paths touched by the patch parameter of an
+// ApplyPatch call
+func patchParamPaths(params map[string]interface{}) []string {
+	patch, _ := params["patch"].(string)
+	return PatchPaths(patch)
+}
@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// PathIgnoreFile lists extra path policy patterns in the project directory,
+// one glob per line; "!pattern" allows a path the other patterns deny
+const PathIgnoreFile = ".gmain-agentignore"
+
+// DefaultDeniedPaths are the paths file tools refuse unless allowed
+var DefaultDeniedPaths = []string{".env*", "*.pem", "*_rsa", ".git/*"}
+
+// pathTools maps the file tools to the parameter holding their path. Grep
+// also checks each file it searches; see pathPolicyUser. Glob, LS and Tree
+// are not covered: they list names, not contents.
+var pathTools = map[string]string{
+	"Read":      "file_path",
+	"Write":     "file_path",
+	"Edit":      "file_path",
+	"MultiEdit": "file_path",
+	"Grep":      "path",
+}
+
+// multiPathTools maps the tools that touch several files to the paths a
+// call uses
+var multiPathTools = map[string]func(params map[string]interface{}) []string{
+	"ApplyPatch": patchParamPaths,
+}
+
+// pathPolicyUser is implemented by tools that open files other than the
+// ones named in their parameters and must apply the policy to each
+type pathPolicyUser interface {
+	SetPathPolicy(policy *PathPolicy)
+}
+
+// PathDeniedError reports a file tool call refused by the path policy
+type PathDeniedError struct {
+	Path    string
+	Pattern string
+}
+
+func (e *PathDeniedError) Error() string {
+	return fmt.Sprintf("Access to %s is blocked by the path policy (matches %q). It may hold secrets; ask the user to allow it with --allow-path or path_policy.allow if access is really needed.", e.Path, e.Pattern)
+}
+
+// PathPolicy blocks file tools from paths matching deny globs. Globs without
+// a slash match any path component ("*.pem"); globs with one match any run
+// of components (".git/*"), or from the project directory or the filesystem
+// root when they start with "/". A path inside a denied directory is denied
+// too.
+type PathPolicy struct {
+	workDir string
+	deny    []string
+	allow   []string
+}
+
+// NewPathPolicy creates a policy for paths relative to workDir
+func NewPathPolicy(workDir string, deny, allow []string) *PathPolicy {
+	return &PathPolicy{workDir: workDir, deny: deny, allow: allow}
+}
+
+// LoadPathIgnoreFile reads the deny and allow patterns of the project's
+// .gmain-agentignore; a missing file yields none
+func LoadPathIgnoreFile(workDir string) (deny, allow []string, err error) {
+	f, err := os.Open(filepath.Join(workDir, PathIgnoreFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if pattern, ok := strings.CutPrefix(line, "!"); ok {
+			allow = append(allow, pattern)
+		} else {
+			deny = append(deny, line)
+		}
+	}
+	return deny, allow, scanner.Err()
+}
+
+// Check returns a *PathDeniedError if path, or the file a symlink at path
+// points to, is denied and not allowed
+func (p *PathPolicy) Check(path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.workDir, path)
+	}
+	path = filepath.Clean(path)
+
+	candidates := []string{path}
+	if real := resolveLinks(path); real != path {
+		candidates = append(candidates, real)
+	}
+	for _, c := range candidates {
+		if p.matchAny(c, p.allow) != "" {
+			continue
+		}
+		if pattern := p.matchAny(c, p.deny); pattern != "" {
+			return &PathDeniedError{Path: path, Pattern: pattern}
+		}
+	}
+	return nil
+}
+
+// CheckToolCall applies the policy to the path arguments of a file tool
+// call; other tools always pass
+func (p *PathPolicy) CheckToolCall(toolName string, params map[string]interface{}) error {
+	if paths, ok := multiPathTools[toolName]; ok {
+		for _, path := range paths(params) {
+			if err := p.Check(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	key, ok := pathTools[toolName]
+	if !ok {
+		return nil
+	}
+	path, _ := params[key].(string)
+	if path == "" {
+		return nil
+	}
+	return p.Check(path)
+}
+
+// matchAny returns the first pattern matching the absolute path, or ""
+func (p *PathPolicy) matchAny(path string, patterns []string) string {
+	parts := strings.Split(strings.TrimPrefix(filepath.ToSlash(path), "/"), "/")
+
+	var relParts []string
+	if rel, err := filepath.Rel(p.workDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		relParts = strings.Split(filepath.ToSlash(rel), "/")
+	}
+
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "":
+		case strings.HasPrefix(pattern, "/"):
+			// Anchored at the project directory, or an absolute path: the
+			// path or one of its parents
+			anchored := strings.TrimPrefix(pattern, "/")
+			for end := 1; end <= len(relParts); end++ {
+				if ok, _ := doublestar.Match(anchored, strings.Join(relParts[:end], "/")); ok {
+					return pattern
+				}
+			}
+			for end := 1; end <= len(parts); end++ {
+				if ok, _ := doublestar.Match(anchored, strings.Join(parts[:end], "/")); ok {
+					return pattern
+				}
+			}
+		case !strings.Contains(pattern, "/"):
+			for _, part := range parts {
+				if ok, _ := doublestar.Match(pattern, part); ok {
+					return pattern
+				}
+			}
+		default:
+			for start := 0; start < len(parts); start++ {
+				for end := start + 1; end <= len(parts); end++ {
+					if ok, _ := doublestar.Match(pattern, strings.Join(parts[start:end], "/")); ok {
+						return pattern
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrepSkipsFilesDeniedByPathPolicy(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "server.pem"), []byte("PRIVATE KEY hunter2\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "main.go"), []byte("// KEY handling\n"), 0644)
+
+	r := NewRegistry()
+	if err := r.Register(NewGrepTool(workDir)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	r.SetPathPolicy(NewPathPolicy(workDir, DefaultDeniedPaths, nil))
+
+	result, err := r.Execute(context.Background(), "Grep", json.RawMessage(`{"pattern":"KEY","output_mode":"content"}`))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if strings.Contains(result.Output, "hunter2") {
+		t.Errorf("Grep printed a denied file:\n%s", result.Output)
+	}
+	if !strings.Contains(result.Output, "main.go") {
+		t.Errorf("Grep output = %q, want the allowed file", result.Output)
+	}
+
+	result, _ = r.Execute(context.Background(), "Grep", json.RawMessage(`{"pattern":"KEY","path":"server.pem","output_mode":"content"}`))
+	if !result.IsError || strings.Contains(result.Output, "hunter2") {
+		t.Errorf("Grep of a denied path = %+v, want it refused", result)
+	}
+}
@@ -42,6 +42,9 @@ Use this before implementing complex features to:
 3. Design the implementation approach
 4. Create a detailed plan
 
+To continue an existing plan instead of starting a new one, pass its file name
+(from plan_list) as plan_file; the task is appended to that plan.
+
 Use plan_exit when ready to implement the plan.`
 }
 
@@ -61,6 +64,7 @@ func (t *PlanEnterTool) Parameters() map[string]interface{} {
 // PlanEnterInput PlanEnter 工具的输入
 type PlanEnterInput struct {
 	TaskDescription string `json:"task_description"`
+	PlanFile        string `json:"plan_file,omitempty"`
 }
 
 func (t *PlanEnterTool) Execute(ctx context.Context, input map[string]interface{}) (*Result, error) {
@@ -79,8 +83,26 @@ func (t *PlanEnterTool) Execute(ctx context.Context, input map[string]interface{
 		return nil, fmt.Errorf("task_description is required")
 	}
 
+	planDir := plansDir(t.workDir)
+
+	// 指定了已有计划时，追加任务后重新打开
+	if planInput.PlanFile != "" {
+		planFile, err := resolvePlanFile(planDir, planInput.PlanFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := appendPlanTask(planFile, planInput.TaskDescription); err != nil {
+			return nil, err
+		}
+		if err := t.switchToPlan(); err != nil {
+			return nil, err
+		}
+		return &Result{
+			Output: planModeOutput("Plan file reopened: " + planFile),
+		}, nil
+	}
+
 	// 创建计划目录
-	planDir := filepath.Join(t.workDir, ".gmain-agent", "plans")
 	if err := os.MkdirAll(planDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create plan directory: %w", err)
 	}
@@ -129,18 +151,47 @@ func (t *PlanEnterTool) Execute(ctx context.Context, input map[string]interface{
 		return nil, fmt.Errorf("failed to create plan file: %w", err)
 	}
 
-	// 切换到 plan agent
+	if err := t.switchToPlan(); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Output: planModeOutput("Plan file created: " + planFile),
+	}, nil
+}
+
+// switchToPlan 切换到 plan agent
+func (t *PlanEnterTool) switchToPlan() error {
 	if t.onModeSwitch != nil {
 		if err := t.onModeSwitch("plan"); err != nil {
-			return nil, fmt.Errorf("failed to switch to plan mode: %w", err)
+			return fmt.Errorf("failed to switch to plan mode: %w", err)
 		}
 	}
+	return nil
+}
 
-	output := fmt.Sprintf(`✓ Entered planning mode
+// appendPlanTask 在已有计划末尾追加新任务
+func appendPlanTask(planFile, task string) error {
+	file, err := os.OpenFile(planFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open plan file: %w", err)
+	}
+	defer file.Close()
+
+	section := fmt.Sprintf("\n## Follow-up (%s)\n\n**Task**: %s\n", time.Now().Format("2006-01-02 15:04:05"), task)
+	if _, err := file.WriteString(section); err != nil {
+		return fmt.Errorf("failed to update plan file: %w", err)
+	}
+	return nil
+}
+
+// planModeOutput 生成进入计划模式后的提示
+func planModeOutput(planLine string) string {
+	return fmt.Sprintf(`✓ Entered planning mode
 
 You are now in PLAN MODE with read-only access.
 
-Plan file created: %s
+%s
 
 In this mode you can:
 - Explore and analyze the codebase (read-only)
@@ -158,9 +209,5 @@ Focus on:
 3. Designing the solution
 4. Creating a detailed implementation plan
 
-When your plan is ready, use the plan_exit tool to switch to implementation mode.`, planFile)
-
-	return &Result{
-		Output: output,
-	}, nil
+When your plan is ready, use the plan_exit tool to switch to implementation mode.`, planLine)
 }
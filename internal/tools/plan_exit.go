@@ -4,9 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
-	"sort"
 )
 
 // PlanExitTool 退出计划模式的工具
@@ -116,33 +114,14 @@ Good luck with the implementation!`, latestPlan)
 
 // findLatestPlan 查找最新的计划文件
 func findLatestPlan(planDir string) (string, error) {
-	// 检查目录是否存在
-	if _, err := os.Stat(planDir); os.IsNotExist(err) {
-		return "", fmt.Errorf("no plans found")
-	}
-
-	// 读取目录
-	entries, err := os.ReadDir(planDir)
+	plans, err := listPlans(planDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to read plan directory: %w", err)
+		return "", err
 	}
-
-	// 过滤出计划文件
-	var planFiles []string
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".md" {
-			planFiles = append(planFiles, entry.Name())
-		}
-	}
-
-	if len(planFiles) == 0 {
+	if len(plans) == 0 {
 		return "", fmt.Errorf("no plan files found")
 	}
 
-	// 按文件名排序（文件名包含时间戳）
-	sort.Strings(planFiles)
-
-	// 返回最新的文件
-	latestFile := planFiles[len(planFiles)-1]
-	return filepath.Join(planDir, latestFile), nil
+	// 文件名包含时间戳，最后一个即最新
+	return plans[len(plans)-1].Path, nil
 }
@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// planInfo 计划文件信息
+type planInfo struct {
+	Name    string    // 文件名
+	Path    string    // 完整路径
+	Title   string    // 第一个 "# " 标题
+	ModTime time.Time // 最后修改时间
+}
+
+// plansDir 返回计划文件目录
+func plansDir(workDir string) string {
+	return filepath.Join(workDir, ".gmain-agent", "plans")
+}
+
+// listPlans 列出计划目录中的 .md 文件，按文件名（含时间戳）升序排列
+// 目录不存在时返回空列表
+func listPlans(planDir string) ([]planInfo, error) {
+	entries, err := os.ReadDir(planDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plan directory: %w", err)
+	}
+
+	var plans []planInfo
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(planDir, entry.Name())
+		plans = append(plans, planInfo{
+			Name:    entry.Name(),
+			Path:    path,
+			Title:   planTitle(path),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].Name < plans[j].Name
+	})
+	return plans, nil
+}
+
+// planTitle 读取文件中第一个 "# " 标题，没有则返回空字符串
+func planTitle(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(line[2:])
+		}
+	}
+	return ""
+}
+
+// resolvePlanFile 将文件名或路径解析为计划目录中已存在的计划文件
+func resolvePlanFile(planDir, name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(planDir, filepath.Base(name))
+	}
+	path = filepath.Clean(path)
+
+	if filepath.Dir(path) != filepath.Clean(planDir) || filepath.Ext(path) != ".md" {
+		return "", fmt.Errorf("%s is not a plan file in %s; use plan_list to see existing plans", name, planDir)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("plan file %s does not exist; use plan_list to see existing plans", name)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// PlanListTool 列出已有计划文件的工具
+type PlanListTool struct {
+	workDir string
+}
+
+// NewPlanListTool 创建新的 PlanList 工具
+func NewPlanListTool(workDir string) *PlanListTool {
+	return &PlanListTool{workDir: workDir}
+}
+
+func (t *PlanListTool) Name() string {
+	return "plan_list"
+}
+
+func (t *PlanListTool) Description() string {
+	return `Lists the implementation plans in .gmain-agent/plans/, newest last, with their titles and last-modified times.
+
+Use this to find an existing plan to continue: pass its file name as plan_file to plan_enter.`
+}
+
+func (t *PlanListTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+func (t *PlanListTool) Execute(ctx context.Context, input map[string]interface{}) (*Result, error) {
+	planDir := plansDir(t.workDir)
+	plans, err := listPlans(planDir)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	if len(plans) == 0 {
+		return NewResult(fmt.Sprintf("No plans found in %s. Use plan_enter to start a new plan.", planDir)), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plans in %s:\n\n", planDir)
+	for _, plan := range plans {
+		title := plan.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Fprintf(&b, "- %s  %s  (modified %s)\n", plan.Name, title, plan.ModTime.Format("2006-01-02 15:04"))
+	}
+	return NewResult(strings.TrimSuffix(b.String(), "\n")), nil
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+)
+
+// killProcessGroup is a no-op on Windows; cancellation kills only the shell
+func killProcessGroup(cmd *exec.Cmd) {}
+
+// detachProcessGroup is a no-op on Windows
+func detachProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup kills the process itself; Windows has no SIGTERM
+func signalProcessGroup(pid int, force bool) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ProgressInterval is the least time between two progress reports of a tool
+const ProgressInterval = 100 * time.Millisecond
+
+// Progress is how far a running tool has got with a long operation
+type Progress struct {
+	Done  int64
+	Total int64  // 0 = unknown
+	Unit  string // What Done counts, e.g. "bytes"
+}
+
+// ProgressFunc receives the progress reports of a running tool
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that passes the progress reports of the
+// tool run with it to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress sends p to the context's ProgressFunc, if it has one
+func ReportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(p)
+	}
+}
+
+// ProgressReader returns r reporting the bytes read from it, out of total
+// (0 = unknown), at most every ProgressInterval. Without a ProgressFunc in
+// ctx it returns r itself.
+func ProgressReader(ctx context.Context, r io.Reader, total int64) io.Reader {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); !ok || fn == nil {
+		return r
+	}
+	return &progressReader{ctx: ctx, r: r, total: total}
+}
+
+// progressReader counts the bytes read through it
+type progressReader struct {
+	ctx   context.Context
+	r     io.Reader
+	total int64
+	done  int64
+	last  time.Time // Time of the last report
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if time.Since(p.last) >= ProgressInterval || err == io.EOF {
+		p.last = time.Now()
+		ReportProgress(p.ctx, Progress{Done: p.done, Total: p.total, Unit: "bytes"})
+	}
+	return n, err
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,12 +17,27 @@ const (
 
 // ReadTool reads files from the filesystem
 type ReadTool struct {
-	workDir string
+	workDir  string
+	restrict bool // Refuse paths outside workDir
+	cache    *readCache
 }
 
 // NewReadTool creates a new Read tool
 func NewReadTool(workDir string) *ReadTool {
-	return &ReadTool{workDir: workDir}
+	return &ReadTool{
+		workDir: workDir,
+		cache:   newReadCache(ReadCacheSize),
+	}
+}
+
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *ReadTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+// ClearCache drops all cached file contents
+func (t *ReadTool) ClearCache() {
+	t.cache.clear()
 }
 
 func (t *ReadTool) Name() string {
@@ -36,7 +52,13 @@ Usage:
 - By default, it reads up to 2000 lines starting from the beginning of the file
 - You can optionally specify a line offset and limit (especially handy for long files)
 - Any lines longer than 2000 characters will be truncated
-- Results are returned using cat -n format, with line numbers starting at 1`
+- Results are returned using cat -n format, with line numbers starting at 1
+- Set tail to read the last N lines instead (e.g. the end of a log)
+- Set raw to get the content without line numbers or line truncation (useful for minified files with very long lines)
+- At most 256KB of content is returned per call; max_bytes changes the cap
+- Image files (PNG, JPEG, GIF, WebP) are returned as images so you can see them
+- Set blame to true to annotate each line with the commit, author and date that last changed it (git repositories only)
+- Unchanged files are served from a cache; set force to true to read the file from disk regardless`
 }
 
 func (t *ReadTool) Parameters() map[string]interface{} {
@@ -55,6 +77,29 @@ func (t *ReadTool) Parameters() map[string]interface{} {
 				"type":        "number",
 				"description": "The number of lines to read. Only provide if the file is too large to read at once",
 			},
+			"tail": map[string]interface{}{
+				"type":        "number",
+				"description": "Read the last N lines of the file instead of reading from offset",
+			},
+			"raw": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Return the content without line numbers and without truncating long lines",
+				"default":     false,
+			},
+			"max_bytes": map[string]interface{}{
+				"type":        "number",
+				"description": "Maximum bytes of content to return (default 262144)",
+			},
+			"blame": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Annotate lines with git blame info (commit, author, date). Slower; only use when investigating history",
+				"default":     false,
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Bypass the read cache and read the file from disk",
+				"default":     false,
+			},
 		},
 		"required": []string{"file_path"},
 	}
@@ -67,8 +112,9 @@ func (t *ReadTool) Execute(ctx context.Context, params map[string]interface{}) (
 	}
 
 	// Resolve path
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(t.workDir, filePath)
+	filePath, err := resolvePath(t.workDir, filePath, t.restrict)
+	if err != nil {
+		return NewErrorResult(err), nil
 	}
 
 	// Check if file exists
@@ -84,6 +130,11 @@ func (t *ReadTool) Execute(ctx context.Context, params map[string]interface{}) (
 		return NewErrorResultString(fmt.Sprintf("%s is a directory, not a file. Use Bash with 'ls' to list directory contents.", filePath)), nil
 	}
 
+	// Image files are returned as image blocks
+	if mediaType, ok := imageExtensions[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return readImage(filePath, info.Size(), mediaType), nil
+	}
+
 	// Get offset and limit
 	offset := GetIntDefault(params, "offset", 1)
 	if offset < 1 {
@@ -93,58 +144,190 @@ func (t *ReadTool) Execute(ctx context.Context, params map[string]interface{}) (
 	if limit <= 0 {
 		limit = DefaultReadLimit
 	}
+	blame := GetBoolDefault(params, "blame", false)
+	force := GetBoolDefault(params, "force", false)
+	tail := GetIntDefault(params, "tail", 0)
+	raw := GetBoolDefault(params, "raw", false)
+	maxBytes := GetIntDefault(params, "max_bytes", DefaultReadMaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = DefaultReadMaxBytes
+	}
 
-	// Open file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return NewErrorResult(err), nil
+	// Use the cached content while the file's mtime is unchanged
+	var src interface {
+		io.Reader
+		io.ReaderAt
+	}
+	if content, ok := t.cache.get(filePath, info); ok && !force {
+		src = strings.NewReader(content)
+	} else if info.Size() <= MaxCachedReadSize {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return NewErrorResult(err), nil
+		}
+		t.cache.put(filePath, info, string(data))
+		src = strings.NewReader(string(data))
+	} else {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return NewErrorResult(err), nil
+		}
+		defer file.Close()
+		src = file
 	}
-	defer file.Close()
 
-	// Read lines
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // Allow larger lines
+	// Files too large to cache can take a while to scan; report progress
+	var scan io.Reader = src
+	if info.Size() > MaxCachedReadSize {
+		scan = ProgressReader(ctx, src, info.Size())
+	}
 
+	var lines []string
+	var capped bool
+	if tail > 0 {
+		lines, capped, err = tailLines(src, info.Size(), tail, maxBytes)
+		if err != nil {
+			return NewErrorResult(fmt.Errorf("error reading file: %w", err)), nil
+		}
+		// Number the lines from the end of the file
+		total, err := countLines(ProgressReader(ctx, io.NewSectionReader(src, 0, info.Size()), info.Size()))
+		if err != nil {
+			return NewErrorResult(fmt.Errorf("error reading file: %w", err)), nil
+		}
+		offset = max(total-len(lines)+1, 1)
+		for i, line := range lines {
+			if !raw && len(line) > MaxLineLength {
+				lines[i] = line[:MaxLineLength] + "..."
+			}
+		}
+	} else {
+		lines, capped, err = readLineRange(bufio.NewReader(scan), offset, limit, maxBytes, raw)
+		if err != nil {
+			return NewErrorResult(fmt.Errorf("error reading file: %w", err)), nil
+		}
+	}
+	linesRead := len(lines)
+
+	// Look up blame info for the read range
+	var blameInfo map[int]blameLine
+	var blameErr error
+	if blame && linesRead > 0 {
+		blameInfo, blameErr = gitBlame(ctx, filePath, offset, offset+linesRead-1)
+	}
+
+	// Format like cat -n
 	var output strings.Builder
-	lineNum := 0
-	linesRead := 0
+	if blameErr != nil {
+		output.WriteString(fmt.Sprintf("(git blame unavailable: %s)\n", blameErr.Error()))
+	}
+	for i, line := range lines {
+		num := offset + i
+		if raw {
+			output.WriteString(line + "\n")
+		} else if info, ok := blameInfo[num]; ok {
+			output.WriteString(fmt.Sprintf("%6d\t%s %s %s\t%s\n", num, shortHash(info.Hash), formatBlameAuthor(info.Author), info.Date.Format("2006-01-02"), line))
+		} else {
+			output.WriteString(fmt.Sprintf("%6d\t%s\n", num, line))
+		}
+	}
+
+	if capped {
+		output.WriteString(fmt.Sprintf("\n... (output capped at %d bytes; use offset and limit, tail or a larger max_bytes to read more)\n", maxBytes))
+	}
 
-	for scanner.Scan() {
+	result := output.String()
+	if result == "" {
+		if offset > 1 && tail == 0 {
+			return NewErrorResultString(fmt.Sprintf("No content found starting at line %d", offset)), nil
+		}
+		return NewResult("(empty file)"), nil
+	}
+
+	return NewResult(result), nil
+}
+
+// readLineRange reads up to limit lines starting at line offset, stopping
+// once maxBytes of content has been collected. Long lines are truncated to
+// MaxLineLength unless raw is set.
+func readLineRange(r *bufio.Reader, offset, limit, maxBytes int, raw bool) (lines []string, capped bool, err error) {
+	lineNum := 0
+	size := 0
+	for len(lines) < limit {
 		lineNum++
 
-		// Skip lines before offset
+		// Skip lines before offset without keeping them
 		if lineNum < offset {
+			if _, _, err := readLine(r, 0); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, false, err
+			}
 			continue
 		}
 
-		// Check limit
-		if linesRead >= limit {
-			break
+		keep := MaxLineLength
+		if raw {
+			keep = maxBytes - size
+		}
+		line, length, err := readLine(r, keep)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, false, err
 		}
 
-		line := scanner.Text()
-
-		// Truncate long lines
-		if len(line) > MaxLineLength {
-			line = line[:MaxLineLength] + "..."
+		if length > keep {
+			if raw {
+				lines = append(lines, line)
+				return lines, true, nil
+			}
+			line += "..."
+		}
+		if size+len(line) > maxBytes && len(lines) > 0 {
+			return lines, true, nil
 		}
+		size += len(line) + 1
+		lines = append(lines, line)
+	}
+	return lines, false, nil
+}
 
-		// Format like cat -n
-		output.WriteString(fmt.Sprintf("%6d\t%s\n", lineNum, line))
-		linesRead++
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
 	}
+	return hash
+}
 
-	if err := scanner.Err(); err != nil {
-		return NewErrorResult(fmt.Errorf("error reading file: %w", err)), nil
+// formatBlameAuthor pads or truncates an author name to a fixed column
+// width, counted in runes so multi-byte names are never split
+func formatBlameAuthor(author string) string {
+	const width = 16
+	runes := []rune(author)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
 	}
+	return author + strings.Repeat(" ", width-len(runes))
+}
 
-	result := output.String()
-	if result == "" {
-		if offset > 1 {
-			return NewErrorResultString(fmt.Sprintf("No content found starting at line %d", offset)), nil
-		}
-		return NewResult("(empty file)"), nil
+// readImage returns an image file as an image result
+func readImage(filePath string, size int64, mediaType string) *Result {
+	if size > MaxImageFileSize {
+		return NewErrorResultString(fmt.Sprintf("Image %s is too large (%d bytes, max %d)", filePath, size, MaxImageFileSize))
 	}
 
-	return NewResult(result), nil
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return NewErrorResult(err)
+	}
+
+	img, err := toImage(data, mediaType, MaxImageFileSize)
+	if err != nil {
+		return NewErrorResultString(fmt.Sprintf("Cannot read image %s: %s", filePath, err.Error()))
+	}
+
+	return NewResultWithImages(fmt.Sprintf("Image file: %s (%s, %d bytes)", filePath, img.MediaType, len(data)), []Image{*img})
 }
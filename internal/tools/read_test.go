@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFormatBlameAuthor(t *testing.T) {
+	cases := []struct {
+		author string
+		want   string
+	}{
+		{"Ann", "Ann             "},
+		{"José", "José            "},
+		{"Bartholomew Montgomery", "Bartholomew Mon…"},
+		{"张伟", "张伟              "},
+		{"東京都千代田区霞が関一丁目二番三号", "東京都千代田区霞が関一丁目二番…"},
+	}
+	for _, c := range cases {
+		got := formatBlameAuthor(c.author)
+		if got != c.want {
+			t.Errorf("formatBlameAuthor(%q) = %q, want %q", c.author, got, c.want)
+		}
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) != 16 {
+			t.Errorf("formatBlameAuthor(%q) = %q, want 16 valid runes", c.author, got)
+		}
+	}
+}
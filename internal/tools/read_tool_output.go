@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// OutputLookup resolves a tool call ID to the file holding its full output
+type OutputLookup func(callID string) (path string, ok bool)
+
+// ReadToolOutputTool pages through the full output of a tool call whose
+// result was truncated
+type ReadToolOutputTool struct {
+	lookup OutputLookup
+}
+
+// NewReadToolOutputTool creates a new read_tool_output tool
+func NewReadToolOutputTool(lookup OutputLookup) *ReadToolOutputTool {
+	return &ReadToolOutputTool{lookup: lookup}
+}
+
+func (t *ReadToolOutputTool) Name() string {
+	return "read_tool_output"
+}
+
+func (t *ReadToolOutputTool) Description() string {
+	return `Reads the full output of an earlier tool call whose result was truncated.
+
+Usage:
+- Pass the call_id given in the truncation notice
+- Results are returned using cat -n format, with line numbers starting at 1
+- By default, it reads up to 2000 lines; use offset and limit to page through the rest`
+}
+
+func (t *ReadToolOutputTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"call_id": map[string]interface{}{
+				"type":        "string",
+				"description": "The tool call ID from the truncation notice",
+			},
+			"offset": map[string]interface{}{
+				"type":        "number",
+				"description": "The line number to start reading from (1-indexed)",
+			},
+			"limit": map[string]interface{}{
+				"type":        "number",
+				"description": "The number of lines to read (default 2000)",
+			},
+		},
+		"required": []string{"call_id"},
+	}
+}
+
+func (t *ReadToolOutputTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	callID, ok := GetString(params, "call_id")
+	if !ok || callID == "" {
+		return NewErrorResultString("call_id parameter is required"), nil
+	}
+
+	path, ok := t.lookup(callID)
+	if !ok {
+		return NewErrorResultString(fmt.Sprintf("No saved output for call %s. Only truncated results are saved, and only until the session ends.", callID)), nil
+	}
+
+	offset := GetIntDefault(params, "offset", 1)
+	if offset < 1 {
+		offset = 1
+	}
+	limit := GetIntDefault(params, "limit", DefaultReadLimit)
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return NewErrorResult(err), nil
+	}
+	defer file.Close()
+
+	lines, capped, err := readLineRange(bufio.NewReader(file), offset, limit, DefaultReadMaxBytes, false)
+	if err != nil {
+		return NewErrorResult(fmt.Errorf("error reading output: %w", err)), nil
+	}
+	if len(lines) == 0 {
+		return NewErrorResultString(fmt.Sprintf("No content found starting at line %d", offset)), nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return NewErrorResult(err), nil
+	}
+	total, err := countLines(file)
+	if err != nil {
+		return NewErrorResult(fmt.Errorf("error reading output: %w", err)), nil
+	}
+
+	var output strings.Builder
+	for i, line := range lines {
+		output.WriteString(fmt.Sprintf("%6d\t%s\n", offset+i, line))
+	}
+
+	last := offset + len(lines) - 1
+	if last < total {
+		output.WriteString(fmt.Sprintf("\n(lines %d-%d of %d", offset, last, total))
+		if capped {
+			output.WriteString(fmt.Sprintf("; capped at %d bytes", DefaultReadMaxBytes))
+		}
+		output.WriteString(fmt.Sprintf("; use offset %d to continue)\n", last+1))
+	}
+
+	return NewResult(output.String()), nil
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"container/list"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ReadCacheSize     = 50          // Files kept by the Read tool's cache
+	MaxCachedReadSize = 1024 * 1024 // Larger files are always read from disk
+)
+
+// readCacheEntry is the content of one file as of its modification time
+type readCacheEntry struct {
+	path    string
+	modTime time.Time
+	size    int64
+	content string
+}
+
+// readCache is a bounded LRU cache of file contents, invalidated by mtime
+type readCache struct {
+	max     int
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+	mu      sync.Mutex
+}
+
+func newReadCache(max int) *readCache {
+	return &readCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached content of path if the file has not changed since it was cached
+func (c *readCache) get(path string, info os.FileInfo) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[path]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*readCacheEntry)
+	if !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		c.order.Remove(elem)
+		delete(c.entries, path)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.content, true
+}
+
+// put stores the content of path, evicting the least recently used file if full
+func (c *readCache) put(path string, info os.FileInfo, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &readCacheEntry{
+		path:    path,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		content: content,
+	}
+	if elem, ok := c.entries[path]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[path] = c.order.PushFront(entry)
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*readCacheEntry).path)
+	}
+}
+
+// clear drops every cached file
+func (c *readCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
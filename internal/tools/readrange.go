@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+const (
+	DefaultReadMaxBytes = 256 * 1024 // Content returned by one Read call unless max_bytes is set
+	tailChunkSize       = 64 * 1024  // Bytes read per step when reading backwards
+)
+
+// readLine reads the next line from r, keeping at most max bytes of it and
+// discarding the rest, so a huge single-line file is never buffered whole.
+// It returns the kept text without its line ending and the full length of
+// the line, or io.EOF once no lines remain.
+func readLine(r *bufio.Reader, max int) (string, int, error) {
+	var buf []byte
+	length := 0
+	for {
+		chunk, err := r.ReadSlice('\n')
+		length += len(chunk)
+		if room := max - len(buf); room > 0 {
+			buf = append(buf, chunk[:min(room, len(chunk))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return "", 0, err
+		}
+		if err == io.EOF && length == 0 {
+			return "", 0, io.EOF
+		}
+		if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+			length--
+		}
+		return strings.TrimRight(string(buf), "\r\n"), length, nil
+	}
+}
+
+// tailLines returns up to n lines from the end of r, reading backwards in
+// chunks and stopping after maxBytes. capped reports that the limit cut the
+// read short, in which case the first line may be partial.
+func tailLines(r io.ReaderAt, size int64, n, maxBytes int) (lines []string, capped bool, err error) {
+	var buf []byte
+	pos := size
+	for pos > 0 {
+		// One newline more than n, since the last line usually ends with one
+		if bytes.Count(buf, []byte{'\n'}) > n {
+			break
+		}
+		if len(buf) >= maxBytes {
+			capped = true
+			break
+		}
+
+		chunk := min(int64(tailChunkSize), pos, int64(maxBytes-len(buf)))
+		pos -= chunk
+		data := make([]byte, chunk)
+		if _, err := r.ReadAt(data, pos); err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		buf = append(data, buf...)
+	}
+
+	text := strings.TrimSuffix(string(buf), "\n")
+	if text == "" {
+		return nil, capped, nil
+	}
+	lines = strings.Split(text, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+		capped = false
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, capped, nil
+}
+
+// countLines counts the lines in r without buffering it
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, tailChunkSize)
+	count := 0
+	endsWithNewline := true // An empty input has no unterminated line
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			count += bytes.Count(buf[:n], []byte{'\n'})
+			endsWithNewline = buf[n-1] == '\n'
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	// A final line without a trailing newline
+	if !endsWithNewline {
+		count++
+	}
+	return count, nil
+}
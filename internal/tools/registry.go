@@ -3,10 +3,14 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/anthropics/claude-code-go/internal/api"
+	"github.com/anthropics/claude-code-go/internal/logger"
 )
 
 // Tool defines the interface that all tools must implement
@@ -28,6 +32,13 @@ type Tool interface {
 type Result struct {
 	Output  string
 	IsError bool
+	Images  []Image // Optional images returned to the model alongside Output
+}
+
+// Image is a base64-encoded image attached to a tool result
+type Image struct {
+	MediaType string
+	Data      string
 }
 
 // NewResult creates a successful result
@@ -35,6 +46,11 @@ func NewResult(output string) *Result {
 	return &Result{Output: output, IsError: false}
 }
 
+// NewResultWithImages creates a successful result that also carries images
+func NewResultWithImages(output string, images []Image) *Result {
+	return &Result{Output: output, Images: images}
+}
+
 // NewErrorResult creates an error result
 func NewErrorResult(err error) *Result {
 	return &Result{Output: err.Error(), IsError: true}
@@ -47,22 +63,121 @@ func NewErrorResultString(msg string) *Result {
 
 // Registry manages all available tools
 type Registry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools    map[string]Tool
+	apiTools []api.Tool               // Cached ToAPITools result, reset on Register
+	timeouts map[string]time.Duration // Per-tool execution limits (absent = none)
+	paths    *PathPolicy              // Paths the file tools refuse (nil = no restriction)
+	history  *FileHistory             // Journal of file tool changes (nil = not recorded)
+	mu       sync.RWMutex
 }
 
+// toolTimeoutGrace is how long a timed-out tool gets to return its partial
+// results before it is abandoned
+const toolTimeoutGrace = 2 * time.Second
+
 // NewRegistry creates a new tool registry
 func NewRegistry() *Registry {
 	return &Registry{
-		tools: make(map[string]Tool),
+		tools:    make(map[string]Tool),
+		timeouts: make(map[string]time.Duration),
+	}
+}
+
+// SetTimeout limits how long the named tool may run (0 removes the limit)
+func (r *Registry) SetTimeout(name string, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if timeout <= 0 {
+		delete(r.timeouts, name)
+		return
+	}
+	r.timeouts[name] = timeout
+}
+
+// SetPathPolicy sets the paths the file tools refuse
+func (r *Registry) SetPathPolicy(policy *PathPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = policy
+	for _, tool := range r.tools {
+		if u, ok := tool.(pathPolicyUser); ok {
+			u.SetPathPolicy(policy)
+		}
+	}
+}
+
+// CheckPathPolicy returns a *PathDeniedError if the path policy refuses the
+// tool call
+func (r *Registry) CheckPathPolicy(name string, params map[string]interface{}) error {
+	r.mu.RLock()
+	policy := r.paths
+	r.mu.RUnlock()
+	if policy == nil {
+		return nil
+	}
+	return policy.CheckToolCall(name, params)
+}
+
+// Validate returns the *ValidationError of a malformed call to a tool that
+// implements Validator, or nil
+func (r *Registry) Validate(name string, params map[string]interface{}) error {
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if v, ok := tool.(Validator); ok {
+		return v.Validate(params)
+	}
+	return nil
+}
+
+// PreviewChange returns the change a file tool call would make, or nil if
+// the tool does not write files. The error is the one the call itself would
+// fail with, or a description of the panic if the preview crashed.
+func (r *Registry) PreviewChange(name string, params map[string]interface{}) (change *FileChange, err error) {
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	p, ok := tool.(changePreviewer)
+	if !ok {
+		return nil, nil
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			change, err = nil, errors.New(panicMessage(tool, v))
+		}
+	}()
+	return p.PreviewChange(params)
+}
+
+// CheckCall validates a call and applies the path policy before it is
+// offered for approval. It returns the error result to send instead of
+// running the call, or nil; a tool that panics while validating gets an
+// error result rather than crashing the session.
+func (r *Registry) CheckCall(name string, params map[string]interface{}) *Result {
+	tool, ok := r.Get(name)
+	if !ok {
+		return nil
 	}
+	return r.checkCall(tool, name, params)
 }
 
-// Register adds a tool to the registry
-func (r *Registry) Register(tool Tool) {
+// Register validates a tool's definition and adds it to the registry
+func (r *Registry) Register(tool Tool) error {
+	if err := ValidateTool(tool); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.tools[tool.Name()] = tool
+	r.apiTools = nil
+	return nil
 }
 
 // Get retrieves a tool by name
@@ -73,6 +188,86 @@ func (r *Registry) Get(name string) (Tool, bool) {
 	return tool, ok
 }
 
+// cacheClearer is implemented by tools that cache data between calls
+type cacheClearer interface {
+	ClearCache()
+}
+
+// approvalNoter is implemented by tools whose calls depend on state the
+// input does not show, such as Bash's working directory
+type approvalNoter interface {
+	ApprovalNote(params map[string]interface{}) string
+}
+
+// ApprovalNote returns what the user should know about a call, beyond its
+// input, before approving it, or ""
+func (r *Registry) ApprovalNote(name string, params map[string]interface{}) string {
+	tool, ok := r.Get(name)
+	if !ok {
+		return ""
+	}
+	if n, ok := tool.(approvalNoter); ok {
+		return n.ApprovalNote(params)
+	}
+	return ""
+}
+
+// ClearCaches drops the caches of all registered tools
+func (r *Registry) ClearCaches() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, tool := range r.tools {
+		if c, ok := tool.(cacheClearer); ok {
+			c.ClearCache()
+		}
+	}
+}
+
+// RestrictToWorkDir makes the file tools refuse paths outside the working
+// directory
+func (r *Registry) RestrictToWorkDir(restrict bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, tool := range r.tools {
+		if w, ok := tool.(workDirRestricter); ok {
+			w.SetRestrictToWorkDir(restrict)
+		}
+	}
+}
+
+// SetFileHistory makes the file tools record their changes in h, so they
+// can be undone
+func (r *Registry) SetFileHistory(h *FileHistory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history = h
+	for _, tool := range r.tools {
+		if rec, ok := tool.(historyRecorder); ok {
+			rec.SetFileHistory(h)
+		}
+	}
+}
+
+// FileHistory returns the journal of file tool changes, or nil if none is set
+func (r *Registry) FileHistory() *FileHistory {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history
+}
+
+// Formatters returns the tools that format their own results, by name
+func (r *Registry) Formatters() map[string]Formatter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	formatters := make(map[string]Formatter)
+	for name, tool := range r.tools {
+		if f, ok := tool.(Formatter); ok {
+			formatters[name] = f
+		}
+	}
+	return formatters
+}
+
 // List returns all registered tools
 func (r *Registry) List() []Tool {
 	r.mu.RLock()
@@ -85,19 +280,25 @@ func (r *Registry) List() []Tool {
 	return tools
 }
 
-// ToAPITools converts registered tools to API tool definitions
+// ToAPITools converts registered tools to API tool definitions.
+// The definitions are built once and reused until the next Register.
 func (r *Registry) ToAPITools() []api.Tool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	tools := make([]api.Tool, 0, len(r.tools))
-	for _, tool := range r.tools {
-		tools = append(tools, api.Tool{
-			Name:        tool.Name(),
-			Description: tool.Description(),
-			InputSchema: tool.Parameters(),
-		})
+	if r.apiTools == nil {
+		r.apiTools = make([]api.Tool, 0, len(r.tools))
+		for _, tool := range r.tools {
+			r.apiTools = append(r.apiTools, api.Tool{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				InputSchema: tool.Parameters(),
+			})
+		}
 	}
+
+	tools := make([]api.Tool, len(r.apiTools))
+	copy(tools, r.apiTools)
 	return tools
 }
 
@@ -117,7 +318,105 @@ func (r *Registry) Execute(ctx context.Context, name string, params json.RawMess
 		paramsMap = make(map[string]interface{})
 	}
 
-	return tool.Execute(ctx, paramsMap)
+	// Reject malformed calls before they can have side effects
+	if result := r.checkCall(tool, name, paramsMap); result != nil {
+		return result, nil
+	}
+
+	r.mu.RLock()
+	timeout := r.timeouts[name]
+	r.mu.RUnlock()
+	if timeout > 0 {
+		return executeWithTimeout(ctx, tool, paramsMap, timeout)
+	}
+	return executeSafely(ctx, tool, paramsMap)
+}
+
+// executeWithTimeout runs a tool under a deadline. Tools that honor the
+// context stop and return partial results; one that ignores it is
+// abandoned after a short grace period so it cannot block the agent.
+func executeWithTimeout(ctx context.Context, tool Tool, params map[string]interface{}, timeout time.Duration) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result *Result
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := executeSafely(ctx, tool, params)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+	}
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-time.After(toolTimeoutGrace):
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return NewErrorResultString(fmt.Sprintf("Tool %s timed out after %v", tool.Name(), timeout)), nil
+		}
+		return NewErrorResultString(fmt.Sprintf("Tool %s was cancelled", tool.Name())), nil
+	}
+}
+
+// stoppedEarlyNote explains partial results from a tool whose context ended
+func stoppedEarlyNote(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "(Stopped early: the tool timed out. Results are incomplete.)"
+	}
+	return "(Stopped early: the tool was cancelled. Results are incomplete.)"
+}
+
+// checkCall validates a call and applies the path policy. It returns the
+// error result rejecting the call, or nil if the call may run.
+func (r *Registry) checkCall(tool Tool, name string, params map[string]interface{}) (result *Result) {
+	var err error
+	defer recoverPanic(tool, &result, &err)
+
+	if err := r.Validate(name, params); err != nil {
+		return NewErrorResult(err)
+	}
+	if err := r.CheckPathPolicy(name, params); err != nil {
+		return NewErrorResult(err)
+	}
+	return nil
+}
+
+// executeSafely runs a tool, turning a panic into an error result so a
+// buggy tool fails its call instead of crashing the session
+func executeSafely(ctx context.Context, tool Tool, params map[string]interface{}) (result *Result, err error) {
+	defer recoverPanic(tool, &result, &err)
+	return tool.Execute(ctx, params)
+}
+
+// recoverPanic is deferred around tool code. On a panic it logs the stack
+// trace and replaces the outcome with an error result the model can see.
+func recoverPanic(tool Tool, result **Result, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	*result = NewErrorResultString(panicMessage(tool, r))
+	*err = nil
+}
+
+// panicMessage logs a recovered panic with its stack trace and returns the
+// message reported in its place
+func panicMessage(tool Tool, r interface{}) string {
+	if log := logger.GetLogger(); log != nil {
+		log.LogError("tool_panic", fmt.Errorf("%v", r), map[string]interface{}{
+			"tool":  tool.Name(),
+			"stack": string(debug.Stack()),
+		})
+	}
+	return fmt.Sprintf("Tool %s crashed: %v", tool.Name(), r)
 }
 
 // Helper functions for parameter extraction
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// panicTool panics in whichever of its methods is named by panicIn
+type panicTool struct {
+	panicIn string
+}
+
+func (t *panicTool) Name() string        { return "Panicky" }
+func (t *panicTool) Description() string { return "A tool that panics" }
+func (t *panicTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+func (t *panicTool) Validate(params map[string]interface{}) error {
+	if t.panicIn == "Validate" {
+		var m map[string]int
+		m["x"] = 1
+	}
+	return nil
+}
+
+func (t *panicTool) PreviewChange(params map[string]interface{}) (*FileChange, error) {
+	if t.panicIn == "PreviewChange" {
+		panic("preview failed")
+	}
+	return nil, nil
+}
+
+func (t *panicTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	if t.panicIn == "Execute" {
+		var p *Result
+		return NewResult(p.Output), nil
+	}
+	return NewResult("ok"), nil
+}
+
+func newPanicRegistry(t *testing.T, panicIn string) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	if err := r.Register(&panicTool{panicIn: panicIn}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return r
+}
+
+func TestExecuteRecoversFromPanics(t *testing.T) {
+	for _, method := range []string{"Validate", "Execute"} {
+		r := newPanicRegistry(t, method)
+		result, err := r.Execute(context.Background(), "Panicky", json.RawMessage(`{}`))
+		if err != nil {
+			t.Fatalf("%s panic: Execute returned error %v, want an error result", method, err)
+		}
+		if !result.IsError || !strings.Contains(result.Output, "Tool Panicky crashed") {
+			t.Errorf("%s panic: result = %+v, want a crash report", method, result)
+		}
+	}
+}
+
+func TestCheckCallRecoversFromValidatePanic(t *testing.T) {
+	r := newPanicRegistry(t, "Validate")
+	result := r.CheckCall("Panicky", map[string]interface{}{})
+	if result == nil || !result.IsError || !strings.Contains(result.Output, "assignment to entry in nil map") {
+		t.Errorf("CheckCall = %+v, want the panic as an error result", result)
+	}
+
+	if result := newPanicRegistry(t, "").CheckCall("Panicky", map[string]interface{}{}); result != nil {
+		t.Errorf("CheckCall of a valid call = %+v, want nil", result)
+	}
+}
+
+func TestPreviewChangeRecoversFromPanic(t *testing.T) {
+	r := newPanicRegistry(t, "PreviewChange")
+	change, err := r.PreviewChange("Panicky", map[string]interface{}{})
+	if change != nil || err == nil || !strings.Contains(err.Error(), "preview failed") {
+		t.Errorf("PreviewChange = %v, %v, want the panic as an error", change, err)
+	}
+}
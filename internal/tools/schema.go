@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// toolNameRe matches the tool names accepted by the Messages API
+var toolNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// schemaTypes are the JSON schema types a parameter may declare
+var schemaTypes = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+// ValidateTool checks a tool's name and parameter schema so that broken
+// definitions fail at registration instead of as API errors mid-conversation
+func ValidateTool(tool Tool) error {
+	name := tool.Name()
+	if !toolNameRe.MatchString(name) {
+		return fmt.Errorf("invalid tool name %q: must be 1-64 letters, digits, '_' or '-'", name)
+	}
+
+	if err := validateSchema(tool.Parameters()); err != nil {
+		return fmt.Errorf("tool %s: invalid parameter schema: %w", name, err)
+	}
+	return nil
+}
+
+// validateSchema checks that schema is a JSON object schema the API will accept
+func validateSchema(schema map[string]interface{}) error {
+	if schema == nil {
+		return fmt.Errorf("schema is nil")
+	}
+
+	// Round-trip through JSON so Go types ([]string etc.) look like what the API sees
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("schema is not JSON-serializable: %w", err)
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return fmt.Errorf("schema is not a JSON object: %w", err)
+	}
+
+	if t, _ := normalized["type"].(string); t != "object" {
+		return fmt.Errorf("top-level type must be \"object\", got %v", normalized["type"])
+	}
+	return validateNode("", normalized)
+}
+
+// validateNode checks one schema node and recurses into its children
+func validateNode(path string, node map[string]interface{}) error {
+	where := path
+	if where == "" {
+		where = "(root)"
+	}
+
+	if raw, ok := node["type"]; ok {
+		switch t := raw.(type) {
+		case string:
+			if !schemaTypes[t] {
+				return fmt.Errorf("%s: unknown type %q", where, t)
+			}
+		case []interface{}:
+			for _, v := range t {
+				s, ok := v.(string)
+				if !ok || !schemaTypes[s] {
+					return fmt.Errorf("%s: unknown type %v", where, v)
+				}
+			}
+		default:
+			return fmt.Errorf("%s: type must be a string or array of strings", where)
+		}
+	}
+
+	var props map[string]interface{}
+	if raw, ok := node["properties"]; ok {
+		props, ok = raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: properties must be an object", where)
+		}
+		for name, p := range props {
+			child, ok := p.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: property %q must be an object", where, name)
+			}
+			if err := validateNode(joinSchemaPath(path, name), child); err != nil {
+				return err
+			}
+		}
+	}
+
+	if raw, ok := node["required"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: required must be an array", where)
+		}
+		for _, v := range list {
+			name, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s: required entries must be strings", where)
+			}
+			if _, ok := props[name]; !ok {
+				return fmt.Errorf("%s: required property %q is not defined", where, name)
+			}
+		}
+	}
+
+	if raw, ok := node["items"]; ok {
+		child, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: items must be an object", where)
+		}
+		if err := validateNode(path+"[]", child); err != nil {
+			return err
+		}
+	}
+
+	if raw, ok := node["enum"]; ok {
+		if list, ok := raw.([]interface{}); !ok || len(list) == 0 {
+			return fmt.Errorf("%s: enum must be a non-empty array", where)
+		}
+	}
+
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		raw, ok := node[key]
+		if !ok {
+			continue
+		}
+		list, ok := raw.([]interface{})
+		if !ok || len(list) == 0 {
+			return fmt.Errorf("%s: %s must be a non-empty array", where, key)
+		}
+		for i, v := range list {
+			child, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: %s[%d] must be an object", where, key, i)
+			}
+			if err := validateNode(joinSchemaPath(path, fmt.Sprintf("%s[%d]", key, i)), child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
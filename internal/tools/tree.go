@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultTreeDepth = 3
+	MaxTreeDepth     = 10
+	MaxTreeEntries   = 500   // Entries shown
+	MaxTreeScan      = 50000 // Entries counted for the directory totals
+)
+
+// TreeTool shows a directory as an ASCII tree with file counts and sizes,
+// without depending on the tree binary
+type TreeTool struct {
+	workDir  string
+	restrict bool // Refuse paths outside workDir
+}
+
+// NewTreeTool creates a new Tree tool
+func NewTreeTool(workDir string) *TreeTool {
+	return &TreeTool{workDir: workDir}
+}
+
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *TreeTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+func (t *TreeTool) Name() string {
+	return "Tree"
+}
+
+func (t *TreeTool) Description() string {
+	return `Shows the structure of a project as a tree, like the tree command, with the number of files and total size of each directory.
+
+Usage:
+- The path parameter defaults to the current working directory
+- Use it first to get an overview of an unfamiliar project
+- Hidden directories, node_modules, vendor, __pycache__ and files excluded by .gitignore are skipped
+- Use ignore to skip more entries with glob patterns (e.g. "*.log", "testdata/**")
+- Set sizes to also show the size of each file
+- Directory totals include levels below the depth shown`
+}
+
+func (t *TreeTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "The directory to show. Defaults to current working directory.",
+			},
+			"depth": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("How many directory levels to show (default: %d, max: %d)", DefaultTreeDepth, MaxTreeDepth),
+			},
+			"ignore": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Glob patterns of entries to skip, matched against names and paths relative to the directory",
+			},
+			"sizes": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Show the size of each file (default: false)",
+			},
+		},
+	}
+}
+
+func (t *TreeTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	dir := t.workDir
+	if path, ok := GetString(params, "path"); ok && path != "" {
+		resolved, err := resolvePath(t.workDir, path, t.restrict)
+		if err != nil {
+			return NewErrorResult(err), nil
+		}
+		dir = resolved
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewErrorResultString(fmt.Sprintf("Path not found: %s", dir)), nil
+		}
+		return NewErrorResult(err), nil
+	}
+	if !info.IsDir() {
+		return NewErrorResultString(fmt.Sprintf("%s is a file, not a directory. Use Read to view it.", dir)), nil
+	}
+
+	depth := GetIntDefault(params, "depth", DefaultTreeDepth)
+	depth = max(1, min(depth, MaxTreeDepth))
+	ignore, _ := GetStringArray(params, "ignore")
+
+	s := &treeScanner{
+		maxDepth: depth,
+		// Same skip rules as List
+		filter: &lister{root: dir, ignore: ignore, gitignore: NewGitIgnore(ignoreRoot(t.workDir, dir))},
+	}
+	root := &treeNode{name: dir, isDir: true}
+	if err := s.scan(ctx, root, dir, 1); err != nil {
+		return NewErrorResult(err), nil
+	}
+
+	r := &treeRenderer{sizes: GetBoolDefault(params, "sizes", false)}
+	r.b.WriteString(root.label(false) + "\n")
+	r.render(root, "")
+
+	fmt.Fprintf(&r.b, "\n%d directories, %d files, %s\n", root.dirs, root.files, formatSize(root.size))
+	if r.truncated {
+		fmt.Fprintf(&r.b, "(Tree truncated at %d entries. Show a subdirectory, lower depth or use ignore to narrow it down.)\n", MaxTreeEntries)
+	}
+	if s.partial {
+		fmt.Fprintf(&r.b, "(Totals are incomplete: counting stopped after %d entries.)\n", MaxTreeScan)
+	}
+	return NewResult(r.b.String()), nil
+}
+
+// treeNode is a file or directory; directories carry the totals of
+// everything below them
+type treeNode struct {
+	name     string
+	isDir    bool
+	size     int64
+	files    int
+	dirs     int
+	err      error       // Directory could not be read
+	children []*treeNode // Only down to the depth shown
+}
+
+// label returns the node's line, without the tree prefix
+func (n *treeNode) label(sizes bool) string {
+	switch {
+	case n.err != nil:
+		return fmt.Sprintf("%s/ (cannot read: %s)", n.name, n.err.Error())
+	case n.isDir:
+		files := "files"
+		if n.files == 1 {
+			files = "file"
+		}
+		return fmt.Sprintf("%s/ (%d %s, %s)", n.name, n.files, files, formatSize(n.size))
+	case sizes:
+		return fmt.Sprintf("%s (%s)", n.name, formatSize(n.size))
+	default:
+		return n.name
+	}
+}
+
+// treeScanner builds the tree and the directory totals
+type treeScanner struct {
+	maxDepth int
+	filter   *lister
+	scanned  int
+	partial  bool // MaxTreeScan was reached
+}
+
+// scan fills node with the entries of dir, which is at the given depth
+func (s *treeScanner) scan(ctx context.Context, node *treeNode, dir string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		node.err = err
+		return nil
+	}
+
+	// Directories first, then files, each sorted by name
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if s.filter.skip(path, entry) {
+			continue
+		}
+		if s.scanned >= MaxTreeScan {
+			s.partial = true
+			return nil
+		}
+		s.scanned++
+
+		child := &treeNode{name: entry.Name(), isDir: entry.IsDir()}
+		if entry.IsDir() {
+			if err := s.scan(ctx, child, path, depth+1); err != nil {
+				return err
+			}
+			node.dirs += child.dirs + 1
+			node.files += child.files
+		} else {
+			if info, err := entry.Info(); err == nil {
+				child.size = info.Size()
+			}
+			node.files++
+		}
+		node.size += child.size
+
+		if depth <= s.maxDepth {
+			node.children = append(node.children, child)
+		}
+	}
+	return nil
+}
+
+// treeRenderer writes the tree in the format of the tree command
+type treeRenderer struct {
+	b         strings.Builder
+	sizes     bool
+	entries   int
+	truncated bool
+}
+
+// render writes the children of node, each line starting with prefix
+func (r *treeRenderer) render(node *treeNode, prefix string) {
+	for i, child := range node.children {
+		if r.entries >= MaxTreeEntries {
+			r.truncated = true
+			return
+		}
+		r.entries++
+
+		branch, indent := "├── ", "│   "
+		if i == len(node.children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		r.b.WriteString(prefix + branch + child.label(r.sizes) + "\n")
+		r.render(child, prefix+indent)
+	}
+}
+
+// formatSize formats a byte count in B, KB or MB
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
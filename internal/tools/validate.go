@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+)
+
+// Validator is implemented by tools that check a call's parameters before
+// running it, so a malformed call fails without side effects
+type Validator interface {
+	Validate(params map[string]interface{}) error
+}
+
+// ValidationError reports an invalid parameter of a tool call
+type ValidationError struct {
+	Tool   string
+	Param  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid %s call: %s %s. The tool was not run; fix the parameters and call it again.", e.Tool, e.Param, e.Reason)
+}
+
+// invalidParam returns a *ValidationError for param of tool
+func invalidParam(tool, param, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Tool: tool, Param: param, Reason: fmt.Sprintf(format, args...)}
+}
+
+// checkString checks that params[key] is a string if present, and that it
+// is present if required
+func checkString(tool string, params map[string]interface{}, key string, required bool) error {
+	v, ok := params[key]
+	if !ok {
+		if required {
+			return invalidParam(tool, key, "is required")
+		}
+		return nil
+	}
+	if _, ok := v.(string); !ok {
+		return invalidParam(tool, key, "must be a string, got %T", v)
+	}
+	return nil
+}
+
+// checkNonEmpty checks that params[key] is a non-empty string
+func checkNonEmpty(tool string, params map[string]interface{}, key string) error {
+	if err := checkString(tool, params, key, true); err != nil {
+		return err
+	}
+	if params[key] == "" {
+		return invalidParam(tool, key, "must not be empty")
+	}
+	return nil
+}
+
+// checkOptional checks that params[key], if present, has the JSON type kind
+// ("boolean" or "number"), and for numbers that it is at least min
+func checkOptional(tool string, params map[string]interface{}, key, kind string, min int) error {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return nil
+	}
+	switch kind {
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return invalidParam(tool, key, "must be a boolean, got %T", v)
+		}
+	case "number":
+		n, ok := GetInt(params, key)
+		if !ok {
+			return invalidParam(tool, key, "must be a number, got %T", v)
+		}
+		if n < min {
+			return invalidParam(tool, key, "must be at least %d, got %d", min, n)
+		}
+	}
+	return nil
+}
+
+// Validate checks the path and content without creating anything
+func (t *WriteTool) Validate(params map[string]interface{}) error {
+	if err := checkNonEmpty(t.Name(), params, "file_path"); err != nil {
+		return err
+	}
+	if err := checkString(t.Name(), params, "content", true); err != nil {
+		return err
+	}
+	for _, key := range []string{"no_diff", "strip_code_fences"} {
+		if err := checkOptional(t.Name(), params, key, "boolean", 0); err != nil {
+			return err
+		}
+	}
+
+	filePath, _, err := t.target(params)
+	if err != nil {
+		return invalidParam(t.Name(), "file_path", "is not allowed: %v", err)
+	}
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		return invalidParam(t.Name(), "file_path", "is a directory: %s", filePath)
+	}
+	return nil
+}
+
+// Validate checks the replacement and the line range
+func (t *EditTool) Validate(params map[string]interface{}) error {
+	if err := checkNonEmpty(t.Name(), params, "file_path"); err != nil {
+		return err
+	}
+	for _, key := range []string{"old_string", "new_string"} {
+		if err := checkString(t.Name(), params, key, true); err != nil {
+			return err
+		}
+	}
+	if params["old_string"] == params["new_string"] {
+		return invalidParam(t.Name(), "new_string", "must be different from old_string")
+	}
+	for _, key := range []string{"replace_all", "no_diff"} {
+		if err := checkOptional(t.Name(), params, key, "boolean", 0); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{"start_line", "end_line"} {
+		if err := checkOptional(t.Name(), params, key, "number", 1); err != nil {
+			return err
+		}
+	}
+	start, hasStart := GetInt(params, "start_line")
+	end, hasEnd := GetInt(params, "end_line")
+	if hasStart && hasEnd && end < start {
+		return invalidParam(t.Name(), "end_line", "(%d) must not be before start_line (%d)", end, start)
+	}
+	return nil
+}
+
+// Validate checks that the patch parses, without reading the files
+func (t *ApplyPatchTool) Validate(params map[string]interface{}) error {
+	if err := checkNonEmpty(t.Name(), params, "patch"); err != nil {
+		return err
+	}
+	patch, _ := GetString(params, "patch")
+	if _, err := parsePatch(patch); err != nil {
+		return invalidParam(t.Name(), "patch", "is not a valid unified diff: %v", err)
+	}
+	return nil
+}
+
+// Validate checks the command and options without running anything
+func (t *BashTool) Validate(params map[string]interface{}) error {
+	if err := checkNonEmpty(t.Name(), params, "command"); err != nil {
+		return err
+	}
+	if err := checkString(t.Name(), params, "description", false); err != nil {
+		return err
+	}
+	if err := checkOptional(t.Name(), params, "timeout", "number", 1); err != nil {
+		return err
+	}
+	return checkOptional(t.Name(), params, "run_in_background", "boolean", 0)
+}
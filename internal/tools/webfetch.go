@@ -2,8 +2,10 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -15,26 +17,47 @@ const (
 	WebFetchTimeout    = 30 * time.Second
 	MaxWebFetchSize    = 1024 * 1024 // 1MB
 	MaxWebFetchContent = 50000       // Characters
+
+	// Image limits when include_images is set
+	MaxWebFetchImages    = 3
+	MaxWebFetchImageSize = 1024 * 1024 // 1MB per image
 )
 
+var imgSrcRe = regexp.MustCompile(`(?is)<img\b[^>]*?\bsrc\s*=\s*["']([^"']+)["']`)
+
 // WebFetchTool fetches content from URLs
 type WebFetchTool struct {
 	httpClient *http.Client
+	guard      *hostGuard
 }
 
-// NewWebFetchTool creates a new WebFetch tool
+// NewWebFetchTool creates a new WebFetch tool. It refuses to fetch internal
+// addresses (loopback, private, link-local and metadata ranges), including
+// on redirects and whatever address the host resolves to when dialed, unless
+// they are allowed with SetAllowedHosts.
 func NewWebFetchTool() *WebFetchTool {
-	return &WebFetchTool{
-		httpClient: &http.Client{
-			Timeout: WebFetchTimeout,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				if len(via) >= 10 {
-					return fmt.Errorf("too many redirects")
-				}
-				return nil
-			},
+	t := &WebFetchTool{guard: newHostGuard(nil)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return t.guard.dialContext(ctx, network, addr)
+	}
+	t.httpClient = &http.Client{
+		Timeout:   WebFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			return t.guard.check(req.Context(), req.URL.Hostname())
 		},
 	}
+	return t
+}
+
+// SetAllowedHosts lets the tool fetch the given internal hosts. Entries are
+// host names, IP addresses or CIDR ranges.
+func (t *WebFetchTool) SetAllowedHosts(hosts []string) {
+	t.guard = newHostGuard(hosts)
 }
 
 func (t *WebFetchTool) Name() string {
@@ -48,6 +71,9 @@ Usage notes:
 - The URL must be a fully-formed valid URL
 - HTTP URLs will be automatically upgraded to HTTPS
 - Results may be summarized if the content is very large
+- Image URLs (PNG, JPEG, GIF, WebP) are returned as images
+- Set output_format to "markdown" to keep headings, links, lists and code blocks from HTML pages
+- Set include_images to also return images embedded in an HTML page, so diagrams and screenshots can be viewed
 - This tool is read-only and does not modify any files`
 }
 
@@ -64,6 +90,15 @@ func (t *WebFetchTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "The prompt to run on the fetched content (currently returns raw content)",
 			},
+			"output_format": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"text", "markdown"},
+				"description": "How to convert HTML pages: plain text or markdown (default: text)",
+			},
+			"include_images": map[string]interface{}{
+				"type":        "boolean",
+				"description": fmt.Sprintf("Download up to %d page images and include them in the result (default: false)", MaxWebFetchImages),
+			},
 		},
 		"required": []string{"url", "prompt"},
 	}
@@ -75,6 +110,11 @@ func (t *WebFetchTool) Execute(ctx context.Context, params map[string]interface{
 		return NewErrorResultString("url parameter is required"), nil
 	}
 
+	format := GetStringDefault(params, "output_format", "text")
+	if format != "text" && format != "markdown" {
+		return NewErrorResultString(fmt.Sprintf("Invalid output_format %q: must be \"text\" or \"markdown\"", format)), nil
+	}
+
 	// Parse and validate URL
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -90,6 +130,11 @@ func (t *WebFetchTool) Execute(ctx context.Context, params map[string]interface{
 		return NewErrorResultString("Only HTTP/HTTPS URLs are supported"), nil
 	}
 
+	// Refuse internal addresses before connecting
+	if err := t.guard.check(ctx, parsedURL.Hostname()); err != nil {
+		return NewErrorResultString(err.Error()), nil
+	}
+
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, "GET", parsedURL.String(), nil)
 	if err != nil {
@@ -102,6 +147,10 @@ func (t *WebFetchTool) Execute(ctx context.Context, params map[string]interface{
 	// Fetch URL
 	resp, err := t.httpClient.Do(req)
 	if err != nil {
+		var blocked *BlockedAddressError
+		if errors.As(err, &blocked) {
+			return NewErrorResultString(blocked.Error()), nil
+		}
 		return NewErrorResultString(fmt.Sprintf("Failed to fetch URL: %s", err.Error())), nil
 	}
 	defer resp.Body.Close()
@@ -110,19 +159,47 @@ func (t *WebFetchTool) Execute(ctx context.Context, params map[string]interface{
 		return NewErrorResultString(fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status)), nil
 	}
 
-	// Read response body with size limit
-	limitedReader := io.LimitReader(resp.Body, MaxWebFetchSize)
+	// Read response body with size limit, reporting progress against the
+	// announced length
+	total := resp.ContentLength
+	if total > MaxWebFetchSize {
+		total = MaxWebFetchSize
+	}
+	limitedReader := io.LimitReader(ProgressReader(ctx, resp.Body, max(total, 0)), MaxWebFetchSize)
 	body, err := io.ReadAll(limitedReader)
 	if err != nil {
 		return NewErrorResultString(fmt.Sprintf("Failed to read response: %s", err.Error())), nil
 	}
 
-	// Convert to text (basic HTML to text conversion)
-	content := string(body)
+	includeImages := GetBoolDefault(params, "include_images", false)
 	contentType := resp.Header.Get("Content-Type")
 
-	if strings.Contains(contentType, "text/html") {
-		content = htmlToText(content)
+	// The URL itself is an image
+	if strings.HasPrefix(contentType, "image/") {
+		if len(body) >= MaxWebFetchSize {
+			return NewErrorResultString(fmt.Sprintf("Cannot include image: larger than %d bytes", MaxWebFetchSize-1)), nil
+		}
+		img, err := toImage(body, contentType, MaxWebFetchImageSize)
+		if err != nil {
+			return NewErrorResultString(fmt.Sprintf("Cannot include image: %s", err.Error())), nil
+		}
+		return NewResultWithImages(fmt.Sprintf("Image from %s (%s, %d bytes)", parsedURL.String(), img.MediaType, len(body)), []Image{*img}), nil
+	}
+
+	// Convert to text (basic HTML to text conversion)
+	content := string(body)
+	isHTML := strings.Contains(contentType, "text/html")
+
+	if isHTML {
+		var title string
+		if format == "markdown" {
+			title, content = htmlToMarkdown(content, resp.Request.URL)
+		} else {
+			title, content = htmlTitle(content), htmlToText(content)
+		}
+		if title != "" {
+			content = "Title: " + title + "\n\n" + content
+		}
 	}
 
 	// Truncate if necessary
@@ -130,7 +207,88 @@ func (t *WebFetchTool) Execute(ctx context.Context, params map[string]interface{
 		content = content[:MaxWebFetchContent] + "\n\n... (content truncated)"
 	}
 
-	return NewResult(content), nil
+	if !includeImages || !isHTML {
+		return NewResult(content), nil
+	}
+
+	// Collect page images (bounded count and size)
+	base := resp.Request.URL
+	images, notes := t.fetchPageImages(ctx, base, string(body))
+	if len(notes) > 0 {
+		content += "\n\n" + strings.Join(notes, "\n")
+	}
+	return NewResultWithImages(content, images), nil
+}
+
+// fetchPageImages downloads images referenced by <img> tags in the page.
+// It returns the images plus one note line per image for the text output.
+func (t *WebFetchTool) fetchPageImages(ctx context.Context, base *url.URL, html string) ([]Image, []string) {
+	var images []Image
+	var notes []string
+	seen := make(map[string]bool)
+
+	for _, match := range imgSrcRe.FindAllStringSubmatch(html, -1) {
+		if len(images) >= MaxWebFetchImages {
+			break
+		}
+
+		ref, err := url.Parse(strings.TrimSpace(match[1]))
+		if err != nil {
+			continue
+		}
+		imgURL := base.ResolveReference(ref)
+		if imgURL.Scheme != "https" && imgURL.Scheme != "http" {
+			continue // data: URIs and the like
+		}
+		key := imgURL.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		img, err := t.fetchImage(ctx, imgURL)
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("[image skipped: %s (%s)]", key, err.Error()))
+			continue
+		}
+		images = append(images, *img)
+		notes = append(notes, fmt.Sprintf("[image %d: %s]", len(images), key))
+	}
+
+	return images, notes
+}
+
+// fetchImage downloads a single image, enforcing the size and type limits
+func (t *WebFetchTool) fetchImage(ctx context.Context, imgURL *url.URL) (*Image, error) {
+	if err := t.guard.check(ctx, imgURL.Hostname()); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", imgURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Claude-Code-Go/1.0")
+	req.Header.Set("Accept", "image/png,image/jpeg,image/gif,image/webp")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxWebFetchImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxWebFetchImageSize {
+		return nil, fmt.Errorf("larger than %d bytes", MaxWebFetchImageSize)
+	}
+
+	return toImage(data, resp.Header.Get("Content-Type"), MaxWebFetchImageSize)
 }
 
 // htmlToText performs basic HTML to text conversion
@@ -142,6 +300,10 @@ func htmlToText(html string) string {
 	styleRe := regexp.MustCompile(`(?is)<style[^>]*>.*?</style>`)
 	html = styleRe.ReplaceAllString(html, "")
 
+	// The title is reported separately
+	titleRe := regexp.MustCompile(`(?is)<title[^>]*>.*?</title>`)
+	html = titleRe.ReplaceAllString(html, "")
+
 	// Remove HTML comments
 	commentRe := regexp.MustCompile(`(?is)<!--.*?-->`)
 	html = commentRe.ReplaceAllString(html, "")
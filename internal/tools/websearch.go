@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebSearchTimeout         = 30 * time.Second
+	DefaultWebSearchResults  = 5
+	MaxWebSearchResults      = 20
+	MaxWebSearchSnippetChars = 300
+
+	// SearchURLEnv names the environment variable holding the search endpoint
+	SearchURLEnv = "ANTHROPIC_SEARCH_URL"
+)
+
+// SearchResult is a single web search hit
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchBackend performs web searches for the WebSearch tool
+type SearchBackend interface {
+	Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error)
+}
+
+// HTTPSearchBackend queries a search endpoint over HTTP.
+//
+// The endpoint receives GET <url>?q=<query>&count=<n> and must answer with
+// JSON of the form {"results": [{"title": ..., "url": ..., "snippet": ...}]}.
+type HTTPSearchBackend struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewHTTPSearchBackend creates a backend for the given search endpoint
+func NewHTTPSearchBackend(endpoint string) *HTTPSearchBackend {
+	return &HTTPSearchBackend{
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: WebSearchTimeout},
+	}
+}
+
+// Search implements SearchBackend
+func (b *HTTPSearchBackend) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	u, err := url.Parse(b.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("count", strconv.Itoa(maxResults))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Claude-Code-Go/1.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("search endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxWebFetchSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search response: %w", err)
+	}
+
+	var parsed struct {
+		Results []SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse search response: %w", err)
+	}
+	return parsed.Results, nil
+}
+
+// WebSearchTool searches the web through a pluggable backend
+type WebSearchTool struct {
+	backend SearchBackend
+}
+
+// NewWebSearchTool creates a WebSearch tool. A nil backend leaves the tool
+// registered but makes every call report that search is not configured.
+func NewWebSearchTool(backend SearchBackend) *WebSearchTool {
+	return &WebSearchTool{backend: backend}
+}
+
+// NewWebSearchToolFromEnv creates a WebSearch tool using the endpoint in
+// ANTHROPIC_SEARCH_URL, if set
+func NewWebSearchToolFromEnv() *WebSearchTool {
+	if endpoint := os.Getenv(SearchURLEnv); endpoint != "" {
+		return NewWebSearchTool(NewHTTPSearchBackend(endpoint))
+	}
+	return NewWebSearchTool(nil)
+}
+
+func (t *WebSearchTool) Name() string {
+	return "WebSearch"
+}
+
+func (t *WebSearchTool) Description() string {
+	return `Searches the web and returns a list of results with titles, URLs and snippets.
+
+Usage notes:
+- Use this to find current information or documentation you don't already know
+- Use WebFetch to read a result in full
+- This tool is read-only and does not modify any files`
+}
+
+func (t *WebSearchTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "The search query",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("Maximum number of results to return (default: %d, max: %d)", DefaultWebSearchResults, MaxWebSearchResults),
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *WebSearchTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
+	query, ok := GetString(params, "query")
+	if !ok || strings.TrimSpace(query) == "" {
+		return NewErrorResultString("query parameter is required"), nil
+	}
+
+	if t.backend == nil {
+		return NewErrorResultString(fmt.Sprintf("WebSearch is not configured: set %s to a search endpoint", SearchURLEnv)), nil
+	}
+
+	maxResults := DefaultWebSearchResults
+	if n, ok := GetInt(params, "max_results"); ok && n > 0 {
+		maxResults = n
+	}
+	if maxResults > MaxWebSearchResults {
+		maxResults = MaxWebSearchResults
+	}
+
+	results, err := t.backend.Search(ctx, query, maxResults)
+	if err != nil {
+		return NewErrorResultString(fmt.Sprintf("Search failed: %s", err.Error())), nil
+	}
+	if len(results) == 0 {
+		return NewResult(fmt.Sprintf("No results found for %q", query)), nil
+	}
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Search results for %q:\n", query)
+	for i, r := range results {
+		fmt.Fprintf(&b, "\n%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if snippet := strings.Join(strings.Fields(r.Snippet), " "); snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", truncateSnippet(snippet, MaxWebSearchSnippetChars))
+		}
+	}
+	return NewResult(b.String()), nil
+}
+
+// truncateSnippet shortens s to maxChars runes
+func truncateSnippet(s string, maxChars int) string {
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	return string(runes[:maxChars]) + "..."
+}
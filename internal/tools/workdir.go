@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutsideWorkDirError reports a path refused because it resolves outside
+// the working directory of a tool restricted to it
+type OutsideWorkDirError struct {
+	Path     string // The path as given
+	Resolved string // The absolute path it resolves to, symlinks followed
+	WorkDir  string
+}
+
+func (e *OutsideWorkDirError) Error() string {
+	if e.Resolved != e.Path {
+		return fmt.Sprintf("Access denied: %s resolves to %s, outside the working directory %s (restrict_to_workdir is on)", e.Path, e.Resolved, e.WorkDir)
+	}
+	return fmt.Sprintf("Access denied: %s is outside the working directory %s (restrict_to_workdir is on)", e.Path, e.WorkDir)
+}
+
+// workDirRestricter is implemented by tools that can be confined to the
+// working directory
+type workDirRestricter interface {
+	SetRestrictToWorkDir(restrict bool)
+}
+
+// resolvePath makes path absolute against workDir and, when restrict is set,
+// returns an *OutsideWorkDirError if it leaves workDir, by ".." or through
+// a symlink
+func resolvePath(workDir, path string, restrict bool) (string, error) {
+	resolved := path
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(workDir, resolved)
+	}
+	resolved = filepath.Clean(resolved)
+	if !restrict {
+		return resolved, nil
+	}
+
+	root := resolveLinks(filepath.Clean(workDir))
+	real := resolveLinks(resolved)
+	if !withinDir(root, real) {
+		return "", &OutsideWorkDirError{Path: path, Resolved: real, WorkDir: workDir}
+	}
+	return resolved, nil
+}
+
+// withinDir reports whether path is dir or below it
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveLinks follows the symlinks in path. Parts that do not exist yet,
+// such as a file Write would create, are kept as they are, and a final
+// link whose target does not exist is followed too.
+func resolveLinks(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	dir := resolveLinks(parent)
+	if target, err := os.Readlink(path); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		return filepath.Clean(target)
+	}
+	return filepath.Join(dir, filepath.Base(path))
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// escapeDirs returns a working directory and, next to it, an outside
+// directory holding secret.txt. Inside the working directory, link.txt
+// links to that file and linkdir links to the outside directory.
+func escapeDirs(t *testing.T) (workDir, outsideDir string) {
+	t.Helper()
+	root := t.TempDir()
+	workDir = filepath.Join(root, "work")
+	outsideDir = filepath.Join(root, "outside")
+	os.MkdirAll(workDir, 0755)
+	os.MkdirAll(outsideDir, 0755)
+	os.WriteFile(filepath.Join(outsideDir, "secret.txt"), []byte("token=hunter2\n"), 0644)
+	if err := os.Symlink(filepath.Join(outsideDir, "secret.txt"), filepath.Join(workDir, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(outsideDir, filepath.Join(workDir, "linkdir")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	return workDir, outsideDir
+}
+
+func TestReadRestrictRefusesEscapes(t *testing.T) {
+	workDir, _ := escapeDirs(t)
+	tool := NewReadTool(workDir)
+	tool.SetRestrictToWorkDir(true)
+
+	for _, path := range []string{"../outside/secret.txt", "link.txt", "linkdir/secret.txt"} {
+		result, err := tool.Execute(context.Background(), map[string]interface{}{"file_path": path})
+		if err != nil {
+			t.Fatalf("Execute(%s): %v", path, err)
+		}
+		if !result.IsError || strings.Contains(result.Output, "hunter2") {
+			t.Errorf("Read %s = %+v, want it refused", path, result)
+		}
+	}
+}
+
+func TestWriteRestrictRefusesEscapes(t *testing.T) {
+	workDir, outsideDir := escapeDirs(t)
+	tool := NewWriteTool(workDir)
+	tool.SetRestrictToWorkDir(true)
+
+	for _, path := range []string{"../outside/new.txt", "link.txt", "linkdir/new.txt"} {
+		result, err := tool.Execute(context.Background(), map[string]interface{}{"file_path": path, "content": "overwritten\n"})
+		if err != nil {
+			t.Fatalf("Execute(%s): %v", path, err)
+		}
+		if !result.IsError {
+			t.Errorf("Write %s = %+v, want it refused", path, result)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(outsideDir, "secret.txt")); string(data) != "token=hunter2\n" {
+		t.Errorf("file outside the working directory was changed to %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(outsideDir, "new.txt")); err == nil {
+		t.Error("Write created a file outside the working directory")
+	}
+}
+
+func TestRestrictAllowsPathsInsideWorkDir(t *testing.T) {
+	workDir, _ := escapeDirs(t)
+	write := NewWriteTool(workDir)
+	write.SetRestrictToWorkDir(true)
+	read := NewReadTool(workDir)
+	read.SetRestrictToWorkDir(true)
+
+	result, err := write.Execute(context.Background(), map[string]interface{}{"file_path": "sub/../notes.txt", "content": "inside\n"})
+	if err != nil || result.IsError {
+		t.Fatalf("Write inside the working directory = %+v, %v", result, err)
+	}
+	result, err = read.Execute(context.Background(), map[string]interface{}{"file_path": "notes.txt"})
+	if err != nil || result.IsError || !strings.Contains(result.Output, "inside") {
+		t.Errorf("Read inside the working directory = %+v, %v", result, err)
+	}
+}
@@ -2,14 +2,20 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/anthropics/claude-code-go/internal/logger"
 )
 
 // WriteTool writes files to the filesystem
 type WriteTool struct {
-	workDir string
+	workDir  string
+	restrict bool         // Refuse paths outside workDir
+	history  *FileHistory // Records changes for /undo (nil = not recorded)
 }
 
 // NewWriteTool creates a new Write tool
@@ -17,6 +23,16 @@ func NewWriteTool(workDir string) *WriteTool {
 	return &WriteTool{workDir: workDir}
 }
 
+// SetRestrictToWorkDir makes the tool refuse paths outside the working directory
+func (t *WriteTool) SetRestrictToWorkDir(restrict bool) {
+	t.restrict = restrict
+}
+
+// SetFileHistory makes the tool record the previous state of files it changes
+func (t *WriteTool) SetFileHistory(h *FileHistory) {
+	t.history = h
+}
+
 func (t *WriteTool) Name() string {
 	return "Write"
 }
@@ -26,7 +42,9 @@ func (t *WriteTool) Description() string {
 
 Usage:
 - This tool will overwrite the existing file if there is one at the provided path
-- The file_path parameter must be an absolute path, not a relative path`
+- The file_path parameter must be an absolute path, not a relative path
+- The result includes a unified diff against the previous content (all lines added for a new file); set no_diff to leave it out, e.g. for large generated files
+- Set strip_code_fences to remove a markdown code fence (e.g. ` + "```go ... ```" + `) wrapping the whole content; content with other fences is written as-is`
 }
 
 func (t *WriteTool) Parameters() map[string]interface{} {
@@ -41,26 +59,35 @@ func (t *WriteTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "The content to write to the file",
 			},
+			"no_diff": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Leave the diff out of the result (default false)",
+				"default":     false,
+			},
+			"strip_code_fences": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Remove a code fence wrapping the entire content (default false)",
+				"default":     false,
+			},
 		},
 		"required": []string{"file_path", "content"},
 	}
 }
 
 func (t *WriteTool) Execute(ctx context.Context, params map[string]interface{}) (*Result, error) {
-	filePath, ok := GetString(params, "file_path")
-	if !ok || filePath == "" {
-		return NewErrorResultString("file_path parameter is required"), nil
+	filePath, content, err := t.target(params)
+	if err != nil {
+		return NewErrorResult(err), nil
 	}
 
-	content, ok := GetString(params, "content")
-	if !ok {
-		return NewErrorResultString("content parameter is required"), nil
+	// Keep the previous content for the diff
+	noDiff := GetBoolDefault(params, "no_diff", false)
+	var oldContent []byte
+	if !noDiff {
+		oldContent, _ = os.ReadFile(filePath)
 	}
 
-	// Resolve path
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(t.workDir, filePath)
-	}
+	t.history.Record(t.Name(), filePath)
 
 	// Create parent directories if they don't exist
 	dir := filepath.Dir(filePath)
@@ -73,5 +100,114 @@ func (t *WriteTool) Execute(ctx context.Context, params map[string]interface{})
 		return NewErrorResult(fmt.Errorf("failed to write file: %w", err)), nil
 	}
 
-	return NewResult(fmt.Sprintf("File written successfully to: %s", filePath)), nil
+	msg := fmt.Sprintf("File written successfully to: %s", filePath)
+	if t.stripsFence(params) {
+		msg += " (the code fence wrapping the content was removed)"
+		if log := logger.GetLogger(); log != nil {
+			log.Log(logger.LogEntry{
+				Type:     "code_fence_stripped",
+				ToolName: t.Name(),
+				Metadata: map[string]interface{}{"file_path": filePath},
+			})
+		}
+	}
+	if !noDiff {
+		if diff := UnifiedDiff(diffName(t.workDir, filePath), string(oldContent), content); diff != "" {
+			msg += "\n\n" + diff
+		}
+	}
+	return NewResult(msg), nil
+}
+
+// PreviewChange returns the change the call would make without writing it
+func (t *WriteTool) PreviewChange(params map[string]interface{}) (*FileChange, error) {
+	filePath, content, err := t.target(params)
+	if err != nil {
+		return nil, err
+	}
+	oldContent, _ := os.ReadFile(filePath)
+	return &FileChange{
+		Path: filePath,
+		Diff: UnifiedDiff(diffName(t.workDir, filePath), string(oldContent), content),
+	}, nil
+}
+
+// target returns the resolved path and the content to write
+func (t *WriteTool) target(params map[string]interface{}) (string, string, error) {
+	filePath, ok := GetString(params, "file_path")
+	if !ok || filePath == "" {
+		return "", "", errors.New("file_path parameter is required")
+	}
+
+	content, ok := GetString(params, "content")
+	if !ok {
+		return "", "", errors.New("content parameter is required")
+	}
+
+	if GetBoolDefault(params, "strip_code_fences", false) {
+		content, _ = stripCodeFence(content)
+	}
+
+	// Resolve path
+	filePath, err := resolvePath(t.workDir, filePath, t.restrict)
+	if err != nil {
+		return "", "", err
+	}
+	return filePath, content, nil
+}
+
+// stripsFence reports whether the call asks to strip a fence and the
+// content has one to strip
+func (t *WriteTool) stripsFence(params map[string]interface{}) bool {
+	if !GetBoolDefault(params, "strip_code_fences", false) {
+		return false
+	}
+	content, _ := GetString(params, "content")
+	_, stripped := stripCodeFence(content)
+	return stripped
+}
+
+// stripCodeFence removes a markdown code fence wrapping all of content: an
+// opening line of three or more backticks or tildes with an optional
+// language tag, and a matching closing line. Content with anything outside
+// the fence, or with a line inside that would close it (several blocks, as
+// in a markdown file), is returned unchanged.
+func stripCodeFence(content string) (string, bool) {
+	lines := strings.Split(strings.Trim(content, "\n"), "\n")
+	if len(lines) < 2 {
+		return content, false
+	}
+
+	open := strings.TrimRight(lines[0], " \t\r")
+	fence := open[:len(open)-len(strings.TrimLeft(open, "`"))]
+	if len(fence) < 3 {
+		fence = open[:len(open)-len(strings.TrimLeft(open, "~"))]
+	}
+	if len(fence) < 3 {
+		return content, false
+	}
+	// The info string may name the language but not contain the fence character
+	if info := open[len(fence):]; strings.ContainsAny(info, fence[:1]) || strings.ContainsAny(strings.TrimSpace(info), " \t") {
+		return content, false
+	}
+
+	isClose := func(line string) bool {
+		line = strings.TrimSpace(line)
+		return len(line) >= len(fence) && strings.Trim(line, fence[:1]) == ""
+	}
+	body := lines[1 : len(lines)-1]
+	if !isClose(lines[len(lines)-1]) {
+		return content, false
+	}
+	for _, line := range body {
+		if isClose(line) {
+			return content, false
+		}
+	}
+
+	stripped := strings.Join(body, "\n")
+	if stripped != "" {
+		stripped += "\n"
+	}
+	return stripped, true
 }
@@ -1,10 +1,13 @@
 package ui
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/anthropics/claude-code-go/internal/api"
 	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -23,10 +26,12 @@ func NewModel(version, agent, modelName, workDir string) *Model {
 	ta.SetHeight(2)
 	ta.Focus()
 
+	theme := DefaultTheme()
+
 	// Initialize spinner
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
-	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#58A6FF"))
+	sp.Style = lipgloss.NewStyle().Foreground(theme.Primary)
 
 	// Initialize viewport
 	vp := viewport.New(80, 20)
@@ -43,30 +48,89 @@ func NewModel(version, agent, modelName, workDir string) *Model {
 		version:      version,
 		workDir:      workDir,
 		tokens:       TokenStats{MaxTokens: 200000},
-		theme:        DefaultTheme(),
+		theme:        theme,
+		styles:       newStyles(theme),
 		eventChan:    make(chan AgentEvent, 100),
 		inputHistory: make([]string, 0),
 		historyIndex: -1,
+		scrollPause:  true,
+		followBottom: true,
 	}
 }
 
+// SetScrollPause enables or disables pausing auto-scroll when the user scrolls up
+func (m *Model) SetScrollPause(enabled bool) {
+	m.scrollPause = enabled
+}
+
+// SetTheme sets the color scheme
+func (m *Model) SetTheme(theme *Theme) {
+	m.theme = theme
+	m.styles = newStyles(theme)
+	m.spinner.Style = lipgloss.NewStyle().Foreground(theme.Primary)
+	m.markdown = nil // Rebuilt with the theme's markdown style
+}
+
+// SetToolFormatters sets the formatters that render tool results, by tool name
+func (m *Model) SetToolFormatters(formatters map[string]ToolFormatter) {
+	m.formatters = formatters
+}
+
 // SetSendCallback sets the callback for sending messages
 func (m *Model) SetSendCallback(cb func(msg string) error) {
 	m.sendCallback = cb
 }
 
+// SetCommands sets the slash commands offered by the command palette
+func (m *Model) SetCommands(commands []Command) {
+	m.commands = commands
+}
+
+// SetCancelCallback sets the function that cancels the running agent turn
+func (m *Model) SetCancelCallback(cb func()) {
+	m.cancelCallback = cb
+}
+
 // GetEventChannel returns the event channel for agent to send events
 func (m *Model) GetEventChannel() chan AgentEvent {
 	return m.eventChan
 }
 
+// SetInitialPrompt sets a prompt that is sent automatically once the TUI starts
+func (m *Model) SetInitialPrompt(prompt string) {
+	m.initialPrompt = prompt
+}
+
+// QuitPressWindow is how soon a second Ctrl+C must follow the first to quit
+const QuitPressWindow = 2 * time.Second
+
+// quitHint is shown in the status bar after the first Ctrl+C
+const quitHint = "Press Ctrl+C again to quit"
+
+// quitHintExpiredMsg hides the quit hint once the window has passed
+type quitHintExpiredMsg struct{}
+
+// initialPromptMsg triggers sending the configured initial prompt
+type initialPromptMsg struct {
+	prompt string
+}
+
 // Init initializes the model
 func (m *Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		textarea.Blink,
 		m.spinner.Tick,
 		m.waitForAgentEvent(),
-	)
+	}
+
+	if m.initialPrompt != "" {
+		prompt := m.initialPrompt
+		cmds = append(cmds, func() tea.Msg {
+			return initialPromptMsg{prompt: prompt}
+		})
+	}
+
+	return tea.Batch(cmds...)
 }
 
 // waitForAgentEvent waits for events from the agent
@@ -77,6 +141,11 @@ func (m *Model) waitForAgentEvent() tea.Cmd {
 	}
 }
 
+// quitPending reports whether Ctrl+C was pressed within QuitPressWindow
+func (m *Model) quitPending() bool {
+	return !m.quitArmedAt.IsZero() && time.Since(m.quitArmedAt) < QuitPressWindow
+}
+
 // tickCmd returns a command that ticks the spinner
 func (m *Model) tickCmd() tea.Cmd {
 	return m.spinner.Tick
@@ -92,6 +161,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+		m.syncFollowBottom()
 
 	case tea.WindowSizeMsg:
 		m.handleWindowSize(msg)
@@ -103,7 +173,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport.LineUp(3)
 		case tea.MouseButtonWheelDown:
 			m.viewport.LineDown(3)
+		case tea.MouseButtonLeft:
+			if msg.Action == tea.MouseActionPress {
+				m.toggleToolAt(msg.Y)
+			}
 		}
+		m.syncFollowBottom()
 
 	case spinner.TickMsg:
 		if m.state == StateLoading || (m.currentTool != nil && m.currentTool.Status == ToolStatusRunning) {
@@ -112,6 +187,18 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
+	case quitHintExpiredMsg:
+		if m.quitPending() {
+			// A later press re-armed the window; its own tick clears it
+			break
+		}
+		m.quitArmedAt = time.Time{}
+
+	case initialPromptMsg:
+		if cmd := m.submit(msg.prompt); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+
 	case AgentEvent:
 		cmd := m.handleAgentEvent(msg)
 		if cmd != nil {
@@ -122,13 +209,15 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Update textarea if in normal state
-	if m.state == StateNormal && !m.isStreaming {
+	if (m.state == StateNormal || m.state == StateCommandPalette) && !m.isStreaming && !m.swallowKey {
 		var cmd tea.Cmd
 		m.textarea, cmd = m.textarea.Update(msg)
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+		m.syncPalette()
 	}
+	m.swallowKey = false
 
 	return m, tea.Batch(cmds...)
 }
@@ -141,15 +230,31 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 	// Global shortcuts
 	switch msg.String() {
 	case "ctrl+c":
-		if m.state == StateLoading || m.isStreaming {
+		// Unblock a tool waiting on a dialog
+		if m.questionDialog != nil {
+			m.closeQuestionDialog(nil)
+		}
+		if m.confirmDialog != nil {
+			m.resolveConfirm("Cancel", "")
+		}
+		busy := m.state == StateLoading || m.isStreaming
+		if busy && m.cancelCallback != nil {
 			// Cancel current operation
+			m.cancelCallback()
+		}
+		if m.quitPending() {
+			m.quitting = true
+			return tea.Quit
+		}
+		if busy {
 			m.state = StateNormal
 			m.isStreaming = false
 			m.addSystemMessage("Operation cancelled")
-			return nil
 		}
-		m.quitting = true
-		return tea.Quit
+		m.quitArmedAt = time.Now()
+		return tea.Tick(QuitPressWindow, func(time.Time) tea.Msg {
+			return quitHintExpiredMsg{}
+		})
 
 	case "ctrl+d":
 		m.quitting = true
@@ -184,6 +289,12 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 		return m.handleNormalKey(msg)
 	case StateConfirm:
 		return m.handleConfirmKey(msg)
+	case StateSessionPicker:
+		return m.handleSessionPickerKey(msg)
+	case StateQuestion:
+		return m.handleQuestionKey(msg)
+	case StateCommandPalette:
+		return m.handleCommandPaletteKey(msg)
 	case StateHelp:
 		if msg.String() == "?" || msg.String() == "esc" || msg.String() == "q" {
 			m.state = StateNormal
@@ -223,6 +334,10 @@ func (m *Model) handleNormalKey(msg tea.KeyMsg) tea.Cmd {
 			return m.loadNextHistory()
 		}
 
+	case "ctrl+r":
+		// Regenerate the last response
+		return m.retry()
+
 	case "esc":
 		// Clear input or exit
 		if m.textarea.Value() != "" {
@@ -271,18 +386,97 @@ func (m *Model) handleNormalKey(msg tea.KeyMsg) tea.Cmd {
 			m.copyLastResponse()
 			return nil
 		}
+
+	// View and copy shortcuts take Alt so they never eat typed input
+	case "alt+t":
+		// Expand or collapse thinking blocks
+		m.showThinking = !m.showThinking
+		m.updateViewport()
+		m.swallowKey = true
+		return nil
+	case "alt+o":
+		// Expand or collapse all tool blocks
+		m.toggleAllTools()
+		m.swallowKey = true
+		return nil
+	case "alt+y":
+		// Copy the output of the last tool call
+		m.copyLastToolOutput()
+		m.swallowKey = true
+		return nil
+	case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+		// Copy the Nth-from-last message
+		key := msg.String()
+		m.copyNthMessage(int(key[len(key)-1] - '0'))
+		m.swallowKey = true
+		return nil
 	}
 
 	return nil
 }
 
-// handleConfirmKey handles keys in confirm dialog state
+// toggleToolAt expands or collapses the tool block whose header is on
+// screen row y
+func (m *Model) toggleToolAt(y int) {
+	row := y - lipgloss.Height(m.renderHeader()) // The viewport is right below the header
+	if row < 0 || row >= m.viewport.Height {
+		return
+	}
+	for _, h := range m.toolHeaders {
+		if h.line == m.viewport.YOffset+row {
+			h.tool.Expanded = !h.tool.Expanded
+			m.refreshToolBlocks()
+			return
+		}
+	}
+}
+
+// toggleAllTools collapses every tool block if any is expanded, and
+// expands them all otherwise
+func (m *Model) toggleAllTools() {
+	expand := true
+	for _, h := range m.toolHeaders {
+		if h.tool.Expanded {
+			expand = false
+			break
+		}
+	}
+	for _, h := range m.toolHeaders {
+		h.tool.Expanded = expand
+	}
+	m.refreshToolBlocks()
+}
+
+// refreshToolBlocks re-renders after tool blocks were toggled, keeping the
+// scroll position unless the view follows new output
+func (m *Model) refreshToolBlocks() {
+	m.viewport.SetContent(m.renderMessages())
+	if m.followBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// handleConfirmKey handles keys in confirm state
 func (m *Model) handleConfirmKey(msg tea.KeyMsg) tea.Cmd {
 	if m.confirmDialog == nil {
 		m.state = StateNormal
 		return nil
 	}
 
+	if m.confirmEditing {
+		switch msg.String() {
+		case "esc":
+			m.confirmEditing = false
+		case "ctrl+s":
+			m.resolveConfirm("Allow", m.confirmEditor.Value())
+		default:
+			var cmd tea.Cmd
+			m.confirmEditor, cmd = m.confirmEditor.Update(msg)
+			return cmd
+		}
+		return nil
+	}
+
 	switch msg.String() {
 	case "left", "h":
 		if m.confirmDialog.Selected > 0 {
@@ -293,41 +487,293 @@ func (m *Model) handleConfirmKey(msg tea.KeyMsg) tea.Cmd {
 			m.confirmDialog.Selected++
 		}
 	case "enter":
-		result := m.confirmDialog.Options[m.confirmDialog.Selected]
-		if m.confirmDialog.Callback != nil {
-			m.confirmDialog.Callback(result)
-		}
-		m.confirmDialog = nil
-		m.state = StateNormal
+		m.resolveConfirm(m.confirmDialog.Options[m.confirmDialog.Selected], "")
 	case "esc":
-		if m.confirmDialog.Callback != nil {
-			m.confirmDialog.Callback("Cancel")
+		m.resolveConfirm("Cancel", "")
+	case "y":
+		m.resolveConfirm("Allow", "")
+	case "n":
+		m.resolveConfirm("Deny", "")
+	case "a":
+		m.resolveConfirm("Allow Always", "")
+	case "e":
+		if m.confirmDialog.Input != "" {
+			m.startConfirmEdit()
+			return textarea.Blink
 		}
-		m.confirmDialog = nil
+	}
+
+	return nil
+}
+
+// startConfirmEdit opens the editor for the pending tool input
+func (m *Model) startConfirmEdit() {
+	ed := textarea.New()
+	ed.ShowLineNumbers = false
+	ed.CharLimit = 0
+	ed.SetWidth(max(min(m.width-10, 54), 10))
+	ed.SetHeight(8)
+	ed.SetValue(prettyJSON(m.confirmDialog.Input))
+	ed.Focus()
+
+	m.confirmEditor = ed
+	m.confirmEditing = true
+}
+
+// resolveConfirm closes the confirm dialog and reports the choice.
+// edited is the raw editor text, or "" when the input was not edited.
+func (m *Model) resolveConfirm(result, edited string) {
+	dialog := m.confirmDialog
+	m.confirmDialog = nil
+	m.confirmEditing = false
+
+	// The agent is still waiting to run the tool
+	if m.isStreaming {
+		m.state = StateLoading
+	} else {
 		m.state = StateNormal
-	case "y":
-		if m.confirmDialog.Callback != nil {
-			m.confirmDialog.Callback("Allow")
+	}
+
+	if dialog.InputCallback != nil {
+		if edited != "" && compactJSON(edited) == compactJSON(dialog.Input) {
+			edited = ""
+		} else if edited != "" {
+			edited = compactJSON(edited)
 		}
-		m.confirmDialog = nil
+		dialog.InputCallback(result, edited)
+		return
+	}
+	if dialog.Callback != nil {
+		dialog.Callback(result)
+	}
+}
+
+// prettyJSON indents JSON for display, returning s unchanged if it is not JSON
+func prettyJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// compactJSON removes insignificant whitespace, returning s trimmed if it is not JSON
+func compactJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err != nil {
+		return strings.TrimSpace(s)
+	}
+	return buf.String()
+}
+
+// handleSessionPickerKey handles keys in the session picker state
+func (m *Model) handleSessionPickerKey(msg tea.KeyMsg) tea.Cmd {
+	p := m.sessionPicker
+	if p == nil {
 		m.state = StateNormal
-	case "n":
-		if m.confirmDialog.Callback != nil {
-			m.confirmDialog.Callback("Deny")
+		return nil
+	}
+
+	filtered := p.Filtered()
+
+	switch msg.Type {
+	case tea.KeyUp:
+		if p.Selected > 0 {
+			p.Selected--
+		}
+	case tea.KeyDown:
+		if p.Selected < len(filtered)-1 {
+			p.Selected++
+		}
+	case tea.KeyPgUp, tea.KeyLeft:
+		p.Selected -= p.PageSize
+		if p.Selected < 0 {
+			p.Selected = 0
+		}
+	case tea.KeyPgDown, tea.KeyRight:
+		p.Selected += p.PageSize
+		if p.Selected > len(filtered)-1 {
+			p.Selected = len(filtered) - 1
+		}
+		if p.Selected < 0 {
+			p.Selected = 0
+		}
+	case tea.KeyEnter:
+		if len(filtered) == 0 {
+			return nil
+		}
+		m.closeSessionPicker(filtered[p.Selected].ID)
+	case tea.KeyEsc:
+		m.closeSessionPicker("")
+	case tea.KeyBackspace:
+		if len(p.Query) > 0 {
+			runes := []rune(p.Query)
+			p.Query = string(runes[:len(runes)-1])
+			p.Selected = 0
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.Query += string(msg.Runes)
+		p.Selected = 0
+	}
+
+	return nil
+}
+
+// handleCommandPaletteKey handles the palette's keys; other keys edit the
+// input, which filters the palette
+func (m *Model) handleCommandPaletteKey(msg tea.KeyMsg) tea.Cmd {
+	input := m.textarea.Value()
+	items := paletteItems(m.commands, input)
+	p := &m.palette
+
+	switch msg.String() {
+	case "up":
+		if p.Selected > 0 {
+			p.Selected--
+		}
+	case "down":
+		if p.Selected < len(items)-1 {
+			p.Selected++
+		}
+	case "tab":
+		// Complete the selected item, then offer the command's arguments
+		if p.Selected < len(items) {
+			value := items[p.Selected].Value
+			if c := m.findCommand(value); c != nil && c.Args != nil {
+				value += " "
+			}
+			m.textarea.SetValue(value)
+			m.syncPalette()
+		}
+	case "enter":
+		if msg.Alt {
+			return m.handleNormalKey(msg)
+		}
+		// Run the selected item, or the input as typed if nothing matches
+		if p.Selected < len(items) {
+			m.textarea.SetValue(items[p.Selected].Value)
 		}
-		m.confirmDialog = nil
 		m.state = StateNormal
-	case "a":
-		if m.confirmDialog.Callback != nil {
-			m.confirmDialog.Callback("Allow Always")
+		m.swallowKey = true
+		return m.sendMessage()
+	case "esc":
+		p.dismissed = input
+		m.state = StateNormal
+	default:
+		return nil
+	}
+	m.swallowKey = true
+	return nil
+}
+
+// syncPalette opens the command palette while the input is a slash command
+// and closes it otherwise
+func (m *Model) syncPalette() {
+	if m.state != StateNormal && m.state != StateCommandPalette {
+		return
+	}
+	input := m.textarea.Value()
+	if len(m.commands) == 0 || !paletteInput(input) || input == m.palette.dismissed {
+		if m.state == StateCommandPalette {
+			m.state = StateNormal
+		}
+		return
+	}
+	m.state = StateCommandPalette
+	if input != m.palette.query {
+		m.palette.query = input
+		m.palette.Selected = 0
+	}
+}
+
+// findCommand returns the command named name, or nil
+func (m *Model) findCommand(name string) *Command {
+	for i := range m.commands {
+		if strings.EqualFold(m.commands[i].Name, name) {
+			return &m.commands[i]
 		}
-		m.confirmDialog = nil
+	}
+	return nil
+}
+
+// handleQuestionKey handles keys in the question dialog
+func (m *Model) handleQuestionKey(msg tea.KeyMsg) tea.Cmd {
+	d := m.questionDialog
+	if d == nil {
 		m.state = StateNormal
+		return nil
+	}
+	q := d.Questions[d.Current]
+
+	switch msg.String() {
+	case "up", "k":
+		if d.Selected > 0 {
+			d.Selected--
+		}
+	case "down", "j":
+		if d.Selected < len(q.Options)-1 {
+			d.Selected++
+		}
+	case " ":
+		if q.MultiSelect {
+			d.Checked[d.Selected] = !d.Checked[d.Selected]
+		}
+	case "enter":
+		var labels []string
+		if q.MultiSelect {
+			for i, opt := range q.Options {
+				if d.Checked[i] {
+					labels = append(labels, opt.Label)
+				}
+			}
+		}
+		if len(labels) == 0 && len(q.Options) > 0 {
+			labels = []string{q.Options[d.Selected].Label}
+		}
+		d.Answers = append(d.Answers, labels)
+
+		// Move on to the next question, or finish
+		if d.Current < len(d.Questions)-1 {
+			d.Current++
+			d.Selected = 0
+			d.Checked = make(map[int]bool)
+			return nil
+		}
+		m.closeQuestionDialog(d.Answers)
+	case "esc":
+		m.closeQuestionDialog(nil)
 	}
 
 	return nil
 }
 
+// closeQuestionDialog hides the question dialog and reports the answers (nil = cancelled)
+func (m *Model) closeQuestionDialog(answers [][]string) {
+	callback := m.questionDialog.Callback
+	m.questionDialog = nil
+
+	// The agent is still running the tool that asked
+	if m.isStreaming {
+		m.state = StateLoading
+	} else {
+		m.state = StateNormal
+	}
+
+	if callback != nil {
+		callback(answers)
+	}
+}
+
+// closeSessionPicker closes the picker and reports the chosen session ID
+func (m *Model) closeSessionPicker(id string) {
+	callback := m.sessionPicker.Callback
+	m.sessionPicker = nil
+	m.state = StateNormal
+	if callback != nil {
+		callback(id)
+	}
+}
+
 // sendMessage sends the current input to the agent
 func (m *Model) sendMessage() tea.Cmd {
 	input := m.textarea.Value()
@@ -339,6 +785,24 @@ func (m *Model) sendMessage() tea.Cmd {
 	m.inputHistory = append(m.inputHistory, input)
 	m.historyIndex = len(m.inputHistory)
 
+	// Clear input
+	m.textarea.Reset()
+
+	return m.submit(input)
+}
+
+// RetryCommand regenerates the last response; the TUI sends it for Ctrl+R
+const RetryCommand = "/retry"
+
+// submit displays input as a user message and sends it to the agent
+func (m *Model) submit(input string) tea.Cmd {
+	if strings.TrimSpace(input) == RetryCommand {
+		return m.retry()
+	}
+
+	// Sending a message always jumps back to the latest output
+	m.followBottom = true
+
 	// Add user message
 	m.messages = append(m.messages, Message{
 		Type:      MessageTypeUser,
@@ -346,9 +810,6 @@ func (m *Model) sendMessage() tea.Cmd {
 		Timestamp: time.Now(),
 	})
 
-	// Clear input
-	m.textarea.Reset()
-
 	// Update viewport
 	m.updateViewport()
 
@@ -371,6 +832,67 @@ func (m *Model) sendMessage() tea.Cmd {
 	return m.tickCmd()
 }
 
+// retry asks the agent to regenerate its last response. The old response is
+// removed from the display once the agent confirms there is one to retry.
+func (m *Model) retry() tea.Cmd {
+	m.followBottom = true
+	m.state = StateLoading
+	m.isStreaming = true
+
+	if m.sendCallback != nil {
+		go func() {
+			if err := m.sendCallback(RetryCommand); err != nil {
+				m.eventChan <- AgentEvent{
+					Type:  AgentEventError,
+					Error: err,
+				}
+			}
+		}()
+	}
+
+	return m.tickCmd()
+}
+
+// dropLastResponse removes the messages shown after the last user prompt
+func (m *Model) dropLastResponse() {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if msg.Type == MessageTypeUser && !strings.HasPrefix(msg.Content, "/") {
+			m.messages = m.messages[:i+1]
+			break
+		}
+	}
+	m.streamingText = ""
+	m.currentTool = nil
+	m.updateViewport()
+}
+
+// dropPartialResponse removes the output of a response that broke off
+// mid-stream: everything after the last finished tool of this turn
+func (m *Model) dropPartialResponse() {
+	m.streamingText = ""
+	m.currentTool = nil
+	if len(m.messages) == 0 || m.messages[len(m.messages)-1].Type != MessageTypeAssistant {
+		m.updateViewport()
+		return
+	}
+
+	msg := &m.messages[len(m.messages)-1]
+	keep := len(msg.Blocks)
+	for keep > 0 {
+		block := msg.Blocks[keep-1]
+		if block.Type == ContentBlockTool && block.Tool != nil && block.Tool.Status != ToolStatusRunning {
+			break
+		}
+		keep--
+	}
+	msg.Blocks = msg.Blocks[:keep]
+	if keep == 0 {
+		m.messages = m.messages[:len(m.messages)-1]
+	}
+	m.updateViewport()
+}
+
 // loadPrevHistory loads previous history item
 func (m *Model) loadPrevHistory() tea.Cmd {
 	if len(m.inputHistory) == 0 {
@@ -419,9 +941,9 @@ func (m *Model) handleWindowSize(msg tea.WindowSizeMsg) {
 		m.viewportHeight = 5
 	}
 
-	m.viewport.Width = m.width - 2
+	m.viewport.Width = max(m.width-2, 1)
 	m.viewport.Height = m.viewportHeight
-	m.textarea.SetWidth(m.width - 4)
+	m.textarea.SetWidth(max(m.width-4, 1))
 
 	m.ready = true
 	m.updateViewport()
@@ -431,11 +953,28 @@ func (m *Model) handleWindowSize(msg tea.WindowSizeMsg) {
 func (m *Model) handleAgentEvent(event AgentEvent) tea.Cmd {
 	switch event.Type {
 	case AgentEventText:
+		m.retryInfo = ""
 		m.streamingText += event.Text
 		m.updateStreamingText()
 		return nil
 
+	case AgentEventThinking:
+		m.finalizeStreamingText()
+		m.ensureAssistantMessage()
+		msg := &m.messages[len(m.messages)-1]
+		if n := len(msg.Blocks); n > 0 && msg.Blocks[n-1].Type == ContentBlockThinking {
+			msg.Blocks[n-1].Text += event.Text
+		} else {
+			msg.Blocks = append(msg.Blocks, ContentBlock{
+				Type: ContentBlockThinking,
+				Text: event.Text,
+			})
+		}
+		m.updateViewport()
+		return nil
+
 	case AgentEventToolStart:
+		m.retryInfo = ""
 		// First, finalize any pending text as a text block
 		m.finalizeStreamingText()
 
@@ -462,30 +1001,52 @@ func (m *Model) handleAgentEvent(event AgentEvent) tea.Cmd {
 		return m.tickCmd()
 
 	case AgentEventToolEnd:
-		if m.currentTool != nil {
-			m.currentTool.Status = ToolStatusSuccess
+		// Tools of one response may finish in any order
+		tool := m.findTool(event.ToolID)
+		if tool == nil {
+			tool = m.currentTool
+		}
+		if tool != nil {
+			tool.Status = ToolStatusSuccess
 			if event.IsError {
-				m.currentTool.Status = ToolStatusError
+				tool.Status = ToolStatusError
+			}
+			if event.ToolInput != "" {
+				tool.Input = event.ToolInput
 			}
-			m.currentTool.Output = event.ToolOutput
-			m.currentTool.EndTime = time.Now()
-			m.currentTool.IsError = event.IsError
+			tool.Output = event.ToolOutput
+			tool.EndTime = time.Now()
+			tool.IsError = event.IsError
+			tool.Progress = nil
+		}
+		if tool == m.currentTool {
 			m.currentTool = nil
 		}
 		m.updateViewport()
 		return nil
 
+	case AgentEventToolProgress:
+		if tool := m.findTool(event.ToolID); tool != nil && tool.Status == ToolStatusRunning {
+			tool.Progress = event.Progress
+			m.updateViewport()
+		}
+		return nil
+
 	case AgentEventError:
 		m.state = StateNormal
 		m.isStreaming = false
-		m.addErrorMessage(event.Error.Error())
+		m.retryInfo = ""
+		m.addErrorMessage(api.DescribeError(event.Error))
 		return nil
 
 	case AgentEventDone:
 		// Finalize any remaining streaming text
 		m.finalizeStreamingText()
-		m.state = StateNormal
+		if m.state == StateLoading {
+			m.state = StateNormal
+		}
 		m.isStreaming = false
+		m.retryInfo = ""
 		m.updateViewport()
 		return nil
 
@@ -494,20 +1055,74 @@ func (m *Model) handleAgentEvent(event AgentEvent) tea.Cmd {
 		m.addSystemMessage(fmt.Sprintf("Switched to %s agent", event.Agent))
 		return nil
 
+	case AgentEventModelChange:
+		m.model = event.Model
+		m.addSystemMessage(fmt.Sprintf("Switched to model %s", event.Model))
+		return nil
+
 	case AgentEventTokenUpdate:
 		m.tokens = event.Tokens
 		return nil
 
+	case AgentEventRateLimit:
+		m.rateLimit = event.RateLimitInfo
+		return nil
+
+	case AgentEventAPIRetry:
+		m.retryInfo = event.RetryInfo
+		return nil
+
+	case AgentEventContextWarning:
+		m.contextWarning = event.Text
+		if event.Text != "" {
+			m.addSystemMessage(event.Text)
+		}
+		return nil
+
+	case AgentEventCostUpdate:
+		m.cost = event.Cost
+		return nil
+
+	case AgentEventRetry:
+		m.dropLastResponse()
+		return nil
+
+	case AgentEventStreamRetry:
+		m.dropPartialResponse()
+		m.addSystemMessage(event.Text)
+		return nil
+
 	case AgentEventCompaction:
 		m.addSystemMessage(event.CompactionInfo)
 		return nil
 
+	case AgentEventHistoryRestored:
+		m.messages = event.History
+		m.streamingText = ""
+		m.currentTool = nil
+		m.addSystemMessage(event.Text)
+		return nil
+
 	case AgentEventConfirmRequest:
 		if event.ConfirmAction != nil {
 			m.confirmDialog = event.ConfirmAction
 			m.state = StateConfirm
 		}
 		return nil
+
+	case AgentEventQuestion:
+		if event.QuestionDialog != nil && len(event.QuestionDialog.Questions) > 0 {
+			m.questionDialog = event.QuestionDialog
+			m.state = StateQuestion
+		}
+		return nil
+
+	case AgentEventSessionPicker:
+		if event.SessionPicker != nil {
+			m.sessionPicker = event.SessionPicker
+			m.state = StateSessionPicker
+		}
+		return nil
 	}
 
 	return nil
@@ -533,6 +1148,22 @@ func (m *Model) addErrorMessage(content string) {
 	m.updateViewport()
 }
 
+// findTool returns the displayed tool execution with the given ID, searching
+// from the most recent message (nil if not found)
+func (m *Model) findTool(id string) *ToolExecution {
+	if id == "" {
+		return nil
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		for _, block := range m.messages[i].Blocks {
+			if block.Type == ContentBlockTool && block.Tool != nil && block.Tool.ID == id {
+				return block.Tool
+			}
+		}
+	}
+	return nil
+}
+
 // ensureAssistantMessage ensures there's an assistant message to add content to
 func (m *Model) ensureAssistantMessage() {
 	if len(m.messages) == 0 || m.messages[len(m.messages)-1].Type != MessageTypeAssistant {
@@ -604,12 +1235,56 @@ func (m *Model) copyLastResponse() {
 		m.copyMessage = "No response to copy"
 		return
 	}
+	m.copyText(messageText(lastAssistantMsg))
+}
 
-	// Build the text content
+// copyNthMessage copies the nth message from the end (1 = the last), counting
+// user and assistant messages only
+func (m *Model) copyNthMessage(n int) {
+	count := 0
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := &m.messages[i]
+		if msg.Type != MessageTypeUser && msg.Type != MessageTypeAssistant {
+			continue
+		}
+		count++
+		if count == n {
+			m.copyText(messageText(msg))
+			if strings.HasPrefix(m.copyMessage, "Copied") {
+				m.copyMessage = fmt.Sprintf("%s (message %d from the end)", m.copyMessage, n)
+			}
+			return
+		}
+	}
+	m.copyMessage = fmt.Sprintf("No message %d from the end", n)
+}
+
+// copyLastToolOutput copies the output of the most recent finished tool call
+func (m *Model) copyLastToolOutput() {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		blocks := m.messages[i].Blocks
+		for j := len(blocks) - 1; j >= 0; j-- {
+			tool := blocks[j].Tool
+			if blocks[j].Type != ContentBlockTool || tool == nil || tool.Status == ToolStatusRunning {
+				continue
+			}
+			m.copyText(tool.Output)
+			if strings.HasPrefix(m.copyMessage, "Copied") {
+				m.copyMessage = fmt.Sprintf("%s (%s output)", m.copyMessage, tool.Name)
+			}
+			return
+		}
+	}
+	m.copyMessage = "No tool output to copy"
+}
+
+// messageText returns the text of a message for the clipboard, with the
+// output of its tool calls
+func messageText(msg *Message) string {
 	var content strings.Builder
 
-	if len(lastAssistantMsg.Blocks) > 0 {
-		for _, block := range lastAssistantMsg.Blocks {
+	if len(msg.Blocks) > 0 {
+		for _, block := range msg.Blocks {
 			switch block.Type {
 			case ContentBlockText:
 				if block.Text != "" {
@@ -622,11 +1297,16 @@ func (m *Model) copyLastResponse() {
 				}
 			}
 		}
-	} else if lastAssistantMsg.Content != "" {
-		content.WriteString(lastAssistantMsg.Content)
+	} else if msg.Content != "" {
+		content.WriteString(msg.Content)
 	}
+	return content.String()
+}
 
-	text := strings.TrimSpace(content.String())
+// copyText copies text to the clipboard and reports the result in the
+// status bar
+func (m *Model) copyText(text string) {
+	text = strings.TrimSpace(text)
 	if text == "" {
 		m.copyMessage = "No content to copy"
 		return
@@ -650,7 +1330,15 @@ func (m *Model) copyLastResponse() {
 func (m *Model) updateViewport() {
 	content := m.renderMessages()
 	m.viewport.SetContent(content)
-	m.viewport.GotoBottom()
+	if m.followBottom || !m.scrollPause {
+		m.viewport.GotoBottom()
+	}
+}
+
+// syncFollowBottom resumes auto-scroll once the user is back at the bottom
+// and pauses it while they have scrolled away
+func (m *Model) syncFollowBottom() {
+	m.followBottom = m.viewport.AtBottom()
 }
 
 // View renders the entire UI
@@ -663,5 +1351,9 @@ func (m *Model) View() string {
 		return "Goodbye!\n"
 	}
 
+	if m.width < minTerminalWidth || m.height < minTerminalHeight {
+		return m.renderTooSmall()
+	}
+
 	return m.renderLayout()
 }
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/anthropics/claude-code-go/internal/api"
+)
+
+// HistoryMessages converts a conversation history into display messages.
+// The responses of one turn, with their tool calls and results, become a
+// single assistant message, as they are shown while streaming.
+func HistoryMessages(history []api.Message) []Message {
+	var messages []Message
+	tools := make(map[string]*ToolExecution) // Tool calls by ID, for their results
+
+	for _, msg := range history {
+		switch msg.Role {
+		case api.RoleUser:
+			var text []string
+			for _, block := range msg.Content {
+				switch block.Type {
+				case api.ContentTypeText:
+					text = append(text, block.Text)
+				case api.ContentTypeToolResult:
+					if tool := tools[block.ToolUseID]; tool != nil {
+						tool.Output = block.Content
+						tool.IsError = block.IsError
+						if block.IsError {
+							tool.Status = ToolStatusError
+						}
+					}
+				}
+			}
+			if len(text) > 0 {
+				messages = append(messages, Message{
+					Type:      MessageTypeUser,
+					Content:   strings.Join(text, "\n"),
+					Timestamp: time.Now(),
+				})
+			}
+
+		case api.RoleAssistant:
+			if len(messages) == 0 || messages[len(messages)-1].Type != MessageTypeAssistant {
+				messages = append(messages, Message{Type: MessageTypeAssistant, Timestamp: time.Now()})
+			}
+			last := &messages[len(messages)-1]
+			for _, block := range msg.Content {
+				switch block.Type {
+				case api.ContentTypeText:
+					last.Blocks = append(last.Blocks, ContentBlock{Type: ContentBlockText, Text: block.Text})
+				case api.ContentTypeThinking:
+					last.Blocks = append(last.Blocks, ContentBlock{Type: ContentBlockThinking, Text: block.Thinking})
+				case api.ContentTypeToolUse:
+					tool := &ToolExecution{
+						ID:     block.ID,
+						Name:   block.Name,
+						Input:  string(block.Input),
+						Status: ToolStatusSuccess,
+					}
+					last.Blocks = append(last.Blocks, ContentBlock{Type: ContentBlockTool, Tool: tool})
+					tools[block.ID] = tool
+				}
+			}
+		}
+	}
+	return messages
+}
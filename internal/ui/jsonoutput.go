@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// JSONUsage is token usage as reported in JSON output
+type JSONUsage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	CacheReadTokens  int `json:"cache_read_input_tokens"`
+	CacheWriteTokens int `json:"cache_creation_input_tokens"`
+}
+
+// JSONEvent is one line of JSON output, mirroring an agent event
+type JSONEvent struct {
+	Type       string          `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	ToolName   string          `json:"tool_name,omitempty"`
+	ToolID     string          `json:"tool_id,omitempty"`
+	ToolInput  json.RawMessage `json:"tool_input,omitempty"`
+	ToolOutput string          `json:"tool_output,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	AgentName  string          `json:"agent_name,omitempty"`
+	Info       string          `json:"info,omitempty"`
+	Usage      *JSONUsage      `json:"usage,omitempty"`
+
+	StopReason   string `json:"stop_reason,omitempty"`   // Why the response ended (conversation_end)
+	StopSequence string `json:"stop_sequence,omitempty"` // The stop sequence matched, if any
+}
+
+// JSONResult is the final line of JSON output
+type JSONResult struct {
+	Type    string    `json:"type"` // Always "result"
+	IsError bool      `json:"is_error"`
+	Error   string    `json:"error,omitempty"`
+	Result  string    `json:"result"` // Text of the final assistant response
+	Usage   JSONUsage `json:"usage"`  // Totals for the whole run
+}
+
+// JSONWriter writes agent events as newline-delimited JSON for scripts
+// and CI pipelines. It replaces the Terminal, so nothing else should
+// write to the same output while it is in use.
+type JSONWriter struct {
+	enc  *json.Encoder
+	text strings.Builder // Text since the last tool call
+	mu   sync.Mutex
+}
+
+// NewJSONWriter creates a JSON writer on w and turns off colored output
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	color.NoColor = true
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONWriter{enc: enc}
+}
+
+// Write emits one event
+func (w *JSONWriter) Write(event JSONEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch event.Type {
+	case "text":
+		w.text.WriteString(event.Text)
+	case "tool_use_start":
+		// Only the response after the last tool call counts as the final text
+		w.text.Reset()
+	}
+	w.enc.Encode(event)
+}
+
+// WriteResult emits the final result object with the run's total usage
+func (w *JSONWriter) WriteResult(usage JSONUsage, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	result := JSONResult{
+		Type:   "result",
+		Result: strings.TrimSpace(w.text.String()),
+		Usage:  usage,
+	}
+	if err != nil {
+		result.IsError = true
+		result.Error = err.Error()
+	}
+	w.enc.Encode(result)
+}
+
+// JSONToolInput converts a tool's raw input for embedding in an event,
+// falling back to a JSON string when it is not valid JSON
+func JSONToolInput(input string) json.RawMessage {
+	if input == "" {
+		return nil
+	}
+	if json.Valid([]byte(input)) {
+		return json.RawMessage(input)
+	}
+	quoted, _ := json.Marshal(input)
+	return quoted
+}
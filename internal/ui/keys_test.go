@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeKeys sends each rune of text to the model as a key press
+func typeKeys(m *Model, text string) {
+	for _, r := range text {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func TestTypedInputKeepsShortcutLetters(t *testing.T) {
+	for _, prompt := range []string{"yes do it", "ok", "try again", "c1 is fine", "one more", "go"} {
+		m := NewModel("test", "build", "model", t.TempDir())
+		typeKeys(m, prompt)
+		if got := m.textarea.Value(); got != prompt {
+			t.Errorf("typing %q left the input as %q", prompt, got)
+		}
+	}
+}
+
+func TestAltShortcutsDoNotType(t *testing.T) {
+	m := NewModel("test", "build", "model", t.TempDir())
+	for _, r := range "toy1" {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}, Alt: true})
+	}
+	if got := m.textarea.Value(); got != "" {
+		t.Errorf("Alt shortcuts typed %q into the input", got)
+	}
+	if !m.showThinking {
+		t.Error("Alt+T did not expand thinking blocks")
+	}
+}
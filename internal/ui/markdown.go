@@ -28,6 +28,23 @@ func NewMarkdownRenderer() *MarkdownRenderer {
 	}
 }
 
+// NewStyledMarkdownRenderer creates a renderer with a fixed glamour style
+// ("dark" or "light") and wrap width. Unlike the auto style it does not
+// query the terminal, so it is safe to use while the TUI owns the screen.
+func NewStyledMarkdownRenderer(style string, width int) *MarkdownRenderer {
+	if style != "dark" && style != "light" {
+		style = "dark"
+	}
+	renderer, _ := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(style),
+		glamour.WithWordWrap(width),
+	)
+
+	return &MarkdownRenderer{
+		renderer: renderer,
+	}
+}
+
 // Render renders markdown to terminal-formatted text
 func (m *MarkdownRenderer) Render(text string) string {
 	if m.renderer == nil {
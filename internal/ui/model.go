@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -25,13 +26,19 @@ type ContentBlockType int
 const (
 	ContentBlockText ContentBlockType = iota
 	ContentBlockTool
+	ContentBlockThinking
 )
 
-// ContentBlock represents a piece of content (text or tool)
+// ContentBlock represents a piece of content (text, tool or thinking)
 type ContentBlock struct {
 	Type ContentBlockType
 	Text string
 	Tool *ToolExecution
+
+	// Rendered markdown of Text, reused while Text and the width are unchanged
+	rendered       string
+	renderedSource string
+	renderedWidth  int
 }
 
 // Message represents a chat message
@@ -53,6 +60,12 @@ const (
 	ToolStatusError
 )
 
+// ToolFormatter renders a tool's result for display, returning "" to fall
+// back to the generic rendering (tools.Formatter satisfies it)
+type ToolFormatter interface {
+	FormatTUI(input, output string) string
+}
+
 // ToolExecution represents a tool execution
 type ToolExecution struct {
 	ID        string
@@ -64,6 +77,14 @@ type ToolExecution struct {
 	EndTime   time.Time
 	Expanded  bool
 	IsError   bool
+	Progress  *ToolProgress // Latest progress report while running (nil = none)
+}
+
+// toolHeader is where a tool block's header line was rendered in the
+// viewport content
+type toolHeader struct {
+	line int
+	tool *ToolExecution
 }
 
 // TokenStats holds token usage statistics
@@ -91,8 +112,68 @@ type ConfirmAction struct {
 	Selected  int
 	Visible   bool
 	Callback  func(result string)
+
+	// Input is the tool input (JSON). When set, the user can edit it before
+	// approving and InputCallback receives the edited input ("" if unchanged).
+	Input         string
+	InputCallback func(result, input string)
+
+	// Diff is a unified diff of a pending file change, shown instead of Details
+	Diff string
+}
+
+// SessionItem is a saved session shown in the session picker
+type SessionItem struct {
+	ID           string
+	Title        string
+	UpdatedAt    time.Time
+	MessageCount int
+}
+
+// SessionPicker holds the state of the session picker list
+type SessionPicker struct {
+	Items    []SessionItem
+	Query    string
+	Selected int // Index into the filtered list
+	PageSize int
+	Callback func(id string) // Called with the chosen session ID, or "" when cancelled
+}
+
+// QuestionOption is one choice of a question
+type QuestionOption struct {
+	Label       string
+	Description string
+}
+
+// QuestionItem is a multiple-choice question asked by the agent
+type QuestionItem struct {
+	Header      string
+	Question    string
+	Options     []QuestionOption
+	MultiSelect bool
 }
 
+// QuestionDialog holds the state of the question dialog. Questions are
+// answered one after another; the callback runs once all are answered.
+type QuestionDialog struct {
+	Questions []QuestionItem
+	Current   int          // Index of the question being answered
+	Selected  int          // Highlighted option
+	Checked   map[int]bool // Toggled options of a multi-select question
+	Answers   [][]string   // Chosen labels, one entry per answered question
+	Callback  func(answers [][]string) // Receives nil when cancelled
+}
+
+// Minimum terminal size below which the layout is replaced by a notice
+const (
+	minTerminalWidth  = 20
+	minTerminalHeight = 8
+)
+
+// minMarkdownWidth is the narrowest text width rendered as markdown; below
+// it assistant text is shown as plain text
+const minMarkdownWidth = 30
+
 // AppState represents the current state of the application
 type AppState int
 
@@ -102,7 +183,10 @@ const (
 	StateConfirm
 	StateHelp
 	StateError
-	StateSelect // Selection mode for copying text
+	StateSelect         // Selection mode for copying text
+	StateSessionPicker  // Picking a saved session to load
+	StateQuestion       // Answering questions from the agent
+	StateCommandPalette // Typing a slash command with the palette open
 )
 
 // Model is the main application model for BubbleTea
@@ -115,6 +199,7 @@ type Model struct {
 	// Messages
 	messages    []Message
 	currentTool *ToolExecution
+	formatters  map[string]ToolFormatter // Custom result rendering by tool name
 
 	// State
 	state       AppState
@@ -123,7 +208,15 @@ type Model struct {
 	version     string
 	workDir     string
 	tokens      TokenStats
+	rateLimit   string // Rate limit warning shown in the status bar ("" when not near a limit)
+	retryInfo   string // API retry notice shown in the status bar ("" when not retrying)
+	contextWarning string // Set while the context is near the compaction threshold; colors the token count
+	cost        float64 // Estimated session cost in USD shown in the status bar (0 = hidden)
 	confirmDialog *ConfirmAction
+	confirmEditor  textarea.Model // Editor for the tool input in the confirm dialog
+	confirmEditing bool
+	sessionPicker *SessionPicker
+	questionDialog *QuestionDialog
 
 	// UI state
 	width           int
@@ -134,6 +227,14 @@ type Model struct {
 	isStreaming     bool
 	selectMode      bool   // Selection mode for copying
 	copyMessage     string // Temporary message for copy feedback
+	scrollPause     bool   // Pause auto-scroll while the user reads earlier output
+	followBottom    bool   // Viewport follows new output
+	showThinking    bool   // Expand finished thinking blocks
+	toolHeaders     []toolHeader // Rendered tool headers, to toggle them on click
+
+	// Markdown renderer for finalized assistant text, rebuilt when the width changes
+	markdown      *MarkdownRenderer
+	markdownWidth int
 
 	// Input history
 	inputHistory []string
@@ -141,7 +242,8 @@ type Model struct {
 	savedInput   string
 
 	// Theme
-	theme *Theme
+	theme  *Theme
+	styles *styles // Derived from theme
 
 	// Channel for agent events
 	eventChan chan AgentEvent
@@ -149,6 +251,20 @@ type Model struct {
 	// Callback for sending messages to agent
 	sendCallback func(msg string) error
 
+	// Callback that cancels the running agent turn (Ctrl+C)
+	cancelCallback func()
+
+	// Slash commands offered by the command palette
+	commands   []Command
+	palette    CommandPalette
+	swallowKey bool // The palette handled the key; keep it from the input
+
+	// Prompt sent automatically at startup (optional)
+	initialPrompt string
+
+	// When Ctrl+C was last pressed; a second press within QuitPressWindow quits
+	quitArmedAt time.Time
+
 	// Quit signal
 	quitting bool
 }
@@ -166,6 +282,18 @@ const (
 	AgentEventTokenUpdate
 	AgentEventCompaction
 	AgentEventConfirmRequest
+	AgentEventSessionPicker
+	AgentEventModelChange
+	AgentEventThinking
+	AgentEventQuestion
+	AgentEventRateLimit
+	AgentEventCostUpdate
+	AgentEventRetry
+	AgentEventAPIRetry
+	AgentEventStreamRetry
+	AgentEventHistoryRestored
+	AgentEventToolProgress
+	AgentEventContextWarning
 )
 
 // AgentEvent represents an event from the agent
@@ -179,51 +307,65 @@ type AgentEvent struct {
 	IsError        bool
 	Error          error
 	Agent          string
+	Model          string
 	Tokens         TokenStats
 	CompactionInfo string
+	RateLimitInfo  string
+	RetryInfo      string
+	Cost           float64
 	ConfirmAction  *ConfirmAction
+	SessionPicker  *SessionPicker
+	QuestionDialog *QuestionDialog
+	History        []Message // Replaces the displayed conversation (AgentEventHistoryRestored)
+	Progress       *ToolProgress
 }
 
 // Theme defines the color scheme
 type Theme struct {
-	Name string
+	Name string `json:"name"`
+	Base string `json:"base"` // "dark" or "light": the markdown style and the defaults for unset colors
 
 	// Background and foreground
-	Background lipgloss.Color
-	Foreground lipgloss.Color
+	Background lipgloss.Color `json:"background"`
+	Foreground lipgloss.Color `json:"foreground"`
+	Surface    lipgloss.Color `json:"surface"` // Header and status bar background
 
 	// Accent colors
-	Primary   lipgloss.Color
-	Secondary lipgloss.Color
-	Accent    lipgloss.Color
+	Primary   lipgloss.Color `json:"primary"`
+	Secondary lipgloss.Color `json:"secondary"`
+	Accent    lipgloss.Color `json:"accent"`
 
 	// Status colors
-	Success lipgloss.Color
-	Warning lipgloss.Color
-	Error   lipgloss.Color
-	Info    lipgloss.Color
+	Success lipgloss.Color `json:"success"`
+	Warning lipgloss.Color `json:"warning"`
+	Error   lipgloss.Color `json:"error"`
+	Info    lipgloss.Color `json:"info"`
 
 	// Agent colors
-	BuildAgent   lipgloss.Color
-	PlanAgent    lipgloss.Color
-	ExploreAgent lipgloss.Color
+	BuildAgent   lipgloss.Color `json:"build_agent"`
+	PlanAgent    lipgloss.Color `json:"plan_agent"`
+	ExploreAgent lipgloss.Color `json:"explore_agent"`
 
 	// Border colors
-	Border    lipgloss.Color
-	BorderDim lipgloss.Color
+	Border    lipgloss.Color `json:"border"`
+	BorderDim lipgloss.Color `json:"border_dim"`
 
 	// Text colors
-	TextPrimary   lipgloss.Color
-	TextSecondary lipgloss.Color
-	TextDim       lipgloss.Color
+	TextPrimary   lipgloss.Color `json:"text_primary"`
+	TextSecondary lipgloss.Color `json:"text_secondary"`
+	TextMuted     lipgloss.Color `json:"text_muted"` // Thinking blocks
+	TextDim       lipgloss.Color `json:"text_dim"`
+	TextInverse   lipgloss.Color `json:"text_inverse"` // Text on primary and agent colored backgrounds
 }
 
 // DefaultTheme returns the default dark theme
 func DefaultTheme() *Theme {
 	return &Theme{
 		Name:          "dark",
+		Base:          "dark",
 		Background:    lipgloss.Color("#0D1117"),
 		Foreground:    lipgloss.Color("#C9D1D9"),
+		Surface:       lipgloss.Color("#161B22"),
 		Primary:       lipgloss.Color("#58A6FF"),
 		Secondary:     lipgloss.Color("#8B949E"),
 		Accent:        lipgloss.Color("#F78166"),
@@ -238,6 +380,24 @@ func DefaultTheme() *Theme {
 		BorderDim:     lipgloss.Color("#21262D"),
 		TextPrimary:   lipgloss.Color("#C9D1D9"),
 		TextSecondary: lipgloss.Color("#8B949E"),
+		TextMuted:     lipgloss.Color("#6E7681"),
 		TextDim:       lipgloss.Color("#484F58"),
+		TextInverse:   lipgloss.Color("#FFFFFF"),
+	}
+}
+
+// Filtered returns the items matching the current query (case-insensitive)
+func (p *SessionPicker) Filtered() []SessionItem {
+	if p.Query == "" {
+		return p.Items
+	}
+
+	query := strings.ToLower(p.Query)
+	var result []SessionItem
+	for _, item := range p.Items {
+		if strings.Contains(strings.ToLower(item.Title), query) || strings.Contains(strings.ToLower(item.ID), query) {
+			result = append(result, item)
+		}
 	}
+	return result
 }
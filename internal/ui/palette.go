@@ -0,0 +1,109 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Command is a slash command offered by the command palette
+type Command struct {
+	Name        string          // Including the slash, e.g. "/model"
+	Description string          // One line shown next to the name
+	Args        func() []string // Argument completions, e.g. agent names (nil = none)
+}
+
+// CommandPalette holds the state of the slash-command palette, which opens
+// while the input is a single line starting with "/"
+type CommandPalette struct {
+	Selected  int
+	query     string // Input the selection was made for
+	dismissed string // Input the palette was closed on with Esc
+}
+
+// paletteItem is a completion shown in the palette
+type paletteItem struct {
+	Value       string // Input the item completes to
+	Label       string
+	Description string
+}
+
+// maxPaletteItems bounds the items the palette shows at once
+const maxPaletteItems = 8
+
+// paletteItems returns the commands, or the arguments of the command typed
+// so far, that fuzzily match the input, best match first
+func paletteItems(commands []Command, input string) []paletteItem {
+	name, arg, hasArg := strings.Cut(input, " ")
+
+	var items []paletteItem
+	var scores []int
+	if !hasArg {
+		for _, c := range commands {
+			if score, ok := fuzzyScore(strings.TrimPrefix(name, "/"), strings.TrimPrefix(c.Name, "/")); ok {
+				items = append(items, paletteItem{Value: c.Name, Label: c.Name, Description: c.Description})
+				scores = append(scores, score)
+			}
+		}
+	} else {
+		for _, c := range commands {
+			if !strings.EqualFold(c.Name, name) || c.Args == nil {
+				continue
+			}
+			for _, a := range c.Args() {
+				if score, ok := fuzzyScore(strings.TrimSpace(arg), a); ok {
+					items = append(items, paletteItem{Value: c.Name + " " + a, Label: a, Description: c.Name})
+					scores = append(scores, score)
+				}
+			}
+		}
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] < scores[order[j]] })
+	sorted := make([]paletteItem, len(items))
+	for i, idx := range order {
+		sorted[i] = items[idx]
+	}
+	return sorted
+}
+
+// fuzzyScore reports whether the letters of query appear in candidate in
+// order (case-insensitive) and scores the match: lower is better, with
+// prefixes and tight matches first
+func fuzzyScore(query, candidate string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	q := []rune(strings.ToLower(query))
+	c := []rune(strings.ToLower(candidate))
+
+	score, qi, last := 0, 0, -1
+	for ci := 0; ci < len(c) && qi < len(q); ci++ {
+		if c[ci] != q[qi] {
+			continue
+		}
+		if last < 0 {
+			score += ci * 2 // Late first match
+		} else {
+			score += ci - last - 1 // Gap since the previous match
+		}
+		last = ci
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// paletteInput reports whether input should open the palette: one line
+// starting with "/"
+func paletteInput(input string) bool {
+	return strings.HasPrefix(input, "/") && !strings.ContainsFunc(input, func(r rune) bool {
+		return r == '\n' || (unicode.IsSpace(r) && r != ' ')
+	})
+}
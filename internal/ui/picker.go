@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// PickerItem is a selectable entry in a startup picker
+type PickerItem struct {
+	Value       string
+	Label       string
+	Description string
+}
+
+// pickerModel is a minimal single-choice list shown before the main UI starts
+type pickerModel struct {
+	title    string
+	items    []PickerItem
+	selected int
+	chosen   string
+	done     bool
+	styles   *styles
+}
+
+func (p *pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (p *pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+
+	switch key.String() {
+	case "up", "k":
+		if p.selected > 0 {
+			p.selected--
+		}
+	case "down", "j":
+		if p.selected < len(p.items)-1 {
+			p.selected++
+		}
+	case "enter":
+		p.chosen = p.items[p.selected].Value
+		p.done = true
+		return p, tea.Quit
+	case "esc", "ctrl+c", "q":
+		p.done = true
+		return p, tea.Quit
+	}
+	return p, nil
+}
+
+func (p *pickerModel) View() string {
+	if p.done {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(p.styles.dialogTitle.Render(p.title))
+	b.WriteString("\n\n")
+	for i, item := range p.items {
+		line := fmt.Sprintf("%-28s %s", item.Label, p.styles.dim.Render(item.Description))
+		if i == p.selected {
+			b.WriteString(p.styles.cursor.Render("> ") + line)
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(p.styles.dim.Render("↑ ↓ Select | Enter Choose | Esc Keep default"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// RunPicker shows a selectable list and returns the chosen value.
+// It returns "" when the user cancels; current preselects the matching item.
+func RunPicker(title string, items []PickerItem, current string) (string, error) {
+	if len(items) == 0 {
+		return "", nil
+	}
+
+	p := &pickerModel{title: title, items: items, styles: newStyles(DefaultTheme())}
+	for i, item := range items {
+		if item.Value == current {
+			p.selected = i
+			break
+		}
+	}
+
+	if _, err := tea.NewProgram(p).Run(); err != nil {
+		return "", err
+	}
+	return p.chosen, nil
+}
+
+// PickFromList prints a numbered list and reads the user's choice.
+// It returns "" when the user presses Enter without choosing.
+func (t *Terminal) PickFromList(title string, items []PickerItem, current string) (string, error) {
+	if len(items) == 0 {
+		return "", nil
+	}
+
+	fmt.Println()
+	fmt.Println(title)
+	for i, item := range items {
+		marker := " "
+		if item.Value == current {
+			marker = "*"
+		}
+		fmt.Printf(" %s%2d. %-28s %s\n", marker, i+1, item.Label, item.Description)
+	}
+
+	for {
+		fmt.Print("Number to choose, Enter to keep the default: ")
+		line, err := t.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if line == "" {
+			return "", nil
+		}
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 1 || n > len(items) {
+			t.PrintWarning(fmt.Sprintf("Invalid choice: %s", line))
+			continue
+		}
+		return items[n-1].Value, nil
+	}
+}
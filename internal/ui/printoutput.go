@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// printInputChars caps the tool input shown on an activity line
+const printInputChars = 120
+
+// PrintWriter implements print mode (-p): only the final assistant response
+// goes to the output, so it can be piped into other commands. Tool activity
+// goes to a separate writer, usually stderr.
+type PrintWriter struct {
+	out      io.Writer
+	activity io.Writer       // Where tool calls are reported (nil = quiet)
+	text     strings.Builder // Text since the last tool call
+	mu       sync.Mutex
+}
+
+// NewPrintWriter creates a print mode writer and turns off colored output.
+// activity may be nil to report no tool calls.
+func NewPrintWriter(out, activity io.Writer) *PrintWriter {
+	color.NoColor = true
+	return &PrintWriter{out: out, activity: activity}
+}
+
+// Text adds streamed response text
+func (w *PrintWriter) Text(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.text.WriteString(text)
+}
+
+// ToolStart starts a new response: only the text after the last tool call
+// is the final answer
+func (w *PrintWriter) ToolStart() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.text.Reset()
+}
+
+// ToolEnd reports a finished tool call on the activity writer
+func (w *PrintWriter) ToolEnd(toolName, input, result string, isError bool) {
+	if w.activity == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := "→ " + toolName
+	if input != "" {
+		line += " " + truncateChars(strings.Join(strings.Fields(input), " "), printInputChars)
+	}
+	fmt.Fprintln(w.activity, line)
+	if isError {
+		first, _, _ := strings.Cut(strings.TrimSpace(result), "\n")
+		fmt.Fprintln(w.activity, "  ✗ "+truncateChars(first, printInputChars))
+	}
+}
+
+// Info reports a notice, such as a retry, on the activity writer
+func (w *PrintWriter) Info(msg string) {
+	if w.activity == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintln(w.activity, msg)
+}
+
+// Finish writes the final response to the output
+func (w *PrintWriter) Finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if text := strings.TrimSpace(w.text.String()); text != "" {
+		fmt.Fprintln(w.out, text)
+	}
+}
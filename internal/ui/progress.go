@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// progressBarWidth is the number of cells in a progress bar
+const progressBarWidth = 20
+
+// ToolProgress is how far a running tool has got with a long operation
+type ToolProgress struct {
+	Done  int64
+	Total int64  // 0 = unknown
+	Unit  string // What Done counts, e.g. "bytes"
+}
+
+// String formats the progress as a bar with the percentage and amounts, or
+// just the amount done when the total is unknown
+func (p ToolProgress) String() string {
+	if p.Total <= 0 {
+		return formatAmount(p.Done, p.Unit)
+	}
+
+	frac := float64(p.Done) / float64(p.Total)
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * progressBarWidth)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+	return fmt.Sprintf("[%s] %3.0f%% %s / %s", bar, frac*100, formatAmount(p.Done, p.Unit), formatAmount(p.Total, p.Unit))
+}
+
+// formatAmount formats n, with byte counts in KB or MB
+func formatAmount(n int64, unit string) string {
+	if unit != "bytes" {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
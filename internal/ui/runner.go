@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/anthropics/claude-code-go/internal/api"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -102,6 +103,14 @@ func (a *AgentEventAdapter) OnText(text string) {
 	}
 }
 
+// OnThinking handles extended thinking events
+func (a *AgentEventAdapter) OnThinking(text string) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventThinking,
+		Text: text,
+	}
+}
+
 // OnToolStart handles tool start events
 func (a *AgentEventAdapter) OnToolStart(name, id, input string) {
 	a.eventChan <- AgentEvent{
@@ -112,17 +121,27 @@ func (a *AgentEventAdapter) OnToolStart(name, id, input string) {
 	}
 }
 
-// OnToolEnd handles tool end events
-func (a *AgentEventAdapter) OnToolEnd(name, id, output string, isError bool) {
+// OnToolEnd handles tool end events; input is the call's final input (JSON)
+func (a *AgentEventAdapter) OnToolEnd(name, id, input, output string, isError bool) {
 	a.eventChan <- AgentEvent{
 		Type:       AgentEventToolEnd,
 		ToolName:   name,
 		ToolID:     id,
+		ToolInput:  input,
 		ToolOutput: output,
 		IsError:    isError,
 	}
 }
 
+// OnToolProgress shows how far the running tool id has got
+func (a *AgentEventAdapter) OnToolProgress(id string, done, total int64, unit string) {
+	a.eventChan <- AgentEvent{
+		Type:     AgentEventToolProgress,
+		ToolID:   id,
+		Progress: &ToolProgress{Done: done, Total: total, Unit: unit},
+	}
+}
+
 // OnError handles error events
 func (a *AgentEventAdapter) OnError(err error) {
 	a.eventChan <- AgentEvent{
@@ -146,6 +165,14 @@ func (a *AgentEventAdapter) OnAgentSwitch(agent string) {
 	}
 }
 
+// OnModelChange handles model switch events
+func (a *AgentEventAdapter) OnModelChange(model string) {
+	a.eventChan <- AgentEvent{
+		Type:  AgentEventModelChange,
+		Model: model,
+	}
+}
+
 // OnTokenUpdate handles token update events
 func (a *AgentEventAdapter) OnTokenUpdate(input, output, cacheRead, cacheWrite int) {
 	a.eventChan <- AgentEvent{
@@ -160,6 +187,64 @@ func (a *AgentEventAdapter) OnTokenUpdate(input, output, cacheRead, cacheWrite i
 	}
 }
 
+// OnRateLimit shows a rate limit warning in the status bar; an empty info clears it
+func (a *AgentEventAdapter) OnRateLimit(info string) {
+	a.eventChan <- AgentEvent{
+		Type:          AgentEventRateLimit,
+		RateLimitInfo: info,
+	}
+}
+
+// OnContextWarning shows that the context is nearing the compaction
+// threshold, or clears the warning when info is empty
+func (a *AgentEventAdapter) OnContextWarning(info string) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventContextWarning,
+		Text: info,
+	}
+}
+
+// OnAPIRetry shows a pending API retry in the status bar until the next
+// response arrives
+func (a *AgentEventAdapter) OnAPIRetry(info string) {
+	a.eventChan <- AgentEvent{
+		Type:      AgentEventAPIRetry,
+		RetryInfo: info,
+	}
+}
+
+// OnCostUpdate shows the estimated session cost in USD next to the token count
+func (a *AgentEventAdapter) OnCostUpdate(cost float64) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventCostUpdate,
+		Cost: cost,
+	}
+}
+
+// OnRetry removes the last response from the display before it is regenerated
+func (a *AgentEventAdapter) OnRetry() {
+	a.eventChan <- AgentEvent{Type: AgentEventRetry}
+}
+
+// OnStreamRetry removes the partial output of a response that broke off
+// mid-stream and notes why
+func (a *AgentEventAdapter) OnStreamRetry(note string) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventStreamRetry,
+		Text: note,
+	}
+}
+
+// OnHistoryRestored replaces the displayed conversation with history, e.g.
+// after going back to a checkpoint, and notes why
+func (a *AgentEventAdapter) OnHistoryRestored(history []api.Message, note string) {
+	a.eventChan <- AgentEvent{
+		Type:    AgentEventHistoryRestored,
+		Text:    note,
+		History: HistoryMessages(history),
+	}
+}
+
 // OnCompaction handles compaction events
 func (a *AgentEventAdapter) OnCompaction(info string) {
 	a.eventChan <- AgentEvent{
@@ -182,6 +267,61 @@ func (a *AgentEventAdapter) OnConfirmRequest(title, message, details string, cal
 	}
 }
 
+// OnDiffConfirmRequest asks the user to approve a file change shown as a diff
+func (a *AgentEventAdapter) OnDiffConfirmRequest(title, message, diff string, callback func(string)) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventConfirmRequest,
+		ConfirmAction: &ConfirmAction{
+			Title:    title,
+			Message:  message,
+			Diff:     diff,
+			Options:  []string{"Allow", "Deny", "Allow Always"},
+			Callback: callback,
+		},
+	}
+}
+
+// OnApprovalRequest asks the user to approve a tool call whose input can be
+// edited first; callback receives the choice and the edited input ("" if unchanged)
+func (a *AgentEventAdapter) OnApprovalRequest(title, message, input string, callback func(result, input string)) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventConfirmRequest,
+		ConfirmAction: &ConfirmAction{
+			Title:         title,
+			Message:       message,
+			Details:       prettyJSON(input),
+			Options:       []string{"Allow", "Deny", "Allow Always"},
+			Input:         input,
+			InputCallback: callback,
+		},
+	}
+}
+
+// OnQuestion shows the question dialog. onAnswer receives the chosen option
+// labels for each question, or nil if the user cancelled.
+func (a *AgentEventAdapter) OnQuestion(questions []QuestionItem, onAnswer func(answers [][]string)) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventQuestion,
+		QuestionDialog: &QuestionDialog{
+			Questions: questions,
+			Checked:   make(map[int]bool),
+			Callback:  onAnswer,
+		},
+	}
+}
+
+// OnSessionPicker opens the session picker; onSelect receives the chosen ID or "" if cancelled
+func (a *AgentEventAdapter) OnSessionPicker(items []SessionItem, onSelect func(id string)) {
+	a.eventChan <- AgentEvent{
+		Type: AgentEventSessionPicker,
+		SessionPicker: &SessionPicker{
+			Items:    items,
+			PageSize: 10,
+			Callback: onSelect,
+		},
+	}
+}
+
 // SimpleTUI provides a simple interface for running the TUI without full integration
 type SimpleTUI struct {
 	runner  *TUIRunner
@@ -204,6 +344,36 @@ func (s *SimpleTUI) SetMessageHandler(handler func(msg string) error) {
 	s.runner.SetSendCallback(handler)
 }
 
+// SetCommands sets the slash commands the command palette offers
+func (s *SimpleTUI) SetCommands(commands []Command) {
+	s.runner.model.SetCommands(commands)
+}
+
+// SetCancelHandler sets the function called when the user cancels a running turn
+func (s *SimpleTUI) SetCancelHandler(handler func()) {
+	s.runner.model.SetCancelCallback(handler)
+}
+
+// SetInitialPrompt sets a prompt that is sent automatically when the TUI starts
+func (s *SimpleTUI) SetInitialPrompt(prompt string) {
+	s.runner.model.SetInitialPrompt(prompt)
+}
+
+// SetTheme sets the color scheme
+func (s *SimpleTUI) SetTheme(theme *Theme) {
+	s.runner.model.SetTheme(theme)
+}
+
+// SetToolFormatters sets the formatters that render tool results, by tool name
+func (s *SimpleTUI) SetToolFormatters(formatters map[string]ToolFormatter) {
+	s.runner.model.SetToolFormatters(formatters)
+}
+
+// SetScrollPause enables or disables pausing auto-scroll when the user scrolls up
+func (s *SimpleTUI) SetScrollPause(enabled bool) {
+	s.runner.model.SetScrollPause(enabled)
+}
+
 // GetAdapter returns the event adapter
 func (s *SimpleTUI) GetAdapter() *AgentEventAdapter {
 	return s.adapter
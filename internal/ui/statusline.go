@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// StatusLine shows a single line, redrawn in place, with the running tool
+// and its elapsed time. Unlike the Spinner it is meant to sit under output
+// that has already been printed and is cleared without a trace.
+type StatusLine struct {
+	interval time.Duration
+	label    string
+	detail   string // Progress shown after the label ("" = none)
+	start    time.Time
+	running  bool
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	mu       sync.Mutex
+}
+
+// NewStatusLine creates a new status line
+func NewStatusLine() *StatusLine {
+	return &StatusLine{
+		interval: time.Second,
+	}
+}
+
+// Start shows the status line for the given label, replacing any current one
+func (s *StatusLine) Start(label string) {
+	s.Stop()
+
+	// In-place updates only make sense on a terminal
+	if !isTerminal(os.Stdout) {
+		return
+	}
+
+	s.mu.Lock()
+	s.label = label
+	s.detail = ""
+	s.start = time.Now()
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.draw()
+	go s.run(s.stopCh, s.doneCh)
+}
+
+// SetDetail shows detail, e.g. the tool's progress, from the next redraw
+func (s *StatusLine) SetDetail(detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detail = detail
+}
+
+// Stop clears the status line
+func (s *StatusLine) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	doneCh := s.doneCh
+	s.mu.Unlock()
+
+	// Wait for the last redraw so it cannot land after the clear
+	<-doneCh
+	fmt.Print("\r\033[K")
+}
+
+func (s *StatusLine) run(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			s.draw()
+		}
+	}
+}
+
+func (s *StatusLine) draw() {
+	s.mu.Lock()
+	label := s.label
+	if s.detail != "" {
+		label += " " + s.detail
+	}
+	elapsed := time.Since(s.start)
+	s.mu.Unlock()
+
+	line := fmt.Sprintf("⏵ %s running · %ds · Ctrl+C to cancel", label, int(elapsed.Seconds()))
+	fmt.Print("\r\033[K" + DimColor.Sprint(line))
+}
+
+// isTerminal reports whether f is attached to a character device
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/anthropics/claude-code-go/internal/api"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/fatih/color"
 )
@@ -47,23 +48,54 @@ var (
 			Padding(0, 1)
 )
 
+// stdinReader is shared by all terminals so buffered input is never lost
+// when more than one Terminal reads from stdin (e.g. startup pickers)
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// ToolDisplayOptions controls how tool results are printed
+type ToolDisplayOptions struct {
+	MaxChars  int  // Maximum characters of output (<= 0 = unlimited)
+	MaxLines  int  // Maximum lines of output (<= 0 = unlimited)
+	ShowInput  bool // Print the tool input before the result
+	Color      bool // Style output with colors
+	StatusLine bool // Show a live status line while a tool runs
+}
+
+// DefaultToolDisplayOptions returns the default tool display settings
+func DefaultToolDisplayOptions() ToolDisplayOptions {
+	return ToolDisplayOptions{
+		MaxChars: 500,
+		Color:    true,
+	}
+}
+
 // Terminal handles terminal I/O and rendering
 type Terminal struct {
 	reader    *bufio.Reader
 	markdown  *MarkdownRenderer
 	spinner   *Spinner
+	status    *StatusLine
 	isStreaming bool
+	isThinking  bool
+	toolDisplay ToolDisplayOptions
 }
 
 // NewTerminal creates a new terminal UI
 func NewTerminal() *Terminal {
 	return &Terminal{
-		reader:   bufio.NewReader(os.Stdin),
-		markdown: NewMarkdownRenderer(),
-		spinner:  NewSpinner(),
+		reader:      stdinReader,
+		markdown:    NewMarkdownRenderer(),
+		spinner:     NewSpinner(),
+		status:      NewStatusLine(),
+		toolDisplay: DefaultToolDisplayOptions(),
 	}
 }
 
+// SetToolDisplay sets how tool results are printed
+func (t *Terminal) SetToolDisplay(opts ToolDisplayOptions) {
+	t.toolDisplay = opts
+}
+
 // PrintWelcome prints the welcome message
 func (t *Terminal) PrintWelcome() {
 	fmt.Println()
@@ -116,8 +148,27 @@ func (t *Terminal) PrintLine(text string) {
 	fmt.Println(text)
 }
 
+// PrintThinking prints extended thinking text (streaming) dimmed
+func (t *Terminal) PrintThinking(text string) {
+	if !t.isThinking {
+		t.isThinking = true
+		fmt.Println()
+		DimColor.Println("Thinking:")
+	}
+	DimColor.Print(text)
+}
+
+// endThinking closes a thinking section before other output
+func (t *Terminal) endThinking() {
+	if t.isThinking {
+		fmt.Println()
+		t.isThinking = false
+	}
+}
+
 // PrintAssistantText prints assistant text (streaming)
 func (t *Terminal) PrintAssistantText(text string) {
+	t.endThinking()
 	if !t.isStreaming {
 		t.isStreaming = true
 		fmt.Println()
@@ -128,6 +179,7 @@ func (t *Terminal) PrintAssistantText(text string) {
 
 // EndAssistantResponse ends the assistant response
 func (t *Terminal) EndAssistantResponse() {
+	t.endThinking()
 	if t.isStreaming {
 		fmt.Println()
 		fmt.Println()
@@ -143,36 +195,90 @@ func (t *Terminal) PrintMarkdown(text string) {
 
 // PrintToolStart prints the start of a tool execution
 func (t *Terminal) PrintToolStart(toolName, toolID string) {
+	t.endThinking()
 	fmt.Println()
 	fmt.Printf("%s %s\n", ToolNameStyle.Render("▶"), ToolNameStyle.Render(toolName))
 }
 
+// StartToolStatus shows the live status line for a running tool, if enabled
+func (t *Terminal) StartToolStatus(toolName string) {
+	if t.toolDisplay.StatusLine {
+		t.status.Start(toolName)
+	}
+}
+
+// UpdateToolStatus shows the running tool's progress in the status line
+func (t *Terminal) UpdateToolStatus(done, total int64, unit string) {
+	t.status.SetDetail(ToolProgress{Done: done, Total: total, Unit: unit}.String())
+}
+
+// StopToolStatus clears the live status line
+func (t *Terminal) StopToolStatus() {
+	t.status.Stop()
+}
+
 // PrintToolEnd prints the end of a tool execution
-func (t *Terminal) PrintToolEnd(toolName string, result string, isError bool) {
+func (t *Terminal) PrintToolEnd(toolName, input, result string, isError bool) {
+	t.status.Stop()
+	opts := t.toolDisplay
+
+	if opts.ShowInput && input != "" {
+		line := "Input: " + truncateChars(input, opts.MaxChars)
+		if opts.Color {
+			fmt.Println(ToolResultStyle.Render(line))
+		} else {
+			fmt.Println("  " + line)
+		}
+	}
+
 	// Truncate long results
-	maxLen := 500
-	if len(result) > maxLen {
-		result = result[:maxLen] + "... (truncated)"
+	result = truncateChars(result, opts.MaxChars)
+	lines := strings.Split(result, "\n")
+	if opts.MaxLines > 0 && len(lines) > opts.MaxLines {
+		hidden := len(lines) - opts.MaxLines
+		lines = append(lines[:opts.MaxLines], fmt.Sprintf("... (%d more lines)", hidden))
 	}
 
 	if isError {
-		fmt.Println(ErrorStyle.Render("  ✗ Error: " + result))
+		msg := "  ✗ Error: " + strings.Join(lines, "\n")
+		if opts.Color {
+			fmt.Println(ErrorStyle.Render(msg))
+		} else {
+			fmt.Println(msg)
+		}
 	} else {
 		// Print result in dimmed style
-		lines := strings.Split(result, "\n")
 		for _, line := range lines {
-			if line != "" {
+			if line == "" {
+				continue
+			}
+			if opts.Color {
 				fmt.Println(ToolResultStyle.Render(line))
+			} else {
+				fmt.Println("  " + line)
 			}
 		}
 	}
 	fmt.Println()
 }
 
+// truncateChars shortens s to maxChars runes (<= 0 = unlimited)
+func truncateChars(s string, maxChars int) string {
+	if maxChars <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= maxChars {
+		return s
+	}
+	return string(runes[:maxChars]) + "... (truncated)"
+}
+
 // PrintError prints an error message
 func (t *Terminal) PrintError(err error) {
+	t.status.Stop()
 	fmt.Println()
-	ErrorColor.Printf("Error: %s\n", err.Error())
+	ErrorColor.Printf("Error: %s\n", api.DescribeError(err))
 	fmt.Println()
 }
 
@@ -211,6 +317,23 @@ func (t *Terminal) PrintBox(title, content string) {
 	fmt.Println(BoxStyle.Render(content))
 }
 
+// PrintDiff prints a unified diff, coloring added and removed lines
+func (t *Terminal) PrintDiff(diff string) {
+	t.status.Stop()
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case !t.toolDisplay.Color, strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Println(line)
+		case strings.HasPrefix(line, "+"):
+			color.New(color.FgGreen).Println(line)
+		case strings.HasPrefix(line, "-"):
+			color.New(color.FgRed).Println(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
 // StartSpinner starts the loading spinner
 func (t *Terminal) StartSpinner(message string) {
 	t.spinner.Start(message)
@@ -239,6 +362,27 @@ Commands:
   /clear    - Clear the conversation history
   /exit     - Exit the program
   /quit     - Same as /exit
+  /model    - Show or switch the model (/model <id>)
+  /agent    - Show or switch the primary agent (/agent <name>)
+  /agents   - List the available agents
+  /pwd      - Show the Bash working directory (/pwd reset to return to the project)
+  /retry    - Regenerate the last response
+  /cost     - Show the estimated session cost
+  /stats    - Show turns, tool calls, tool time and the files changed
+  /output   - List truncated tool outputs, or show one (/output <call id>)
+  /image    - Attach image files to your next message (/image <path>..., /image clear)
+  /export   - Save the conversation as Markdown or HTML (/export <file.md|file.html>)
+  /perms    - Show the current agent's permission rules
+  /sessions - Browse and load saved sessions
+  /resume   - Resume a saved session (/resume <id>, or latest for this directory)
+  /pin      - Pin a note that survives compaction (/pin alone lists pins)
+  /unpin    - Remove a pinned note by number, or all
+  /summary  - Summarize the conversation so far (history is unchanged)
+  /compact  - Compact the conversation now (/compact keep=N keeps the last N exchanges)
+  /undo     - Revert the last file change made by a tool (/undo N reverts the last N)
+  /checkpoint  - Save the conversation as a named checkpoint (/checkpoint <name>)
+  /goto        - Go back to a checkpoint, dropping later messages (/goto <name>)
+  /checkpoints - List the saved checkpoints
 
 Tips:
   - Type your message and press Enter to send
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LightTheme returns a theme for terminals with a light background
+func LightTheme() *Theme {
+	return &Theme{
+		Name:          "light",
+		Base:          "light",
+		Background:    lipgloss.Color("#FFFFFF"),
+		Foreground:    lipgloss.Color("#1F2328"),
+		Surface:       lipgloss.Color("#F6F8FA"),
+		Primary:       lipgloss.Color("#0969DA"),
+		Secondary:     lipgloss.Color("#656D76"),
+		Accent:        lipgloss.Color("#BC4C00"),
+		Success:       lipgloss.Color("#1A7F37"),
+		Warning:       lipgloss.Color("#9A6700"),
+		Error:         lipgloss.Color("#CF222E"),
+		Info:          lipgloss.Color("#0969DA"),
+		BuildAgent:    lipgloss.Color("#0969DA"),
+		PlanAgent:     lipgloss.Color("#8250DF"),
+		ExploreAgent:  lipgloss.Color("#1A7F37"),
+		Border:        lipgloss.Color("#D0D7DE"),
+		BorderDim:     lipgloss.Color("#EAEEF2"),
+		TextPrimary:   lipgloss.Color("#1F2328"),
+		TextSecondary: lipgloss.Color("#656D76"),
+		TextMuted:     lipgloss.Color("#6E7781"),
+		TextDim:       lipgloss.Color("#8C959F"),
+		TextInverse:   lipgloss.Color("#FFFFFF"),
+	}
+}
+
+// ThemeByName returns the built-in "dark" or "light" theme
+func ThemeByName(name string) (*Theme, error) {
+	switch name {
+	case "", "dark":
+		return DefaultTheme(), nil
+	case "light":
+		return LightTheme(), nil
+	}
+	return nil, fmt.Errorf("unknown theme %q (use \"dark\" or \"light\")", name)
+}
+
+// LoadThemeFile reads a custom theme from a JSON file. Colors left out are
+// taken from the theme named by its "base" field, dark by default.
+func LoadThemeFile(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme: %w", err)
+	}
+	var head struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+	theme, err := ThemeByName(head.Base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, theme); err != nil {
+		return nil, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+	if theme.Name == "dark" || theme.Name == "light" {
+		theme.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return theme, nil
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestSetThemeChangesStyles(t *testing.T) {
+	m := NewModel("test", "build", "model", t.TempDir())
+	dark := m.styles
+
+	m.SetTheme(LightTheme())
+	light := m.styles
+
+	checks := []struct {
+		name        string
+		dark, light lipgloss.TerminalColor
+		want        lipgloss.Color
+	}{
+		{"user label", dark.userLabel.GetForeground(), light.userLabel.GetForeground(), LightTheme().Primary},
+		{"error", dark.errorMessage.GetForeground(), light.errorMessage.GetForeground(), LightTheme().Error},
+		{"status bar", dark.statusBar.GetBackground(), light.statusBar.GetBackground(), LightTheme().Surface},
+		{"input border", dark.inputBorder.GetBorderTopForeground(), light.inputBorder.GetBorderTopForeground(), LightTheme().Border},
+	}
+	for _, c := range checks {
+		if c.light != c.want {
+			t.Errorf("%s: light color = %v, want %v", c.name, c.light, c.want)
+		}
+		if c.dark == c.light {
+			t.Errorf("%s: color %v did not change with the theme", c.name, c.dark)
+		}
+	}
+}
+
+func TestLoadThemeFileOverridesBase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "solar.json")
+	os.WriteFile(path, []byte(`{"base":"light","primary":"#123456"}`), 0644)
+
+	theme, err := LoadThemeFile(path)
+	if err != nil {
+		t.Fatalf("LoadThemeFile: %v", err)
+	}
+	if theme.Name != "solar" || theme.Primary != "#123456" || theme.Error != LightTheme().Error {
+		t.Errorf("theme = %+v, want solar with its primary over the light defaults", theme)
+	}
+	if got := newStyles(theme).userLabel.GetForeground(); got != lipgloss.Color("#123456") {
+		t.Errorf("user label color = %v, want the custom primary", got)
+	}
+}
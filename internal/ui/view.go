@@ -8,96 +8,216 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
-// Styles
-var (
+// styles holds the lipgloss styles of the TUI, derived from a theme
+type styles struct {
+	theme *Theme
+
 	// Header styles
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#C9D1D9")).
-			Background(lipgloss.Color("#161B22")).
-			Padding(0, 1)
+	header lipgloss.Style
 
 	// Message styles
-	userLabelStyle = lipgloss.NewStyle().
+	userLabel      lipgloss.Style
+	assistantLabel lipgloss.Style
+	systemMessage  lipgloss.Style
+	errorMessage   lipgloss.Style
+
+	// Tool styles
+	toolHeader lipgloss.Style
+	toolInput  lipgloss.Style
+	toolOutput lipgloss.Style
+
+	// Diff lines in Edit and Write output
+	diffAdded   lipgloss.Style
+	diffRemoved lipgloss.Style
+
+	// Status bar styles
+	statusBar lipgloss.Style
+	warning   lipgloss.Style
+	success   lipgloss.Style
+
+	// Input area styles
+	inputBorder lipgloss.Style
+
+	// Dialog styles
+	dialog               lipgloss.Style
+	dialogTitle          lipgloss.Style
+	dialogButton         lipgloss.Style
+	dialogButtonSelected lipgloss.Style
+	dialogDetails        lipgloss.Style
+	pickerBorder         lipgloss.Style
+	cursor               lipgloss.Style
+
+	// Help styles
+	helpTitle lipgloss.Style
+	helpKey   lipgloss.Style
+	helpDesc  lipgloss.Style
+	helpBox   lipgloss.Style
+
+	dim      lipgloss.Style
+	thinking lipgloss.Style
+}
+
+// newStyles builds the styles for a theme
+func newStyles(t *Theme) *styles {
+	return &styles{
+		theme: t,
+
+		header: lipgloss.NewStyle().
 			Bold(true).
-			Foreground(lipgloss.Color("#58A6FF"))
+			Foreground(t.TextPrimary).
+			Background(t.Surface).
+			Padding(0, 1),
 
-	assistantLabelStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("#3FB950"))
+		userLabel: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(t.Primary),
 
-	systemMessageStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#8B949E")).
-				Italic(true)
+		assistantLabel: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(t.Success),
 
-	errorMessageStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#F85149")).
-				Bold(true)
+		systemMessage: lipgloss.NewStyle().
+			Foreground(t.TextSecondary).
+			Italic(true),
 
-	// Tool styles
-	toolHeaderStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#C9D1D9"))
+		errorMessage: lipgloss.NewStyle().
+			Foreground(t.Error).
+			Bold(true),
+
+		toolHeader: lipgloss.NewStyle().
+			Foreground(t.TextPrimary),
+
+		toolInput: lipgloss.NewStyle().
+			Foreground(t.TextSecondary).
+			MarginLeft(2),
+
+		toolOutput: lipgloss.NewStyle().
+			Foreground(t.TextSecondary).
+			MarginLeft(2),
 
-	toolInputStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#8B949E")).
-			MarginLeft(2)
+		diffAdded: lipgloss.NewStyle().
+			Foreground(t.Success).
+			MarginLeft(2),
 
-	toolOutputStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#8B949E")).
-			MarginLeft(2)
+		diffRemoved: lipgloss.NewStyle().
+			Foreground(t.Error).
+			MarginLeft(2),
 
-	toolBoxStyle = lipgloss.NewStyle().
+		statusBar: lipgloss.NewStyle().
+			Foreground(t.TextSecondary).
+			Background(t.Surface).
+			Padding(0, 1),
+
+		warning: lipgloss.NewStyle().
+			Foreground(t.Warning),
+
+		success: lipgloss.NewStyle().
+			Foreground(t.Success),
+
+		inputBorder: lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#30363D")).
-			Padding(0, 1).
-			MarginLeft(2)
+			BorderForeground(t.Border).
+			Padding(0, 1),
 
-	// Status bar styles
-	statusBarStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#8B949E")).
-			Background(lipgloss.Color("#161B22")).
-			Padding(0, 1)
+		dialog: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(t.Warning).
+			Padding(1, 2),
 
-	// Input area styles
-	inputBorderStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("#30363D")).
-				Padding(0, 1)
+		dialogTitle: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(t.Warning),
+
+		dialogButton: lipgloss.NewStyle().
+			Padding(0, 2).
+			MarginRight(1).
+			Background(t.Border).
+			Foreground(t.TextSecondary),
+
+		dialogButtonSelected: lipgloss.NewStyle().
+			Padding(0, 2).
+			MarginRight(1).
+			Background(t.Primary).
+			Foreground(t.TextInverse).
+			Bold(true),
+
+		dialogDetails: lipgloss.NewStyle().
+			Background(t.BorderDim).
+			Foreground(t.TextPrimary).
+			Padding(0, 1),
+
+		pickerBorder: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(t.Primary).
+			Padding(1, 2),
 
-	// Dialog styles
-	dialogStyle = lipgloss.NewStyle().
+		cursor: lipgloss.NewStyle().
+			Foreground(t.Primary).
+			Bold(true),
+
+		helpTitle: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(t.Primary),
+
+		helpKey: lipgloss.NewStyle().
+			Foreground(t.Primary).
+			Width(12),
+
+		helpDesc: lipgloss.NewStyle().
+			Foreground(t.TextSecondary),
+
+		helpBox: lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#D29922")).
-			Padding(1, 2)
-
-	dialogTitleStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("#D29922"))
-
-	dialogButtonStyle = lipgloss.NewStyle().
-				Padding(0, 2).
-				MarginRight(1).
-				Background(lipgloss.Color("#30363D")).
-				Foreground(lipgloss.Color("#8B949E"))
-
-	dialogButtonSelectedStyle = lipgloss.NewStyle().
-					Padding(0, 2).
-					MarginRight(1).
-					Background(lipgloss.Color("#58A6FF")).
-					Foreground(lipgloss.Color("#FFFFFF")).
-					Bold(true)
+			BorderForeground(t.Border).
+			Padding(1, 2),
 
-	// Help styles
-	helpKeyStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#58A6FF")).
-			Width(12)
+		dim: lipgloss.NewStyle().
+			Foreground(t.TextDim),
 
-	helpDescStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#8B949E"))
+		thinking: lipgloss.NewStyle().
+			Foreground(t.TextMuted).
+			Italic(true),
+	}
+}
 
-	dimStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#484F58"))
-)
+// toolStatusColor returns the color of a tool's status icon
+func (s *styles) toolStatusColor(status ToolStatus) lipgloss.Color {
+	switch status {
+	case ToolStatusRunning:
+		return s.theme.Primary
+	case ToolStatusSuccess:
+		return s.theme.Success
+	case ToolStatusError:
+		return s.theme.Error
+	}
+	return s.theme.TextSecondary
+}
+
+// agentColor returns the badge color of an agent
+func (s *styles) agentColor(agent string) lipgloss.Color {
+	switch agent {
+	case "build":
+		return s.theme.BuildAgent
+	case "plan":
+		return s.theme.PlanAgent
+	case "explore":
+		return s.theme.ExploreAgent
+	}
+	return s.theme.Secondary
+}
+
+// renderTooSmall renders a placeholder when the terminal is below the minimum size
+func (m *Model) renderTooSmall() string {
+	msg := fmt.Sprintf("Terminal too small (%dx%d)\nNeed at least %dx%d", m.width, m.height, minTerminalWidth, minTerminalHeight)
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		lines[i] = truncateDisplay(line, m.width)
+	}
+	if m.height > 0 && len(lines) > m.height {
+		lines = lines[:m.height]
+	}
+	return strings.Join(lines, "\n")
+}
 
 // renderLayout renders the main layout
 func (m *Model) renderLayout() string {
@@ -114,6 +234,21 @@ func (m *Model) renderLayout() string {
 		sections = append(sections, m.renderConfirmDialog())
 	}
 
+	// Session picker (if visible)
+	if m.state == StateSessionPicker && m.sessionPicker != nil {
+		sections = append(sections, m.renderSessionPicker())
+	}
+
+	// Question dialog (if visible)
+	if m.state == StateQuestion && m.questionDialog != nil {
+		sections = append(sections, m.renderQuestionDialog())
+	}
+
+	// Command palette (if visible)
+	if m.state == StateCommandPalette {
+		sections = append(sections, m.renderCommandPalette())
+	}
+
 	// Help panel (if visible)
 	if m.state == StateHelp {
 		sections = append(sections, m.renderHelpPanel())
@@ -160,15 +295,24 @@ func (m *Model) renderHeader() string {
 		Render(right)
 
 	header := lipgloss.JoinHorizontal(lipgloss.Top, leftStyled, centerStyled, rightStyled)
-	return headerStyle.Width(m.width).Render(header)
+	return m.styles.header.Width(m.width).Render(header)
 }
 
 // renderMessages renders all messages
 func (m *Model) renderMessages() string {
 	var parts []string
-
-	for _, msg := range m.messages {
-		parts = append(parts, m.renderMessage(msg))
+	m.toolHeaders = m.toolHeaders[:0]
+
+	line := 0
+	for i := range m.messages {
+		// renderMessage records its tool headers relative to the message
+		first := len(m.toolHeaders)
+		rendered := m.renderMessage(&m.messages[i], i == len(m.messages)-1)
+		for j := first; j < len(m.toolHeaders); j++ {
+			m.toolHeaders[j].line += line
+		}
+		parts = append(parts, rendered)
+		line += lipgloss.Height(rendered) + 1 // Messages are separated by a blank line
 	}
 
 	// Add streaming indicator if streaming and no content yet
@@ -189,32 +333,36 @@ func (m *Model) renderMessages() string {
 	return strings.Join(parts, "\n\n")
 }
 
-// renderMessage renders a single message
-func (m *Model) renderMessage(msg Message) string {
+// renderMessage renders a single message; last marks the message that may still be streaming
+func (m *Model) renderMessage(msg *Message, last bool) string {
 	var parts []string
 
 	switch msg.Type {
 	case MessageTypeUser:
-		label := userLabelStyle.Render("You:")
+		label := m.styles.userLabel.Render("You:")
 		parts = append(parts, label+" "+msg.Content)
 
 	case MessageTypeAssistant:
-		label := assistantLabelStyle.Render("Claude:")
+		label := m.styles.assistantLabel.Render("Claude:")
 		parts = append(parts, label)
 
 		// Render content blocks in order (new approach)
 		if len(msg.Blocks) > 0 {
-			for _, block := range msg.Blocks {
+			for i, block := range msg.Blocks {
 				switch block.Type {
+				case ContentBlockThinking:
+					// The block still streaming is always shown expanded
+					live := m.isStreaming && i == len(msg.Blocks)-1
+					parts = append(parts, m.renderThinkingBlock(block.Text, live || m.showThinking))
 				case ContentBlockText:
 					if block.Text != "" {
-						lines := strings.Split(block.Text, "\n")
-						for _, line := range lines {
-							parts = append(parts, "  "+line)
-						}
+						// Text still arriving stays raw until finalizeStreamingText
+						live := last && m.streamingText != "" && i == len(msg.Blocks)-1
+						parts = append(parts, m.renderTextBlock(&msg.Blocks[i], live))
 					}
 				case ContentBlockTool:
 					if block.Tool != nil {
+						m.toolHeaders = append(m.toolHeaders, toolHeader{line: lineCount(parts), tool: block.Tool})
 						parts = append(parts, m.renderToolBlock(*block.Tool))
 					}
 				}
@@ -226,45 +374,108 @@ func (m *Model) renderMessage(msg Message) string {
 				parts = append(parts, "  "+line)
 			}
 			// Render tools (old approach)
-			for _, tool := range msg.Tools {
-				parts = append(parts, m.renderToolBlock(tool))
+			for i := range msg.Tools {
+				m.toolHeaders = append(m.toolHeaders, toolHeader{line: lineCount(parts), tool: &msg.Tools[i]})
+				parts = append(parts, m.renderToolBlock(msg.Tools[i]))
 			}
 		}
 
 	case MessageTypeSystem:
-		parts = append(parts, systemMessageStyle.Render("  "+msg.Content))
+		parts = append(parts, m.styles.systemMessage.Render("  "+msg.Content))
 
 	case MessageTypeError:
-		parts = append(parts, errorMessageStyle.Render("Error: "+msg.Content))
+		parts = append(parts, m.styles.errorMessage.Render("Error: "+msg.Content))
 	}
 
 	return strings.Join(parts, "\n")
 }
 
+// lineCount returns the number of lines parts take once joined by newlines
+func lineCount(parts []string) int {
+	n := 0
+	for _, part := range parts {
+		n += lipgloss.Height(part)
+	}
+	return n
+}
+
+// renderTextBlock renders assistant text as markdown. Streaming text and
+// terminals too narrow for markdown get the plain text, indented.
+func (m *Model) renderTextBlock(block *ContentBlock, live bool) string {
+	width := m.width - 4
+	if live || width < minMarkdownWidth {
+		return indentText(block.Text)
+	}
+
+	if block.renderedSource == block.Text && block.renderedWidth == width {
+		return block.rendered
+	}
+
+	if m.markdown == nil || m.markdownWidth != width {
+		m.markdown = NewStyledMarkdownRenderer(m.theme.Base, width)
+		m.markdownWidth = width
+	}
+	rendered := strings.Trim(m.markdown.Render(block.Text), "\n")
+
+	block.rendered = rendered
+	block.renderedSource = block.Text
+	block.renderedWidth = width
+	return rendered
+}
+
+// indentText indents every line of text by two spaces
+func indentText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "  " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderThinkingBlock renders extended thinking as a dimmed, collapsible block
+func (m *Model) renderThinkingBlock(text string, expanded bool) string {
+	text = strings.TrimSpace(text)
+	lines := strings.Split(text, "\n")
+
+	expandIcon := "▶"
+	if expanded {
+		expandIcon = "▼"
+	}
+	header := fmt.Sprintf("  %s %s %s",
+		m.styles.dim.Render(expandIcon),
+		m.styles.thinking.Render("Thinking"),
+		m.styles.dim.Render(fmt.Sprintf("(%d lines, Alt+T to toggle)", len(lines))),
+	)
+	if !expanded {
+		return header
+	}
+
+	parts := []string{header}
+	for _, line := range lines {
+		parts = append(parts, m.styles.thinking.Render("    "+truncateDisplay(line, max(m.width-6, 4))))
+	}
+	return strings.Join(parts, "\n")
+}
+
 // renderToolBlock renders a tool execution block
 func (m *Model) renderToolBlock(tool ToolExecution) string {
 	var parts []string
 
 	// Header: status icon + name + duration
 	var icon string
-	var iconColor lipgloss.Color
 
 	switch tool.Status {
 	case ToolStatusPending:
 		icon = "○"
-		iconColor = lipgloss.Color("#8B949E")
 	case ToolStatusRunning:
 		icon = m.spinner.View()
-		iconColor = lipgloss.Color("#58A6FF")
 	case ToolStatusSuccess:
 		icon = "✓"
-		iconColor = lipgloss.Color("#3FB950")
 	case ToolStatusError:
 		icon = "✗"
-		iconColor = lipgloss.Color("#F85149")
 	}
 
-	iconStyled := lipgloss.NewStyle().Foreground(iconColor).Render(icon)
+	iconStyled := lipgloss.NewStyle().Foreground(m.styles.toolStatusColor(tool.Status)).Render(icon)
 
 	// Expand/collapse indicator
 	expandIcon := "▶"
@@ -281,34 +492,46 @@ func (m *Model) renderToolBlock(tool ToolExecution) string {
 	}
 
 	header := fmt.Sprintf("  %s %s %s %s",
-		dimStyle.Render(expandIcon),
+		m.styles.dim.Render(expandIcon),
 		iconStyled,
-		toolHeaderStyle.Bold(true).Render(tool.Name),
-		dimStyle.Render(duration),
+		m.styles.toolHeader.Bold(true).Render(tool.Name),
+		m.styles.dim.Render(duration),
 	)
 	parts = append(parts, header)
 
+	// Progress of a long operation, shown even when collapsed
+	if tool.Status == ToolStatusRunning && tool.Progress != nil {
+		parts = append(parts, m.styles.dim.Render("    "+tool.Progress.String()))
+	}
+
 	// Details (if expanded)
 	if tool.Expanded {
 		// Input
 		if tool.Input != "" {
-			inputLabel := dimStyle.Render("    Input:")
+			inputLabel := m.styles.dim.Render("    Input:")
 			parts = append(parts, inputLabel)
 			// Truncate long input
-			input := tool.Input
-			if len(input) > 200 {
-				input = input[:200] + "..."
-			}
-			parts = append(parts, toolInputStyle.Render("    "+input))
+			input := truncateDisplay(tool.Input, 203)
+			parts = append(parts, m.styles.toolInput.Render("    "+input))
 		}
 
-		// Output
-		if tool.Output != "" {
+		// Output, rendered by the tool's formatter if it has one
+		var formatted string
+		if f, ok := m.formatters[tool.Name]; ok && tool.Output != "" && !tool.IsError {
+			formatted = f.FormatTUI(tool.Input, tool.Output)
+		}
+		if formatted != "" {
+			parts = append(parts, m.styles.dim.Render("    Output:"))
+			lineStyle := lipgloss.NewStyle().MaxWidth(max(m.width-6, 4))
+			for _, line := range strings.Split(formatted, "\n") {
+				parts = append(parts, lineStyle.Render("    "+line))
+			}
+		} else if tool.Output != "" {
 			var outputLabel string
 			if tool.IsError {
-				outputLabel = errorMessageStyle.Render("    Error:")
+				outputLabel = m.styles.errorMessage.Render("    Error:")
 			} else {
-				outputLabel = dimStyle.Render("    Output:")
+				outputLabel = m.styles.dim.Render("    Output:")
 			}
 			parts = append(parts, outputLabel)
 
@@ -320,11 +543,14 @@ func (m *Model) renderToolBlock(tool ToolExecution) string {
 				lines = lines[:maxLines]
 				lines = append(lines, fmt.Sprintf("... (%d more lines)", len(strings.Split(output, "\n"))-maxLines))
 			}
+			showsDiff := tool.Name == "Edit" || tool.Name == "Write"
 			for _, line := range lines {
-				if len(line) > m.width-10 {
-					line = line[:m.width-10] + "..."
+				line = truncateDisplay(line, max(m.width-10, 4))
+				style := m.styles.toolOutput
+				if showsDiff {
+					style = m.styles.diffLine(line)
 				}
-				parts = append(parts, toolOutputStyle.Render("    "+line))
+				parts = append(parts, style.Render("    "+line))
 			}
 		}
 	}
@@ -332,6 +558,19 @@ func (m *Model) renderToolBlock(tool ToolExecution) string {
 	return strings.Join(parts, "\n")
 }
 
+// diffLine colors added and removed lines of a unified diff
+func (s *styles) diffLine(line string) lipgloss.Style {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return s.toolOutput
+	case strings.HasPrefix(line, "+"):
+		return s.diffAdded
+	case strings.HasPrefix(line, "-"):
+		return s.diffRemoved
+	}
+	return s.toolOutput
+}
+
 // renderInputArea renders the input area
 func (m *Model) renderInputArea() string {
 	// Prompt indicator
@@ -348,17 +587,21 @@ func (m *Model) renderInputArea() string {
 	// Combine
 	content := prompt + input
 
-	return inputBorderStyle.Width(m.width - 2).Render(content)
+	return m.styles.inputBorder.Width(max(m.width-2, 1)).Render(content)
 }
 
 // renderStatusBar renders the status bar
 func (m *Model) renderStatusBar() string {
 	// Left: Token info or copy message
 	var leftContent string
-	if m.copyMessage != "" {
-		leftContent = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#3FB950")).
-			Render(m.copyMessage)
+	if m.quitPending() {
+		leftContent = m.styles.warning.Render(quitHint)
+	} else if m.copyMessage != "" {
+		leftContent = m.styles.success.Render(m.copyMessage)
+	} else if m.retryInfo != "" {
+		leftContent = m.styles.warning.Render(m.retryInfo)
+	} else if m.rateLimit != "" {
+		leftContent = m.styles.warning.Render(m.rateLimit)
 	} else {
 		tokenInfo := fmt.Sprintf("Tokens: %s/%s",
 			formatTokenCount(m.tokens.Total()),
@@ -367,17 +610,28 @@ func (m *Model) renderStatusBar() string {
 		if m.tokens.CacheReadTokens > 0 {
 			tokenInfo += fmt.Sprintf(" (+%s cache)", formatTokenCount(m.tokens.CacheReadTokens))
 		}
+		if m.cost > 0 {
+			tokenInfo += fmt.Sprintf(" ~$%.2f", m.cost)
+		}
 		leftContent = tokenInfo
+		if m.contextWarning != "" {
+			// Nearing automatic compaction
+			leftContent = m.styles.warning.Render(tokenInfo)
+		}
 	}
 
 	// Center: Hints
 	var hints string
 	if m.selectMode {
-		hints = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#D29922")).
-			Render("SELECT MODE: Use mouse to select text | Ctrl+Y to exit")
+		hints = m.styles.warning.Render("SELECT MODE: Use mouse to select text | Ctrl+Y to exit")
 	} else if m.state == StateConfirm {
 		hints = "← → Select | Enter Confirm | y Allow | n Deny | Esc Cancel"
+	} else if m.state == StateSessionPicker {
+		hints = "↑ ↓ Select | ← → Page | Type to search | Enter Load | Esc Cancel"
+	} else if m.state == StateCommandPalette {
+		hints = "↑ ↓ Select | Tab Complete | Enter Run | Esc Close"
+	} else if m.scrollPause && !m.followBottom {
+		hints = m.styles.warning.Render("Auto-scroll paused | End Resume")
 	} else {
 		hints = "Enter Send | c Copy | Ctrl+Y Select | ? Help"
 	}
@@ -392,13 +646,14 @@ func (m *Model) renderStatusBar() string {
 
 	leftStyled := lipgloss.NewStyle().
 		Width(leftWidth).
+		MaxHeight(1).
 		Align(lipgloss.Left).
 		Render(leftContent)
 
 	centerStyled := lipgloss.NewStyle().
 		Width(centerWidth).
 		Align(lipgloss.Center).
-		Foreground(lipgloss.Color("#8B949E")).
+		Foreground(m.theme.TextSecondary).
 		Render(hints)
 
 	rightStyled := lipgloss.NewStyle().
@@ -407,27 +662,14 @@ func (m *Model) renderStatusBar() string {
 		Render(agentBadge)
 
 	bar := lipgloss.JoinHorizontal(lipgloss.Top, leftStyled, centerStyled, rightStyled)
-	return statusBarStyle.Width(m.width).Render(bar)
+	return m.styles.statusBar.Width(m.width).Render(bar)
 }
 
 // renderAgentBadge renders the agent badge
 func (m *Model) renderAgentBadge() string {
-	var bgColor lipgloss.Color
-
-	switch m.agent {
-	case "build":
-		bgColor = lipgloss.Color("#58A6FF")
-	case "plan":
-		bgColor = lipgloss.Color("#A371F7")
-	case "explore":
-		bgColor = lipgloss.Color("#3FB950")
-	default:
-		bgColor = lipgloss.Color("#8B949E")
-	}
-
 	return lipgloss.NewStyle().
-		Background(bgColor).
-		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(m.styles.agentColor(m.agent)).
+		Foreground(m.theme.TextInverse).
 		Padding(0, 1).
 		Bold(true).
 		Render(m.agent)
@@ -442,7 +684,7 @@ func (m *Model) renderConfirmDialog() string {
 	var parts []string
 
 	// Title
-	title := dialogTitleStyle.Render("⚠ " + m.confirmDialog.Title)
+	title := m.styles.dialogTitle.Render("⚠ " + m.confirmDialog.Title)
 	parts = append(parts, title)
 	parts = append(parts, "")
 
@@ -450,25 +692,34 @@ func (m *Model) renderConfirmDialog() string {
 	parts = append(parts, m.confirmDialog.Message)
 	parts = append(parts, "")
 
-	// Details (command/path)
-	if m.confirmDialog.Details != "" {
-		detailBox := lipgloss.NewStyle().
-			Background(lipgloss.Color("#21262D")).
-			Foreground(lipgloss.Color("#C9D1D9")).
-			Padding(0, 1).
-			Render(m.confirmDialog.Details)
+	// Details (command/path), the diff of a file change, or the input editor
+	if m.confirmEditing {
+		parts = append(parts, m.confirmEditor.View())
+		parts = append(parts, "")
+	} else if m.confirmDialog.Diff != "" {
+		parts = append(parts, m.renderConfirmDiff(max(min(m.width-4, 100), 1)-6))
+		parts = append(parts, "")
+	} else if m.confirmDialog.Details != "" {
+		detailBox := m.styles.dialogDetails.Render(m.confirmDialog.Details)
 		parts = append(parts, detailBox)
 		parts = append(parts, "")
 	}
 
 	// Buttons
+	if m.confirmEditing {
+		parts = append(parts, m.styles.dim.Render("Ctrl+S Approve edited input | Esc Back"))
+		content := lipgloss.JoinVertical(lipgloss.Left, parts...)
+		dialog := m.styles.dialog.Width(max(min(m.width-4, 60), 1)).Render(content)
+		return lipgloss.Place(m.width, 0, lipgloss.Center, lipgloss.Top, dialog)
+	}
+
 	var buttons []string
 	for i, opt := range m.confirmDialog.Options {
 		var btn string
 		if i == m.confirmDialog.Selected {
-			btn = dialogButtonSelectedStyle.Render(opt)
+			btn = m.styles.dialogButtonSelected.Render(opt)
 		} else {
-			btn = dialogButtonStyle.Render(opt)
+			btn = m.styles.dialogButton.Render(opt)
 		}
 		buttons = append(buttons, btn)
 	}
@@ -477,74 +728,256 @@ func (m *Model) renderConfirmDialog() string {
 	parts = append(parts, "")
 
 	// Hints
-	hints := dimStyle.Render("y Allow | n Deny | a Always | Esc Cancel")
+	hintText := "y Allow | n Deny | a Always | Esc Cancel"
+	if m.confirmDialog.Input != "" {
+		hintText = "y Allow | e Edit | n Deny | a Always | Esc Cancel"
+	}
+	hints := m.styles.dim.Render(hintText)
 	parts = append(parts, hints)
 
 	content := lipgloss.JoinVertical(lipgloss.Left, parts...)
 
-	// Center the dialog
-	dialogWidth := min(m.width-4, 60)
-	dialog := dialogStyle.Width(dialogWidth).Render(content)
+	// Center the dialog; diffs get more room
+	dialogWidth := max(min(m.width-4, 60), 1)
+	if m.confirmDialog.Diff != "" {
+		dialogWidth = max(min(m.width-4, 100), 1)
+	}
+	dialog := m.styles.dialog.Width(dialogWidth).Render(content)
 
 	return lipgloss.Place(m.width, 0, lipgloss.Center, lipgloss.Top, dialog)
 }
 
+// renderConfirmDiff renders the diff of the confirm dialog, colored and cut
+// to fit the screen
+func (m *Model) renderConfirmDiff(width int) string {
+	lines := strings.Split(m.confirmDialog.Diff, "\n")
+	maxLines := max(m.height-16, 5)
+	more := 0
+	if len(lines) > maxLines {
+		more = len(lines) - maxLines
+		lines = lines[:maxLines]
+	}
+
+	rendered := make([]string, 0, len(lines)+1)
+	for _, line := range lines {
+		line = truncateDisplay(line, max(width, 4))
+		rendered = append(rendered, m.styles.diffLine(line).Render(line))
+	}
+	if more > 0 {
+		rendered = append(rendered, m.styles.dim.Render(fmt.Sprintf("... (%d more lines)", more)))
+	}
+	return strings.Join(rendered, "\n")
+}
+
+// renderSessionPicker renders the paginated session picker
+func (m *Model) renderSessionPicker() string {
+	p := m.sessionPicker
+	filtered := p.Filtered()
+
+	pageSize := p.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	page := p.Selected / pageSize
+	totalPages := (len(filtered) + pageSize - 1) / pageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	var parts []string
+	parts = append(parts, m.styles.dialogTitle.Render("Sessions"))
+	parts = append(parts, "Search: "+p.Query+"█")
+	parts = append(parts, "")
+
+	if len(filtered) == 0 {
+		parts = append(parts, m.styles.dim.Render("No matching sessions"))
+	}
+
+	start := page * pageSize
+	end := min(start+pageSize, len(filtered))
+	for i := start; i < end; i++ {
+		item := filtered[i]
+		line := fmt.Sprintf("%-40s %s  %3d msgs",
+			truncateDisplay(item.Title, 40),
+			item.UpdatedAt.Format("2006-01-02 15:04"),
+			item.MessageCount,
+		)
+		if i == p.Selected {
+			parts = append(parts, m.styles.dialogButtonSelected.Render(line))
+		} else {
+			parts = append(parts, "  "+line)
+		}
+	}
+
+	parts = append(parts, "")
+	parts = append(parts, m.styles.dim.Render(fmt.Sprintf("Page %d/%d · %d sessions", page+1, totalPages, len(filtered))))
+
+	content := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	dialogWidth := max(min(m.width-4, 90), 1)
+	dialog := m.styles.pickerBorder.Width(dialogWidth).Render(content)
+
+	return lipgloss.Place(m.width, 0, lipgloss.Center, lipgloss.Top, dialog)
+}
+
+// renderCommandPalette renders the commands or arguments matching the input
+func (m *Model) renderCommandPalette() string {
+	items := paletteItems(m.commands, m.textarea.Value())
+	selected := min(m.palette.Selected, max(len(items)-1, 0))
+
+	var parts []string
+	if len(items) == 0 {
+		parts = append(parts, m.styles.dim.Render("No matching commands"))
+	}
+
+	// Scroll the window of items to keep the selection visible
+	start := max(selected-maxPaletteItems+1, 0)
+	end := min(start+maxPaletteItems, len(items))
+	labelWidth := 0
+	for _, item := range items[start:end] {
+		labelWidth = max(labelWidth, len([]rune(item.Label)))
+	}
+	for i := start; i < end; i++ {
+		item := items[i]
+		line := fmt.Sprintf("%-*s  %s", labelWidth, item.Label, m.styles.dim.Render(item.Description))
+		if i == selected {
+			parts = append(parts, m.styles.cursor.Render("> ")+line)
+		} else {
+			parts = append(parts, "  "+line)
+		}
+	}
+	if len(items) > maxPaletteItems {
+		parts = append(parts, m.styles.dim.Render(fmt.Sprintf("%d/%d", selected+1, len(items))))
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	return m.styles.pickerBorder.Width(max(m.width-2, 1)).Render(content)
+}
+
+// renderQuestionDialog renders the current question of the question dialog
+func (m *Model) renderQuestionDialog() string {
+	d := m.questionDialog
+	q := d.Questions[d.Current]
+	dialogWidth := max(min(m.width-4, 80), 1)
+
+	var parts []string
+	title := q.Header
+	if len(d.Questions) > 1 {
+		title = fmt.Sprintf("%s (%d/%d)", q.Header, d.Current+1, len(d.Questions))
+	}
+	parts = append(parts, m.styles.dialogTitle.Render(title))
+	parts = append(parts, lipgloss.NewStyle().Width(max(dialogWidth-6, 1)).Render(q.Question))
+	parts = append(parts, "")
+
+	for i, opt := range q.Options {
+		cursor := "  "
+		if i == d.Selected {
+			cursor = "› "
+		}
+		box := ""
+		if q.MultiSelect {
+			box = "[ ] "
+			if d.Checked[i] {
+				box = "[x] "
+			}
+		}
+		line := cursor + box + opt.Label
+		if i == d.Selected {
+			line = m.styles.dialogTitle.Render(line)
+		}
+		parts = append(parts, line)
+		if opt.Description != "" {
+			desc := truncateDisplay(opt.Description, max(dialogWidth-12, 4))
+			parts = append(parts, m.styles.dim.Render("      "+desc))
+		}
+	}
+
+	parts = append(parts, "")
+	hint := "↑/↓ Select | Enter Confirm | Esc Cancel"
+	if q.MultiSelect {
+		hint = "↑/↓ Move | Space Toggle | Enter Confirm | Esc Cancel"
+	}
+	parts = append(parts, m.styles.dim.Render(hint))
+
+	content := lipgloss.JoinVertical(lipgloss.Left, parts...)
+	dialog := m.styles.dialog.Width(dialogWidth).Render(content)
+
+	return lipgloss.Place(m.width, 0, lipgloss.Center, lipgloss.Top, dialog)
+}
+
+// truncateDisplay truncates s to at most maxLen runes
+func truncateDisplay(s string, maxLen int) string {
+	if maxLen <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	if maxLen <= 3 {
+		return string(runes[:maxLen])
+	}
+	return string(runes[:maxLen-3]) + "..."
+}
+
 // renderHelpPanel renders the help panel
 func (m *Model) renderHelpPanel() string {
 	var parts []string
 
-	parts = append(parts, lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#58A6FF")).Render("Keyboard Shortcuts"))
+	parts = append(parts, m.styles.helpTitle.Render("Keyboard Shortcuts"))
 	parts = append(parts, "")
 
 	// Global
 	parts = append(parts, lipgloss.NewStyle().Bold(true).Render("Global"))
-	parts = append(parts, renderHelpItem("Ctrl+C", "Cancel / Quit"))
-	parts = append(parts, renderHelpItem("Ctrl+L", "Clear screen"))
-	parts = append(parts, renderHelpItem("Ctrl+D", "Exit"))
-	parts = append(parts, renderHelpItem("?", "Toggle help"))
+	parts = append(parts, m.styles.helpItem("Ctrl+C", "Cancel (twice to quit)"))
+	parts = append(parts, m.styles.helpItem("Ctrl+L", "Clear screen"))
+	parts = append(parts, m.styles.helpItem("Ctrl+D", "Exit"))
+	parts = append(parts, m.styles.helpItem("?", "Toggle help"))
+	parts = append(parts, m.styles.helpItem("/", "Search slash commands"))
 	parts = append(parts, "")
 
 	// Scrolling
 	parts = append(parts, lipgloss.NewStyle().Bold(true).Render("Scrolling"))
-	parts = append(parts, renderHelpItem("j / k", "Scroll down / up"))
-	parts = append(parts, renderHelpItem("PgDn/PgUp", "Half page down / up"))
-	parts = append(parts, renderHelpItem("Ctrl+D/U", "Half page down / up"))
-	parts = append(parts, renderHelpItem("g / G", "Go to top / bottom"))
-	parts = append(parts, renderHelpItem("Mouse", "Scroll wheel"))
+	parts = append(parts, m.styles.helpItem("j / k", "Scroll down / up"))
+	parts = append(parts, m.styles.helpItem("PgDn/PgUp", "Half page down / up"))
+	parts = append(parts, m.styles.helpItem("Ctrl+D/U", "Half page down / up"))
+	parts = append(parts, m.styles.helpItem("g / G", "Go to top / bottom"))
+	parts = append(parts, m.styles.helpItem("Mouse", "Scroll wheel"))
 	parts = append(parts, "")
 
 	// Input
 	parts = append(parts, lipgloss.NewStyle().Bold(true).Render("Input"))
-	parts = append(parts, renderHelpItem("Enter", "Send message"))
-	parts = append(parts, renderHelpItem("Alt+Enter", "New line"))
-	parts = append(parts, renderHelpItem("Up/Down", "History navigation"))
-	parts = append(parts, renderHelpItem("Esc", "Clear input"))
+	parts = append(parts, m.styles.helpItem("Enter", "Send message"))
+	parts = append(parts, m.styles.helpItem("Alt+Enter", "New line"))
+	parts = append(parts, m.styles.helpItem("Up/Down", "History navigation"))
+	parts = append(parts, m.styles.helpItem("Esc", "Clear input"))
+	parts = append(parts, m.styles.helpItem("Ctrl+R", "Regenerate last response"))
 	parts = append(parts, "")
 
 	// Copy
 	parts = append(parts, lipgloss.NewStyle().Bold(true).Render("Copy"))
-	parts = append(parts, renderHelpItem("c", "Copy last response"))
-	parts = append(parts, renderHelpItem("Ctrl+Y", "Toggle select mode"))
-	parts = append(parts, renderHelpItem("Shift+Mouse", "Select text (native)"))
+	parts = append(parts, m.styles.helpItem("c", "Copy last response"))
+	parts = append(parts, m.styles.helpItem("Alt+1-9", "Copy Nth message from the end"))
+	parts = append(parts, m.styles.helpItem("Alt+Y", "Copy last tool output"))
+	parts = append(parts, m.styles.helpItem("Alt+T", "Expand/collapse thinking"))
+	parts = append(parts, m.styles.helpItem("Alt+O / Click", "Expand/collapse tool blocks"))
+	parts = append(parts, m.styles.helpItem("Ctrl+Y", "Toggle select mode"))
+	parts = append(parts, m.styles.helpItem("Shift+Mouse", "Select text (native)"))
 	parts = append(parts, "")
 
 	// Close hint
-	parts = append(parts, dimStyle.Render("Press ? or Esc to close"))
+	parts = append(parts, m.styles.dim.Render("Press ? or Esc to close"))
 
 	content := lipgloss.JoinVertical(lipgloss.Left, parts...)
 
-	helpBox := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#30363D")).
-		Padding(1, 2).
-		Width(min(m.width-4, 50)).
+	helpBox := m.styles.helpBox.
+		Width(max(min(m.width-4, 50), 1)).
 		Render(content)
 
 	return lipgloss.Place(m.width, 0, lipgloss.Center, lipgloss.Top, helpBox)
 }
 
-func renderHelpItem(key, desc string) string {
-	return helpKeyStyle.Render(key) + helpDescStyle.Render(desc)
+func (s *styles) helpItem(key, desc string) string {
+	return s.helpKey.Render(key) + s.helpDesc.Render(desc)
 }
 
 // formatTokenCount formats token count for display
@@ -564,3 +997,10 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}